@@ -24,9 +24,13 @@ import (
 	gethaccounts "github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
 	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/signer/core/apitypes"
 )
 
+// MimetypeAminoJSON is the mime type of Cosmos SDK Amino JSON sign docs.
+const MimetypeAminoJSON = "application/x-cosmos-amino-json"
+
 // Account represents an Ethereum account located at a specific location defined
 // by the optional URL field.
 type Account struct {
@@ -77,6 +81,10 @@ type Wallet interface {
 	// to the wallet's tracked account list.
 	Derive(path gethaccounts.DerivationPath, pin bool) (Account, error)
 
+	// ConfirmAddress derives the account at the specified derivation path, while
+	// displaying its address on the device for the user to verify and confirm.
+	ConfirmAddress(path gethaccounts.DerivationPath) (Account, error)
+
 	// SignTx requests the wallet to sign the given transaction.
 	//
 	// It looks up the account specified either solely via its address contained within,
@@ -92,6 +100,46 @@ type Wallet interface {
 
 	// Sign a TypedData object using EIP-712 encoding
 	SignTypedData(account Account, typedData apitypes.TypedData) ([]byte, error)
+
+	// SignText requests the wallet to sign the text as an EIP-191 personal message
+	// (personal_sign). The signature is in [R || S || V] format with V being 27
+	// or 28.
+	SignText(account Account, text []byte) ([]byte, error)
+
+	// SignData requests the wallet to sign the given data, described by its mime
+	// type. Hardware wallets only sign data they can display: Cosmos SDK Amino
+	// JSON sign docs (MimetypeAminoJSON) and prehashed EIP-712 data.
+	SignData(account Account, mimeType string, data []byte) ([]byte, error)
+
+	// SignAuthorization requests the wallet to sign an EIP-7702 authorization,
+	// delegating the account's code to the authorization's address. The returned
+	// authorization carries the signature, verified to be made by the account, and
+	// can be embedded into the authorization list of a set-code transaction.
+	SignAuthorization(account Account, auth coretypes.SetCodeAuthorization) (coretypes.SetCodeAuthorization, error)
+
+	// DeriveEth2PublicKey retrieves the BLS12-381 public key at the specified
+	// EIP-2334 derivation path (e.g. m/12381/3600/i/0 for withdrawal keys).
+	DeriveEth2PublicKey(path gethaccounts.DerivationPath) (BLSPublicKey, error)
+
+	// SetEth2WithdrawalIndex sets the index of the withdrawal key the wallet uses
+	// when generating Ethereum 2 deposit data.
+	SetEth2WithdrawalIndex(index uint32) error
+
+	// GetEncryptionPublicKey retrieves the X25519 public key messages can be
+	// encrypted to for the account, as returned by eth_getEncryptionPublicKey.
+	GetEncryptionPublicKey(account Account) (EncryptionPublicKey, error)
+
+	// Decrypt decrypts an x25519-xsalsa20-poly1305 message encrypted to the
+	// account's encryption public key, as done by eth_decrypt. The ciphertext is
+	// the JSON encoded EncryptedData, optionally hex encoded with a 0x prefix.
+	Decrypt(account Account, ciphertext []byte) ([]byte, error)
+}
+
+// WalletEvent is an event fired by an account backend when a wallet arrival or
+// departure is detected, or a wallet was opened.
+type WalletEvent struct {
+	Wallet Wallet                       // Wallet instance arrived or departed
+	Kind   gethaccounts.WalletEventType // Event type that happened in the system
 }
 
 // Backend is a "wallet provider" that may contain a batch of accounts they can
@@ -108,4 +156,8 @@ type Backend interface {
 	// go, the same wallet might appear at a different positions in the list during
 	// subsequent retrievals.
 	Wallets() []Wallet
+
+	// Subscribe creates an async subscription to receive notifications when the
+	// backend detects the arrival or departure of a wallet.
+	Subscribe(sink chan<- WalletEvent) event.Subscription
 }
@@ -0,0 +1,147 @@
+package accounts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/evmos/ethereum-ledger-go/internal/bech32"
+)
+
+// EthSecp256k1TypeURL is the protobuf Any type URL of Ethermint's ethsecp256k1
+// public keys.
+const EthSecp256k1TypeURL = "/ethermint.crypto.v1.ethsecp256k1.PubKey"
+
+// errNoPublicKey is returned when converting an account without a known public key.
+var errNoPublicKey = errors.New("account has no public key")
+
+// Bech32Address returns the account's address in bech32 form with the given human
+// readable part. Ethermint accounts share the raw 20 byte Ethereum address (the
+// keccak256 hash of the uncompressed public key), only the encoding differs.
+func (a Account) Bech32Address(hrp string) (string, error) {
+	return bech32.Encode(hrp, a.Address.Bytes())
+}
+
+// CompressedPublicKey returns the 33 byte compressed secp256k1 public key of the
+// account.
+func (a Account) CompressedPublicKey() ([]byte, error) {
+	if a.PublicKey == nil {
+		return nil, errNoPublicKey
+	}
+	return crypto.CompressPubkey(a.PublicKey), nil
+}
+
+// AnyPublicKey returns the account's public key wrapped as an Ethermint
+// ethsecp256k1 protobuf Any.
+func (a Account) AnyPublicKey() (*AnyPubKey, error) {
+	key, err := a.CompressedPublicKey()
+	if err != nil {
+		return nil, err
+	}
+	return &AnyPubKey{TypeURL: EthSecp256k1TypeURL, Key: key}, nil
+}
+
+// Bech32ToAddress decodes a bech32 account address, returning its human readable
+// part and the Ethereum address it represents.
+func Bech32ToAddress(addr string) (string, common.Address, error) {
+	hrp, data, err := bech32.Decode(addr)
+	if err != nil {
+		return "", common.Address{}, err
+	}
+	if len(data) != common.AddressLength {
+		return "", common.Address{}, fmt.Errorf("invalid address length: have %d, want %d", len(data), common.AddressLength)
+	}
+	return hrp, common.BytesToAddress(data), nil
+}
+
+// AddressFromCompressedPublicKey derives the Ethereum (and thus Ethermint) address
+// of a compressed secp256k1 public key.
+func AddressFromCompressedPublicKey(key []byte) (common.Address, error) {
+	pubkey, err := crypto.DecompressPubkey(key)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pubkey), nil
+}
+
+// AnyPubKey is a public key wrapped in a protobuf Any, as stored by Cosmos SDK
+// accounts and keyrings.
+type AnyPubKey struct {
+	TypeURL string // Protobuf type of the key, e.g. EthSecp256k1TypeURL
+	Key     []byte // Compressed public key
+}
+
+// Marshal returns the protobuf encoding of the Any, wrapping the key in the
+// PubKey message ({bytes key = 1}) shared by all secp256k1 key types.
+func (k *AnyPubKey) Marshal() []byte {
+	value := appendProtoBytes(nil, 1, k.Key)
+
+	out := appendProtoBytes(nil, 1, []byte(k.TypeURL))
+	return appendProtoBytes(out, 2, value)
+}
+
+// MarshalJSON implements json.Marshaler, using the proto3 JSON form of an Any
+// as printed by Cosmos SDK tooling.
+func (k *AnyPubKey) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type string `json:"@type"`
+		Key  []byte `json:"key"`
+	}{k.TypeURL, k.Key})
+}
+
+// appendProtoBytes appends a length delimited protobuf field.
+func appendProtoBytes(out []byte, field int, value []byte) []byte {
+	out = appendProtoVarint(out, uint64(field<<3|2))
+	out = appendProtoVarint(out, uint64(len(value)))
+	return append(out, value...)
+}
+
+// appendProtoVarint appends a protobuf base 128 varint.
+func appendProtoVarint(out []byte, v uint64) []byte {
+	for v >= 0x80 {
+		out = append(out, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(out, byte(v))
+}
+
+// AccountView is an opt-in representation of an account in all the formats used
+// by Ethereum and Cosmos SDK tooling.
+type AccountView struct {
+	Address             common.Address   `json:"address"`             // Ethereum hex address
+	Bech32Address       string           `json:"bech32Address"`       // Cosmos SDK bech32 address
+	PublicKey           hexutil.Bytes    `json:"publicKey"`           // Uncompressed secp256k1 public key
+	CompressedPublicKey hexutil.Bytes    `json:"compressedPublicKey"` // Compressed secp256k1 public key
+	AnyPublicKey        *AnyPubKey       `json:"anyPublicKey"`        // Ethermint ethsecp256k1 Any
+	URL                 gethaccounts.URL `json:"url"`
+}
+
+// View converts the account into all its representations, encoding the bech32
+// address with the given human readable part. The address is verified to be
+// derived from the public key, as Ethermint requires.
+func (a Account) View(hrp string) (*AccountView, error) {
+	anyKey, err := a.AnyPublicKey()
+	if err != nil {
+		return nil, err
+	}
+	if derived := crypto.PubkeyToAddress(*a.PublicKey); derived != a.Address {
+		return nil, fmt.Errorf("address mismatch: public key derives %s, account has %s", derived, a.Address)
+	}
+	addr, err := a.Bech32Address(hrp)
+	if err != nil {
+		return nil, err
+	}
+	return &AccountView{
+		Address:             a.Address,
+		Bech32Address:       addr,
+		PublicKey:           crypto.FromECDSAPub(a.PublicKey),
+		CompressedPublicKey: anyKey.Key,
+		AnyPublicKey:        anyKey,
+		URL:                 a.URL,
+	}, nil
+}
@@ -0,0 +1,65 @@
+package accounts
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBech32Address(t *testing.T) {
+	account := Account{Address: common.HexToAddress("0x7cB61D4117AE31a12E393a1Cfa3BaC666481D02E")}
+
+	addr, err := account.Bech32Address("evmos")
+	require.NoError(t, err)
+	require.Equal(t, "evmos10jmp6sgh4cc6zt3e8gw05wavvejgr5pwjnpcky", addr)
+
+	hrp, decoded, err := Bech32ToAddress(addr)
+	require.NoError(t, err)
+	require.Equal(t, "evmos", hrp)
+	require.Equal(t, account.Address, decoded)
+
+	_, err = account.CompressedPublicKey()
+	require.Error(t, err)
+}
+
+func TestAccountView(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	account := Account{Address: crypto.PubkeyToAddress(key.PublicKey), PublicKey: &key.PublicKey}
+
+	view, err := account.View("cosmos")
+	require.NoError(t, err)
+	require.Len(t, view.CompressedPublicKey, 33)
+	require.Len(t, view.PublicKey, 65)
+
+	// All representations must derive the same address
+	derived, err := AddressFromCompressedPublicKey(view.CompressedPublicKey)
+	require.NoError(t, err)
+	require.Equal(t, account.Address, derived)
+
+	hrp, decoded, err := Bech32ToAddress(view.Bech32Address)
+	require.NoError(t, err)
+	require.Equal(t, "cosmos", hrp)
+	require.Equal(t, account.Address, decoded)
+
+	// The Any wraps the compressed key into the ethsecp256k1 PubKey message
+	raw := view.AnyPublicKey.Marshal()
+	require.Equal(t, byte(0x0a), raw[0])
+	require.Equal(t, EthSecp256k1TypeURL, string(raw[2:2+raw[1]]))
+	value := raw[2+raw[1]:]
+	require.Equal(t, []byte{0x12, 35, 0x0a, 33}, value[:4])
+	require.Equal(t, []byte(view.CompressedPublicKey), value[4:])
+
+	blob, err := json.Marshal(view.AnyPublicKey)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"@type":"/ethermint.crypto.v1.ethsecp256k1.PubKey","key":"`+base64.StdEncoding.EncodeToString(view.CompressedPublicKey)+`"}`, string(blob))
+
+	// Accounts whose address doesn't match their key are rejected
+	account.Address = common.Address{0x01}
+	_, err = account.View("evmos")
+	require.ErrorContains(t, err, "address mismatch")
+}
@@ -0,0 +1,122 @@
+package accounts
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"golang.org/x/crypto/nacl/box"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/salsa20/salsa"
+)
+
+// EncryptionVersion is the only encryption scheme supported by eth_decrypt.
+const EncryptionVersion = "x25519-xsalsa20-poly1305"
+
+// EncryptionPublicKey is an X25519 public key, as returned by
+// eth_getEncryptionPublicKey.
+type EncryptionPublicKey [32]byte
+
+// String returns the base64 encoding of the key, the format used by
+// eth_getEncryptionPublicKey.
+func (k EncryptionPublicKey) String() string {
+	return base64.StdEncoding.EncodeToString(k[:])
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (k EncryptionPublicKey) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (k *EncryptionPublicKey) UnmarshalText(input []byte) error {
+	blob, err := base64.StdEncoding.DecodeString(string(input))
+	if err != nil {
+		return err
+	}
+	if len(blob) != len(k) {
+		return fmt.Errorf("invalid encryption public key length: have %d, want %d", len(blob), len(k))
+	}
+	copy(k[:], blob)
+	return nil
+}
+
+// EncryptedData is a message encrypted to an encryption public key, in the JSON
+// format consumed by eth_decrypt. Binary fields are base64 encoded.
+type EncryptedData struct {
+	Version        string `json:"version"`
+	Nonce          []byte `json:"nonce"`
+	EphemPublicKey []byte `json:"ephemPublicKey"`
+	Ciphertext     []byte `json:"ciphertext"`
+}
+
+// Encrypt encrypts the message to the given encryption public key using a fresh
+// ephemeral key, producing data any eth_decrypt implementation can open.
+func Encrypt(publicKey EncryptionPublicKey, message []byte) (*EncryptedData, error) {
+	ephemPub, ephemPriv, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	var nonce [24]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	peer := [32]byte(publicKey)
+
+	return &EncryptedData{
+		Version:        EncryptionVersion,
+		Nonce:          nonce[:],
+		EphemPublicKey: ephemPub[:],
+		Ciphertext:     box.Seal(nil, message, &nonce, &peer, ephemPriv),
+	}, nil
+}
+
+// ParseEncryptedData decodes the JSON encrypted data passed to eth_decrypt. The
+// JSON may optionally be hex encoded with a 0x prefix, as sent by dapps.
+func ParseEncryptedData(ciphertext []byte) (*EncryptedData, error) {
+	if bytes.HasPrefix(ciphertext, []byte("0x")) {
+		blob, err := hexutil.Decode(string(ciphertext))
+		if err != nil {
+			return nil, err
+		}
+		ciphertext = blob
+	}
+	data := new(EncryptedData)
+	if err := json.Unmarshal(ciphertext, data); err != nil {
+		return nil, fmt.Errorf("invalid encrypted data: %w", err)
+	}
+	if data.Version != EncryptionVersion {
+		return nil, fmt.Errorf("unsupported encryption version %q", data.Version)
+	}
+	if len(data.Nonce) != 24 {
+		return nil, fmt.Errorf("invalid nonce length: have %d, want 24", len(data.Nonce))
+	}
+	if len(data.EphemPublicKey) != 32 {
+		return nil, fmt.Errorf("invalid ephemeral public key length: have %d, want 32", len(data.EphemPublicKey))
+	}
+	return data, nil
+}
+
+// Open decrypts the data with the raw X25519 shared secret between the recipient
+// key and the ephemeral key, allowing the private key to stay on a device.
+func (d *EncryptedData) Open(sharedSecret [32]byte) ([]byte, error) {
+	// Derive the box key the same way NaCl's box.Precompute does
+	var (
+		zeros  [16]byte
+		boxKey [32]byte
+	)
+	salsa.HSalsa20(&boxKey, &zeros, &sharedSecret, &salsa.Sigma)
+
+	var nonce [24]byte
+	copy(nonce[:], d.Nonce)
+
+	message, ok := secretbox.Open(nil, d.Ciphertext, &nonce, &boxKey)
+	if !ok {
+		return nil, errors.New("decryption failed")
+	}
+	return message, nil
+}
@@ -0,0 +1,92 @@
+package accounts
+
+import (
+	"errors"
+	"fmt"
+
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// BLSPublicKeyLength is the length of a compressed BLS12-381 public key.
+const BLSPublicKeyLength = 48
+
+const (
+	eth2Purpose  = 12381 // EIP-2334 purpose of BLS12-381 keys
+	eth2CoinType = 3600  // EIP-2334 coin type of Ethereum 2 keys
+)
+
+// BLSPublicKey is a compressed BLS12-381 G1 point, as used by Ethereum 2 for
+// validator and withdrawal keys.
+type BLSPublicKey [BLSPublicKeyLength]byte
+
+// ParseBLSPublicKey validates that the blob is a compressed BLS12-381 G1 point
+// on the curve and in the correct subgroup, returning it as a public key.
+func ParseBLSPublicKey(blob []byte) (BLSPublicKey, error) {
+	var key BLSPublicKey
+	if len(blob) != BLSPublicKeyLength {
+		return key, fmt.Errorf("invalid BLS public key length: have %d, want %d", len(blob), BLSPublicKeyLength)
+	}
+	if blob[0]&0x80 == 0 {
+		return key, errors.New("BLS public key not in compressed form")
+	}
+	var point bls12381.G1Affine
+	if _, err := point.SetBytes(blob); err != nil {
+		return key, fmt.Errorf("invalid BLS public key: %w", err)
+	}
+	if point.IsInfinity() {
+		return key, errors.New("invalid BLS public key: point at infinity")
+	}
+	copy(key[:], blob)
+	return key, nil
+}
+
+// Bytes returns the compressed encoding of the public key.
+func (k BLSPublicKey) Bytes() []byte {
+	return k[:]
+}
+
+// Hex returns the 0x prefixed hex encoding of the public key.
+func (k BLSPublicKey) Hex() string {
+	return hexutil.Encode(k[:])
+}
+
+// String implements fmt.Stringer.
+func (k BLSPublicKey) String() string {
+	return k.Hex()
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (k BLSPublicKey) MarshalText() ([]byte, error) {
+	return hexutil.Bytes(k[:]).MarshalText()
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, validating the point.
+func (k *BLSPublicKey) UnmarshalText(input []byte) error {
+	var blob hexutil.Bytes
+	if err := blob.UnmarshalText(input); err != nil {
+		return err
+	}
+	key, err := ParseBLSPublicKey(blob)
+	if err != nil {
+		return err
+	}
+	*k = key
+	return nil
+}
+
+// Eth2WithdrawalPath returns the EIP-2334 derivation path m/12381/3600/i/0 of
+// the withdrawal key of the validator at the given index.
+func Eth2WithdrawalPath(index uint32) gethaccounts.DerivationPath {
+	return gethaccounts.DerivationPath{eth2Purpose, eth2CoinType, index, 0}
+}
+
+// ValidateEth2Path checks that the derivation path is an EIP-2334 one, rooted
+// at m/12381/3600 and deriving at least a withdrawal key (m/12381/3600/i/0).
+func ValidateEth2Path(path gethaccounts.DerivationPath) error {
+	if len(path) < 4 || path[0] != eth2Purpose || path[1] != eth2CoinType {
+		return fmt.Errorf("invalid EIP-2334 derivation path %v, want m/12381/3600/i/0", path)
+	}
+	return nil
+}
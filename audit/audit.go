@@ -0,0 +1,262 @@
+// Package audit records every signing request made through a wallet, along with
+// its outcome, into a sink such as a tamper-evident, hash-chained log file.
+//
+// Wrapping a wallet already enforcing a policy (see package policy) records the
+// policy decisions too:
+//
+//	wallet = audit.NewWallet(policy.NewWallet(wallet, rules), log)
+package audit
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+
+	"github.com/evmos/ethereum-ledger-go/accounts"
+	"github.com/evmos/ethereum-ledger-go/policy"
+	"github.com/evmos/ethereum-ledger-go/usbwallet"
+)
+
+// Kinds of signing requests.
+const (
+	KindTransaction   = "transaction"
+	KindTypedData     = "typedData"
+	KindMessage       = "message"
+	KindData          = "data"
+	KindAuthorization = "authorization" // EIP-7702 authorization
+	KindDecryption    = "decryption"    // eth_decrypt, the plaintext is never recorded
+	KindRecovery      = "recovery"      // Repair of the log itself, e.g. a torn entry truncated
+)
+
+// Policy decisions.
+const (
+	PolicyNone     = "none"     // No policy enforced
+	PolicyAllowed  = "allowed"  // Allowed by the policy
+	PolicyRejected = "rejected" // Rejected by the policy, never reaching the device
+)
+
+// Outcomes of signing requests.
+const (
+	ResultApproved = "approved" // Approved on the device and signed
+	ResultRejected = "rejected" // Denied by the user on the device
+	ResultBlocked  = "blocked"  // Rejected by the policy
+	ResultFailed   = "failed"   // Failed otherwise, e.g. device disconnected
+)
+
+// Record is the audit record of a signing request.
+type Record struct {
+	Seq  uint64      `json:"seq"`  // Position in the log, from 0
+	Prev common.Hash `json:"prev"` // Digest of the previous record, zero for the first
+
+	Time    time.Time      `json:"time"`
+	Device  string         `json:"device"` // Fingerprint of the device seed, empty if unavailable
+	Path    string         `json:"path,omitempty"`
+	Address common.Address `json:"address"`
+
+	Kind   string       `json:"kind"`
+	Hash   common.Hash  `json:"hash"`             // Signing hash of the transaction, EIP-712 hash, message or ciphertext hash
+	TxHash *common.Hash `json:"txHash,omitempty"` // Hash of the signed transaction
+
+	Policy       string `json:"policy"`
+	PolicyRule   string `json:"policyRule,omitempty"`
+	PolicyReason string `json:"policyReason,omitempty"`
+
+	Result    string        `json:"result"`
+	Error     string        `json:"error,omitempty"`
+	Signature hexutil.Bytes `json:"signature,omitempty"` // Signature, or signed transaction
+}
+
+// Sink receives audit records. The sequence number and previous digest are set
+// by the sink.
+type Sink interface {
+	Write(record *Record) error
+}
+
+// Wallet records the signing requests made through a wallet into a sink. If a
+// record can't be written, the request fails and any signature is withheld.
+type Wallet struct {
+	accounts.Wallet
+	sink Sink
+
+	fingerprint string // Cached device fingerprint
+	lock        sync.Mutex
+}
+
+// NewWallet wraps a wallet, recording its signing requests into the sink.
+func NewWallet(wallet accounts.Wallet, sink Sink) *Wallet {
+	return &Wallet{Wallet: wallet, sink: sink}
+}
+
+// Unwrap returns the underlying wallet.
+func (w *Wallet) Unwrap() accounts.Wallet {
+	return w.Wallet
+}
+
+// SignTx implements accounts.Wallet, recording the request.
+func (w *Wallet) SignTx(account accounts.Account, tx *coretypes.Transaction, chainID *big.Int) ([]byte, error) {
+	record := w.newRecord(account, KindTransaction, coretypes.LatestSignerForChainID(chainID).Hash(tx))
+	raw, err := w.Wallet.SignTx(account, tx, chainID)
+	if err == nil {
+		signed := new(coretypes.Transaction)
+		if err := signed.UnmarshalBinary(raw); err == nil {
+			hash := signed.Hash()
+			record.TxHash = &hash
+		}
+	}
+	if err := w.finish(record, raw, err); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// SignTypedData implements accounts.Wallet, recording the request.
+func (w *Wallet) SignTypedData(account accounts.Account, typedData apitypes.TypedData) ([]byte, error) {
+	var hash common.Hash
+	if sighash, _, err := apitypes.TypedDataAndHash(typedData); err == nil {
+		hash = common.BytesToHash(sighash)
+	}
+	record := w.newRecord(account, KindTypedData, hash)
+	sig, err := w.Wallet.SignTypedData(account, typedData)
+	if err := w.finish(record, sig, err); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// SignText implements accounts.Wallet, recording the request.
+func (w *Wallet) SignText(account accounts.Account, text []byte) ([]byte, error) {
+	record := w.newRecord(account, KindMessage, common.BytesToHash(gethaccounts.TextHash(text)))
+	sig, err := w.Wallet.SignText(account, text)
+	if err := w.finish(record, sig, err); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// SignData implements accounts.Wallet, recording the request.
+func (w *Wallet) SignData(account accounts.Account, mimeType string, data []byte) ([]byte, error) {
+	record := w.newRecord(account, KindData, crypto.Keccak256Hash(data))
+	sig, err := w.Wallet.SignData(account, mimeType, data)
+	if err := w.finish(record, sig, err); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// SignAuthorization implements accounts.Wallet, recording the request.
+func (w *Wallet) SignAuthorization(account accounts.Account, auth coretypes.SetCodeAuthorization) (coretypes.SetCodeAuthorization, error) {
+	record := w.newRecord(account, KindAuthorization, auth.SigHash())
+	signed, err := w.Wallet.SignAuthorization(account, auth)
+
+	var sig []byte
+	if err == nil {
+		sig = make([]byte, crypto.SignatureLength)
+		signed.R.PutUint256(sig[:32])
+		signed.S.PutUint256(sig[32:64])
+		sig[crypto.RecoveryIDOffset] = signed.V
+	}
+	if err := w.finish(record, sig, err); err != nil {
+		return coretypes.SetCodeAuthorization{}, err
+	}
+	return signed, nil
+}
+
+// Decrypt implements accounts.Wallet, recording the request along with the hash
+// of the ciphertext, but not the plaintext.
+func (w *Wallet) Decrypt(account accounts.Account, ciphertext []byte) ([]byte, error) {
+	record := w.newRecord(account, KindDecryption, crypto.Keccak256Hash(ciphertext))
+	plaintext, err := w.Wallet.Decrypt(account, ciphertext)
+	if err := w.finish(record, nil, err); err != nil {
+		return nil, err
+	}
+	return plaintext, nil
+}
+
+// newRecord starts the record of a request.
+func (w *Wallet) newRecord(account accounts.Account, kind string, hash common.Hash) *Record {
+	record := &Record{
+		Time:    time.Now().UTC(),
+		Device:  w.deviceFingerprint(),
+		Address: account.Address,
+		Kind:    kind,
+		Hash:    hash,
+		Policy:  PolicyNone,
+	}
+	if i := strings.Index(account.URL.Path, "/m/"); i >= 0 {
+		record.Path = account.URL.Path[i+1:]
+	}
+	if w.enforcesPolicy() {
+		record.Policy = PolicyAllowed
+	}
+	return record
+}
+
+// finish completes the record with the outcome of the request and writes it,
+// returning the error of the request, or of the write.
+func (w *Wallet) finish(record *Record, sig []byte, err error) error {
+	var rejection *policy.Rejection
+	switch {
+	case err == nil:
+		record.Result = ResultApproved
+		record.Signature = sig
+	case errors.As(err, &rejection):
+		record.Policy = PolicyRejected
+		record.PolicyRule = rejection.Rule
+		record.PolicyReason = rejection.Reason
+		record.Result = ResultBlocked
+	case errors.Is(err, usbwallet.ErrLedgerUserRejected):
+		record.Result = ResultRejected
+	default:
+		record.Result = ResultFailed
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if werr := w.sink.Write(record); werr != nil {
+		return werr
+	}
+	return err
+}
+
+// enforcesPolicy returns whether the wrapped wallet chain enforces a policy.
+func (w *Wallet) enforcesPolicy() bool {
+	var wallet accounts.Wallet = w.Wallet
+	for {
+		if _, ok := wallet.(*policy.Wallet); ok {
+			return true
+		}
+		unwrapper, ok := wallet.(interface{ Unwrap() accounts.Wallet })
+		if !ok {
+			return false
+		}
+		wallet = unwrapper.Unwrap()
+	}
+}
+
+// deviceFingerprint returns the fingerprint of the device seed: the first bytes
+// of the hash of the public key at m/44'/60'/0'/0/0. It's derived once, an
+// empty fingerprint being returned if the derivation fails.
+func (w *Wallet) deviceFingerprint() string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.fingerprint == "" {
+		account, err := w.Wallet.Derive(gethaccounts.DefaultBaseDerivationPath, false)
+		if err != nil || account.PublicKey == nil {
+			return ""
+		}
+		w.fingerprint = hexutil.Encode(crypto.Keccak256(crypto.FromECDSAPub(account.PublicKey))[:8])
+	}
+	return w.fingerprint
+}
+
+var _ accounts.Wallet = (*Wallet)(nil)
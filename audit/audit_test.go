@@ -0,0 +1,238 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/stretchr/testify/require"
+
+	ledger "github.com/evmos/ethereum-ledger-go"
+	"github.com/evmos/ethereum-ledger-go/accounts"
+	"github.com/evmos/ethereum-ledger-go/internal/testutil"
+	"github.com/evmos/ethereum-ledger-go/policy"
+	"github.com/evmos/ethereum-ledger-go/usbwallet"
+)
+
+var testRecipient = common.HexToAddress("0x3535353535353535353535353535353535353535")
+
+// readRecords reads back the records of the log file.
+func readRecords(t *testing.T, path string) []*Record {
+	t.Helper()
+
+	blob, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	var records []*Record
+	for _, line := range bytes.Split(bytes.TrimSpace(blob), []byte("\n")) {
+		var e entry
+		require.NoError(t, json.Unmarshal(line, &e))
+		record := new(Record)
+		require.NoError(t, json.Unmarshal(e.Record, record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestWallet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log, err := OpenFile(path)
+	require.NoError(t, err)
+	defer log.Close()
+
+	// Wrap a policy enforcing wallet, allowing a single recipient
+	inner, account, device := testutil.SimulatedWallet(t)
+	rules, err := policy.NewRulePolicy(&policy.Rules{Recipients: []common.Address{testRecipient}})
+	require.NoError(t, err)
+	wallet := NewWallet(policy.NewWallet(inner, rules), log)
+
+	builder := ledger.NewTxBuilder(big.NewInt(1)).ToAddress(testRecipient).Gas(21000).GasFees(big.NewInt(1), big.NewInt(params.GWei))
+	raw, err := builder.Sign(wallet, account)
+	require.NoError(t, err)
+
+	_, err = builder.ToAddress(testutil.Address).Sign(wallet, account)
+	require.ErrorIs(t, err, policy.ErrRejected)
+
+	device.SetRejecting(true)
+	_, err = wallet.SignText(account, []byte("hello"))
+	require.ErrorIs(t, err, usbwallet.ErrLedgerUserRejected)
+	device.SetRejecting(false)
+
+	typedData := apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {{Name: "name", Type: "string"}},
+			"Mail":         {{Name: "contents", Type: "string"}},
+		},
+		PrimaryType: "Mail",
+		Domain:      apitypes.TypedDataDomain{Name: "Ether Mail"},
+		Message:     apitypes.TypedDataMessage{"contents": "Hello, Bob!"},
+	}
+	sig, err := wallet.SignTypedData(account, typedData)
+	require.NoError(t, err)
+
+	auth, err := wallet.SignAuthorization(account, coretypes.SetCodeAuthorization{Address: testRecipient, Nonce: 1})
+	require.NoError(t, err)
+
+	pubkey, err := wallet.GetEncryptionPublicKey(account)
+	require.NoError(t, err)
+	data, err := accounts.Encrypt(pubkey, []byte("hello ledger"))
+	require.NoError(t, err)
+	ciphertext, err := json.Marshal(data)
+	require.NoError(t, err)
+	_, err = wallet.Decrypt(account, ciphertext)
+	require.NoError(t, err)
+
+	// Every request is recorded, along with the policy decision and outcome
+	records := readRecords(t, path)
+	require.Len(t, records, 6)
+	for i, record := range records {
+		require.Equal(t, uint64(i), record.Seq)
+		require.Equal(t, testutil.Address, record.Address)
+		require.Equal(t, "m/44'/60'/0'/0/0", record.Path)
+		require.Len(t, record.Device, 18)
+		require.Equal(t, records[0].Device, record.Device)
+	}
+	tx := new(coretypes.Transaction)
+	require.NoError(t, tx.UnmarshalBinary(raw))
+
+	require.Equal(t, KindTransaction, records[0].Kind)
+	require.Equal(t, PolicyAllowed, records[0].Policy)
+	require.Equal(t, ResultApproved, records[0].Result)
+	require.Equal(t, tx.Hash(), *records[0].TxHash)
+	require.Equal(t, coretypes.LatestSignerForChainID(big.NewInt(1)).Hash(tx), records[0].Hash)
+	require.Equal(t, raw, []byte(records[0].Signature))
+
+	require.Equal(t, PolicyRejected, records[1].Policy)
+	require.Equal(t, policy.RuleRecipients, records[1].PolicyRule)
+	require.Equal(t, ResultBlocked, records[1].Result)
+	require.Empty(t, records[1].Signature)
+
+	require.Equal(t, KindMessage, records[2].Kind)
+	require.Equal(t, ResultRejected, records[2].Result)
+
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	require.NoError(t, err)
+	require.Equal(t, KindTypedData, records[3].Kind)
+	require.Equal(t, common.BytesToHash(hash), records[3].Hash)
+	require.Equal(t, sig, []byte(records[3].Signature))
+
+	signer, err := auth.Authority()
+	require.NoError(t, err)
+	require.Equal(t, testutil.Address, signer)
+	require.Equal(t, KindAuthorization, records[4].Kind)
+	require.Equal(t, auth.SigHash(), records[4].Hash)
+	require.Len(t, records[4].Signature, 65)
+
+	// Decryptions are recorded by the ciphertext, the plaintext is withheld
+	require.Equal(t, KindDecryption, records[5].Kind)
+	require.Equal(t, ResultApproved, records[5].Result)
+	require.Equal(t, crypto.Keccak256Hash(ciphertext), records[5].Hash)
+	require.Empty(t, records[5].Signature)
+
+	// The log verifies, and reopening continues the chain
+	n, head := log.Head()
+	require.Equal(t, uint64(6), n)
+	res, err := VerifyFile(path, head)
+	require.NoError(t, err)
+	require.Equal(t, 6, res.Records)
+
+	_, err = OpenFile(path)
+	require.ErrorIs(t, err, ErrLocked)
+	require.NoError(t, log.Close())
+
+	log, err = OpenFile(path)
+	require.NoError(t, err)
+	require.NoError(t, log.Write(&Record{Kind: KindMessage, Result: ResultFailed}))
+	res, err = VerifyFile(path, common.Hash{})
+	require.NoError(t, err)
+	require.Equal(t, 7, res.Records)
+}
+
+func TestVerifyTampering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log, err := OpenFile(path)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, log.Write(&Record{Address: testutil.Address, Kind: KindTransaction, Result: ResultApproved}))
+	}
+	_, head := log.Head()
+	require.NoError(t, log.Close())
+
+	blob, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := bytes.SplitAfter(bytes.TrimSpace(blob), []byte("\n"))
+	require.Len(t, lines, 3)
+
+	tests := []struct {
+		name string
+		log  []byte
+		line int
+	}{
+		{"edited", bytes.Replace(blob, []byte(ResultApproved), []byte(ResultRejected), 1), 1},
+		{"removed", append(append([]byte{}, lines[0]...), lines[2]...), 2},
+		{"reordered", append(append(append([]byte{}, lines[1]...), lines[0]...), lines[2]...), 1},
+		{"truncated", lines[0], 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Verify(bytes.NewReader(tt.log), head)
+			var verr *VerifyError
+			require.ErrorAs(t, err, &verr)
+			require.Equal(t, tt.line, verr.Line)
+		})
+	}
+	// Truncation goes unnoticed without the head
+	_, err = Verify(bytes.NewReader(lines[0]), common.Hash{})
+	require.NoError(t, err)
+}
+
+func TestOpenFileTornEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log, err := OpenFile(path)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, log.Write(&Record{Address: testutil.Address, Kind: KindTransaction, Result: ResultApproved}))
+	}
+	require.NoError(t, log.Close())
+
+	// Simulate a crash in the middle of writing the third entry
+	blob, err := os.ReadFile(path)
+	require.NoError(t, err)
+	torn := []byte(`{"record":{"seq":2,"prev":"0x`)
+	require.NoError(t, os.WriteFile(path, append(append([]byte{}, blob...), torn...), 0o600))
+
+	_, err = VerifyFile(path, common.Hash{})
+	require.Error(t, err)
+
+	// Reopening cuts the torn entry off and records the truncation
+	log, err = OpenFile(path)
+	require.NoError(t, err)
+	require.NoError(t, log.Write(&Record{Address: testutil.Address, Kind: KindMessage, Result: ResultApproved}))
+	_, head := log.Head()
+	require.NoError(t, log.Close())
+
+	res, err := VerifyFile(path, head)
+	require.NoError(t, err)
+	require.Equal(t, 4, res.Records)
+
+	records := readRecords(t, path)
+	require.Equal(t, KindRecovery, records[2].Kind)
+	require.Equal(t, ResultFailed, records[2].Result)
+	require.Equal(t, crypto.Keccak256Hash(torn), records[2].Hash)
+	require.Equal(t, fmt.Sprintf("truncated torn entry of %d bytes at offset %d", len(torn), len(blob)), records[2].Error)
+	require.Equal(t, KindMessage, records[3].Kind)
+
+	// An unterminated tail longer than any entry is not a torn write
+	require.NoError(t, os.WriteFile(path, bytes.Repeat([]byte{'x'}, maxLineSize+2), 0o600))
+	_, err = OpenFile(path)
+	require.ErrorContains(t, err, "unterminated entry")
+}
@@ -0,0 +1,255 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gofrs/flock"
+)
+
+// maxLineSize is the longest log line accepted, fitting signed transactions
+// with large calldata.
+const maxLineSize = 4 * 1024 * 1024
+
+// ErrLocked is returned if the log file is already open for writing, e.g. by
+// another process.
+var ErrLocked = errors.New("audit: log file locked by another writer")
+
+// entry is a line of the log file. The digest chains the record to the previous
+// one, hashing the previous digest with the exact bytes of the record.
+type entry struct {
+	Record json.RawMessage `json:"record"`
+	Digest common.Hash     `json:"digest"`
+}
+
+// digest computes the chained digest of the raw record.
+func digest(prev common.Hash, record []byte) common.Hash {
+	return crypto.Keccak256Hash(prev[:], record)
+}
+
+// FileLog is a Sink appending records to a file, one JSON entry per line, each
+// hash-chained to the previous one so edits, insertions and removals are
+// detected by Verify. Truncation of the most recent records can only be
+// detected against a head digest kept elsewhere, see Head.
+type FileLog struct {
+	file  *os.File
+	flock *flock.Flock
+
+	seq  uint64      // Sequence number of the next record
+	head common.Hash // Digest of the last record
+	lock sync.Mutex
+}
+
+// OpenFile opens the log file for appending, creating it if missing. The file
+// is verified and locked against other writers until closed.
+//
+// A torn last entry, left behind by a write interrupted by a crash, is truncated
+// and the truncation recorded as a KindRecovery record of its own, carrying the
+// hash of the bytes cut off.
+func OpenFile(path string) (*FileLog, error) {
+	lock := flock.New(path + ".lock")
+	locked, err := lock.TryLock()
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, ErrLocked
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		lock.Unlock()
+		return nil, err
+	}
+	torn, offset, err := truncateTorn(file)
+	if err != nil {
+		file.Close()
+		lock.Unlock()
+		return nil, err
+	}
+	res, err := Verify(file, common.Hash{})
+	if err != nil {
+		file.Close()
+		lock.Unlock()
+		return nil, err
+	}
+	log := &FileLog{file: file, flock: lock, seq: uint64(res.Records), head: res.Head}
+	if torn != nil {
+		err := log.Write(&Record{
+			Time:   time.Now().UTC(),
+			Kind:   KindRecovery,
+			Hash:   crypto.Keccak256Hash(torn),
+			Policy: PolicyNone,
+			Result: ResultFailed,
+			Error:  fmt.Sprintf("truncated torn entry of %d bytes at offset %d", len(torn), offset),
+		})
+		if err != nil {
+			log.Close()
+			return nil, err
+		}
+	}
+	return log, nil
+}
+
+// truncateTorn cuts a torn last entry (one lacking its terminating newline) off
+// the log file, returning its bytes and offset, or nil if the log ends cleanly.
+func truncateTorn(file *os.File) ([]byte, int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	size := info.Size()
+	if size == 0 {
+		return nil, 0, nil
+	}
+	tail := make([]byte, min(size, maxLineSize+1))
+	if _, err := file.ReadAt(tail, size-int64(len(tail))); err != nil {
+		return nil, 0, err
+	}
+	if tail[len(tail)-1] == '\n' {
+		return nil, 0, nil
+	}
+	cut := bytes.LastIndexByte(tail, '\n') + 1
+	if cut == 0 && int64(len(tail)) < size {
+		return nil, 0, fmt.Errorf("audit: log ends with an unterminated entry longer than %d bytes", maxLineSize)
+	}
+	offset := size - int64(len(tail)) + int64(cut)
+	if err := file.Truncate(offset); err != nil {
+		return nil, 0, fmt.Errorf("audit: failed to truncate torn entry: %v", err)
+	}
+	if err := file.Sync(); err != nil {
+		return nil, 0, fmt.Errorf("audit: failed to sync truncation: %v", err)
+	}
+	return tail[cut:], offset, nil
+}
+
+// Head returns the number of records and the digest of the last one, to be
+// kept apart from the log (e.g. published periodically) to detect truncation.
+func (l *FileLog) Head() (uint64, common.Hash) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.seq, l.head
+}
+
+// Write implements Sink, appending the record and syncing it to disk.
+func (l *FileLog) Write(record *Record) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.file == nil {
+		return os.ErrClosed
+	}
+	record.Seq, record.Prev = l.seq, l.head
+
+	blob, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(&entry{Record: blob, Digest: digest(l.head, blob)})
+	if err != nil {
+		return err
+	}
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("audit: failed to write record: %v", err)
+	}
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("audit: failed to sync record: %v", err)
+	}
+	l.seq, l.head = l.seq+1, digest(l.head, blob)
+	return nil
+}
+
+// Close closes the log file, releasing its lock.
+func (l *FileLog) Close() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	l.flock.Unlock()
+	return err
+}
+
+// VerifyResult summarizes a verified log.
+type VerifyResult struct {
+	Records int         `json:"records"`
+	Head    common.Hash `json:"head"` // Digest of the last record, zero if empty
+}
+
+// VerifyError reports where a log fails verification.
+type VerifyError struct {
+	Line   int    `json:"line"` // Line of the offending entry, from 1
+	Reason string `json:"reason"`
+}
+
+// Error implements error.
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("audit: line %d: %s", e.Line, e.Reason)
+}
+
+// Verify reads a log, checking that every record chains to the previous one
+// with consecutive sequence numbers, detecting edited, inserted, reordered or
+// removed records. If a head digest is given, the log must end with it, which
+// also detects truncation.
+func Verify(r io.Reader, head common.Hash) (*VerifyResult, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	var (
+		res  = new(VerifyResult)
+		line int
+	)
+	for scanner.Scan() {
+		line++
+
+		var e entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, &VerifyError{line, fmt.Sprintf("malformed entry: %v", err)}
+		}
+		var record Record
+		if err := json.Unmarshal(e.Record, &record); err != nil {
+			return nil, &VerifyError{line, fmt.Sprintf("malformed record: %v", err)}
+		}
+		if record.Seq != uint64(res.Records) {
+			return nil, &VerifyError{line, fmt.Sprintf("sequence number %d, expected %d", record.Seq, res.Records)}
+		}
+		if record.Prev != res.Head {
+			return nil, &VerifyError{line, fmt.Sprintf("previous digest %s, expected %s", record.Prev.Hex(), res.Head.Hex())}
+		}
+		if want := digest(res.Head, e.Record); e.Digest != want {
+			return nil, &VerifyError{line, fmt.Sprintf("digest %s, computed %s", e.Digest.Hex(), want.Hex())}
+		}
+		res.Records++
+		res.Head = e.Digest
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, &VerifyError{line + 1, err.Error()}
+	}
+	if head != (common.Hash{}) && head != res.Head {
+		return nil, &VerifyError{line, fmt.Sprintf("log ends with digest %s, expected %s", res.Head.Hex(), head.Hex())}
+	}
+	return res, nil
+}
+
+// VerifyFile verifies the log file, see Verify.
+func VerifyFile(path string, head common.Hash) (*VerifyResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Verify(file, head)
+}
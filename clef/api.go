@@ -0,0 +1,189 @@
+// Package clef serves Ledger accounts through the external API of go-ethereum's
+// clef signer, so geth, foundry and other clients supporting an external signer
+// can sign with a Ledger without linking this library.
+package clef
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"mime"
+
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+
+	"github.com/evmos/ethereum-ledger-go/accounts"
+	"github.com/evmos/ethereum-ledger-go/internal/resolver"
+)
+
+// ExternalAPIVersion is the version of the clef external API implemented.
+const ExternalAPIVersion = "6.1.0"
+
+var (
+	// ErrUnknownAccount is returned if a request names an account that none of the
+	// Ledger wallets derives.
+	ErrUnknownAccount = resolver.ErrUnknownAccount
+
+	// ErrChainID is returned if a transaction requests a chain ID other than the
+	// one the signer is configured with.
+	ErrChainID = resolver.ErrChainID
+
+	// ErrContentType is returned for data of a content type the Ledger can't sign.
+	ErrContentType = errors.New("clef: unsupported content type")
+)
+
+// Config configures the accounts and chain served by an API.
+type Config struct {
+	ChainID *big.Int                      // Chain transactions are signed for
+	Paths   []gethaccounts.DerivationPath // Paths derived on every wallet, m/44'/60'/0'/0/0 if empty
+}
+
+// SignTransactionResult is the result of account_signTransaction, carrying the
+// signed transaction both encoded and decoded.
+type SignTransactionResult struct {
+	Raw hexutil.Bytes          `json:"raw"`
+	Tx  *coretypes.Transaction `json:"tx"`
+}
+
+// API implements the clef external API, exposed in the "account" namespace, on
+// top of the Ledger wallets of a backend (e.g. an EthereumLedger or usbwallet.Hub).
+//
+// Wallets are opened and their configured paths derived as they are discovered,
+// the accounts found being the ones listed and signed with.
+type API struct {
+	resolver *resolver.Resolver
+	chainID  *big.Int
+}
+
+// NewAPI creates a clef external API serving the accounts of the backend's
+// wallets at the configured paths.
+func NewAPI(backend accounts.Backend, config Config) *API {
+	return &API{
+		resolver: resolver.New(backend, config.Paths),
+		chainID:  config.ChainID,
+	}
+}
+
+// List returns the addresses of the accounts available for signing.
+func (api *API) List(ctx context.Context) ([]common.Address, error) {
+	addrs := make([]common.Address, 0) // Empty list instead of null if none found
+	for _, account := range api.resolver.Accounts() {
+		addrs = append(addrs, account.Address)
+	}
+	return addrs, nil
+}
+
+// SignTransaction signs the transaction with the account in its from field,
+// returning it both encoded and decoded. The method selector is accepted for
+// compatibility, the Ledger displaying the calldata on its own.
+func (api *API) SignTransaction(ctx context.Context, args apitypes.SendTxArgs, methodSelector *string) (*SignTransactionResult, error) {
+	chainID, err := resolver.ChainID(args.ChainID, api.chainID)
+	if err != nil {
+		return nil, err
+	}
+	args.ChainID = (*hexutil.Big)(chainID)
+
+	wallet, account, err := api.find(args.From.Address())
+	if err != nil {
+		return nil, err
+	}
+	tx, err := args.ToTransaction()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := wallet.SignTx(account, tx, (*big.Int)(args.ChainID))
+	if err != nil {
+		return nil, err
+	}
+	signed := new(coretypes.Transaction)
+	if err := signed.UnmarshalBinary(raw); err != nil {
+		return nil, err
+	}
+	return &SignTransactionResult{Raw: raw, Tx: signed}, nil
+}
+
+// SignData signs the data with the account, as described by its content type:
+// personal messages (text/plain) given hex encoded, EIP-712 typed data
+// (data/typed) given as JSON and Cosmos SDK Amino JSON sign docs given hex
+// encoded. The signature is in [R || S || V] format with V being 27 or 28.
+func (api *API) SignData(ctx context.Context, contentType string, addr common.MixedcaseAddress, data interface{}) (hexutil.Bytes, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, err
+	}
+	switch mediaType {
+	case apitypes.TextPlain.Mime:
+		msg, err := fromHex(data)
+		if err != nil {
+			return nil, err
+		}
+		wallet, account, err := api.find(addr.Address())
+		if err != nil {
+			return nil, err
+		}
+		return wallet.SignText(account, msg)
+
+	case apitypes.DataTyped.Mime:
+		blob, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		var typedData apitypes.TypedData
+		if err := json.Unmarshal(blob, &typedData); err != nil {
+			return nil, err
+		}
+		return api.SignTypedData(ctx, addr, typedData)
+
+	case accounts.MimetypeAminoJSON:
+		doc, err := fromHex(data)
+		if err != nil {
+			return nil, err
+		}
+		wallet, account, err := api.find(addr.Address())
+		if err != nil {
+			return nil, err
+		}
+		return wallet.SignData(account, accounts.MimetypeAminoJSON, doc)
+
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrContentType, mediaType)
+	}
+}
+
+// SignTypedData signs the EIP-712 typed data with the account. The signature is
+// in [R || S || V] format with V being 27 or 28.
+func (api *API) SignTypedData(ctx context.Context, addr common.MixedcaseAddress, typedData apitypes.TypedData) (hexutil.Bytes, error) {
+	wallet, account, err := api.find(addr.Address())
+	if err != nil {
+		return nil, err
+	}
+	return wallet.SignTypedData(account, typedData)
+}
+
+// Version returns the version of the external API implemented.
+func (api *API) Version(ctx context.Context) (string, error) {
+	return ExternalAPIVersion, nil
+}
+
+// find looks up the wallet deriving the account with the given address.
+func (api *API) find(addr common.Address) (accounts.Wallet, accounts.Account, error) {
+	account, err := api.resolver.Find(addr)
+	if err != nil {
+		return nil, accounts.Account{}, err
+	}
+	return account.Wallet, account.Account, nil
+}
+
+// fromHex interprets the data as a hex string with 0x prefix.
+func fromHex(data interface{}) ([]byte, error) {
+	str, ok := data.(string)
+	if !ok {
+		return nil, fmt.Errorf("clef: data must be a hex string, got %T", data)
+	}
+	return hexutil.Decode(str)
+}
@@ -0,0 +1,179 @@
+package clef
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evmos/ethereum-ledger-go/internal/testutil"
+	"github.com/evmos/ethereum-ledger-go/usbwallet/simulator"
+)
+
+var (
+	testSecond   = gethaccounts.DerivationPath{0x80000000 + 44, 0x80000000 + 60, 0x80000000 + 0, 0, 1}
+	testChainID  = big.NewInt(1337)
+	testTypedRaw = `{
+		"types": {
+			"EIP712Domain": [{"name": "name", "type": "string"}, {"name": "chainId", "type": "uint256"}],
+			"Mail": [{"name": "contents", "type": "string"}]
+		},
+		"primaryType": "Mail",
+		"domain": {"name": "Ether Mail", "chainId": "1337"},
+		"message": {"contents": "Hello, Bob!"}
+	}`
+)
+
+// startServer serves the accounts of a simulated Ledger on an IPC socket and an
+// HTTP endpoint, returning clients connected to both.
+func startServer(t *testing.T) (*simulator.Device, []*rpc.Client) {
+	t.Helper()
+
+	hub, device := testutil.SimulatedHub()
+	api := NewAPI(hub, Config{
+		ChainID: testChainID,
+		Paths:   []gethaccounts.DerivationPath{gethaccounts.DefaultBaseDerivationPath, testSecond},
+	})
+	server, err := NewServer(api)
+	require.NoError(t, err)
+	t.Cleanup(server.Stop)
+
+	// Socket paths are length limited, keep it short
+	dir, err := os.MkdirTemp("", "clef")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	ipc := filepath.Join(dir, "clef.ipc")
+	require.NoError(t, server.ListenIPC(ipc))
+
+	addr, err := server.ListenHTTP("127.0.0.1:0")
+	require.NoError(t, err)
+
+	var clients []*rpc.Client
+	for _, endpoint := range []string{ipc, "http://" + addr.String()} {
+		client, err := rpc.Dial(endpoint)
+		require.NoError(t, err)
+		t.Cleanup(client.Close)
+
+		clients = append(clients, client)
+	}
+	return device, clients
+}
+
+func TestAPI(t *testing.T) {
+	device, clients := startServer(t)
+	second, err := device.Address(testSecond)
+	require.NoError(t, err)
+
+	for _, client := range clients {
+		var version string
+		require.NoError(t, client.Call(&version, "account_version"))
+		require.Equal(t, ExternalAPIVersion, version)
+
+		var addrs []common.Address
+		require.NoError(t, client.Call(&addrs, "account_list"))
+		require.Equal(t, []common.Address{testutil.Address, second}, addrs)
+	}
+}
+
+func TestSignTransaction(t *testing.T) {
+	_, clients := startServer(t)
+	to := common.NewMixedcaseAddress(common.HexToAddress("0x3535353535353535353535353535353535353535"))
+
+	tests := []struct {
+		name   string
+		args   map[string]interface{}
+		txType uint8
+	}{
+		{"legacy", map[string]interface{}{"gasPrice": "0x3b9aca00"}, coretypes.LegacyTxType},
+		{"dynamic fee", map[string]interface{}{"maxFeePerGas": "0x3b9aca00", "maxPriorityFeePerGas": "0x1", "chainId": "0x539"}, coretypes.DynamicFeeTxType},
+	}
+	for _, client := range clients {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				args := map[string]interface{}{
+					"from": testutil.Address, "to": to, "gas": "0x5208", "value": "0x1", "nonce": "0x3", "input": "0xdead",
+				}
+				for k, v := range tt.args {
+					args[k] = v
+				}
+				var result SignTransactionResult
+				require.NoError(t, client.Call(&result, "account_signTransaction", args, nil))
+				require.Equal(t, tt.txType, result.Tx.Type())
+				require.Equal(t, uint64(3), result.Tx.Nonce())
+
+				decoded := new(coretypes.Transaction)
+				require.NoError(t, decoded.UnmarshalBinary(result.Raw))
+				require.Equal(t, result.Tx.Hash(), decoded.Hash())
+
+				sender, err := coretypes.Sender(coretypes.LatestSignerForChainID(testChainID), decoded)
+				require.NoError(t, err)
+				require.Equal(t, testutil.Address, sender)
+			})
+		}
+	}
+	// Requests for other chains or unknown accounts are refused
+	args := map[string]interface{}{"from": testutil.Address, "to": to, "gas": "0x5208", "value": "0x0", "nonce": "0x0", "maxFeePerGas": "0x1", "maxPriorityFeePerGas": "0x1", "chainId": "0x1"}
+	require.ErrorContains(t, clients[0].Call(nil, "account_signTransaction", args, nil), ErrChainID.Error())
+
+	args = map[string]interface{}{"from": to, "to": to, "gas": "0x5208", "value": "0x0", "nonce": "0x0", "gasPrice": "0x1"}
+	require.ErrorContains(t, clients[0].Call(nil, "account_signTransaction", args, nil), ErrUnknownAccount.Error())
+}
+
+func TestSignData(t *testing.T) {
+	device, clients := startServer(t)
+	msg := []byte("hello ledger")
+
+	for _, client := range clients {
+		var sig hexutil.Bytes
+		require.NoError(t, client.Call(&sig, "account_signData", "text/plain", testutil.Address, hexutil.Encode(msg)))
+		require.Len(t, sig, 65)
+		require.Contains(t, []byte{27, 28}, sig[64])
+
+		sig[64] -= 27
+		pub, err := crypto.SigToPub(gethaccounts.TextHash(msg), sig)
+		require.NoError(t, err)
+		require.Equal(t, testutil.Address, crypto.PubkeyToAddress(*pub))
+
+		// Content types the Ledger can't display are refused
+		err = client.Call(&sig, "account_signData", "application/x-clique-header", testutil.Address, "0x00")
+		require.ErrorContains(t, err, ErrContentType.Error())
+	}
+	// Rejections on the device are passed back to the client
+	device.SetRejecting(true)
+	require.Error(t, clients[0].Call(nil, "account_signData", "text/plain", testutil.Address, hexutil.Encode(msg)))
+}
+
+func TestSignTypedData(t *testing.T) {
+	_, clients := startServer(t)
+
+	var typedData apitypes.TypedData
+	require.NoError(t, json.Unmarshal([]byte(testTypedRaw), &typedData))
+
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	require.NoError(t, err)
+
+	for _, client := range clients {
+		// Both the dedicated method and the data/typed content type sign the same
+		var sig, viaData hexutil.Bytes
+		require.NoError(t, client.CallContext(context.Background(), &sig, "account_signTypedData", testutil.Address, typedData))
+		require.NoError(t, client.CallContext(context.Background(), &viaData, "account_signData", "data/typed", testutil.Address, typedData))
+		require.Equal(t, sig, viaData)
+
+		sig[64] -= 27
+		pub, err := crypto.SigToPub(hash, sig)
+		require.NoError(t, err)
+		require.Equal(t, testutil.Address, crypto.PubkeyToAddress(*pub))
+	}
+}
@@ -0,0 +1,91 @@
+package clef
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Server exposes an API over JSON-RPC, on HTTP and Unix domain socket endpoints.
+type Server struct {
+	rpc *rpc.Server
+
+	listeners []net.Listener
+	servers   []*http.Server
+	lock      sync.Mutex
+}
+
+// NewServer creates a JSON-RPC server exposing the API in the "account"
+// namespace, without starting any endpoints.
+func NewServer(api *API) (*Server, error) {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("account", api); err != nil {
+		return nil, err
+	}
+	return &Server{rpc: srv}, nil
+}
+
+// ServeHTTP implements http.Handler, serving the API over HTTP (POST requests
+// only).
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.rpc.ServeHTTP(w, r)
+}
+
+// ListenHTTP starts serving the API over HTTP on the given TCP address (e.g.
+// localhost:8550), returning the address listened on.
+func (s *Server) ListenHTTP(addr string) (net.Addr, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	server := &http.Server{Handler: s}
+
+	s.lock.Lock()
+	s.servers = append(s.servers, server)
+	s.lock.Unlock()
+
+	go server.Serve(listener)
+	return listener.Addr(), nil
+}
+
+// ListenIPC starts serving the API on a Unix domain socket at the given path,
+// replacing any stale socket left behind.
+func (s *Server) ListenIPC(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		listener.Close()
+		return err
+	}
+	s.lock.Lock()
+	s.listeners = append(s.listeners, listener)
+	s.lock.Unlock()
+
+	go s.rpc.ServeListener(listener)
+	return nil
+}
+
+// Stop closes all endpoints and stops serving requests.
+func (s *Server) Stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, server := range s.servers {
+		server.Close()
+	}
+	for _, listener := range s.listeners {
+		listener.Close()
+	}
+	s.servers, s.listeners = nil, nil
+
+	s.rpc.Stop()
+}
@@ -0,0 +1,63 @@
+// Command ledger-audit verifies the hash chain of audit log files, detecting
+// edited, inserted, reordered or removed records:
+//
+//	ledger-audit [-head 0x...] audit.log
+//
+// Given the head digest recorded apart from the log, truncation is detected too.
+// The exit code is 0 if the log verifies, 1 if it doesn't and 2 on usage errors.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/evmos/ethereum-ledger-go/audit"
+)
+
+func main() {
+	var (
+		head   = flag.String("head", "", "digest the log is expected to end with")
+		asJSON = flag.Bool("json", false, "print the result as JSON")
+	)
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: ledger-audit [flags] <log file>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	var expected common.Hash
+	if *head != "" {
+		blob, err := hexutil.Decode(*head)
+		if err != nil || len(blob) != common.HashLength {
+			fmt.Fprintf(os.Stderr, "invalid head digest %q\n", *head)
+			os.Exit(2)
+		}
+		expected = common.BytesToHash(blob)
+	}
+	res, err := audit.VerifyFile(flag.Arg(0), expected)
+	if *asJSON {
+		out := map[string]interface{}{"valid": err == nil}
+		if err != nil {
+			out["error"] = err.Error()
+		} else {
+			out["records"], out["head"] = res.Records, res.Head
+		}
+		json.NewEncoder(os.Stdout).Encode(out)
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	} else {
+		fmt.Printf("%d records verified, head %s\n", res.Records, res.Head.Hex())
+	}
+	if err != nil {
+		os.Exit(1)
+	}
+}
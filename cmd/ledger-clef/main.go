@@ -0,0 +1,92 @@
+// Command ledger-clef runs a signer daemon exposing the accounts of connected
+// Ledger devices through clef's external API, over HTTP and a Unix domain socket:
+//
+//	ledger-clef -chainid 1 -ipc ~/.clef/ledger.ipc -http localhost:8550
+//
+// Clients configured with an external signer (e.g. geth --signer) can then sign
+// with the Ledger without linking this library.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"os/signal"
+	"syscall"
+
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+
+	ledger "github.com/evmos/ethereum-ledger-go"
+	"github.com/evmos/ethereum-ledger-go/accounts"
+	"github.com/evmos/ethereum-ledger-go/clef"
+	"github.com/evmos/ethereum-ledger-go/internal/flags"
+	"github.com/evmos/ethereum-ledger-go/policy"
+)
+
+func main() {
+	var (
+		paths   flags.Paths
+		chainID = flag.Uint64("chainid", 1, "chain ID transactions are signed for")
+		http    = flag.String("http", "", "HTTP endpoint to listen on (e.g. localhost:8550)")
+		ipc     = flag.String("ipc", "", "Unix domain socket path to listen on")
+		rules   = flag.String("policy", "", "JSON rule file requests are checked against before reaching the Ledger")
+	)
+	flag.Var(&paths, "path", "derivation path of an account to serve, repeatable (default m/44'/60'/0'/0/0)")
+	flag.Parse()
+
+	if *http == "" && *ipc == "" {
+		fmt.Fprintln(os.Stderr, "at least one of -http or -ipc is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(new(big.Int).SetUint64(*chainID), paths, *rules, *http, *ipc); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run serves the API on the requested endpoints until interrupted.
+func run(chainID *big.Int, paths []gethaccounts.DerivationPath, rules, http, ipc string) error {
+	var backend accounts.Backend
+	hub, err := ledger.New()
+	if err != nil {
+		return err
+	}
+	backend = hub
+	if rules != "" {
+		p, err := policy.LoadRulePolicy(rules)
+		if err != nil {
+			return err
+		}
+		p.SetWarningHandler(func(account accounts.Account, finding policy.Finding) {
+			log.Printf("Risk warning for %s: %s (%s)", account.Address.Hex(), finding.Reason, finding.Rule)
+		})
+		backend = policy.NewBackend(hub, p)
+	}
+	server, err := clef.NewServer(clef.NewAPI(backend, clef.Config{ChainID: chainID, Paths: paths}))
+	if err != nil {
+		return err
+	}
+	defer server.Stop()
+
+	if http != "" {
+		addr, err := server.ListenHTTP(http)
+		if err != nil {
+			return err
+		}
+		log.Printf("Serving clef external API on http://%s", addr)
+	}
+	if ipc != "" {
+		if err := server.ListenIPC(ipc); err != nil {
+			return err
+		}
+		defer os.Remove(ipc)
+		log.Printf("Serving clef external API on %s", ipc)
+	}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	<-sigs
+
+	return nil
+}
@@ -0,0 +1,535 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+
+	"github.com/evmos/ethereum-ledger-go/accounts"
+)
+
+// newFlags creates the flag set of a command, along with the flags common to all.
+func (env *env) newFlags(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet("ledger-eth "+name, flag.ContinueOnError)
+	flags.SetOutput(env.stderr)
+	flags.Bool("json", false, "print the result as JSON")
+	return flags
+}
+
+// parseFlags parses the command line of a command, refusing positional arguments.
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return &usageError{err}
+	}
+	if flags.NArg() > 0 {
+		return usagef("unexpected arguments: %v", flags.Args())
+	}
+	return nil
+}
+
+// deviceFlags are the flags of commands talking to a device.
+type deviceFlags struct {
+	index *int
+	path  *string
+}
+
+// addDeviceFlags adds the device selection flags to the flag set.
+func addDeviceFlags(flags *flag.FlagSet) *deviceFlags {
+	return &deviceFlags{
+		index: flags.Int("device", 0, "index of the device to use, as listed by the devices command"),
+		path:  flags.String("path", gethaccounts.DefaultBaseDerivationPath.String(), "derivation path of the account"),
+	}
+}
+
+// wallet opens the selected wallet, which the caller must close.
+func (env *env) wallet(index int) (accounts.Wallet, error) {
+	backend, err := env.backend()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errNoDevice, err)
+	}
+	wallets := backend.Wallets()
+	if index < 0 || index >= len(wallets) {
+		return nil, errNoDevice
+	}
+	wallet := wallets[index]
+	if err := wallet.Open(""); err != nil {
+		return nil, fmt.Errorf("%w: %v", errNoDevice, err)
+	}
+	return wallet, nil
+}
+
+// account opens the selected wallet and derives the selected account on it. The
+// returned wallet must be closed by the caller.
+func (env *env) account(device *deviceFlags) (accounts.Wallet, accounts.Account, error) {
+	path, err := gethaccounts.ParseDerivationPath(*device.path)
+	if err != nil {
+		return nil, accounts.Account{}, usagef("invalid path %q: %v", *device.path, err)
+	}
+	wallet, err := env.wallet(*device.index)
+	if err != nil {
+		return nil, accounts.Account{}, err
+	}
+	account, err := wallet.Derive(path, true)
+	if err != nil {
+		wallet.Close()
+		return nil, accounts.Account{}, err
+	}
+	return wallet, account, nil
+}
+
+// readInput reads the file at the path, or stdin for "-".
+func (env *env) readInput(path string) ([]byte, error) {
+	if path == "" {
+		return nil, usagef("input file not given, use -file")
+	}
+	if path == "-" {
+		return io.ReadAll(env.stdin)
+	}
+	blob, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &usageError{err}
+	}
+	return blob, nil
+}
+
+// devicesResult lists the wallets of the hub.
+type devicesResult []deviceInfo
+
+// deviceInfo describes a wallet of the hub.
+type deviceInfo struct {
+	Index  int    `json:"index"`
+	URL    string `json:"url"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (r devicesResult) text() string {
+	if len(r) == 0 {
+		return "no devices found\n"
+	}
+	var b strings.Builder
+	for _, d := range r {
+		status := d.Status
+		if d.Error != "" {
+			status = "error: " + d.Error
+		}
+		fmt.Fprintf(&b, "%d\t%s\t%s\n", d.Index, d.URL, status)
+	}
+	return b.String()
+}
+
+// runDevices lists the wallets of the hub, opening each to retrieve its status.
+func runDevices(env *env, args []string) (result, error) {
+	flags := env.newFlags("devices")
+	if err := parseFlags(flags, args); err != nil {
+		return nil, err
+	}
+	backend, err := env.backend()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errNoDevice, err)
+	}
+	res := make(devicesResult, 0)
+	for i, wallet := range backend.Wallets() {
+		info := deviceInfo{Index: i, URL: wallet.URL().String()}
+		if err := wallet.Open(""); err != nil {
+			info.Error = err.Error()
+		} else {
+			status, err := wallet.Status()
+			info.Status = status
+			if err != nil {
+				info.Error = err.Error()
+			}
+			wallet.Close()
+		}
+		res = append(res, info)
+	}
+	return res, nil
+}
+
+// addressResult is a derived account.
+type addressResult struct {
+	Address   common.Address `json:"address"`
+	Path      string         `json:"path"`
+	PublicKey hexutil.Bytes  `json:"publicKey"`
+	Verified  bool           `json:"verified"` // Whether the user confirmed it on the device
+}
+
+func (r *addressResult) text() string {
+	verified := ""
+	if r.Verified {
+		verified = " (verified on device)"
+	}
+	return fmt.Sprintf("%s\t%s%s\n", r.Address.Hex(), r.Path, verified)
+}
+
+// runAddress derives the account, optionally displaying it on the device for
+// the user to confirm.
+func runAddress(env *env, args []string) (result, error) {
+	flags := env.newFlags("address")
+	device := addDeviceFlags(flags)
+	verify := flags.Bool("verify", false, "display the address on the device and wait for confirmation")
+	if err := parseFlags(flags, args); err != nil {
+		return nil, err
+	}
+	path, err := gethaccounts.ParseDerivationPath(*device.path)
+	if err != nil {
+		return nil, usagef("invalid path %q: %v", *device.path, err)
+	}
+	wallet, err := env.wallet(*device.index)
+	if err != nil {
+		return nil, err
+	}
+	defer wallet.Close()
+
+	var account accounts.Account
+	if *verify {
+		account, err = wallet.ConfirmAddress(path)
+	} else {
+		account, err = wallet.Derive(path, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+	res := &addressResult{Address: account.Address, Path: path.String(), Verified: *verify}
+	if account.PublicKey != nil {
+		res.PublicKey = crypto.FromECDSAPub(account.PublicKey)
+	}
+	return res, nil
+}
+
+// signTxResult is a signed transaction.
+type signTxResult struct {
+	From common.Address `json:"from"`
+	Hash common.Hash    `json:"hash"`
+	Raw  hexutil.Bytes  `json:"raw"`
+}
+
+func (r *signTxResult) text() string {
+	return fmt.Sprintf("from: %s\nhash: %s\nraw:  %s\n", r.From.Hex(), r.Hash.Hex(), r.Raw)
+}
+
+// runSignTx signs a transaction given as JSON or as its unsigned RLP encoding.
+func runSignTx(env *env, args []string) (result, error) {
+	flags := env.newFlags("sign-tx")
+	device := addDeviceFlags(flags)
+	var (
+		file    = flags.String("file", "", "transaction as JSON or unsigned RLP hex, - for stdin")
+		chainID = flags.Uint64("chainid", 0, "chain ID to sign for, if not part of the transaction")
+	)
+	if err := parseFlags(flags, args); err != nil {
+		return nil, err
+	}
+	input, err := env.readInput(*file)
+	if err != nil {
+		return nil, err
+	}
+	var chain *big.Int
+	if *chainID != 0 {
+		chain = new(big.Int).SetUint64(*chainID)
+	}
+	tx, chain, err := parseTx(input, chain)
+	if err != nil {
+		return nil, &usageError{err}
+	}
+	wallet, account, err := env.account(device)
+	if err != nil {
+		return nil, err
+	}
+	defer wallet.Close()
+
+	raw, err := wallet.SignTx(account, tx, chain)
+	if err != nil {
+		return nil, err
+	}
+	signed := new(coretypes.Transaction)
+	if err := signed.UnmarshalBinary(raw); err != nil {
+		return nil, err
+	}
+	return &signTxResult{From: account.Address, Hash: signed.Hash(), Raw: raw}, nil
+}
+
+// signatureResult is a signed message or typed data.
+type signatureResult struct {
+	From      common.Address `json:"from"`
+	Hash      common.Hash    `json:"hash"`
+	Signature hexutil.Bytes  `json:"signature"`
+}
+
+func (r *signatureResult) text() string {
+	return fmt.Sprintf("from:      %s\nhash:      %s\nsignature: %s\n", r.From.Hex(), r.Hash.Hex(), r.Signature)
+}
+
+// runSignTyped signs EIP-712 typed data given as JSON.
+func runSignTyped(env *env, args []string) (result, error) {
+	flags := env.newFlags("sign-typed")
+	device := addDeviceFlags(flags)
+	file := flags.String("file", "", "EIP-712 typed data as JSON, - for stdin")
+	if err := parseFlags(flags, args); err != nil {
+		return nil, err
+	}
+	typedData, hash, err := env.readTypedData(*file)
+	if err != nil {
+		return nil, err
+	}
+	wallet, account, err := env.account(device)
+	if err != nil {
+		return nil, err
+	}
+	defer wallet.Close()
+
+	sig, err := wallet.SignTypedData(account, *typedData)
+	if err != nil {
+		return nil, err
+	}
+	return &signatureResult{From: account.Address, Hash: hash, Signature: sig}, nil
+}
+
+// runSignMessage signs an EIP-191 personal message.
+func runSignMessage(env *env, args []string) (result, error) {
+	flags := env.newFlags("sign-message")
+	device := addDeviceFlags(flags)
+	var (
+		text = flags.String("message", "", "message to sign as text")
+		data = flags.String("hex", "", "message to sign as hex")
+	)
+	if err := parseFlags(flags, args); err != nil {
+		return nil, err
+	}
+	msg, err := message(*text, *data)
+	if err != nil {
+		return nil, err
+	}
+	wallet, account, err := env.account(device)
+	if err != nil {
+		return nil, err
+	}
+	defer wallet.Close()
+
+	sig, err := wallet.SignText(account, msg)
+	if err != nil {
+		return nil, err
+	}
+	return &signatureResult{From: account.Address, Hash: common.BytesToHash(gethaccounts.TextHash(msg)), Signature: sig}, nil
+}
+
+// verifyResult is a verified signature.
+type verifyResult struct {
+	Address common.Address `json:"address"`
+	Hash    common.Hash    `json:"hash"`
+	Valid   bool           `json:"valid"`
+}
+
+func (r *verifyResult) text() string {
+	return fmt.Sprintf("valid signature by %s\n", r.Address.Hex())
+}
+
+// runVerify verifies a signature of a personal message or typed data against
+// the expected address, without needing a device.
+func runVerify(env *env, args []string) (result, error) {
+	flags := env.newFlags("verify")
+	var (
+		address   = flags.String("address", "", "address expected to have signed")
+		signature = flags.String("signature", "", "65 byte signature as hex, V being 0/1 or 27/28")
+		text      = flags.String("message", "", "personal message signed, as text")
+		data      = flags.String("hex", "", "personal message signed, as hex")
+		typed     = flags.String("typed", "", "EIP-712 typed data signed, as JSON file")
+	)
+	if err := parseFlags(flags, args); err != nil {
+		return nil, err
+	}
+	if !common.IsHexAddress(*address) {
+		return nil, usagef("invalid address %q", *address)
+	}
+	sig, err := hexutil.Decode(*signature)
+	if err != nil || len(sig) != crypto.SignatureLength {
+		return nil, usagef("invalid signature %q", *signature)
+	}
+	var hash common.Hash
+	if *typed != "" {
+		if *text != "" || *data != "" {
+			return nil, usagef("only one of -message, -hex and -typed can be given")
+		}
+		if _, hash, err = env.readTypedData(*typed); err != nil {
+			return nil, err
+		}
+	} else {
+		msg, err := message(*text, *data)
+		if err != nil {
+			return nil, err
+		}
+		hash = common.BytesToHash(gethaccounts.TextHash(msg))
+	}
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	pubkey, err := crypto.SigToPub(hash[:], sig)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidSignature, err)
+	}
+	expected := common.HexToAddress(*address)
+	if recovered := crypto.PubkeyToAddress(*pubkey); recovered != expected {
+		return nil, fmt.Errorf("%w: signed by %s", errInvalidSignature, recovered.Hex())
+	}
+	return &verifyResult{Address: expected, Hash: hash, Valid: true}, nil
+}
+
+// readTypedData reads and hashes the EIP-712 typed data in the file.
+func (env *env) readTypedData(file string) (*apitypes.TypedData, common.Hash, error) {
+	input, err := env.readInput(file)
+	if err != nil {
+		return nil, common.Hash{}, err
+	}
+	typedData := new(apitypes.TypedData)
+	if err := json.Unmarshal(input, typedData); err != nil {
+		return nil, common.Hash{}, usagef("invalid typed data: %v", err)
+	}
+	hash, _, err := apitypes.TypedDataAndHash(*typedData)
+	if err != nil {
+		return nil, common.Hash{}, usagef("invalid typed data: %v", err)
+	}
+	return typedData, common.BytesToHash(hash), nil
+}
+
+// message returns the personal message given either as text or as hex.
+func message(text, data string) ([]byte, error) {
+	switch {
+	case text != "" && data != "":
+		return nil, usagef("only one of -message and -hex can be given")
+	case text != "":
+		return []byte(text), nil
+	case data != "":
+		msg, err := hexutil.Decode(data)
+		if err != nil {
+			return nil, usagef("invalid hex message: %v", err)
+		}
+		return msg, nil
+	default:
+		return nil, usagef("message not given, use -message or -hex")
+	}
+}
+
+// parseTx decodes a transaction given either as JSON (the eth_signTransaction
+// arguments) or as the hex of its unsigned RLP encoding, returning it along with
+// the chain ID to sign for.
+func parseTx(input []byte, chainID *big.Int) (*coretypes.Transaction, *big.Int, error) {
+	input = bytes.TrimSpace(input)
+	if len(input) > 0 && input[0] == '{' {
+		var args apitypes.SendTxArgs
+		if err := json.Unmarshal(input, &args); err != nil {
+			return nil, nil, fmt.Errorf("invalid transaction: %v", err)
+		}
+		if args.ChainID != nil {
+			if chainID != nil && chainID.Cmp((*big.Int)(args.ChainID)) != 0 {
+				return nil, nil, fmt.Errorf("chain ID %d differs from the transaction's %d", chainID, args.ChainID.ToInt())
+			}
+			chainID = args.ChainID.ToInt()
+		}
+		if chainID == nil {
+			return nil, nil, errors.New("chain ID not given, use -chainid")
+		}
+		args.ChainID = (*hexutil.Big)(chainID)
+
+		// Conversion leaves the fees unchecked, catch them before they panic
+		switch {
+		case args.MaxFeePerGas != nil || args.MaxPriorityFeePerGas != nil:
+			if args.MaxFeePerGas == nil || args.MaxPriorityFeePerGas == nil {
+				return nil, nil, errors.New("both maxFeePerGas and maxPriorityFeePerGas must be given")
+			}
+			if args.BlobHashes != nil && args.BlobFeeCap == nil {
+				return nil, nil, errors.New("maxFeePerBlobGas must be given for blob transactions")
+			}
+		case args.BlobHashes != nil:
+			return nil, nil, errors.New("maxFeePerGas and maxPriorityFeePerGas must be given for blob transactions")
+		case args.GasPrice == nil:
+			return nil, nil, errors.New("either gasPrice or maxFeePerGas and maxPriorityFeePerGas must be given")
+		}
+		tx, err := args.ToTransaction()
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid transaction: %v", err)
+		}
+		return tx, chainID, nil
+	}
+	blob, err := hexutil.Decode(string(input))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid transaction hex: %v", err)
+	}
+	tx, txChainID, err := decodeUnsignedTx(blob)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid unsigned transaction: %v", err)
+	}
+	if txChainID != nil {
+		if chainID != nil && chainID.Cmp(txChainID) != 0 {
+			return nil, nil, fmt.Errorf("chain ID %d differs from the transaction's %d", chainID, txChainID)
+		}
+		chainID = txChainID
+	}
+	if chainID == nil {
+		return nil, nil, errors.New("chain ID not given, use -chainid")
+	}
+	return tx, chainID, nil
+}
+
+// decodeUnsignedTx decodes the unsigned RLP encoding of a transaction, as hashed
+// for signing. Legacy transactions carry the chain ID only if EIP-155 encoded.
+func decodeUnsignedTx(blob []byte) (*coretypes.Transaction, *big.Int, error) {
+	if len(blob) == 0 {
+		return nil, nil, errors.New("empty input")
+	}
+	// Typed transactions lack the trailing signature values, append zero ones
+	if blob[0] < 0x7f {
+		var fields []rlp.RawValue
+		if err := rlp.DecodeBytes(blob[1:], &fields); err != nil {
+			return nil, nil, err
+		}
+		payload, err := rlp.EncodeToBytes(append(fields, rlp.EmptyString, rlp.EmptyString, rlp.EmptyString))
+		if err != nil {
+			return nil, nil, err
+		}
+		tx := new(coretypes.Transaction)
+		if err := tx.UnmarshalBinary(append([]byte{blob[0]}, payload...)); err != nil {
+			return nil, nil, err
+		}
+		return tx, tx.ChainId(), nil
+	}
+	var fields []rlp.RawValue
+	if err := rlp.DecodeBytes(blob, &fields); err != nil {
+		return nil, nil, err
+	}
+	var chainID *big.Int
+	switch len(fields) {
+	case 6:
+	case 9:
+		chainID = new(big.Int)
+		if err := rlp.DecodeBytes(fields[6], chainID); err != nil {
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, fmt.Errorf("legacy transaction with %d fields", len(fields))
+	}
+	payload, err := rlp.EncodeToBytes(append(fields[:6:6], rlp.EmptyString, rlp.EmptyString, rlp.EmptyString))
+	if err != nil {
+		return nil, nil, err
+	}
+	tx := new(coretypes.Transaction)
+	if err := tx.UnmarshalBinary(payload); err != nil {
+		return nil, nil, err
+	}
+	return tx, chainID, nil
+}
@@ -0,0 +1,187 @@
+// Command ledger-eth is a command line interface to Ledger devices running the
+// Ethereum app:
+//
+//	ledger-eth devices
+//	ledger-eth address [-path m/44'/60'/0'/0/0] [-verify]
+//	ledger-eth sign-tx [-path ...] [-chainid 1] -file tx.json|tx.rlp
+//	ledger-eth sign-typed [-path ...] -file typed.json
+//	ledger-eth sign-message [-path ...] -message "hello" | -hex 0x68656c6c6f
+//	ledger-eth verify -address 0x... -signature 0x... -message ... | -hex ... | -typed file
+//
+// Every command accepts -json to print its result (or failure) as JSON for
+// scripting. The exit code tells the failures apart, see the exit* constants.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+
+	ledger "github.com/evmos/ethereum-ledger-go"
+	"github.com/evmos/ethereum-ledger-go/accounts"
+	"github.com/evmos/ethereum-ledger-go/usbwallet"
+)
+
+// Exit codes of the command, mapped from the typed device errors.
+const (
+	exitOK               = 0 // Command succeeded
+	exitFailure          = 1 // Unclassified failure
+	exitUsage            = 2 // Invalid command line or input
+	exitNoDevice         = 3 // No Ledger connected, or it can't be opened
+	exitRejected         = 4 // User denied the request on the device
+	exitLocked           = 5 // Device locked with its PIN
+	exitWrongApp         = 6 // Ethereum app not open on the device
+	exitNotSupported     = 7 // Request not supported by the app (version)
+	exitInvalidData      = 8 // Device refused the request payload
+	exitInvalidSignature = 9 // Verified signature doesn't match
+)
+
+var (
+	// errNoDevice is returned if no Ledger wallet is available.
+	errNoDevice = errors.New("no Ledger device found")
+
+	// errInvalidSignature is returned if a verified signature doesn't match.
+	errInvalidSignature = errors.New("signature does not match the address")
+)
+
+// usageError is returned for invalid command lines or inputs.
+type usageError struct{ err error }
+
+func (e *usageError) Error() string { return e.err.Error() }
+func (e *usageError) Unwrap() error { return e.err }
+
+// usagef creates a usage error with the formatted message.
+func usagef(format string, args ...interface{}) error {
+	return &usageError{fmt.Errorf(format, args...)}
+}
+
+// exitCode maps an error to the exit code of the command.
+func exitCode(err error) int {
+	var usage *usageError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &usage):
+		return exitUsage
+	case errors.Is(err, errNoDevice):
+		return exitNoDevice
+	case errors.Is(err, usbwallet.ErrLedgerUserRejected):
+		return exitRejected
+	case errors.Is(err, usbwallet.ErrLedgerLocked):
+		return exitLocked
+	case errors.Is(err, usbwallet.ErrLedgerClassNotSupported):
+		return exitWrongApp
+	case errors.Is(err, usbwallet.ErrLedgerInstructionNotSupported), errors.Is(err, gethaccounts.ErrNotSupported):
+		return exitNotSupported
+	case errors.Is(err, usbwallet.ErrLedgerInvalidData):
+		return exitInvalidData
+	case errors.Is(err, errInvalidSignature):
+		return exitInvalidSignature
+	default:
+		return exitFailure
+	}
+}
+
+// command is a subcommand of the CLI.
+type command struct {
+	name  string
+	usage string
+	run   func(env *env, args []string) (result, error)
+}
+
+// result is the outcome of a command, printed as JSON or text.
+type result interface {
+	text() string
+}
+
+// env is the environment commands run in.
+type env struct {
+	backend func() (accounts.Backend, error) // Lazily opened, not needed by all commands
+	stdin   io.Reader
+	stderr  io.Writer // Set by run, for the flag usages
+}
+
+var commands = []*command{
+	{"devices", "list the connected Ledger devices and their status", runDevices},
+	{"address", "derive an address, optionally verifying it on the device", runAddress},
+	{"sign-tx", "sign a transaction given as JSON or unsigned RLP", runSignTx},
+	{"sign-typed", "sign EIP-712 typed data given as JSON", runSignTyped},
+	{"sign-message", "sign an EIP-191 personal message", runSignMessage},
+	{"verify", "verify a signature against an address", runVerify},
+}
+
+func main() {
+	env := &env{
+		backend: func() (accounts.Backend, error) { return ledger.New() },
+		stdin:   os.Stdin,
+	}
+	os.Exit(run(env, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command line, printing the result to stdout and failures to
+// stderr (or stdout with -json), returning the exit code.
+func run(env *env, args []string, stdout, stderr io.Writer) int {
+	env.stderr = stderr
+
+	if len(args) == 0 {
+		printUsage(stderr)
+		return exitUsage
+	}
+	var cmd *command
+	for _, c := range commands {
+		if c.name == args[0] {
+			cmd = c
+		}
+	}
+	if cmd == nil {
+		if args[0] != "help" && args[0] != "-h" && args[0] != "--help" {
+			fmt.Fprintf(stderr, "unknown command %q\n", args[0])
+		}
+		printUsage(stderr)
+		return exitUsage
+	}
+	// Look for -json upfront, so even flag parsing failures are reported as JSON
+	asJSON := false
+	for _, arg := range args[1:] {
+		if arg == "-json" || arg == "--json" {
+			asJSON = true
+		}
+	}
+	res, err := cmd.run(env, args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		if asJSON {
+			json.NewEncoder(stdout).Encode(map[string]interface{}{"error": err.Error(), "code": exitCode(err)})
+		} else {
+			fmt.Fprintf(stderr, "ledger-eth %s: %v\n", cmd.name, err)
+		}
+		return exitCode(err)
+	}
+	if asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(res)
+	} else {
+		fmt.Fprint(stdout, res.text())
+	}
+	return exitOK
+}
+
+// printUsage lists the available commands.
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: ledger-eth <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-14s %s\n", c.name, c.usage)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "run ledger-eth <command> -h for the flags of a command")
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evmos/ethereum-ledger-go/accounts"
+	"github.com/evmos/ethereum-ledger-go/internal/testutil"
+	"github.com/evmos/ethereum-ledger-go/usbwallet/simulator"
+)
+
+const testTyped = `{
+	"types": {
+		"EIP712Domain": [{"name": "name", "type": "string"}, {"name": "chainId", "type": "uint256"}],
+		"Mail": [{"name": "contents", "type": "string"}]
+	},
+	"primaryType": "Mail",
+	"domain": {"name": "Ether Mail", "chainId": "1"},
+	"message": {"contents": "Hello, Bob!"}
+}`
+
+var testRecipient = common.HexToAddress("0x3535353535353535353535353535353535353535")
+
+// testEnv creates an environment on a simulated device, returning the device
+// to steer it.
+func testEnv() (*env, *simulator.Device) {
+	hub, device := testutil.SimulatedHub()
+	return &env{
+		backend: func() (accounts.Backend, error) { return hub, nil },
+		stdin:   strings.NewReader(""),
+	}, device
+}
+
+// runJSON runs the command line with -json, decoding its output.
+func runJSON(t *testing.T, env *env, out interface{}, args ...string) int {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	code := run(env, append(args, "-json"), &stdout, &stderr)
+	if out != nil {
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), out), stdout.String())
+	}
+	return code
+}
+
+// writeFile writes the content into a file of the test's temporary directory.
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestDevices(t *testing.T) {
+	env, _ := testEnv()
+
+	var devices []deviceInfo
+	require.Equal(t, exitOK, runJSON(t, env, &devices, "devices"))
+	require.Len(t, devices, 1)
+	require.Empty(t, devices[0].Error)
+	require.NotEmpty(t, devices[0].URL)
+
+	// No device at the index
+	var failure struct {
+		Error string `json:"error"`
+		Code  int    `json:"code"`
+	}
+	require.Equal(t, exitNoDevice, runJSON(t, env, &failure, "address", "-device", "1"))
+	require.Equal(t, exitNoDevice, failure.Code)
+}
+
+func TestAddress(t *testing.T) {
+	env, device := testEnv()
+
+	var res addressResult
+	require.Equal(t, exitOK, runJSON(t, env, &res, "address"))
+	require.Equal(t, testutil.Address, res.Address)
+	require.False(t, res.Verified)
+	require.Len(t, res.PublicKey, 65)
+
+	require.Equal(t, exitOK, runJSON(t, env, &res, "address", "-path", "m/44'/60'/0'/0/1", "-verify"))
+	path, err := gethaccounts.ParseDerivationPath("m/44'/60'/0'/0/1")
+	require.NoError(t, err)
+	expected, err := device.Address(path)
+	require.NoError(t, err)
+	require.Equal(t, expected, res.Address)
+	require.True(t, res.Verified)
+
+	// Denying the address on the device is told apart by the exit code
+	device.SetRejecting(true)
+	require.Equal(t, exitRejected, runJSON(t, env, nil, "address", "-verify"))
+
+	// Text output is the address and its path
+	device.SetRejecting(false)
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, exitOK, run(env, []string{"address"}, &stdout, &stderr))
+	require.Equal(t, testutil.Address.Hex()+"\tm/44'/60'/0'/0/0\n", stdout.String())
+
+	require.Equal(t, exitUsage, run(env, []string{"address", "-path", "m/x"}, &stdout, &stderr))
+	require.Equal(t, exitUsage, run(env, []string{"nosuchcommand"}, &stdout, &stderr))
+}
+
+func TestSignTx(t *testing.T) {
+	env, _ := testEnv()
+
+	// Transactions given as JSON
+	var res signTxResult
+	file := writeFile(t, "tx.json", `{
+		"from": "`+testutil.Address.Hex()+`",
+		"to": "`+testRecipient.Hex()+`",
+		"gas": "0x5208",
+		"maxFeePerGas": "0x3b9aca00",
+		"maxPriorityFeePerGas": "0x3b9aca00",
+		"value": "0x1",
+		"nonce": "0x2",
+		"chainId": "0x1"
+	}`)
+	require.Equal(t, exitOK, runJSON(t, env, &res, "sign-tx", "-file", file))
+	tx := decodeSigned(t, res.Raw, big.NewInt(1))
+	require.Equal(t, uint8(coretypes.DynamicFeeTxType), tx.Type())
+	require.Equal(t, uint64(2), tx.Nonce())
+	require.Equal(t, tx.Hash(), res.Hash)
+
+	// Unsigned RLP of typed transactions, as hashed for signing
+	payload, err := rlp.EncodeToBytes([]interface{}{big.NewInt(5), uint64(7), big.NewInt(1e9), uint64(21000), testRecipient, big.NewInt(1), []byte{}, coretypes.AccessList{}})
+	require.NoError(t, err)
+	file = writeFile(t, "tx.rlp", hexutil.Encode(append([]byte{coretypes.AccessListTxType}, payload...)))
+	require.Equal(t, exitOK, runJSON(t, env, &res, "sign-tx", "-file", file))
+	tx = decodeSigned(t, res.Raw, big.NewInt(5))
+	require.Equal(t, uint8(coretypes.AccessListTxType), tx.Type())
+	require.Equal(t, uint64(7), tx.Nonce())
+
+	// Unsigned RLP of legacy EIP-155 transactions, as hashed for signing
+	legacy, err := rlp.EncodeToBytes([]interface{}{uint64(3), big.NewInt(1e9), uint64(21000), testRecipient, big.NewInt(1), []byte{}, big.NewInt(10), uint(0), uint(0)})
+	require.NoError(t, err)
+	env.stdin = strings.NewReader(hexutil.Encode(legacy))
+	require.Equal(t, exitOK, runJSON(t, env, &res, "sign-tx", "-file", "-"))
+	tx = decodeSigned(t, res.Raw, big.NewInt(10))
+	require.Equal(t, uint64(3), tx.Nonce())
+
+	// Conflicting chain IDs and missing fees are refused
+	require.Equal(t, exitUsage, runJSON(t, env, nil, "sign-tx", "-file", file, "-chainid", "1"))
+	file = writeFile(t, "nofee.json", `{"to": "`+testRecipient.Hex()+`", "gas": "0x5208", "chainId": "0x1"}`)
+	require.Equal(t, exitUsage, runJSON(t, env, nil, "sign-tx", "-file", file))
+}
+
+// decodeSigned decodes the signed transaction, checking it's signed by the test
+// account.
+func decodeSigned(t *testing.T, raw []byte, chainID *big.Int) *coretypes.Transaction {
+	t.Helper()
+
+	tx := new(coretypes.Transaction)
+	require.NoError(t, tx.UnmarshalBinary(raw))
+	from, err := coretypes.Sender(coretypes.LatestSignerForChainID(chainID), tx)
+	require.NoError(t, err)
+	require.Equal(t, testutil.Address, from)
+	return tx
+}
+
+func TestSignAndVerify(t *testing.T) {
+	env, device := testEnv()
+
+	var res signatureResult
+	require.Equal(t, exitOK, runJSON(t, env, &res, "sign-message", "-message", "hello"))
+	require.Equal(t, testutil.Address, res.From)
+
+	var verified verifyResult
+	require.Equal(t, exitOK, runJSON(t, env, &verified, "verify", "-address", testutil.Address.Hex(), "-signature", res.Signature.String(), "-hex", "0x68656c6c6f"))
+	require.True(t, verified.Valid)
+	require.Equal(t, exitInvalidSignature, runJSON(t, env, nil, "verify", "-address", testRecipient.Hex(), "-signature", res.Signature.String(), "-message", "hello"))
+	require.Equal(t, exitInvalidSignature, runJSON(t, env, nil, "verify", "-address", testutil.Address.Hex(), "-signature", res.Signature.String(), "-message", "hullo"))
+
+	file := writeFile(t, "typed.json", testTyped)
+	require.Equal(t, exitOK, runJSON(t, env, &res, "sign-typed", "-file", file))
+	require.Equal(t, exitOK, runJSON(t, env, &verified, "verify", "-address", testutil.Address.Hex(), "-signature", res.Signature.String(), "-typed", file))
+	require.Equal(t, res.Hash, verified.Hash)
+
+	device.SetRejecting(true)
+	require.Equal(t, exitRejected, runJSON(t, env, nil, "sign-message", "-message", "hello"))
+	require.Equal(t, exitUsage, runJSON(t, env, nil, "sign-message"))
+}
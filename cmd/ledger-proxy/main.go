@@ -0,0 +1,79 @@
+// Command ledger-proxy runs a JSON-RPC proxy in front of a node, signing the
+// transactions and messages of dapps and scripts with connected Ledger devices:
+//
+//	ledger-proxy -upstream http://localhost:8545 -http localhost:8555
+//
+// Unmodified tooling (e.g. Hardhat scripts) pointed at the proxy sees the Ledger
+// accounts as if the node managed them.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	ledger "github.com/evmos/ethereum-ledger-go"
+	"github.com/evmos/ethereum-ledger-go/accounts"
+	"github.com/evmos/ethereum-ledger-go/internal/flags"
+	"github.com/evmos/ethereum-ledger-go/policy"
+	"github.com/evmos/ethereum-ledger-go/proxy"
+)
+
+func main() {
+	var (
+		paths    flags.Paths
+		upstream = flag.String("upstream", "", "RPC endpoint of the node requests are forwarded to")
+		addr     = flag.String("http", "localhost:8555", "HTTP endpoint to listen on")
+		rules    = flag.String("policy", "", "JSON rule file requests are checked against before reaching the Ledger")
+		origins  = flag.String("corsdomain", "", "comma separated origins browsers may send requests from (\"*\" for any)")
+		hosts    = flag.String("vhosts", "localhost", "comma separated host names requests may be addressed to (\"*\" for any)")
+	)
+	flag.Var(&paths, "path", "derivation path of an account to sign with, repeatable (default m/44'/60'/0'/0/0)")
+	flag.Parse()
+
+	if *upstream == "" {
+		fmt.Fprintln(os.Stderr, "-upstream is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+	var backend accounts.Backend
+	hub, err := ledger.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	backend = hub
+	if *rules != "" {
+		rp, err := policy.LoadRulePolicy(*rules)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rp.SetWarningHandler(func(account accounts.Account, finding policy.Finding) {
+			log.Printf("Risk warning for %s: %s (%s)", account.Address.Hex(), finding.Reason, finding.Rule)
+		})
+		backend = policy.NewBackend(hub, rp)
+	}
+	p, err := proxy.Dial(context.Background(), backend, *upstream, paths)
+	if err != nil {
+		log.Fatal(err)
+	}
+	p.SetAllowedOrigins(splitList(*origins))
+	p.SetAllowedHosts(splitList(*hosts))
+
+	log.Printf("Proxying http://%s to %s", *addr, *upstream)
+	log.Fatal(http.ListenAndServe(*addr, p))
+}
+
+// splitList splits a comma separated flag value, dropping empty entries.
+func splitList(value string) []string {
+	var list []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
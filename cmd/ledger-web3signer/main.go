@@ -0,0 +1,92 @@
+// Command ledger-web3signer serves the accounts of connected Ledger devices
+// through the eth1 JSON-RPC API of Consensys Web3Signer:
+//
+//	ledger-web3signer -config accounts.json -http localhost:9000
+//
+// The accounts served, along with their expected addresses, are read from the
+// configuration file (see web3signer.LoadConfig), or derived at the paths given
+// with -path flags.
+package main
+
+import (
+	"errors"
+	"flag"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+
+	ledger "github.com/evmos/ethereum-ledger-go"
+	"github.com/evmos/ethereum-ledger-go/accounts"
+	"github.com/evmos/ethereum-ledger-go/internal/flags"
+	"github.com/evmos/ethereum-ledger-go/policy"
+	"github.com/evmos/ethereum-ledger-go/web3signer"
+)
+
+func main() {
+	var (
+		paths   flags.Paths
+		config  = flag.String("config", "", "JSON file configuring the chain and accounts served")
+		chainID = flag.Uint64("chainid", 0, "chain ID transactions are signed for, overriding the configuration")
+		addr    = flag.String("http", "localhost:9000", "HTTP endpoint to listen on")
+		rules   = flag.String("policy", "", "JSON rule file requests are checked against before reaching the Ledger")
+	)
+	flag.Var(&paths, "path", "derivation path of an account to serve, repeatable (default m/44'/60'/0'/0/0)")
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	if err := run(logger, *config, *chainID, paths, *rules, *addr); err != nil {
+		logger.Error("Signer failed", "err", err)
+		os.Exit(1)
+	}
+}
+
+// run serves the API on the requested endpoint until failing.
+func run(logger *slog.Logger, file string, chainID uint64, paths []gethaccounts.DerivationPath, rules, addr string) error {
+	config := new(web3signer.Config)
+	if file != "" {
+		var err error
+		if config, err = web3signer.LoadConfig(file); err != nil {
+			return err
+		}
+	}
+	for _, path := range paths {
+		config.Accounts = append(config.Accounts, web3signer.AccountConfig{Path: path.String()})
+	}
+	if chainID != 0 {
+		config.ChainID = new(big.Int).SetUint64(chainID)
+	}
+	if config.ChainID == nil {
+		return errors.New("chain ID not configured, use -chainid or a configuration file")
+	}
+	config.Logger = logger
+
+	var backend accounts.Backend
+	hub, err := ledger.New()
+	if err != nil {
+		return err
+	}
+	backend = hub
+	if rules != "" {
+		p, err := policy.LoadRulePolicy(rules)
+		if err != nil {
+			return err
+		}
+		p.SetWarningHandler(func(account accounts.Account, finding policy.Finding) {
+			logger.Warn("Risky signing request", "account", account.Address, "rule", finding.Rule, "reason", finding.Reason)
+		})
+		backend = policy.NewBackend(hub, p)
+	}
+	api, err := web3signer.NewAPI(backend, config)
+	if err != nil {
+		return err
+	}
+	handler, err := web3signer.NewHandler(api)
+	if err != nil {
+		return err
+	}
+	logger.Info("Serving Web3Signer eth1 API", "endpoint", "http://"+addr, "chainid", config.ChainID)
+	return http.ListenAndServe(addr, handler)
+}
@@ -0,0 +1,184 @@
+// Package cosmos adapts Ledger Ethereum wallets to the Cosmos SDK keyring, which
+// drives hardware wallets through its crypto/ledger.SECP256K1 interface.
+package cosmos
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+
+	ledger "github.com/evmos/ethereum-ledger-go"
+	"github.com/evmos/ethereum-ledger-go/accounts"
+	"github.com/evmos/ethereum-ledger-go/ethermint"
+)
+
+// DefaultHRP is the bech32 human readable part used if none is requested.
+const DefaultHRP = "evmos"
+
+// ErrUnsupportedSignMode is returned when signing anything but Amino JSON sign
+// docs, the only ones the Ethereum app can display via EIP-712.
+var ErrUnsupportedSignMode = errors.New("cosmos: only Amino JSON sign docs are supported")
+
+// SECP256K1 mirrors the Cosmos SDK crypto/ledger.SECP256K1 interface, used by
+// the keyring to derive keys and sign on Ledger devices.
+type SECP256K1 interface {
+	Close() error
+	// GetPublicKeySECP256K1 returns the public key
+	GetPublicKeySECP256K1([]uint32) ([]byte, error)
+	// GetAddressPubKeySECP256K1 returns the compressed public key and the bech32 address
+	GetAddressPubKeySECP256K1([]uint32, string) ([]byte, string, error)
+	// SignSECP256K1 signs a message (requiring confirmation on the device)
+	SignSECP256K1([]uint32, []byte, byte) ([]byte, error)
+}
+
+// TypedDataBuilder converts the Amino JSON sign doc bytes of a Cosmos transaction
+// into the EIP-712 typed data signed by the Ledger in its place. The signer is
+// the bech32 address of the signing account.
+type TypedDataBuilder func(signDoc []byte, signer string) (apitypes.TypedData, error)
+
+// Adapter implements the Cosmos SDK SECP256K1 device interface on top of a Ledger
+// Ethereum wallet, signing sign docs as EIP-712 typed data.
+type Adapter struct {
+	wallet accounts.Wallet
+
+	hrp     string           // Default bech32 human readable part
+	builder TypedDataBuilder // Converter of sign docs into EIP-712 typed data (nil = Ethermint)
+	lock    sync.RWMutex
+}
+
+// NewAdapter creates a Cosmos SDK device adapter on top of an already opened
+// wallet, deriving addresses with the default human readable part.
+func NewAdapter(wallet accounts.Wallet) *Adapter {
+	return &Adapter{
+		wallet: wallet,
+		hrp:    DefaultHRP,
+	}
+}
+
+// Discover finds the first Ledger running the Ethereum app and opens it, making
+// it usable as the Cosmos SDK ledger discovery function:
+//
+//	ledger.SetDiscoverLedger(func() (ledger.SECP256K1, error) { return cosmos.Discover() })
+func Discover() (*Adapter, error) {
+	hub, err := ledger.New()
+	if err != nil {
+		return nil, err
+	}
+	wallets := hub.Wallets()
+	if len(wallets) == 0 {
+		return nil, errors.New("cosmos: no Ledger device found")
+	}
+	if err := wallets[0].Open(""); err != nil {
+		return nil, err
+	}
+	return NewAdapter(wallets[0]), nil
+}
+
+// SetHRP configures the bech32 human readable part used if the keyring doesn't
+// request one explicitly.
+func (a *Adapter) SetHRP(hrp string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.hrp = hrp
+}
+
+// SetTypedDataBuilder configures the converter of sign docs into the EIP-712
+// typed data presented to the Ledger. By default, sign docs are wrapped into
+// Ethermint's schema with the signer paying the fees.
+func (a *Adapter) SetTypedDataBuilder(builder TypedDataBuilder) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.builder = builder
+}
+
+// Close implements SECP256K1, releasing the underlying wallet.
+func (a *Adapter) Close() error {
+	return a.wallet.Close()
+}
+
+// GetPublicKeySECP256K1 implements SECP256K1, returning the compressed 33 byte
+// public key of the account at the derivation path.
+func (a *Adapter) GetPublicKeySECP256K1(path []uint32) ([]byte, error) {
+	account, err := a.derive(path)
+	if err != nil {
+		return nil, err
+	}
+	return account.CompressedPublicKey()
+}
+
+// GetAddressPubKeySECP256K1 implements SECP256K1, returning the compressed 33 byte
+// public key and the bech32 address of the account at the derivation path. If no
+// human readable part is given, the configured one is used.
+func (a *Adapter) GetAddressPubKeySECP256K1(path []uint32, hrp string) ([]byte, string, error) {
+	if hrp == "" {
+		a.lock.RLock()
+		hrp = a.hrp
+		a.lock.RUnlock()
+	}
+	account, err := a.derive(path)
+	if err != nil {
+		return nil, "", err
+	}
+	address, err := account.Bech32Address(hrp)
+	if err != nil {
+		return nil, "", err
+	}
+	pubkey, err := account.CompressedPublicKey()
+	if err != nil {
+		return nil, "", err
+	}
+	return pubkey, address, nil
+}
+
+// SignSECP256K1 implements SECP256K1, signing the Amino JSON sign doc with the
+// account at the derivation path. If the open app can't sign sign docs natively,
+// it is converted into EIP-712 typed data and signed as such. The sign mode p2
+// must be 0 (Amino JSON), textual sign docs are not supported.
+func (a *Adapter) SignSECP256K1(path []uint32, signDoc []byte, p2 byte) ([]byte, error) {
+	if p2 != 0 {
+		return nil, ErrUnsupportedSignMode
+	}
+	a.lock.RLock()
+	hrp, builder := a.hrp, a.builder
+	a.lock.RUnlock()
+
+	account, err := a.derive(path)
+	if err != nil {
+		return nil, err
+	}
+	// Apps signing sign docs natively (e.g. the Cosmos app) don't need EIP-712
+	sig, err := a.wallet.SignData(account, accounts.MimetypeAminoJSON, signDoc)
+	if !errors.Is(err, gethaccounts.ErrNotSupported) {
+		return sig, err
+	}
+	if builder == nil {
+		builder = ethermint.WrapSignDoc
+	}
+	signer, err := account.Bech32Address(hrp)
+	if err != nil {
+		return nil, err
+	}
+	typedData, err := builder(signDoc, signer)
+	if err != nil {
+		return nil, fmt.Errorf("cosmos: failed to build typed data: %w", err)
+	}
+	return a.wallet.SignTypedData(account, typedData)
+}
+
+// derive retrieves the account at the Cosmos SDK derivation path, pinning it so
+// the wallet can sign with it afterwards.
+func (a *Adapter) derive(path []uint32) (accounts.Account, error) {
+	if len(path) != 5 {
+		return accounts.Account{}, fmt.Errorf("cosmos: invalid derivation path length %d, want 5", len(path))
+	}
+	// The wallet hardens the path in place, don't modify the keyring's copy
+	hdPath := make(gethaccounts.DerivationPath, len(path))
+	copy(hdPath, path)
+
+	return a.wallet.Derive(hdPath, true)
+}
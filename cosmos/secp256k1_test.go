@@ -0,0 +1,141 @@
+package cosmos
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evmos/ethereum-ledger-go/accounts"
+	"github.com/evmos/ethereum-ledger-go/internal/bech32"
+)
+
+// fakeWallet is a software wallet deriving every path to the same key, recording
+// the requests it receives.
+type fakeWallet struct {
+	accounts.Wallet
+
+	key     *ecdsa.PrivateKey
+	paths   []gethaccounts.DerivationPath
+	signed  []apitypes.TypedData
+	closed  bool
+	native  bool // Whether sign docs are signed natively
+	signErr error
+}
+
+func (w *fakeWallet) Derive(path gethaccounts.DerivationPath, pin bool) (accounts.Account, error) {
+	path[0] |= 0x80000000 // Mimic the in-place hardening of the usb wallet
+	w.paths = append(w.paths, path)
+	return accounts.Account{Address: crypto.PubkeyToAddress(w.key.PublicKey), PublicKey: &w.key.PublicKey}, nil
+}
+
+func (w *fakeWallet) SignTypedData(account accounts.Account, typedData apitypes.TypedData) ([]byte, error) {
+	w.signed = append(w.signed, typedData)
+	return []byte("signature"), w.signErr
+}
+
+func (w *fakeWallet) SignData(account accounts.Account, mimeType string, data []byte) ([]byte, error) {
+	if !w.native {
+		return nil, gethaccounts.ErrNotSupported
+	}
+	return []byte("native signature"), nil
+}
+
+func (w *fakeWallet) Close() error {
+	w.closed = true
+	return nil
+}
+
+func newFakeWallet(t *testing.T) *fakeWallet {
+	key, err := crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+	require.NoError(t, err)
+	return &fakeWallet{key: key}
+}
+
+func mustBech32(t *testing.T, hrp string, wallet *fakeWallet) string {
+	t.Helper()
+
+	addr, err := bech32.Encode(hrp, crypto.PubkeyToAddress(wallet.key.PublicKey).Bytes())
+	require.NoError(t, err)
+	return addr
+}
+
+func TestGetAddressPubKey(t *testing.T) {
+	wallet := newFakeWallet(t)
+	adapter := NewAdapter(wallet)
+
+	path := []uint32{44, 60, 0, 0, 0}
+	pubkey, addr, err := adapter.GetAddressPubKeySECP256K1(path, "")
+	require.NoError(t, err)
+	require.Len(t, pubkey, 33)
+	require.Equal(t, crypto.CompressPubkey(&wallet.key.PublicKey), pubkey)
+	require.Equal(t, mustBech32(t, "evmos", wallet), addr)
+
+	// The keyring's path must not be modified
+	require.Equal(t, []uint32{44, 60, 0, 0, 0}, path)
+
+	// Requested and configured human readable parts are honoured
+	_, addr, err = adapter.GetAddressPubKeySECP256K1(path, "cosmos")
+	require.NoError(t, err)
+	require.Equal(t, mustBech32(t, "cosmos", wallet), addr)
+
+	adapter.SetHRP("evmosvaloper")
+	_, addr, err = adapter.GetAddressPubKeySECP256K1(path, "")
+	require.NoError(t, err)
+	require.Equal(t, mustBech32(t, "evmosvaloper", wallet), addr)
+
+	pubkey, err = adapter.GetPublicKeySECP256K1(path)
+	require.NoError(t, err)
+	require.Len(t, pubkey, 33)
+
+	_, err = adapter.GetPublicKeySECP256K1([]uint32{44, 60, 0})
+	require.Error(t, err)
+}
+
+func TestSignSECP256K1(t *testing.T) {
+	wallet := newFakeWallet(t)
+	adapter := NewAdapter(wallet)
+
+	signDoc := []byte(`{"account_number":"1","chain_id":"evmos_9000-1","fee":{"amount":[{"amount":"20","denom":"aevmos"}],"gas":"200000"},"memo":"","msgs":[{"type":"cosmos-sdk/MsgSend","value":{"amount":[{"amount":"1","denom":"aevmos"}],"from_address":"evmos1a","to_address":"evmos1b"}}],"sequence":"0"}`)
+
+	// Sign docs are wrapped into Ethermint's schema by default, the signer paying fees
+	sig, err := adapter.SignSECP256K1([]uint32{44, 60, 0, 0, 0}, signDoc, 0)
+	require.NoError(t, err)
+	require.Equal(t, []byte("signature"), sig)
+	require.Equal(t, "Tx", wallet.signed[0].PrimaryType)
+	require.Equal(t, int64(9000), (*big.Int)(wallet.signed[0].Domain.ChainId).Int64())
+	require.Equal(t, mustBech32(t, "evmos", wallet), wallet.signed[0].Message["fee"].(map[string]interface{})["feePayer"])
+
+	adapter.SetTypedDataBuilder(func(doc []byte, signer string) (apitypes.TypedData, error) {
+		require.Equal(t, signDoc, doc)
+		return apitypes.TypedData{PrimaryType: "Custom"}, nil
+	})
+	_, err = adapter.SignSECP256K1([]uint32{44, 60, 0, 0, 0}, signDoc, 0)
+	require.NoError(t, err)
+	require.Equal(t, "Custom", wallet.signed[1].PrimaryType)
+
+	_, err = adapter.SignSECP256K1([]uint32{44, 60, 0, 0, 0}, signDoc, 1)
+	require.ErrorIs(t, err, ErrUnsupportedSignMode)
+
+	adapter.SetTypedDataBuilder(func([]byte, string) (apitypes.TypedData, error) {
+		return apitypes.TypedData{}, errors.New("bad sign doc")
+	})
+	_, err = adapter.SignSECP256K1([]uint32{44, 60, 0, 0, 0}, signDoc, 0)
+	require.ErrorContains(t, err, "bad sign doc")
+	require.Len(t, wallet.signed, 2)
+
+	// Natively signing apps skip the EIP-712 conversion
+	wallet.native = true
+	sig, err = adapter.SignSECP256K1([]uint32{44, 60, 0, 0, 0}, signDoc, 0)
+	require.NoError(t, err)
+	require.Equal(t, []byte("native signature"), sig)
+	require.Len(t, wallet.signed, 2)
+
+	require.NoError(t, adapter.Close())
+	require.True(t, wallet.closed)
+}
@@ -0,0 +1,64 @@
+package erc7730
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// decodeCalldata unpacks the arguments of a method call into a tree of named
+// values, suitable for path lookups.
+func decodeCalldata(method abi.Method, data []byte) (map[string]interface{}, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("calldata too short: %d bytes", len(data))
+	}
+	values, err := method.Inputs.UnpackValues(data[4:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s calldata: %w", method.Name, err)
+	}
+	tree := make(map[string]interface{}, len(values))
+	for i, arg := range method.Inputs {
+		tree[arg.Name] = toTree(arg.Type, values[i])
+	}
+	return tree, nil
+}
+
+// toTree converts an ABI decoded value into its generic representation: tuples
+// become maps keyed by component name, arrays become lists, integers become big
+// integers and fixed size byte arrays become byte slices.
+func toTree(typ abi.Type, value interface{}) interface{} {
+	rv := reflect.ValueOf(value)
+
+	switch typ.T {
+	case abi.TupleTy:
+		tuple := make(map[string]interface{}, len(typ.TupleElems))
+		for i, elem := range typ.TupleElems {
+			tuple[typ.TupleRawNames[i]] = toTree(*elem, rv.Field(i).Interface())
+		}
+		return tuple
+
+	case abi.SliceTy, abi.ArrayTy:
+		list := make([]interface{}, rv.Len())
+		for i := range list {
+			list[i] = toTree(*typ.Elem, rv.Index(i).Interface())
+		}
+		return list
+
+	case abi.IntTy, abi.UintTy:
+		if n, ok := value.(*big.Int); ok {
+			return n
+		}
+		if typ.T == abi.IntTy {
+			return big.NewInt(rv.Int())
+		}
+		return new(big.Int).SetUint64(rv.Uint())
+
+	case abi.FixedBytesTy:
+		fixed := make([]byte, rv.Len())
+		reflect.Copy(reflect.ValueOf(fixed), rv)
+		return fixed
+	}
+	return value
+}
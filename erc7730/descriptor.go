@@ -0,0 +1,285 @@
+// Package erc7730 implements ERC-7730 clear signing descriptors. Descriptors are
+// matched against outgoing transactions and EIP-712 messages to render human
+// readable previews, and to provide the Ledger with the metadata it needs to
+// display contract calls instead of blind signing them.
+package erc7730
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/evmos/ethereum-ledger-go/internal/ethutil"
+)
+
+// Field formats defined by ERC-7730.
+const (
+	FormatRaw         = "raw"         // Value displayed as is
+	FormatAddressName = "addressName" // Address, possibly resolved to a trusted name
+	FormatCalldata    = "calldata"    // Embedded calldata of a nested call
+	FormatAmount      = "amount"      // Amount in the chain's native currency
+	FormatTokenAmount = "tokenAmount" // Amount of an ERC-20 token
+	FormatNFTName     = "nftName"     // Identifier of an NFT within its collection
+	FormatDate        = "date"        // Timestamp or block height
+	FormatDuration    = "duration"    // Duration in seconds
+	FormatUnit        = "unit"        // Value with a unit and decimals
+	FormatEnum        = "enum"        // Value mapped through an enumeration
+)
+
+// Descriptor is an ERC-7730 clear signing descriptor, binding the display
+// formats of a contract's functions or EIP-712 messages to their deployments.
+type Descriptor struct {
+	Context  Context  `json:"context"`
+	Metadata Metadata `json:"metadata"`
+	Display  Display  `json:"display"`
+	Includes string   `json:"includes,omitempty"`
+
+	methods map[string]abi.Method // ABI methods of the formats, keyed by format key
+}
+
+// Context defines what a descriptor applies to: either a contract's calldata or
+// EIP-712 messages verified by a contract.
+type Context struct {
+	ID       string           `json:"$id,omitempty"`
+	Contract *ContractContext `json:"contract,omitempty"`
+	EIP712   *EIP712Context   `json:"eip712,omitempty"`
+}
+
+// Deployment is a contract address on a specific chain.
+type Deployment struct {
+	ChainID uint64         `json:"chainId"`
+	Address common.Address `json:"address"`
+}
+
+// ContractContext binds a descriptor to the calldata of deployed contracts.
+type ContractContext struct {
+	Deployments []Deployment    `json:"deployments"`
+	ABI         json.RawMessage `json:"abi,omitempty"` // Inline ABI or URL to retrieve it from
+}
+
+// EIP712Context binds a descriptor to EIP-712 messages verified by deployed
+// contracts, optionally restricted to specific domain values.
+type EIP712Context struct {
+	Deployments []Deployment           `json:"deployments"`
+	Domain      map[string]interface{} `json:"domain,omitempty"`
+}
+
+// Metadata holds the information about the descriptor's owner, along with the
+// constants and enumerations referenced by the display formats.
+type Metadata struct {
+	Owner     string                     `json:"owner,omitempty"`
+	Info      *OwnerInfo                 `json:"info,omitempty"`
+	Token     *TokenMetadata             `json:"token,omitempty"`
+	Constants map[string]interface{}     `json:"constants,omitempty"`
+	Enums     map[string]json.RawMessage `json:"enums,omitempty"`
+}
+
+// OwnerInfo is the optional legal information about a descriptor's owner.
+type OwnerInfo struct {
+	LegalName      string `json:"legalName,omitempty"`
+	URL            string `json:"url,omitempty"`
+	DeploymentDate string `json:"deploymentDate,omitempty"`
+}
+
+// TokenMetadata describes the ERC-20 token implemented by a contract.
+type TokenMetadata struct {
+	Name     string `json:"name,omitempty"`
+	Ticker   string `json:"ticker"`
+	Decimals uint8  `json:"decimals"`
+}
+
+// Display holds the display formats of a descriptor, keyed by function signature
+// (or selector) for contracts and by encoded type for EIP-712 messages.
+type Display struct {
+	Definitions map[string]Field  `json:"definitions,omitempty"`
+	Formats     map[string]Format `json:"formats"`
+}
+
+// Format describes how to display a single function call or EIP-712 message.
+type Format struct {
+	ID       string   `json:"$id,omitempty"`
+	Intent   Intent   `json:"intent,omitempty"`
+	Fields   []Field  `json:"fields"`
+	Required []string `json:"required,omitempty"`
+	Excluded []string `json:"excluded,omitempty"`
+}
+
+// Field describes how to display a value located at a path within the calldata,
+// the transaction container or the descriptor itself. Fields with nested fields
+// group them under a common path prefix.
+type Field struct {
+	Ref    string                 `json:"$ref,omitempty"`
+	Path   string                 `json:"path,omitempty"`
+	Label  string                 `json:"label,omitempty"`
+	Format string                 `json:"format,omitempty"`
+	Params map[string]interface{} `json:"params,omitempty"`
+	Fields []Field                `json:"fields,omitempty"`
+}
+
+// Intent is the short description of what a function call or message does. It
+// may be given either as a plain string or as a set of key/value pairs.
+type Intent string
+
+// UnmarshalJSON implements json.Unmarshaler, flattening key/value intents into
+// a single string with sorted keys.
+func (i *Intent) UnmarshalJSON(input []byte) error {
+	var text string
+	if err := json.Unmarshal(input, &text); err == nil {
+		*i = Intent(text)
+		return nil
+	}
+	var pairs map[string]string
+	if err := json.Unmarshal(input, &pairs); err != nil {
+		return fmt.Errorf("intent must be a string or an object of strings: %w", err)
+	}
+	keys := make([]string, 0, len(pairs))
+	for key := range pairs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for j, key := range keys {
+		parts[j] = key + ": " + pairs[key]
+	}
+	*i = Intent(strings.Join(parts, ", "))
+	return nil
+}
+
+// Parse decodes an ERC-7730 descriptor from its JSON representation, resolving
+// field references and validating the display formats against the context.
+func Parse(data []byte) (*Descriptor, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber() // Keep constants such as uint256 thresholds exact
+
+	d := new(Descriptor)
+	if err := dec.Decode(d); err != nil {
+		return nil, fmt.Errorf("erc7730: invalid descriptor: %w", err)
+	}
+	if err := d.init(); err != nil {
+		return nil, fmt.Errorf("erc7730: %w", err)
+	}
+	return d, nil
+}
+
+// ParseFile reads and decodes an ERC-7730 descriptor from a JSON file.
+func ParseFile(path string) (*Descriptor, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	d, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return d, nil
+}
+
+// init validates a freshly decoded descriptor, resolving its field references
+// and the ABI methods of its contract formats.
+func (d *Descriptor) init() error {
+	if d.Includes != "" {
+		return errors.New("descriptors including other files are not supported")
+	}
+	if (d.Context.Contract == nil) == (d.Context.EIP712 == nil) {
+		return errors.New("context must bind either a contract or EIP-712 messages")
+	}
+	if len(d.Display.Formats) == 0 {
+		return errors.New("no display formats")
+	}
+	for key, format := range d.Display.Formats {
+		fields, err := d.resolveFields(format.Fields)
+		if err != nil {
+			return fmt.Errorf("format %q: %w", key, err)
+		}
+		format.Fields = fields
+		d.Display.Formats[key] = format
+	}
+	if d.Context.Contract == nil {
+		return nil
+	}
+	// Contract formats are keyed by function signature or selector, resolve them
+	var contractABI *abi.ABI
+	if len(d.Context.Contract.ABI) > 0 && d.Context.Contract.ABI[0] == '[' {
+		parsed, err := abi.JSON(bytes.NewReader(d.Context.Contract.ABI))
+		if err != nil {
+			return fmt.Errorf("invalid contract abi: %w", err)
+		}
+		contractABI = &parsed
+	}
+	d.methods = make(map[string]abi.Method, len(d.Display.Formats))
+	for key := range d.Display.Formats {
+		if !strings.HasPrefix(key, "0x") {
+			method, err := ethutil.ParseSignature(key)
+			if err != nil {
+				return fmt.Errorf("format %q: %w", key, err)
+			}
+			d.methods[key] = method
+			continue
+		}
+		if contractABI == nil {
+			return fmt.Errorf("format %q: selector keys require an inline abi", key)
+		}
+		method, err := contractABI.MethodById(common.FromHex(key))
+		if err != nil {
+			return fmt.Errorf("format %q: %w", key, err)
+		}
+		d.methods[key] = *method
+	}
+	return nil
+}
+
+// resolveFields replaces the field references to display definitions with the
+// referenced definitions, overridden by the values set on the referencing field.
+func (d *Descriptor) resolveFields(fields []Field) ([]Field, error) {
+	resolved := make([]Field, len(fields))
+	for i, field := range fields {
+		if field.Ref != "" {
+			name := strings.TrimPrefix(field.Ref, "$.display.definitions.")
+			def, ok := d.Display.Definitions[name]
+			if !ok || name == field.Ref {
+				return nil, fmt.Errorf("unknown definition %q", field.Ref)
+			}
+			if field.Label == "" {
+				field.Label = def.Label
+			}
+			if field.Format == "" {
+				field.Format = def.Format
+			}
+			params := make(map[string]interface{}, len(def.Params)+len(field.Params))
+			for key, value := range def.Params {
+				params[key] = value
+			}
+			for key, value := range field.Params {
+				params[key] = value
+			}
+			field.Params, field.Ref = params, ""
+		}
+		if len(field.Fields) > 0 {
+			nested, err := d.resolveFields(field.Fields)
+			if err != nil {
+				return nil, err
+			}
+			field.Fields = nested
+		}
+		resolved[i] = field
+	}
+	return resolved, nil
+}
+
+// deployedAt returns whether the deployments contain the given contract.
+func deployedAt(deployments []Deployment, chainID uint64, address common.Address) bool {
+	for _, deployment := range deployments {
+		if deployment.ChainID == chainID && deployment.Address == address {
+			return true
+		}
+	}
+	return false
+}
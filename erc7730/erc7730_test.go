@@ -0,0 +1,353 @@
+package erc7730
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/evmos/ethereum-ledger-go/internal/testutil"
+	"github.com/evmos/ethereum-ledger-go/usbwallet"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	usdc    = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	router  = common.HexToAddress("0x68b3465833fb72A70ecDF485E0e4C7bD8665Fc45")
+	permit2 = common.HexToAddress("0x000000000022D473030F116dDEE9F6B43aC78BA3")
+	alice   = common.HexToAddress("0x1111111111111111111111111111111111111111")
+	bob     = common.HexToAddress("0x2222222222222222222222222222222222222222")
+)
+
+const tokenDescriptor = `{
+  "context": {
+    "contract": {
+      "deployments": [{"chainId": 1, "address": "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"}]
+    }
+  },
+  "metadata": {
+    "owner": "Circle",
+    "token": {"name": "USD Coin", "ticker": "USDC", "decimals": 6},
+    "constants": {"max": "0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"}
+  },
+  "display": {
+    "definitions": {
+      "amount": {"label": "Amount", "format": "tokenAmount", "params": {"threshold": "$.metadata.constants.max"}}
+    },
+    "formats": {
+      "transfer(address to,uint256 value)": {
+        "intent": "Send",
+        "fields": [
+          {"path": "to", "label": "To", "format": "addressName"},
+          {"path": "value", "$ref": "$.display.definitions.amount"}
+        ],
+        "required": ["to", "value"]
+      },
+      "approve(address spender,uint256 value)": {
+        "intent": {"Approve": "spending"},
+        "fields": [
+          {"path": "spender", "label": "Spender", "format": "addressName"},
+          {"path": "value", "$ref": "$.display.definitions.amount", "params": {"message": "All"}}
+        ]
+      }
+    }
+  }
+}`
+
+const routerDescriptor = `{
+  "context": {
+    "contract": {
+      "deployments": [{"chainId": 1, "address": "0x68b3465833fb72A70ecDF485E0e4C7bD8665Fc45"}]
+    }
+  },
+  "metadata": {
+    "owner": "Uniswap",
+    "enums": {"mode": {"0": "Exact input", "1": "Exact output"}}
+  },
+  "display": {
+    "formats": {
+      "swap((address tokenIn,address tokenOut,uint256 amountIn) params,address[] recipients,uint8 mode,uint256 deadline)": {
+        "intent": "Swap",
+        "fields": [
+          {"path": "params", "fields": [
+            {"path": "amountIn", "label": "Send", "format": "tokenAmount", "params": {"tokenPath": "tokenIn"}},
+            {"path": "tokenOut", "label": "Receive", "format": "addressName"}
+          ]},
+          {"path": "recipients.[]", "label": "Recipient", "format": "addressName"},
+          {"path": "mode", "label": "Mode", "format": "enum", "params": {"$ref": "$.metadata.enums.mode"}},
+          {"path": "deadline", "label": "Deadline", "format": "date", "params": {"encoding": "timestamp"}},
+          {"path": "@.value", "label": "Fee", "format": "amount"}
+        ]
+      }
+    }
+  }
+}`
+
+const permitDescriptor = `{
+  "context": {
+    "eip712": {
+      "deployments": [{"chainId": 1, "address": "0x000000000022D473030F116dDEE9F6B43aC78BA3"}],
+      "domain": {"name": "Permit2"}
+    }
+  },
+  "metadata": {"owner": "Uniswap"},
+  "display": {
+    "formats": {
+      "PermitSingle(PermitDetails details,address spender,uint256 sigDeadline)": {
+        "intent": "Approve token spending",
+        "fields": [
+          {"path": "spender", "label": "Spender", "format": "addressName"},
+          {"path": "details.amount", "label": "Amount", "format": "tokenAmount", "params": {"tokenPath": "details.token"}},
+          {"path": "details.expiration", "label": "Expires", "format": "date"},
+          {"path": "sigDeadline", "label": "Valid for", "format": "duration"}
+        ]
+      }
+    }
+  }
+}`
+
+func newTestRegistry(t *testing.T) *Registry {
+	t.Helper()
+
+	registry := NewRegistry()
+	for _, raw := range []string{tokenDescriptor, routerDescriptor, permitDescriptor} {
+		d, err := Parse([]byte(raw))
+		require.NoError(t, err)
+		registry.Add(d)
+	}
+	return registry
+}
+
+func TestParseInvalidDescriptors(t *testing.T) {
+	for name, raw := range map[string]string{
+		"no context":      `{"display": {"formats": {"f()": {"fields": []}}}}`,
+		"no formats":      `{"context": {"contract": {"deployments": []}}, "display": {}}`,
+		"unknown ref":     `{"context": {"contract": {"deployments": []}}, "display": {"formats": {"f()": {"fields": [{"$ref": "$.display.definitions.x"}]}}}}`,
+		"selector no abi": `{"context": {"contract": {"deployments": []}}, "display": {"formats": {"0xa9059cbb": {"fields": []}}}}`,
+		"includes":        `{"includes": "common.json", "context": {"contract": {"deployments": []}}, "display": {"formats": {"f()": {"fields": []}}}}`,
+	} {
+		_, err := Parse([]byte(raw))
+		require.Error(t, err, name)
+	}
+}
+
+func TestSelectorFormatWithABI(t *testing.T) {
+	d, err := Parse([]byte(`{
+	  "context": {"contract": {
+	    "deployments": [{"chainId": 10, "address": "0x1111111111111111111111111111111111111111"}],
+	    "abi": [{"type": "function", "name": "deposit", "inputs": [{"name": "amount", "type": "uint256"}], "outputs": []}]
+	  }},
+	  "metadata": {},
+	  "display": {"formats": {"0xb6b55f25": {"intent": "Deposit", "fields": [{"path": "amount", "label": "Amount", "format": "amount"}]}}}
+	}`))
+	require.NoError(t, err)
+
+	data := append(common.FromHex("0xb6b55f25"), common.LeftPadBytes(big.NewInt(1500000000000000000).Bytes(), 32)...)
+	tx := coretypes.NewTransaction(0, alice, big.NewInt(0), 100000, big.NewInt(1), data)
+
+	preview, err := NewRegistry(d).PreviewTransaction(bob, tx, big.NewInt(10))
+	require.NoError(t, err)
+	require.Equal(t, []PreviewField{{Label: "Amount", Value: "1.5 ETH"}}, preview.Fields)
+}
+
+func TestMatchTransaction(t *testing.T) {
+	registry := newTestRegistry(t)
+	data := testutil.PackCall(t, "transfer(address to,uint256 value)", bob, big.NewInt(1))
+
+	match, err := registry.MatchTransaction(coretypes.NewTransaction(0, usdc, nil, 0, nil, data), big.NewInt(1))
+	require.NoError(t, err)
+	require.Equal(t, "transfer(address to,uint256 value)", match.FormatKey)
+	require.Equal(t, [4]byte{0xa9, 0x05, 0x9c, 0xbb}, match.Selector())
+	require.Equal(t, usdc, match.Contract)
+
+	// Wrong chain, wrong contract, unknown selector and plain transfers don't match
+	for _, tc := range []struct {
+		to      common.Address
+		chainID int64
+		data    []byte
+	}{
+		{usdc, 5, data},
+		{bob, 1, data},
+		{usdc, 1, testutil.PackCall(t, "mint(address to,uint256 value)", bob, big.NewInt(1))},
+		{usdc, 1, nil},
+	} {
+		_, err := registry.MatchTransaction(coretypes.NewTransaction(0, tc.to, nil, 0, nil, tc.data), big.NewInt(tc.chainID))
+		require.ErrorIs(t, err, ErrNoDescriptor)
+	}
+}
+
+func TestPreviewTokenCalls(t *testing.T) {
+	registry := newTestRegistry(t)
+
+	data := testutil.PackCall(t, "transfer(address to,uint256 value)", bob, big.NewInt(12_345_000))
+	preview, err := registry.PreviewTransaction(alice, coretypes.NewTransaction(0, usdc, nil, 0, nil, data), big.NewInt(1))
+	require.NoError(t, err)
+
+	require.Equal(t, "Send", preview.Intent)
+	require.Equal(t, "Circle", preview.Owner)
+	require.Equal(t, []PreviewField{
+		{Label: "To", Value: bob.Hex()},
+		{Label: "Amount", Value: "12.345 USDC"},
+	}, preview.Fields)
+	require.Equal(t, "Send (Circle)\nTo: "+bob.Hex()+"\nAmount: 12.345 USDC", preview.String())
+
+	// Unlimited approvals are displayed with the threshold message
+	data = testutil.PackCall(t, "approve(address spender,uint256 value)", router, math.MaxBig256)
+	preview, err = registry.PreviewTransaction(alice, coretypes.NewTransaction(0, usdc, nil, 0, nil, data), big.NewInt(1))
+	require.NoError(t, err)
+
+	require.Equal(t, "Approve: spending", preview.Intent)
+	require.Equal(t, PreviewField{Label: "Amount", Value: "All USDC"}, preview.Fields[1])
+}
+
+func TestPreviewNestedCall(t *testing.T) {
+	registry := newTestRegistry(t)
+
+	weth := common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2")
+	params := struct {
+		TokenIn  common.Address
+		TokenOut common.Address
+		AmountIn *big.Int
+	}{usdc, weth, big.NewInt(2_500_000)}
+
+	data := testutil.PackCall(t, "swap((address tokenIn,address tokenOut,uint256 amountIn) params,address[] recipients,uint8 mode,uint256 deadline)",
+		params, []common.Address{alice, bob}, uint8(1), big.NewInt(1700000000))
+	tx := coretypes.NewTransaction(0, router, big.NewInt(1e15), 0, nil, data)
+
+	preview, err := registry.PreviewTransaction(alice, tx, big.NewInt(1))
+	require.NoError(t, err)
+	require.Equal(t, []PreviewField{
+		{Label: "Send", Value: "2.5 USDC"},
+		{Label: "Receive", Value: weth.Hex()},
+		{Label: "Recipient", Value: alice.Hex()},
+		{Label: "Recipient", Value: bob.Hex()},
+		{Label: "Mode", Value: "Exact output"},
+		{Label: "Deadline", Value: "2023-11-14 22:13:20 UTC"},
+		{Label: "Fee", Value: "0.001 ETH"},
+	}, preview.Fields)
+}
+
+// newTestPermit creates a Permit2 PermitSingle message described by the test
+// registry.
+func newTestPermit() apitypes.TypedData {
+	return apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {
+				{Name: "name", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+				{Name: "verifyingContract", Type: "address"},
+			},
+			"PermitDetails": {
+				{Name: "token", Type: "address"},
+				{Name: "amount", Type: "uint160"},
+				{Name: "expiration", Type: "uint48"},
+				{Name: "nonce", Type: "uint48"},
+			},
+			"PermitSingle": {
+				{Name: "details", Type: "PermitDetails"},
+				{Name: "spender", Type: "address"},
+				{Name: "sigDeadline", Type: "uint256"},
+			},
+		},
+		PrimaryType: "PermitSingle",
+		Domain: apitypes.TypedDataDomain{
+			Name:              "Permit2",
+			ChainId:           math.NewHexOrDecimal256(1),
+			VerifyingContract: permit2.Hex(),
+		},
+		Message: apitypes.TypedDataMessage{
+			"details": map[string]interface{}{
+				"token":      usdc.Hex(),
+				"amount":     "1000000",
+				"expiration": "1700000000",
+				"nonce":      "0",
+			},
+			"spender":     router.Hex(),
+			"sigDeadline": "5400",
+		},
+	}
+}
+
+func TestPreviewTypedData(t *testing.T) {
+	registry := newTestRegistry(t)
+	registry.SetTokenLookup(func(chainID *big.Int, token common.Address) (*TokenMetadata, bool) {
+		return nil, false
+	})
+	typedData := newTestPermit()
+	preview, err := registry.PreviewTypedData(typedData)
+	require.NoError(t, err)
+	require.Equal(t, []PreviewField{
+		{Label: "Spender", Value: router.Hex()},
+		{Label: "Amount", Value: "1 USDC"},
+		{Label: "Expires", Value: "2023-11-14 22:13:20 UTC"},
+		{Label: "Valid for", Value: "01:30:00"},
+	}, preview.Fields)
+
+	// Messages from other domains are not described
+	typedData.Domain.Name = "Other"
+	_, err = registry.PreviewTypedData(typedData)
+	require.ErrorIs(t, err, ErrNoDescriptor)
+}
+
+type staticSource struct {
+	meta         *usbwallet.TransactionMetadata
+	typedMeta    *usbwallet.TypedDataMetadata
+	matched      []*TransactionMatch
+	typedMatched []*TypedDataMatch
+}
+
+func (s *staticSource) TransactionDescriptors(match *TransactionMatch) (*usbwallet.TransactionMetadata, error) {
+	s.matched = append(s.matched, match)
+	return s.meta, nil
+}
+
+func (s *staticSource) TypedDataDescriptors(match *TypedDataMatch) (*usbwallet.TypedDataMetadata, error) {
+	s.typedMatched = append(s.typedMatched, match)
+	return s.typedMeta, nil
+}
+
+func TestMetadataProvider(t *testing.T) {
+	source := &staticSource{meta: &usbwallet.TransactionMetadata{Info: []byte{0x01}}}
+	provider := NewMetadataProvider(newTestRegistry(t), source)
+
+	data := testutil.PackCall(t, "transfer(address to,uint256 value)", bob, big.NewInt(1))
+	meta, err := provider.TransactionMetadata(coretypes.NewTransaction(0, usdc, nil, 0, nil, data), big.NewInt(1))
+	require.NoError(t, err)
+	require.Equal(t, source.meta, meta)
+	require.Len(t, source.matched, 1)
+	require.Equal(t, usdc, source.matched[0].Contract)
+
+	// Unknown calls are left to blind signing
+	meta, err = provider.TransactionMetadata(coretypes.NewTransaction(0, bob, nil, 0, nil, data), big.NewInt(1))
+	require.NoError(t, err)
+	require.Nil(t, meta)
+	require.Len(t, source.matched, 1)
+}
+
+func TestTypedDataMetadataProvider(t *testing.T) {
+	source := &staticSource{typedMeta: &usbwallet.TypedDataMetadata{Info: []byte{0x01}}}
+	provider := NewMetadataProvider(newTestRegistry(t), source)
+
+	meta, err := provider.TypedDataMetadata(newTestPermit())
+	require.NoError(t, err)
+	require.Equal(t, source.typedMeta, meta)
+	require.Len(t, source.typedMatched, 1)
+	require.Equal(t, permit2, source.typedMatched[0].Contract)
+	require.Equal(t, "PermitSingle(PermitDetails details,address spender,uint256 sigDeadline)", source.typedMatched[0].FormatKey)
+
+	// Messages of other contracts or chains only display their hashes
+	typedData := newTestPermit()
+	typedData.Domain.VerifyingContract = bob.Hex()
+	meta, err = provider.TypedDataMetadata(typedData)
+	require.NoError(t, err)
+	require.Nil(t, meta)
+
+	typedData = newTestPermit()
+	typedData.Domain.ChainId = math.NewHexOrDecimal256(10)
+	meta, err = provider.TypedDataMetadata(typedData)
+	require.NoError(t, err)
+	require.Nil(t, meta)
+	require.Len(t, source.typedMatched, 1)
+}
@@ -0,0 +1,60 @@
+package erc7730
+
+import (
+	"errors"
+	"math/big"
+
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/evmos/ethereum-ledger-go/usbwallet"
+)
+
+// DescriptorSource retrieves the signed binary descriptors the Ledger Ethereum
+// app needs to clear sign a matched contract call. The descriptors must be signed
+// by a key the app trusts, so in production the source is Ledger's crypto asset
+// list service, which serves the descriptors generated from ERC-7730 files.
+type DescriptorSource interface {
+	TransactionDescriptors(match *TransactionMatch) (*usbwallet.TransactionMetadata, error)
+	TypedDataDescriptors(match *TypedDataMatch) (*usbwallet.TypedDataMetadata, error)
+}
+
+// MetadataProvider implements usbwallet.TransactionMetadataProvider and
+// usbwallet.TypedDataMetadataProvider, driving the clear signing metadata of the
+// transactions and EIP-712 messages described by a registry's descriptors.
+type MetadataProvider struct {
+	registry *Registry
+	source   DescriptorSource
+}
+
+// NewMetadataProvider creates a transaction metadata provider for the calls
+// described by the registry, retrieving the signed descriptors from source.
+func NewMetadataProvider(registry *Registry, source DescriptorSource) *MetadataProvider {
+	return &MetadataProvider{registry: registry, source: source}
+}
+
+// TransactionMetadata implements usbwallet.TransactionMetadataProvider. Calls not
+// described by any descriptor yield no metadata, falling back to blind signing.
+func (p *MetadataProvider) TransactionMetadata(tx *coretypes.Transaction, chainID *big.Int) (*usbwallet.TransactionMetadata, error) {
+	match, err := p.registry.MatchTransaction(tx, chainID)
+	if errors.Is(err, ErrNoDescriptor) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p.source.TransactionDescriptors(match)
+}
+
+// TypedDataMetadata implements usbwallet.TypedDataMetadataProvider, matching the
+// message on its domain, verifying contract and primary type. Messages not
+// described by any descriptor yield no metadata, displaying their hashes only.
+func (p *MetadataProvider) TypedDataMetadata(typedData apitypes.TypedData) (*usbwallet.TypedDataMetadata, error) {
+	match, err := p.registry.MatchTypedData(typedData)
+	if errors.Is(err, ErrNoDescriptor) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p.source.TypedDataDescriptors(match)
+}
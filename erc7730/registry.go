@@ -0,0 +1,219 @@
+package erc7730
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// ErrNoDescriptor is returned if no known descriptor describes a transaction or
+// an EIP-712 message.
+var ErrNoDescriptor = errors.New("erc7730: no matching descriptor")
+
+// TokenLookup retrieves the metadata of ERC-20 tokens not described by any of
+// the registry's descriptors, needed to display token amounts.
+type TokenLookup func(chainID *big.Int, token common.Address) (*TokenMetadata, bool)
+
+// Registry is a collection of descriptors, matched against transactions and
+// EIP-712 messages.
+type Registry struct {
+	descriptors []*Descriptor
+	tokens      TokenLookup
+
+	lock sync.RWMutex
+}
+
+// NewRegistry creates a registry of the given descriptors.
+func NewRegistry(descriptors ...*Descriptor) *Registry {
+	return &Registry{descriptors: descriptors}
+}
+
+// Add inserts a descriptor into the registry.
+func (r *Registry) Add(d *Descriptor) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.descriptors = append(r.descriptors, d)
+}
+
+// LoadDir parses all the JSON descriptors within a directory into the registry.
+func (r *Registry) LoadDir(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		d, err := ParseFile(file)
+		if err != nil {
+			return err
+		}
+		r.Add(d)
+	}
+	return nil
+}
+
+// SetTokenLookup configures the lookup of token metadata for tokens which have no
+// descriptor of their own in the registry.
+func (r *Registry) SetTokenLookup(lookup TokenLookup) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.tokens = lookup
+}
+
+// TransactionMatch is a contract call matched to the format describing it.
+type TransactionMatch struct {
+	Descriptor *Descriptor
+	Format     *Format
+	FormatKey  string     // Key of the format within the descriptor
+	Method     abi.Method // ABI method described by the format
+	ChainID    *big.Int
+	Contract   common.Address
+}
+
+// Selector returns the 4 byte function selector of the matched call.
+func (m *TransactionMatch) Selector() [4]byte {
+	var selector [4]byte
+	copy(selector[:], m.Method.ID)
+	return selector
+}
+
+// MatchTransaction looks up the format describing a transaction's calldata based
+// on the chain ID, the called contract and the function selector.
+func (r *Registry) MatchTransaction(tx *coretypes.Transaction, chainID *big.Int) (*TransactionMatch, error) {
+	if tx.To() == nil || len(tx.Data()) < 4 || chainID == nil || !chainID.IsUint64() {
+		return nil, ErrNoDescriptor
+	}
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	selector := tx.Data()[:4]
+	for _, d := range r.descriptors {
+		if d.Context.Contract == nil || !deployedAt(d.Context.Contract.Deployments, chainID.Uint64(), *tx.To()) {
+			continue
+		}
+		for key, method := range d.methods {
+			if string(method.ID) != string(selector) {
+				continue
+			}
+			format := d.Display.Formats[key]
+			return &TransactionMatch{
+				Descriptor: d,
+				Format:     &format,
+				FormatKey:  key,
+				Method:     method,
+				ChainID:    new(big.Int).Set(chainID),
+				Contract:   *tx.To(),
+			}, nil
+		}
+	}
+	return nil, ErrNoDescriptor
+}
+
+// TypedDataMatch is an EIP-712 message matched to the format describing it.
+type TypedDataMatch struct {
+	Descriptor *Descriptor
+	Format     *Format
+	FormatKey  string // Key of the format within the descriptor
+	ChainID    *big.Int
+	Contract   common.Address
+}
+
+// MatchTypedData looks up the format describing an EIP-712 message based on its
+// domain and primary type.
+func (r *Registry) MatchTypedData(typedData apitypes.TypedData) (*TypedDataMatch, error) {
+	chainID := (*big.Int)(typedData.Domain.ChainId)
+	if chainID == nil || !chainID.IsUint64() || !common.IsHexAddress(typedData.Domain.VerifyingContract) {
+		return nil, ErrNoDescriptor
+	}
+	contract := common.HexToAddress(typedData.Domain.VerifyingContract)
+
+	// Formats may be keyed by the primary type's own or full encoding, or its name
+	keys := []string{
+		structSignature(typedData, typedData.PrimaryType),
+		string(typedData.EncodeType(typedData.PrimaryType)),
+		typedData.PrimaryType,
+	}
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	for _, d := range r.descriptors {
+		ctx := d.Context.EIP712
+		if ctx == nil || !deployedAt(ctx.Deployments, chainID.Uint64(), contract) || !domainMatches(ctx.Domain, typedData.Domain) {
+			continue
+		}
+		for _, key := range keys {
+			format, ok := d.Display.Formats[key]
+			if !ok {
+				continue
+			}
+			return &TypedDataMatch{
+				Descriptor: d,
+				Format:     &format,
+				FormatKey:  key,
+				ChainID:    new(big.Int).Set(chainID),
+				Contract:   contract,
+			}, nil
+		}
+	}
+	return nil, ErrNoDescriptor
+}
+
+// structSignature returns the encoding of a single struct type, without the
+// types it references.
+func structSignature(typedData apitypes.TypedData, name string) string {
+	fields := make([]string, len(typedData.Types[name]))
+	for i, field := range typedData.Types[name] {
+		fields[i] = field.Type + " " + field.Name
+	}
+	return name + "(" + strings.Join(fields, ",") + ")"
+}
+
+// domainMatches returns whether an EIP-712 domain has all the textual values a
+// descriptor's context is restricted to.
+func domainMatches(want map[string]interface{}, domain apitypes.TypedDataDomain) bool {
+	have := map[string]string{
+		"name":              domain.Name,
+		"version":           domain.Version,
+		"verifyingContract": domain.VerifyingContract,
+		"salt":              domain.Salt,
+	}
+	for key, value := range want {
+		actual, ok := have[key]
+		if !ok {
+			continue // Numeric domain values are covered by the deployments
+		}
+		if !strings.EqualFold(fmt.Sprint(value), actual) {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupToken retrieves the metadata of a token, either from a token descriptor
+// within the registry or through the configured token lookup.
+func (r *Registry) lookupToken(chainID *big.Int, token common.Address) (*TokenMetadata, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	for _, d := range r.descriptors {
+		if d.Metadata.Token == nil || d.Context.Contract == nil {
+			continue
+		}
+		if chainID.IsUint64() && deployedAt(d.Context.Contract.Deployments, chainID.Uint64(), token) {
+			return d.Metadata.Token, true
+		}
+	}
+	if r.tokens != nil {
+		return r.tokens(chainID, token)
+	}
+	return nil, false
+}
@@ -0,0 +1,580 @@
+package erc7730
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+
+	"github.com/evmos/ethereum-ledger-go/internal/ethutil"
+)
+
+// nativeCurrency is the ticker amounts in the chain's native currency are shown in.
+const nativeCurrency = "ETH"
+
+// Preview is the human readable rendering of a transaction or EIP-712 message,
+// matching what a clear signing device displays.
+type Preview struct {
+	Intent string         // Short description of the operation
+	Owner  string         // Owner of the contract or message format
+	Fields []PreviewField // Labelled values, in display order
+}
+
+// PreviewField is a single labelled value of a preview.
+type PreviewField struct {
+	Label string
+	Value string
+}
+
+// String implements fmt.Stringer, rendering the preview as text with one line
+// per field.
+func (p *Preview) String() string {
+	var b strings.Builder
+	b.WriteString(p.Intent)
+	if p.Owner != "" {
+		fmt.Fprintf(&b, " (%s)", p.Owner)
+	}
+	for _, field := range p.Fields {
+		fmt.Fprintf(&b, "\n%s: %s", field.Label, field.Value)
+	}
+	return b.String()
+}
+
+// PreviewTransaction renders the human readable preview of a contract call sent
+// from the given account.
+func (r *Registry) PreviewTransaction(from common.Address, tx *coretypes.Transaction, chainID *big.Int) (*Preview, error) {
+	match, err := r.MatchTransaction(tx, chainID)
+	if err != nil {
+		return nil, err
+	}
+	calldata, err := decodeCalldata(match.Method, tx.Data())
+	if err != nil {
+		return nil, fmt.Errorf("erc7730: %w", err)
+	}
+	rd := &renderer{
+		registry:   r,
+		descriptor: match.Descriptor,
+		chainID:    match.ChainID,
+		root:       calldata,
+		container: map[string]interface{}{
+			"from":    from,
+			"to":      match.Contract,
+			"value":   tx.Value(),
+			"chainId": match.ChainID,
+		},
+	}
+	return rd.render(match.Format)
+}
+
+// PreviewTypedData renders the human readable preview of an EIP-712 message.
+func (r *Registry) PreviewTypedData(typedData apitypes.TypedData) (*Preview, error) {
+	match, err := r.MatchTypedData(typedData)
+	if err != nil {
+		return nil, err
+	}
+	rd := &renderer{
+		registry:   r,
+		descriptor: match.Descriptor,
+		chainID:    match.ChainID,
+		root:       map[string]interface{}(typedData.Message),
+		container: map[string]interface{}{
+			"to":      match.Contract,
+			"chainId": match.ChainID,
+		},
+	}
+	return rd.render(match.Format)
+}
+
+// renderer renders the fields of a single matched format.
+type renderer struct {
+	registry   *Registry
+	descriptor *Descriptor
+	chainID    *big.Int
+
+	root      interface{}            // Decoded calldata or message, the default path root
+	container map[string]interface{} // Transaction container values, the "@." path root
+}
+
+// render renders a format into a preview, ensuring its required fields exist.
+func (rd *renderer) render(format *Format) (*Preview, error) {
+	for _, path := range format.Required {
+		values, err := rd.lookup(rd.root, path)
+		if err != nil {
+			return nil, fmt.Errorf("erc7730: required field %q: %w", path, err)
+		}
+		if len(values) == 0 {
+			return nil, fmt.Errorf("erc7730: required field %q missing", path)
+		}
+	}
+	preview := &Preview{
+		Intent: string(format.Intent),
+		Owner:  rd.descriptor.Metadata.Owner,
+	}
+	if err := rd.renderFields(format.Fields, rd.root, preview); err != nil {
+		return nil, fmt.Errorf("erc7730: %w", err)
+	}
+	return preview, nil
+}
+
+// renderFields renders a list of fields relative to a node of the value tree.
+func (rd *renderer) renderFields(fields []Field, node interface{}, preview *Preview) error {
+	for _, field := range fields {
+		values := []interface{}{node}
+		if field.Path != "" {
+			var err error
+			if values, err = rd.lookup(node, field.Path); err != nil {
+				return fmt.Errorf("field %q: %w", field.Path, err)
+			}
+		}
+		// Field groups render their nested fields relative to each matched value
+		if len(field.Fields) > 0 {
+			for _, value := range values {
+				if err := rd.renderFields(field.Fields, value, preview); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		for _, value := range values {
+			text, err := rd.format(field, value, node)
+			if err != nil {
+				return fmt.Errorf("field %q: %w", field.Path, err)
+			}
+			preview.Fields = append(preview.Fields, PreviewField{Label: field.Label, Value: text})
+		}
+	}
+	return nil
+}
+
+// lookup resolves a path into the values it points to. Paths are relative to
+// the given node, unless prefixed with "#." (calldata or message root), "@."
+// (transaction container) or "$." (descriptor constants).
+func (rd *renderer) lookup(node interface{}, path string) ([]interface{}, error) {
+	switch {
+	case strings.HasPrefix(path, "#."):
+		node, path = rd.root, path[2:]
+	case strings.HasPrefix(path, "@."):
+		node, path = rd.container, path[2:]
+	case strings.HasPrefix(path, "$."):
+		value, err := rd.constant(path)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{value}, nil
+	}
+	nodes := []interface{}{node}
+	for _, segment := range splitPath(path) {
+		var next []interface{}
+		for _, node := range nodes {
+			values, err := step(node, segment)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, values...)
+		}
+		nodes = next
+	}
+	return nodes, nil
+}
+
+// splitPath splits a path into its segments, accepting array accessors both as
+// separate segments ("list.[0]") and attached to their field ("list[0]").
+func splitPath(path string) []string {
+	var segments []string
+	for _, segment := range strings.Split(strings.ReplaceAll(path, "[", ".["), ".") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+// step resolves a single path segment against a node: a field name, an index
+// ("[1]", "[-1]"), a slice ("[0:4]") or all the elements ("[]") of a list.
+func step(node interface{}, segment string) ([]interface{}, error) {
+	if !strings.HasPrefix(segment, "[") {
+		fields, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("cannot access %q of %T", segment, node)
+		}
+		value, ok := fields[segment]
+		if !ok {
+			return nil, nil // Absent values are skipped, unless required
+		}
+		return []interface{}{value}, nil
+	}
+	if !strings.HasSuffix(segment, "]") {
+		return nil, fmt.Errorf("invalid accessor %q", segment)
+	}
+	accessor := segment[1 : len(segment)-1]
+
+	switch list := node.(type) {
+	case []interface{}:
+		if accessor == "" {
+			return list, nil
+		}
+		if strings.Contains(accessor, ":") {
+			start, end, err := sliceBounds(accessor, len(list))
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{list[start:end]}, nil
+		}
+		index, err := strconv.Atoi(accessor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid index %q", accessor)
+		}
+		if index < 0 {
+			index += len(list)
+		}
+		if index < 0 || index >= len(list) {
+			return nil, nil
+		}
+		return []interface{}{list[index]}, nil
+
+	case []byte:
+		start, end, err := sliceBounds(accessor, len(list))
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{list[start:end]}, nil
+	}
+	return nil, fmt.Errorf("cannot index %T", node)
+}
+
+// sliceBounds parses the bounds of a "start:end" slice accessor, either of which
+// may be omitted or negative.
+func sliceBounds(accessor string, length int) (int, int, error) {
+	parts := strings.Split(accessor, ":")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid slice %q", accessor)
+	}
+	bounds := [2]int{0, length}
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid slice %q", accessor)
+		}
+		if n < 0 {
+			n += length
+		}
+		if n < 0 || n > length {
+			return 0, 0, fmt.Errorf("slice %q out of bounds", accessor)
+		}
+		bounds[i] = n
+	}
+	if bounds[0] > bounds[1] {
+		return 0, 0, fmt.Errorf("invalid slice %q", accessor)
+	}
+	return bounds[0], bounds[1], nil
+}
+
+// constant resolves a "$.metadata.constants.<name>" reference.
+func (rd *renderer) constant(ref string) (interface{}, error) {
+	name := strings.TrimPrefix(ref, "$.metadata.constants.")
+	value, ok := rd.descriptor.Metadata.Constants[name]
+	if !ok || name == ref {
+		return nil, fmt.Errorf("unknown constant %q", ref)
+	}
+	return value, nil
+}
+
+// param retrieves a format parameter, resolving constant references.
+func (rd *renderer) param(field Field, name string) (interface{}, bool, error) {
+	value, ok := field.Params[name]
+	if !ok {
+		return nil, false, nil
+	}
+	if ref, isRef := value.(string); isRef && strings.HasPrefix(ref, "$.") {
+		resolved, err := rd.constant(ref)
+		return resolved, true, err
+	}
+	return value, true, nil
+}
+
+// format renders a single value according to its field's format.
+func (rd *renderer) format(field Field, value interface{}, node interface{}) (string, error) {
+	switch field.Format {
+	case "", FormatRaw:
+		return formatRaw(value), nil
+
+	case FormatAddressName:
+		addr, err := toAddress(value)
+		if err != nil {
+			return "", err
+		}
+		return addr.Hex(), nil
+
+	case FormatCalldata:
+		data, ok := value.([]byte)
+		if !ok {
+			return "", fmt.Errorf("calldata must be bytes, have %T", value)
+		}
+		return hexutil.Encode(data), nil
+
+	case FormatAmount:
+		amount, err := toBig(value)
+		if err != nil {
+			return "", err
+		}
+		return ethutil.FormatUnits(amount, 18) + " " + nativeCurrency, nil
+
+	case FormatTokenAmount:
+		return rd.formatTokenAmount(field, value, node)
+
+	case FormatNFTName:
+		id, err := toBig(value)
+		if err != nil {
+			return "", err
+		}
+		collection, err := rd.addressParam(field, "collectionPath", "collection", node)
+		if err != nil || collection == nil {
+			return "#" + id.String(), err
+		}
+		return fmt.Sprintf("#%s of %s", id, collection.Hex()), nil
+
+	case FormatDate:
+		n, err := toBig(value)
+		if err != nil {
+			return "", err
+		}
+		if encoding, _, _ := rd.param(field, "encoding"); encoding == "blockheight" {
+			return "block #" + n.String(), nil
+		}
+		if !n.IsInt64() {
+			return "", fmt.Errorf("timestamp out of range: %s", n)
+		}
+		return time.Unix(n.Int64(), 0).UTC().Format("2006-01-02 15:04:05 UTC"), nil
+
+	case FormatDuration:
+		n, err := toBig(value)
+		if err != nil {
+			return "", err
+		}
+		if !n.IsInt64() {
+			return "", fmt.Errorf("duration out of range: %s", n)
+		}
+		secs := n.Int64()
+		return fmt.Sprintf("%02d:%02d:%02d", secs/3600, secs/60%60, secs%60), nil
+
+	case FormatUnit:
+		n, err := toBig(value)
+		if err != nil {
+			return "", err
+		}
+		decimals := uint8(0)
+		if raw, ok, err := rd.param(field, "decimals"); err != nil {
+			return "", err
+		} else if ok {
+			d, err := toBig(raw)
+			if err != nil || !d.IsUint64() || d.Uint64() > 77 {
+				return "", fmt.Errorf("invalid unit decimals %v", raw)
+			}
+			decimals = uint8(d.Uint64())
+		}
+		base, ok, err := rd.param(field, "base")
+		if err != nil || !ok {
+			return ethutil.FormatUnits(n, decimals), err
+		}
+		return ethutil.FormatUnits(n, decimals) + fmt.Sprint(base), nil
+
+	case FormatEnum:
+		return rd.formatEnum(field, value)
+	}
+	return "", fmt.Errorf("unsupported format %q", field.Format)
+}
+
+// formatTokenAmount renders an amount of the token referenced by the field's
+// parameters (or the called contract itself if it's a token).
+func (rd *renderer) formatTokenAmount(field Field, value interface{}, node interface{}) (string, error) {
+	amount, err := toBig(value)
+	if err != nil {
+		return "", err
+	}
+	token, err := rd.addressParam(field, "tokenPath", "token", node)
+	if err != nil {
+		return "", err
+	}
+	if token == nil && rd.descriptor.Metadata.Token != nil {
+		to := rd.container["to"].(common.Address)
+		token = &to
+	}
+	// Resolve the token's ticker and decimals, special casing the native currency
+	var meta *TokenMetadata
+	if token != nil {
+		if rd.isNativeCurrency(field, *token) {
+			meta = &TokenMetadata{Ticker: nativeCurrency, Decimals: 18}
+		} else if known, ok := rd.registry.lookupToken(rd.chainID, *token); ok {
+			meta = known
+		}
+	}
+	// Amounts above the threshold are displayed as a message, e.g. "Unlimited"
+	if threshold, ok, err := rd.param(field, "threshold"); err != nil {
+		return "", err
+	} else if ok {
+		limit, err := toBig(threshold)
+		if err != nil {
+			return "", fmt.Errorf("invalid threshold: %w", err)
+		}
+		if amount.Cmp(limit) >= 0 {
+			message := "Unlimited"
+			if text, ok := field.Params["message"].(string); ok {
+				message = text
+			}
+			if meta != nil {
+				message += " " + meta.Ticker
+			}
+			return message, nil
+		}
+	}
+	switch {
+	case meta != nil:
+		return ethutil.FormatUnits(amount, meta.Decimals) + " " + meta.Ticker, nil
+	case token != nil:
+		return fmt.Sprintf("%s (unknown token %s)", amount, token.Hex()), nil
+	default:
+		return amount.String() + " (unknown token)", nil
+	}
+}
+
+// isNativeCurrency returns whether a token address is one of the placeholders
+// the field uses to denote the chain's native currency.
+func (rd *renderer) isNativeCurrency(field Field, token common.Address) bool {
+	raw, ok, err := rd.param(field, "nativeCurrencyAddress")
+	if !ok || err != nil {
+		return false
+	}
+	candidates, isList := raw.([]interface{})
+	if !isList {
+		candidates = []interface{}{raw}
+	}
+	for _, candidate := range candidates {
+		if addr, err := toAddress(candidate); err == nil && addr == token {
+			return true
+		}
+	}
+	return false
+}
+
+// addressParam resolves an address parameter given either as a path into the
+// value tree or as a constant.
+func (rd *renderer) addressParam(field Field, pathParam, valueParam string, node interface{}) (*common.Address, error) {
+	if path, ok := field.Params[pathParam].(string); ok {
+		values, err := rd.lookup(node, path)
+		if err != nil {
+			return nil, err
+		}
+		if len(values) == 0 {
+			return nil, fmt.Errorf("%s %q not found", pathParam, path)
+		}
+		addr, err := toAddress(values[0])
+		if err != nil {
+			return nil, err
+		}
+		return &addr, nil
+	}
+	raw, ok, err := rd.param(field, valueParam)
+	if !ok || err != nil {
+		return nil, err
+	}
+	addr, err := toAddress(raw)
+	if err != nil {
+		return nil, err
+	}
+	return &addr, nil
+}
+
+// formatEnum renders a value through the enumeration referenced by the field.
+func (rd *renderer) formatEnum(field Field, value interface{}) (string, error) {
+	ref, _ := field.Params["$ref"].(string)
+	name := strings.TrimPrefix(ref, "$.metadata.enums.")
+
+	raw, ok := rd.descriptor.Metadata.Enums[name]
+	if !ok || name == ref {
+		return "", fmt.Errorf("unknown enum %q", ref)
+	}
+	var labels map[string]string
+	if err := json.Unmarshal(raw, &labels); err != nil {
+		return "", fmt.Errorf("enum %q must be defined inline", name)
+	}
+	n, err := toBig(value)
+	if err != nil {
+		return "", err
+	}
+	if label, ok := labels[n.String()]; ok {
+		return label, nil
+	}
+	return n.String(), nil
+}
+
+// formatRaw renders a value without any interpretation.
+func formatRaw(value interface{}) string {
+	switch v := value.(type) {
+	case *big.Int:
+		return v.String()
+	case common.Address:
+		return v.Hex()
+	case []byte:
+		return hexutil.Encode(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = formatRaw(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	}
+	return fmt.Sprint(value)
+}
+
+// toBig converts a decoded calldata or JSON message value into an integer.
+func toBig(value interface{}) (*big.Int, error) {
+	switch v := value.(type) {
+	case *big.Int:
+		return v, nil
+	case string:
+		if n, ok := math.ParseBig256(v); ok {
+			return n, nil
+		}
+	case json.Number:
+		if n, ok := math.ParseBig256(v.String()); ok {
+			return n, nil
+		}
+	case float64:
+		if n, acc := big.NewFloat(v).Int(nil); acc == big.Exact {
+			return n, nil
+		}
+	case []byte:
+		return new(big.Int).SetBytes(v), nil
+	}
+	return nil, fmt.Errorf("invalid integer %v", value)
+}
+
+// toAddress converts a decoded calldata or JSON message value into an address.
+func toAddress(value interface{}) (common.Address, error) {
+	switch v := value.(type) {
+	case common.Address:
+		return v, nil
+	case string:
+		if common.IsHexAddress(v) {
+			return common.HexToAddress(v), nil
+		}
+	case []byte:
+		if len(v) == common.AddressLength {
+			return common.BytesToAddress(v), nil
+		}
+	}
+	return common.Address{}, fmt.Errorf("invalid address %v", value)
+}
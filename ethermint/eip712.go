@@ -0,0 +1,258 @@
+// Package ethermint converts Cosmos SDK Amino JSON sign docs into the EIP-712
+// typed data Ethermint based chains (e.g. Evmos) verify legacy EIP-712 signed
+// transactions against, so they can be signed by the Ledger Ethereum app.
+package ethermint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+)
+
+const (
+	rootPrefix = "_"    // Prefix of the root object of a message
+	typePrefix = "Type" // Prefix of every generated message type name
+	txField    = "Tx"   // Primary type of the typed data
+
+	maxDuplicateTypeDefs = 1000 // Maximum number of distinct types sharing a name
+)
+
+// chainIDPattern matches Ethermint chain IDs ({identifier}_{EIP155}-{epoch}).
+var chainIDPattern = regexp.MustCompile(`^([a-z]{1,})_([1-9][0-9]*)-([1-9][0-9]*)$`)
+
+// ParseChainID extracts the EIP-155 chain ID from an Ethermint chain ID, e.g.
+// 9001 from evmos_9001-2.
+func ParseChainID(chainID string) (*big.Int, error) {
+	matches := chainIDPattern.FindStringSubmatch(strings.TrimSpace(chainID))
+	if matches == nil {
+		return nil, fmt.Errorf("invalid Ethermint chain ID %q, want {identifier}_{EIP155}-{epoch}", chainID)
+	}
+	id, ok := new(big.Int).SetString(matches[2], 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid EIP-155 chain ID in %q", chainID)
+	}
+	return id, nil
+}
+
+// WrapTxToTypedData wraps an Amino JSON StdSignDoc into Ethermint's EIP-712
+// schema. The sign doc's msgs are flattened into msg0, msg1, ... fields of the
+// Tx, each with its own type derived from the message JSON, so messages of
+// different kinds can be mixed. If a fee payer is given, it is embedded into
+// the fee as the account delegated to pay it.
+func WrapTxToTypedData(signDoc []byte, chainID uint64, feePayer string) (apitypes.TypedData, error) {
+	var message map[string]interface{}
+	if err := json.Unmarshal(signDoc, &message); err != nil {
+		return apitypes.TypedData{}, fmt.Errorf("invalid sign doc: %w", err)
+	}
+	msgs, ok := message["msgs"].([]interface{})
+	if !ok || len(msgs) == 0 {
+		return apitypes.TypedData{}, errors.New("sign doc contains no msgs")
+	}
+	delete(message, "msgs")
+
+	types := apitypes.Types{
+		"EIP712Domain": {
+			{Name: "name", Type: "string"},
+			{Name: "version", Type: "string"},
+			{Name: "chainId", Type: "uint256"},
+			{Name: "verifyingContract", Type: "string"},
+			{Name: "salt", Type: "string"},
+		},
+		txField: {
+			{Name: "account_number", Type: "string"},
+			{Name: "chain_id", Type: "string"},
+			{Name: "fee", Type: "Fee"},
+			{Name: "memo", Type: "string"},
+			{Name: "sequence", Type: "string"},
+		},
+		"Fee": {
+			{Name: "amount", Type: "Coin[]"},
+			{Name: "gas", Type: "string"},
+		},
+		"Coin": {
+			{Name: "denom", Type: "string"},
+			{Name: "amount", Type: "string"},
+		},
+	}
+	// Flatten the messages into the Tx, typing each of them individually
+	for i, msg := range msgs {
+		field := fmt.Sprintf("msg%d", i)
+		if _, ok := message[field]; ok {
+			return apitypes.TypedData{}, fmt.Errorf("sign doc already contains field %s", field)
+		}
+		obj, ok := msg.(map[string]interface{})
+		if !ok {
+			return apitypes.TypedData{}, fmt.Errorf("msg %d is not an object", i)
+		}
+		rootType, err := msgRootType(obj)
+		if err != nil {
+			return apitypes.TypedData{}, fmt.Errorf("msg %d: %w", i, err)
+		}
+		typeDef, err := addTypesToRoot(types, rootType, rootPrefix, obj)
+		if err != nil {
+			return apitypes.TypedData{}, fmt.Errorf("msg %d: %w", i, err)
+		}
+		types[txField] = append(types[txField], apitypes.Type{Name: field, Type: typeDef})
+		message[field] = obj
+	}
+	// Embed the fee payer if fees are delegated
+	if feePayer != "" {
+		fee, ok := message["fee"].(map[string]interface{})
+		if !ok {
+			return apitypes.TypedData{}, errors.New("sign doc contains no fee")
+		}
+		fee["feePayer"] = feePayer
+		types["Fee"] = []apitypes.Type{
+			{Name: "feePayer", Type: "string"},
+			{Name: "amount", Type: "Coin[]"},
+			{Name: "gas", Type: "string"},
+		}
+	}
+	return apitypes.TypedData{
+		Types:       types,
+		PrimaryType: txField,
+		Domain: apitypes.TypedDataDomain{
+			Name:              "Cosmos Web3",
+			Version:           "1.0.0",
+			ChainId:           (*math.HexOrDecimal256)(new(big.Int).SetUint64(chainID)),
+			VerifyingContract: "cosmos",
+			Salt:              "0",
+		},
+		Message: message,
+	}, nil
+}
+
+// WrapSignDoc is a convenience wrapper around WrapTxToTypedData, taking the
+// EIP-155 chain ID from the sign doc's Ethermint chain ID.
+func WrapSignDoc(signDoc []byte, feePayer string) (apitypes.TypedData, error) {
+	var doc struct {
+		ChainID string `json:"chain_id"`
+	}
+	if err := json.Unmarshal(signDoc, &doc); err != nil {
+		return apitypes.TypedData{}, fmt.Errorf("invalid sign doc: %w", err)
+	}
+	chainID, err := ParseChainID(doc.ChainID)
+	if err != nil {
+		return apitypes.TypedData{}, err
+	}
+	if !chainID.IsUint64() {
+		return apitypes.TypedData{}, fmt.Errorf("chain ID %v out of range", chainID)
+	}
+	return WrapTxToTypedData(signDoc, chainID.Uint64(), feePayer)
+}
+
+// msgRootType derives the type name of a message from its Amino type, e.g.
+// TypeMsgSend from cosmos-sdk/MsgSend.
+func msgRootType(msg map[string]interface{}) (string, error) {
+	msgType, _ := msg["type"].(string)
+	if msgType == "" {
+		return "", errors.New("malformed message type value")
+	}
+	parts := strings.Split(msgType, "/")
+	return typePrefix + parts[len(parts)-1], nil
+}
+
+// addTypesToRoot walks the fields of a JSON object in reverse sorted order (as
+// Ethermint does), recursively adding nested objects as new types, and registers
+// the object's own type under a unique name, which is returned.
+func addTypesToRoot(types apitypes.Types, rootType string, prefix string, obj map[string]interface{}) (string, error) {
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+
+	var fields []apitypes.Type
+	for _, name := range names {
+		value := obj[name]
+
+		// Arrays are typed by their first element, EIP-712 can't mix types anyway
+		collection := false
+		if list, ok := value.([]interface{}); ok {
+			if len(list) == 0 {
+				// The element type is unknown, arbitrarily assume strings
+				fields = append(fields, apitypes.Type{Name: name, Type: "string[]"})
+				continue
+			}
+			value, collection = list[0], true
+		}
+		suffix := ""
+		if collection {
+			suffix = "[]"
+		}
+		switch v := value.(type) {
+		case bool:
+			fields = append(fields, apitypes.Type{Name: name, Type: "bool" + suffix})
+		case float64:
+			fields = append(fields, apitypes.Type{Name: name, Type: "int64" + suffix})
+		case string:
+			fields = append(fields, apitypes.Type{Name: name, Type: "string" + suffix})
+		case map[string]interface{}:
+			typeDef, err := addTypesToRoot(types, rootType, prefix+"."+name, v)
+			if err != nil {
+				return "", err
+			}
+			fields = append(fields, apitypes.Type{Name: name, Type: typeDef + suffix})
+		}
+		// Null values (and nested arrays) can't be typed and are left out
+	}
+	typeDef := rootType
+	if prefix != rootPrefix {
+		typeDef = sanitizeTypeDef(prefix)
+	}
+	// Reuse identical definitions, otherwise index the name to keep it unique
+	for i := 0; i < maxDuplicateTypeDefs; i++ {
+		indexed := fmt.Sprintf("%s%d", typeDef, i)
+
+		existing, ok := types[indexed]
+		if !ok {
+			types[indexed] = fields
+			return indexed, nil
+		}
+		if typesEqual(existing, fields) {
+			return indexed, nil
+		}
+	}
+	return "", fmt.Errorf("too many distinct definitions of type %s", typeDef)
+}
+
+// sanitizeTypeDef converts a field path into an EIP-712 compliant type name, e.g.
+// TypeValueInitialDeposit from _.value.initial_deposit.
+func sanitizeTypeDef(path string) string {
+	var (
+		sb    strings.Builder
+		caser = cases.Title(language.English, cases.NoLower)
+	)
+	for _, part := range strings.Split(path, ".") {
+		if part == rootPrefix {
+			sb.WriteString(typePrefix)
+			continue
+		}
+		for _, word := range strings.Split(part, "_") {
+			sb.WriteString(caser.String(word))
+		}
+	}
+	return sb.String()
+}
+
+// typesEqual reports whether two type definitions are identical.
+func typesEqual(a, b []apitypes.Type) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,185 @@
+package ethermint
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/stretchr/testify/require"
+)
+
+const msgSendDoc = `{
+	"account_number": "8",
+	"chain_id": "evmos_9000-1",
+	"fee": {"amount": [{"amount": "4000000000000000", "denom": "aevmos"}], "gas": "200000"},
+	"memo": "",
+	"msgs": [{
+		"type": "cosmos-sdk/MsgSend",
+		"value": {
+			"amount": [{"amount": "1", "denom": "aevmos"}],
+			"from_address": "evmos1hnmrdr0jc2ve3ycxft0gcjjtrdkncpmmkeamf9",
+			"to_address": "evmos12luku6uxehhak02py4rcz65zu0swh7wjsrw0pp"
+		}
+	}],
+	"sequence": "3"
+}`
+
+func TestWrapMsgSend(t *testing.T) {
+	typedData, err := WrapTxToTypedData([]byte(msgSendDoc), 9000, "")
+	require.NoError(t, err)
+
+	require.Equal(t, "Tx", typedData.PrimaryType)
+	require.Equal(t, "Cosmos Web3", typedData.Domain.Name)
+	require.Equal(t, "1.0.0", typedData.Domain.Version)
+	require.Equal(t, "cosmos", typedData.Domain.VerifyingContract)
+	require.Equal(t, "0", typedData.Domain.Salt)
+
+	require.Equal(t, []apitypes.Type{
+		{Name: "account_number", Type: "string"},
+		{Name: "chain_id", Type: "string"},
+		{Name: "fee", Type: "Fee"},
+		{Name: "memo", Type: "string"},
+		{Name: "sequence", Type: "string"},
+		{Name: "msg0", Type: "TypeMsgSend0"},
+	}, typedData.Types["Tx"])
+	require.Equal(t, []apitypes.Type{
+		{Name: "value", Type: "TypeValue0"},
+		{Name: "type", Type: "string"},
+	}, typedData.Types["TypeMsgSend0"])
+	require.Equal(t, []apitypes.Type{
+		{Name: "to_address", Type: "string"},
+		{Name: "from_address", Type: "string"},
+		{Name: "amount", Type: "TypeValueAmount0[]"},
+	}, typedData.Types["TypeValue0"])
+	require.Equal(t, []apitypes.Type{
+		{Name: "denom", Type: "string"},
+		{Name: "amount", Type: "string"},
+	}, typedData.Types["TypeValueAmount0"])
+
+	require.NotContains(t, typedData.Message, "msgs")
+	require.Contains(t, typedData.Message, "msg0")
+
+	// The result must be hashable by the same encoder the Ledger mirrors
+	_, _, err = apitypes.TypedDataAndHash(typedData)
+	require.NoError(t, err)
+}
+
+func TestWrapHeterogeneousMsgs(t *testing.T) {
+	doc := `{
+		"account_number": "8",
+		"chain_id": "evmos_9001-2",
+		"fee": {"amount": [{"amount": "20", "denom": "aevmos"}], "gas": "400000"},
+		"memo": "batch",
+		"msgs": [
+			{"type": "cosmos-sdk/MsgSend", "value": {"amount": [{"amount": "1", "denom": "aevmos"}], "from_address": "a", "to_address": "b"}},
+			{"type": "cosmos-sdk/MsgVote", "value": {"option": 1, "proposal_id": "5", "voter": "a"}},
+			{"type": "cosmos-sdk/MsgSend", "value": {"amount": [{"amount": "2", "denom": "aevmos"}], "from_address": "a", "to_address": "c"}},
+			{"type": "cosmos-sdk/MsgDelegate", "value": {"amount": {"amount": "3", "denom": "aevmos"}, "delegator_address": "a", "validator_address": "v"}}
+		],
+		"sequence": "0"
+	}`
+	typedData, err := WrapSignDoc([]byte(doc), "evmos1feepayer")
+	require.NoError(t, err)
+	require.Equal(t, int64(9001), (*big.Int)(typedData.Domain.ChainId).Int64())
+
+	tx := typedData.Types["Tx"]
+	require.Equal(t, apitypes.Type{Name: "msg0", Type: "TypeMsgSend0"}, tx[5])
+	require.Equal(t, apitypes.Type{Name: "msg1", Type: "TypeMsgVote0"}, tx[6])
+	require.Equal(t, apitypes.Type{Name: "msg2", Type: "TypeMsgSend0"}, tx[7])
+	require.Equal(t, apitypes.Type{Name: "msg3", Type: "TypeMsgDelegate0"}, tx[8])
+
+	// Different values under the same field path get distinct indexed types
+	require.Equal(t, "TypeValue1", typedData.Types["TypeMsgVote0"][0].Type)
+	require.Equal(t, []apitypes.Type{
+		{Name: "voter", Type: "string"},
+		{Name: "proposal_id", Type: "string"},
+		{Name: "option", Type: "int64"},
+	}, typedData.Types["TypeValue1"])
+	// Identical definitions are shared, even between array and single fields
+	require.Equal(t, "TypeValueAmount0", typedData.Types["TypeValue2"][2].Type)
+
+	// The fee payer is embedded into the fee
+	require.Equal(t, []apitypes.Type{
+		{Name: "feePayer", Type: "string"},
+		{Name: "amount", Type: "Coin[]"},
+		{Name: "gas", Type: "string"},
+	}, typedData.Types["Fee"])
+	require.Equal(t, "evmos1feepayer", typedData.Message["fee"].(map[string]interface{})["feePayer"])
+
+	_, _, err = apitypes.TypedDataAndHash(typedData)
+	require.NoError(t, err)
+}
+
+// TestEvmosVectors checks the wrapped sign docs hash (and thus sign) exactly as
+// Evmos verifies them. The expected values were generated with the eip712
+// package of Ethermint v0.22.0 (WrapTxToTypedData) and crypto.Sign.
+func TestEvmosVectors(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	signer := common.HexToAddress("0x71562b71999873DB5b286dF957af199Ec94617F7")
+
+	tests := []struct {
+		doc  string
+		hash string
+		sig  string
+	}{
+		{
+			doc:  msgSendDoc,
+			hash: "0xb4355a832c5680fec83de1b8c330cdc7d7ea4a8ca3cc365c9bd9fecb5dce651f",
+			sig:  "0xf0ef631c9dd516dc6f78fbf3080f8f89d6140636cd521bf87063bf0416b63ec839944094969761b424f2932c6aeaf28de893b1d46ca81d7a30f48777446f95c700",
+		},
+		{
+			doc:  `{"account_number":"12","chain_id":"evmos_9001-2","fee":{"amount":[{"amount":"15000000000000000","denom":"aevmos"}],"gas":"300000"},"memo":"vote and stake","msgs":[{"type":"cosmos-sdk/MsgVote","value":{"option":1,"proposal_id":"42","voter":"evmos1hnmrdr0jc2ve3ycxft0gcjjtrdkncpmmkeamf9"}},{"type":"cosmos-sdk/MsgDelegate","value":{"amount":{"amount":"1000000000000000000","denom":"aevmos"},"delegator_address":"evmos1hnmrdr0jc2ve3ycxft0gcjjtrdkncpmmkeamf9","validator_address":"evmosvaloper1hnmrdr0jc2ve3ycxft0gcjjtrdkncpmm5rq3d2"}},{"type":"cosmos-sdk/MsgSubmitProposal","value":{"content":{"type":"cosmos-sdk/TextProposal","value":{"description":"Raise the block gas limit","title":"Gas"}},"initial_deposit":[{"amount":"1","denom":"aevmos"}],"is_expedited":false,"proposer":"evmos1hnmrdr0jc2ve3ycxft0gcjjtrdkncpmmkeamf9","tags":[]}}],"sequence":"7"}`,
+			hash: "0x526660957a4559e11135fdcea2a2023604ad8f0bc8f121c8f110c54fc5db7054",
+			sig:  "0x1c2c4045b0d470a289d1564af33983552139e4c0240b2465d2b9c8acc7c8587a4d0daeb9c1bc589189469ce54658b94e6125bb084bc36d28946a47b78785a63a00",
+		},
+	}
+	for i, tt := range tests {
+		typedData, err := WrapSignDoc([]byte(tt.doc), "")
+		require.NoError(t, err, i)
+		hash, _, err := apitypes.TypedDataAndHash(typedData)
+		require.NoError(t, err, i)
+		require.Equal(t, tt.hash, hexutil.Encode(hash), i)
+
+		sig, err := crypto.Sign(hash, key)
+		require.NoError(t, err, i)
+		require.Equal(t, tt.sig, hexutil.Encode(sig), i)
+
+		pubkey, err := crypto.SigToPub(hash, sig)
+		require.NoError(t, err, i)
+		require.Equal(t, signer, crypto.PubkeyToAddress(*pubkey), i)
+	}
+}
+
+func TestWrapLargeChainID(t *testing.T) {
+	typedData, err := WrapTxToTypedData([]byte(msgSendDoc), math.MaxUint64, "")
+	require.NoError(t, err)
+	require.Equal(t, new(big.Int).SetUint64(math.MaxUint64), (*big.Int)(typedData.Domain.ChainId))
+}
+
+func TestWrapInvalid(t *testing.T) {
+	for name, doc := range map[string]string{
+		"not json":     `{`,
+		"no msgs":      `{"chain_id":"evmos_9000-1","msgs":[]}`,
+		"untyped msg":  `{"chain_id":"evmos_9000-1","msgs":[{"value":{}}]}`,
+		"scalar msg":   `{"chain_id":"evmos_9000-1","msgs":["send"]}`,
+		"clashing msg": `{"chain_id":"evmos_9000-1","msg0":"x","msgs":[{"type":"a/B"}]}`,
+	} {
+		_, err := WrapTxToTypedData([]byte(doc), 9000, "")
+		require.Error(t, err, name)
+	}
+}
+
+func TestParseChainID(t *testing.T) {
+	id, err := ParseChainID("evmos_9001-2")
+	require.NoError(t, err)
+	require.Equal(t, int64(9001), id.Int64())
+
+	for _, invalid := range []string{"evmos-9001", "evmos_0-1", "Evmos_9001-2", "evmos_9001-", "cosmoshub-4"} {
+		_, err := ParseChainID(invalid)
+		require.Error(t, err, invalid)
+	}
+}
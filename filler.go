@@ -0,0 +1,234 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/evmos/ethereum-ledger-go/accounts"
+)
+
+// ChainReader is the chain access a Filler needs to complete transactions. It is
+// satisfied by *ethclient.Client and go-ethereum's simulated backend client.
+type ChainReader interface {
+	// ChainID retrieves the chain ID for replay protection.
+	ChainID(ctx context.Context) (*big.Int, error)
+
+	// PendingNonceAt retrieves the next nonce of the account, including the
+	// transactions in the pool.
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+
+	// EstimateGas estimates the gas needed to execute the call.
+	EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error)
+
+	// HeaderByNumber retrieves a block header, the latest one if number is nil.
+	HeaderByNumber(ctx context.Context, number *big.Int) (*coretypes.Header, error)
+
+	// SuggestGasPrice retrieves the suggested gas price for legacy transactions.
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+
+	// SuggestGasTipCap retrieves the suggested tip for dynamic fee transactions.
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+}
+
+// blobFeeReader is implemented by chain readers able to retrieve the blob base
+// fee, needed to fill in the blob fee cap of blob transactions.
+type blobFeeReader interface {
+	BlobBaseFee(ctx context.Context) (*big.Int, error)
+}
+
+// errNoBlobFee is returned if a blob transaction lacks a blob fee cap, and the
+// chain reader can't suggest one.
+var errNoBlobFee = errors.New("blob fee cap not set and chain reader can't retrieve the blob base fee")
+
+// Filler completes partially specified transactions with the nonce, gas limit and
+// fees retrieved from the chain, then signs them with the Ledger wallet.
+//
+// Nonces are tracked locally across signings, so transactions signed in quick
+// succession don't reuse a nonce before the previous ones reach the pool.
+type Filler struct {
+	wallet  accounts.Wallet
+	account accounts.Account
+	reader  ChainReader
+
+	nonce *uint64    // Next nonce to issue, nil until the first signing
+	lock  sync.Mutex // Serializes filling and signing to keep the nonces unique
+}
+
+// NewFiller creates a transaction filler for the account of the wallet, reading
+// the chain state through the given reader.
+func NewFiller(wallet accounts.Wallet, account accounts.Account, reader ChainReader) *Filler {
+	return &Filler{
+		wallet:  wallet,
+		account: account,
+		reader:  reader,
+	}
+}
+
+// DialFiller creates a transaction filler reading the chain state from the node
+// at the given RPC endpoint.
+func DialFiller(ctx context.Context, wallet accounts.Wallet, account accounts.Account, rawurl string) (*Filler, error) {
+	client, err := ethclient.DialContext(ctx, rawurl)
+	if err != nil {
+		return nil, err
+	}
+	return NewFiller(wallet, account, client), nil
+}
+
+// Fill completes the transaction fields left unset in the builder, without
+// reserving the nonce used. The builder itself is not modified.
+//
+// Unset nonces are taken from the pending state (or the local tracker if ahead),
+// gas limits are estimated, and fees are suggested by the node: dynamic fees if
+// the chain has a base fee and no gas price was set, a gas price otherwise.
+func (f *Filler) Fill(ctx context.Context, b *TxBuilder) (*coretypes.Transaction, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	filled, err := f.fill(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+	return filled.Build()
+}
+
+// SignTx completes the transaction fields left unset in the builder, then signs
+// it with the Ledger wallet. The nonce is only reserved if signing succeeds.
+func (f *Filler) SignTx(ctx context.Context, b *TxBuilder) (*coretypes.Transaction, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	filled, err := f.fill(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := filled.Sign(f.wallet, f.account)
+	if err != nil {
+		return nil, err
+	}
+	signed := new(coretypes.Transaction)
+	if err := signed.UnmarshalBinary(raw); err != nil {
+		return nil, err
+	}
+	// Signing succeeded, make sure the next transaction doesn't reuse the nonce
+	if f.nonce == nil || *f.nonce <= signed.Nonce() {
+		next := signed.Nonce() + 1
+		f.nonce = &next
+	}
+	return signed, nil
+}
+
+// ResetNonce drops the locally tracked nonce, so the next transaction takes it
+// from the pending state again (e.g. after signed transactions were discarded).
+func (f *Filler) ResetNonce() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.nonce = nil
+}
+
+// fill returns a copy of the builder with the unset fields completed.
+//
+// Note, fill assumes the lock is held!
+func (f *Filler) fill(ctx context.Context, b *TxBuilder) (*TxBuilder, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	filled := *b
+
+	if filled.chainID == nil {
+		chainID, err := f.reader.ChainID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		filled.chainID = chainID
+	}
+	if !filled.nonceSet {
+		nonce, err := f.reader.PendingNonceAt(ctx, f.account.Address)
+		if err != nil {
+			return nil, err
+		}
+		if f.nonce != nil && *f.nonce > nonce {
+			nonce = *f.nonce
+		}
+		filled.Nonce(nonce)
+	}
+	if err := f.fillFees(ctx, &filled); err != nil {
+		return nil, err
+	}
+	if filled.gas == 0 {
+		call := ethereum.CallMsg{
+			From:              f.account.Address,
+			To:                filled.to,
+			GasPrice:          filled.gasPrice,
+			GasTipCap:         filled.gasTipCap,
+			GasFeeCap:         filled.gasFeeCap,
+			Value:             filled.value,
+			Data:              filled.data,
+			AccessList:        filled.accessList,
+			BlobGasFeeCap:     filled.blobFeeCap,
+			BlobHashes:        filled.blobHashes,
+			AuthorizationList: filled.authorizations,
+		}
+		gas, err := f.reader.EstimateGas(ctx, call)
+		if err != nil {
+			return nil, err
+		}
+		filled.gas = gas
+	}
+	return &filled, nil
+}
+
+// fillFees completes the fee fields left unset in the builder.
+func (f *Filler) fillFees(ctx context.Context, b *TxBuilder) error {
+	if len(b.blobHashes) > 0 && b.blobFeeCap == nil {
+		reader, ok := f.reader.(blobFeeReader)
+		if !ok {
+			return errNoBlobFee
+		}
+		fee, err := reader.BlobBaseFee(ctx)
+		if err != nil {
+			return err
+		}
+		b.blobFeeCap = new(big.Int).Mul(fee, big.NewInt(2))
+	}
+	// Nothing to do if the fees were set explicitly
+	if b.gasPrice != nil || (b.gasTipCap != nil && b.gasFeeCap != nil) {
+		return nil
+	}
+	head, err := f.reader.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// Pre-London chains (or explicitly legacy transactions) pay a gas price
+	legacy := b.txType != nil && *b.txType <= coretypes.AccessListTxType
+	if head.BaseFee == nil || legacy {
+		if b.gasTipCap != nil || b.gasFeeCap != nil {
+			return ErrFeeCaps
+		}
+		price, err := f.reader.SuggestGasPrice(ctx)
+		if err != nil {
+			return err
+		}
+		b.gasPrice = price
+		return nil
+	}
+	// London chains pay a tip on top of the base fee, allowing it to double
+	if b.gasTipCap == nil {
+		tip, err := f.reader.SuggestGasTipCap(ctx)
+		if err != nil {
+			return err
+		}
+		b.gasTipCap = tip
+	}
+	if b.gasFeeCap == nil {
+		b.gasFeeCap = new(big.Int).Add(b.gasTipCap, new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package ledger
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFillerSignTx(t *testing.T) {
+	wallet, account, backend := newSimulatedLedger(t)
+	client := backend.Client()
+	filler := NewFiller(wallet, account, client)
+
+	ctx := context.Background()
+	recipient := common.HexToAddress(testRecipient)
+
+	// Only the recipient and value are given, the rest comes from the chain
+	tx, err := filler.SignTx(ctx, NewTxBuilder(nil).ToAddress(recipient).Value(big.NewInt(params.GWei)))
+	require.NoError(t, err)
+	require.Equal(t, uint8(coretypes.DynamicFeeTxType), tx.Type())
+	require.Equal(t, big.NewInt(1337), tx.ChainId())
+	require.Equal(t, uint64(0), tx.Nonce())
+	require.Equal(t, params.TxGas, tx.Gas())
+	require.NotNil(t, tx.GasFeeCap())
+
+	require.NoError(t, client.SendTransaction(ctx, tx))
+	backend.Commit()
+
+	receipt, err := client.TransactionReceipt(ctx, tx.Hash())
+	require.NoError(t, err)
+	require.Equal(t, coretypes.ReceiptStatusSuccessful, receipt.Status)
+
+	balance, err := client.BalanceAt(ctx, recipient, nil)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(params.GWei), balance)
+}
+
+func TestFillerLegacy(t *testing.T) {
+	wallet, account, backend := newSimulatedLedger(t)
+	filler := NewFiller(wallet, account, backend.Client())
+
+	tx, err := filler.Fill(context.Background(), NewTxBuilder(nil).Type(coretypes.LegacyTxType).To(testRecipient))
+	require.NoError(t, err)
+	require.Equal(t, uint8(coretypes.LegacyTxType), tx.Type())
+	require.Positive(t, tx.GasPrice().Sign())
+
+	// Explicit fields are left alone
+	tx, err = filler.Fill(context.Background(), NewTxBuilder(nil).To(testRecipient).Nonce(9).Gas(50000).GasPrice(big.NewInt(params.GWei)))
+	require.NoError(t, err)
+	require.Equal(t, uint64(9), tx.Nonce())
+	require.Equal(t, uint64(50000), tx.Gas())
+	require.Equal(t, big.NewInt(params.GWei), tx.GasPrice())
+}
+
+func TestFillerNonceTracking(t *testing.T) {
+	wallet, account, backend := newSimulatedLedger(t)
+	client := backend.Client()
+	filler := NewFiller(wallet, account, client)
+
+	// Consecutive signings don't collide, even before reaching the pool
+	ctx := context.Background()
+
+	var txs []*coretypes.Transaction
+	for i := 0; i < 3; i++ {
+		tx, err := filler.SignTx(ctx, NewTxBuilder(nil).To(testRecipient))
+		require.NoError(t, err)
+		require.Equal(t, uint64(i), tx.Nonce())
+		txs = append(txs, tx)
+	}
+	// Filling alone doesn't reserve a nonce
+	tx, err := filler.Fill(ctx, NewTxBuilder(nil).To(testRecipient))
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), tx.Nonce())
+
+	for _, tx := range txs {
+		require.NoError(t, client.SendTransaction(ctx, tx))
+	}
+	backend.Commit()
+
+	nonce, err := client.NonceAt(ctx, account.Address, nil)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), nonce)
+
+	// Failed signings don't burn a nonce
+	_, err = filler.SignTx(ctx, NewTxBuilder(nil).To(testRecipient).Gas(params.TxGas).GasFees(big.NewInt(2), big.NewInt(1)))
+	require.ErrorIs(t, err, ErrFeeCaps)
+
+	tx, err = filler.SignTx(ctx, NewTxBuilder(nil).To(testRecipient))
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), tx.Nonce())
+
+	// Discarding the signed transaction needs a reset to reuse its nonce
+	tx, err = filler.Fill(ctx, NewTxBuilder(nil).To(testRecipient))
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), tx.Nonce())
+
+	filler.ResetNonce()
+	tx, err = filler.Fill(ctx, NewTxBuilder(nil).To(testRecipient))
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), tx.Nonce())
+}
@@ -0,0 +1,97 @@
+package gethwallet
+
+import (
+	"errors"
+	"sync"
+
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/event"
+
+	"github.com/evmos/ethereum-ledger-go/accounts"
+)
+
+// errNoBackend is returned when wrapping a nil backend.
+var errNoBackend = errors.New("gethwallet: nil backend")
+
+// Backend adapts a backend of this library (e.g. usbwallet.Hub) to go-ethereum's
+// accounts.Backend, so it can be registered in an accounts.Manager.
+type Backend struct {
+	backend accounts.Backend
+
+	wrapped map[accounts.Wallet]*Wallet // Adapters of the known wallets, kept stable across calls
+	lock    sync.Mutex
+}
+
+// NewBackend wraps a backend into a go-ethereum compatible one.
+func NewBackend(backend accounts.Backend) (*Backend, error) {
+	if backend == nil {
+		return nil, errNoBackend
+	}
+	return &Backend{
+		backend: backend,
+		wrapped: make(map[accounts.Wallet]*Wallet),
+	}, nil
+}
+
+// Wallets implements gethaccounts.Backend, wrapping the current wallets.
+func (b *Backend) Wallets() []gethaccounts.Wallet {
+	wallets := b.backend.Wallets()
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	// Forget the adapters of departed wallets
+	live := make(map[accounts.Wallet]*Wallet, len(wallets))
+	result := make([]gethaccounts.Wallet, len(wallets))
+	for i, wallet := range wallets {
+		live[wallet] = b.wrapLocked(wallet)
+		result[i] = live[wallet]
+	}
+	b.wrapped = live
+	return result
+}
+
+// Subscribe implements gethaccounts.Backend, forwarding the wallet events of the
+// underlying backend with the wallets wrapped.
+func (b *Backend) Subscribe(sink chan<- gethaccounts.WalletEvent) event.Subscription {
+	events := make(chan accounts.WalletEvent)
+	sub := b.backend.Subscribe(events)
+
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case ev := <-events:
+				select {
+				case sink <- gethaccounts.WalletEvent{Wallet: b.wrap(ev.Wallet), Kind: ev.Kind}:
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	})
+}
+
+// wrap returns the adapter of a wallet, creating it if needed.
+func (b *Backend) wrap(wallet accounts.Wallet) *Wallet {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.wrapLocked(wallet)
+}
+
+// wrapLocked returns the adapter of a wallet, assuming the lock is held.
+func (b *Backend) wrapLocked(wallet accounts.Wallet) *Wallet {
+	if w, ok := b.wrapped[wallet]; ok {
+		return w
+	}
+	w := NewWallet(wallet)
+	b.wrapped[wallet] = w
+	return w
+}
+
+var _ gethaccounts.Backend = (*Backend)(nil)
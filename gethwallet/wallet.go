@@ -0,0 +1,281 @@
+// Package gethwallet adapts the wallets and backends of this library to the
+// go-ethereum accounts interfaces, so Ledgers can be registered in a standard
+// accounts.Manager and used by clef or other geth based tooling.
+package gethwallet
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/evmos/ethereum-ledger-go/accounts"
+)
+
+// selfDeriveCycle is the time between account discovery rounds.
+const selfDeriveCycle = time.Second
+
+// personalMessagePrefix is the EIP-191 prefix of personal messages, followed by
+// the message length in decimal.
+const personalMessagePrefix = "\x19Ethereum Signed Message:\n"
+
+// Wallet adapts a wallet of this library to go-ethereum's accounts.Wallet.
+type Wallet struct {
+	wallet accounts.Wallet
+
+	deriveLock  sync.Mutex
+	deriveQuit  chan struct{}                 // Stops the running self-derivation loop
+	deriveNexts []gethaccounts.DerivationPath // Next paths to discover accounts at
+}
+
+// NewWallet wraps a wallet into a go-ethereum compatible one.
+func NewWallet(wallet accounts.Wallet) *Wallet {
+	return &Wallet{wallet: wallet}
+}
+
+// Unwrap returns the underlying wallet, e.g. to sign EIP-712 typed data.
+func (w *Wallet) Unwrap() accounts.Wallet {
+	return w.wallet
+}
+
+// URL implements gethaccounts.Wallet.
+func (w *Wallet) URL() gethaccounts.URL {
+	return w.wallet.URL()
+}
+
+// Status implements gethaccounts.Wallet.
+func (w *Wallet) Status() (string, error) {
+	return w.wallet.Status()
+}
+
+// Open implements gethaccounts.Wallet.
+func (w *Wallet) Open(passphrase string) error {
+	return w.wallet.Open(passphrase)
+}
+
+// Close implements gethaccounts.Wallet, also stopping any account discovery.
+func (w *Wallet) Close() error {
+	w.SelfDerive(nil, nil)
+	return w.wallet.Close()
+}
+
+// Accounts implements gethaccounts.Wallet.
+func (w *Wallet) Accounts() []gethaccounts.Account {
+	accs := w.wallet.Accounts()
+
+	cpy := make([]gethaccounts.Account, len(accs))
+	for i, account := range accs {
+		cpy[i] = toGethAccount(account)
+	}
+	return cpy
+}
+
+// Contains implements gethaccounts.Wallet.
+func (w *Wallet) Contains(account gethaccounts.Account) bool {
+	return w.wallet.Contains(w.toAccount(account))
+}
+
+// Derive implements gethaccounts.Wallet.
+func (w *Wallet) Derive(path gethaccounts.DerivationPath, pin bool) (gethaccounts.Account, error) {
+	account, err := w.wallet.Derive(path, pin)
+	if err != nil {
+		return gethaccounts.Account{}, err
+	}
+	return toGethAccount(account), nil
+}
+
+// SelfDerive implements gethaccounts.Wallet, periodically discovering the accounts
+// at the base paths (and their successors) which have been used on chain, pinning
+// them along with the first unused one of each base. If no chain is given, only
+// the base accounts are pinned. Calling it again replaces the previous bases.
+func (w *Wallet) SelfDerive(bases []gethaccounts.DerivationPath, chain ethereum.ChainStateReader) {
+	w.deriveLock.Lock()
+	defer w.deriveLock.Unlock()
+
+	if w.deriveQuit != nil {
+		close(w.deriveQuit)
+		w.deriveQuit = nil
+	}
+	w.deriveNexts = make([]gethaccounts.DerivationPath, len(bases))
+	for i, base := range bases {
+		w.deriveNexts[i] = make(gethaccounts.DerivationPath, len(base))
+		copy(w.deriveNexts[i], base)
+	}
+	if len(bases) == 0 {
+		return
+	}
+	quit := make(chan struct{})
+	w.deriveQuit = quit
+
+	go func() {
+		for {
+			w.selfDerive(quit, chain)
+
+			select {
+			case <-quit:
+				return
+			case <-time.After(selfDeriveCycle):
+			}
+		}
+	}()
+}
+
+// selfDerive runs a single round of account discovery, continuing from where
+// the previous round left off.
+func (w *Wallet) selfDerive(quit chan struct{}, chain ethereum.ChainStateReader) {
+	w.deriveLock.Lock()
+	defer w.deriveLock.Unlock()
+
+	for i, next := range w.deriveNexts {
+		for {
+			// Bail out if the derivation was replaced or stopped in the mean time
+			select {
+			case <-quit:
+				return
+			default:
+			}
+			path := make(gethaccounts.DerivationPath, len(next))
+			copy(path, next)
+
+			account, err := w.wallet.Derive(path, false)
+			if err != nil {
+				return // Device busy or gone, retry next round
+			}
+			used := false
+			if chain != nil {
+				ctx, cancel := context.WithTimeout(context.Background(), selfDeriveCycle)
+				balance, err := chain.BalanceAt(ctx, account.Address, nil)
+				if err == nil && balance.Sign() == 0 {
+					var nonce uint64
+					nonce, err = chain.NonceAt(ctx, account.Address, nil)
+					used = nonce > 0
+				} else {
+					used = err == nil
+				}
+				cancel()
+				if err != nil {
+					return
+				}
+			}
+			// Pin the account: all used ones, and the first empty one to fill up next
+			copy(path, next)
+			if _, err := w.wallet.Derive(path, true); err != nil {
+				return
+			}
+			if !used {
+				break
+			}
+			next[len(next)-1]++
+			w.deriveNexts[i] = next
+		}
+	}
+}
+
+// SignData implements gethaccounts.Wallet. EIP-191 personal messages (text/plain
+// with the signed message prefix, as passed by clef) are signed via SignText,
+// everything else is passed to the underlying wallet. Signatures are returned in
+// [R || S || V] format with V being 0 or 1.
+func (w *Wallet) SignData(account gethaccounts.Account, mimeType string, data []byte) ([]byte, error) {
+	if mimeType == gethaccounts.MimetypeTextPlain {
+		if text, ok := stripPersonalPrefix(data); ok {
+			return w.SignText(account, text)
+		}
+	}
+	signature, err := w.wallet.SignData(w.toAccount(account), mimeType, data)
+	if err != nil {
+		return nil, err
+	}
+	return normalizeV(signature), nil
+}
+
+// SignDataWithPassphrase implements gethaccounts.Wallet. Since USB wallets don't
+// rely on passphrases, these are silently ignored.
+func (w *Wallet) SignDataWithPassphrase(account gethaccounts.Account, passphrase, mimeType string, data []byte) ([]byte, error) {
+	return w.SignData(account, mimeType, data)
+}
+
+// SignText implements gethaccounts.Wallet, signing the text as an EIP-191 personal
+// message. The signature is in [R || S || V] format with V being 0 or 1.
+func (w *Wallet) SignText(account gethaccounts.Account, text []byte) ([]byte, error) {
+	signature, err := w.wallet.SignText(w.toAccount(account), text)
+	if err != nil {
+		return nil, err
+	}
+	return normalizeV(signature), nil
+}
+
+// SignTextWithPassphrase implements gethaccounts.Wallet. Since USB wallets don't
+// rely on passphrases, these are silently ignored.
+func (w *Wallet) SignTextWithPassphrase(account gethaccounts.Account, passphrase string, text []byte) ([]byte, error) {
+	return w.SignText(account, text)
+}
+
+// SignTx implements gethaccounts.Wallet, decoding the signed transaction returned
+// by the underlying wallet.
+func (w *Wallet) SignTx(account gethaccounts.Account, tx *coretypes.Transaction, chainID *big.Int) (*coretypes.Transaction, error) {
+	raw, err := w.wallet.SignTx(w.toAccount(account), tx, chainID)
+	if err != nil {
+		return nil, err
+	}
+	signed := new(coretypes.Transaction)
+	if err := signed.UnmarshalBinary(raw); err != nil {
+		return nil, err
+	}
+	return signed, nil
+}
+
+// SignTxWithPassphrase implements gethaccounts.Wallet. Since USB wallets don't
+// rely on passphrases, these are silently ignored.
+func (w *Wallet) SignTxWithPassphrase(account gethaccounts.Account, passphrase string, tx *coretypes.Transaction, chainID *big.Int) (*coretypes.Transaction, error) {
+	return w.SignTx(account, tx, chainID)
+}
+
+// toAccount converts a go-ethereum account into one of the underlying wallet,
+// filling in the public key if the account is known.
+func (w *Wallet) toAccount(account gethaccounts.Account) accounts.Account {
+	for _, known := range w.wallet.Accounts() {
+		if known.Address == account.Address {
+			return known
+		}
+	}
+	return accounts.Account{Address: account.Address, URL: account.URL}
+}
+
+// toGethAccount converts an account into a go-ethereum one.
+func toGethAccount(account accounts.Account) gethaccounts.Account {
+	return gethaccounts.Account{Address: account.Address, URL: account.URL}
+}
+
+// stripPersonalPrefix extracts the message from an EIP-191 personal message.
+func stripPersonalPrefix(data []byte) ([]byte, bool) {
+	if !bytes.HasPrefix(data, []byte(personalMessagePrefix)) {
+		return nil, false
+	}
+	data = data[len(personalMessagePrefix):]
+
+	// The length is ambiguous if the message starts with digits, try all splits
+	for i := 1; i <= len(data) && data[i-1] >= '0' && data[i-1] <= '9'; i++ {
+		if length, err := strconv.Atoi(string(data[:i])); err == nil && length == len(data)-i {
+			return data[i:], true
+		}
+	}
+	return nil, false
+}
+
+// normalizeV converts the recovery ID of a 65 byte signature from 27/28 to 0/1,
+// as go-ethereum wallets return them.
+func normalizeV(signature []byte) []byte {
+	if len(signature) == crypto.SignatureLength && signature[crypto.RecoveryIDOffset] >= 27 {
+		signature[crypto.RecoveryIDOffset] -= 27
+	}
+	return signature
+}
+
+var _ gethaccounts.Wallet = (*Wallet)(nil)
@@ -0,0 +1,312 @@
+package gethwallet
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evmos/ethereum-ledger-go/accounts"
+)
+
+// softWallet is an in-memory HD-like wallet deriving a key per path, signing the
+// way the Ledger wallets of this library do.
+type softWallet struct {
+	accounts.Wallet // Unimplemented methods panic
+
+	url gethaccounts.URL
+
+	lock   sync.Mutex
+	keys   map[common.Address]*ecdsa.PrivateKey
+	pinned []accounts.Account
+}
+
+func newSoftWallet(path string) *softWallet {
+	return &softWallet{
+		url:  gethaccounts.URL{Scheme: "soft", Path: path},
+		keys: make(map[common.Address]*ecdsa.PrivateKey),
+	}
+}
+
+func (w *softWallet) URL() gethaccounts.URL        { return w.url }
+func (w *softWallet) Status() (string, error)      { return "ok", nil }
+func (w *softWallet) Open(passphrase string) error { return nil }
+func (w *softWallet) Close() error                 { return nil }
+
+func (w *softWallet) Accounts() []accounts.Account {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return append([]accounts.Account{}, w.pinned...)
+}
+
+func (w *softWallet) Contains(account accounts.Account) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, pinned := range w.pinned {
+		if pinned.Address == account.Address {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *softWallet) Derive(path gethaccounts.DerivationPath, pin bool) (accounts.Account, error) {
+	key, err := crypto.ToECDSA(crypto.Keccak256([]byte(path.String())))
+	if err != nil {
+		return accounts.Account{}, err
+	}
+	account := accounts.Account{
+		Address:   crypto.PubkeyToAddress(key.PublicKey),
+		PublicKey: &key.PublicKey,
+		URL:       w.url,
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.keys[account.Address] = key
+	if pin {
+		for _, pinned := range w.pinned {
+			if pinned.Address == account.Address {
+				return account, nil
+			}
+		}
+		w.pinned = append(w.pinned, account)
+	}
+	return account, nil
+}
+
+func (w *softWallet) key(account accounts.Account) (*ecdsa.PrivateKey, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if account.PublicKey == nil {
+		return nil, gethaccounts.ErrUnknownAccount
+	}
+	key, ok := w.keys[account.Address]
+	if !ok {
+		return nil, gethaccounts.ErrUnknownAccount
+	}
+	return key, nil
+}
+
+func (w *softWallet) SignText(account accounts.Account, text []byte) ([]byte, error) {
+	key, err := w.key(account)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := crypto.Sign(gethaccounts.TextHash(text), key)
+	if err != nil {
+		return nil, err
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	return sig, nil
+}
+
+func (w *softWallet) SignData(account accounts.Account, mimeType string, data []byte) ([]byte, error) {
+	return nil, gethaccounts.ErrNotSupported
+}
+
+func (w *softWallet) SignTx(account accounts.Account, tx *coretypes.Transaction, chainID *big.Int) ([]byte, error) {
+	key, err := w.key(account)
+	if err != nil {
+		return nil, err
+	}
+	signed, err := coretypes.SignTx(tx, coretypes.LatestSignerForChainID(chainID), key)
+	if err != nil {
+		return nil, err
+	}
+	return signed.MarshalBinary()
+}
+
+// softBackend is an in-memory backend of soft wallets.
+type softBackend struct {
+	lock    sync.Mutex
+	wallets []accounts.Wallet
+	feed    event.Feed
+}
+
+func (b *softBackend) Wallets() []accounts.Wallet {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return append([]accounts.Wallet{}, b.wallets...)
+}
+
+func (b *softBackend) Subscribe(sink chan<- accounts.WalletEvent) event.Subscription {
+	return b.feed.Subscribe(sink)
+}
+
+func (b *softBackend) add(wallet accounts.Wallet) {
+	b.lock.Lock()
+	b.wallets = append(b.wallets, wallet)
+	b.lock.Unlock()
+
+	b.feed.Send(accounts.WalletEvent{Wallet: wallet, Kind: gethaccounts.WalletArrived})
+}
+
+// usedChain is a chain state reader where only the given accounts have nonces.
+type usedChain struct {
+	ethereum.ChainStateReader
+
+	used map[common.Address]bool
+}
+
+func (c *usedChain) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	return new(big.Int), nil
+}
+
+func (c *usedChain) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	if c.used[account] {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func basePath() gethaccounts.DerivationPath {
+	path := make(gethaccounts.DerivationPath, len(gethaccounts.DefaultBaseDerivationPath))
+	copy(path, gethaccounts.DefaultBaseDerivationPath)
+	return path
+}
+
+func TestWalletSignText(t *testing.T) {
+	w := NewWallet(newSoftWallet("one"))
+
+	account, err := w.Derive(basePath(), true)
+	require.NoError(t, err)
+
+	text := []byte("hello geth")
+	sig, err := w.SignText(account, text)
+	require.NoError(t, err)
+	require.Less(t, sig[crypto.RecoveryIDOffset], byte(2))
+
+	pubkey, err := crypto.SigToPub(gethaccounts.TextHash(text), sig)
+	require.NoError(t, err)
+	require.Equal(t, account.Address, crypto.PubkeyToAddress(*pubkey))
+
+	// Prefixed personal messages passed as data (as clef does) are signed as text
+	data := []byte("\x19Ethereum Signed Message:\n10hello geth")
+	sig2, err := w.SignDataWithPassphrase(account, "ignored", gethaccounts.MimetypeTextPlain, data)
+	require.NoError(t, err)
+	require.Equal(t, sig, sig2)
+
+	// Anything else goes to the underlying wallet
+	_, err = w.SignData(account, gethaccounts.MimetypeTextPlain, text)
+	require.ErrorIs(t, err, gethaccounts.ErrNotSupported)
+}
+
+func TestStripPersonalPrefix(t *testing.T) {
+	text, ok := stripPersonalPrefix([]byte("\x19Ethereum Signed Message:\n1112345678901"))
+	require.True(t, ok)
+	require.Equal(t, "12345678901", string(text))
+
+	_, ok = stripPersonalPrefix([]byte("\x19Ethereum Signed Message:\n5abc"))
+	require.False(t, ok)
+}
+
+func TestWalletSignTx(t *testing.T) {
+	w := NewWallet(newSoftWallet("one"))
+
+	account, err := w.Derive(basePath(), true)
+	require.NoError(t, err)
+
+	chainID := big.NewInt(1)
+	to := common.HexToAddress("0x0102030405060708091011121314151617181920")
+	tx := coretypes.NewTx(&coretypes.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     7,
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(100),
+		Gas:       21000,
+		To:        &to,
+		Value:     big.NewInt(1000),
+	})
+	signed, err := w.SignTxWithPassphrase(account, "ignored", tx, chainID)
+	require.NoError(t, err)
+	signer := coretypes.LatestSignerForChainID(chainID)
+	require.Equal(t, signer.Hash(tx), signer.Hash(signed))
+
+	sender, err := coretypes.Sender(signer, signed)
+	require.NoError(t, err)
+	require.Equal(t, account.Address, sender)
+
+	// Accounts not known to the wallet are rejected
+	_, err = w.SignTx(gethaccounts.Account{Address: common.Address{1}}, tx, chainID)
+	require.ErrorIs(t, err, gethaccounts.ErrUnknownAccount)
+}
+
+func TestWalletSelfDerive(t *testing.T) {
+	soft := newSoftWallet("one")
+	w := NewWallet(soft)
+	defer w.Close()
+
+	// Mark the first two accounts as used, so the third one is pinned as empty
+	var (
+		chain = &usedChain{used: make(map[common.Address]bool)}
+		path  = basePath()
+	)
+	for i := 0; i < 2; i++ {
+		account, err := soft.Derive(path, false)
+		require.NoError(t, err)
+		chain.used[account.Address] = true
+		path[len(path)-1]++
+	}
+	w.SelfDerive([]gethaccounts.DerivationPath{basePath()}, chain)
+
+	require.Eventually(t, func() bool { return len(w.Accounts()) == 3 }, time.Second, 10*time.Millisecond)
+	require.Never(t, func() bool { return len(w.Accounts()) > 3 }, 3*selfDeriveCycle/2, 50*time.Millisecond)
+
+	for _, account := range w.Accounts() {
+		require.True(t, w.Contains(account))
+	}
+}
+
+func TestWalletSelfDeriveWithoutChain(t *testing.T) {
+	w := NewWallet(newSoftWallet("one"))
+	defer w.Close()
+
+	w.SelfDerive([]gethaccounts.DerivationPath{basePath()}, nil)
+	require.Eventually(t, func() bool { return len(w.Accounts()) == 1 }, time.Second, 10*time.Millisecond)
+}
+
+func TestBackendManager(t *testing.T) {
+	soft := &softBackend{}
+	soft.add(newSoftWallet("one"))
+
+	backend, err := NewBackend(soft)
+	require.NoError(t, err)
+
+	manager := gethaccounts.NewManager(nil, backend)
+	defer manager.Close()
+
+	require.Len(t, manager.Wallets(), 1)
+
+	// Arriving wallets are tracked by the manager
+	second := newSoftWallet("two")
+	account, err := second.Derive(basePath(), true)
+	require.NoError(t, err)
+	soft.add(second)
+
+	require.Eventually(t, func() bool { return len(manager.Wallets()) == 2 }, time.Second, 10*time.Millisecond)
+
+	wallet, err := manager.Find(toGethAccount(account))
+	require.NoError(t, err)
+	require.Same(t, second, wallet.(*Wallet).Unwrap())
+
+	// Wrappers are stable across retrievals
+	require.Same(t, backend.Wallets()[1], backend.Wallets()[1])
+
+	_, err = NewBackend(nil)
+	require.Error(t, err)
+}
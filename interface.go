@@ -1,6 +1,8 @@
 package ledger
 
 import (
+	"github.com/ethereum/go-ethereum/event"
+
 	"github.com/evmos/ethereum-ledger-go/accounts"
 	"github.com/evmos/ethereum-ledger-go/usbwallet"
 )
@@ -13,6 +15,39 @@ func (el EthereumLedger) Wallets() []accounts.Wallet {
 	return el.hub.Wallets()
 }
 
+// Subscribe creates an async subscription to receive notifications on the
+// arrival, opening or departure of Ledger devices.
+func (el EthereumLedger) Subscribe(sink chan<- accounts.WalletEvent) event.Subscription {
+	return el.hub.Subscribe(sink)
+}
+
+// SetTrustedNameResolver configures the resolver used to look up trusted names
+// (e.g. ENS domains) of transaction recipients, displayed by the Ledger instead
+// of the raw address. A nil resolver disables the lookups.
+func (el EthereumLedger) SetTrustedNameResolver(resolver usbwallet.TrustedNameResolver) {
+	el.hub.SetTrustedNameResolver(resolver)
+}
+
+// SetTransactionMetadataProvider configures the provider used to look up clear
+// signing metadata of transactions, displayed by the Ledger instead of blind
+// signing their calldata. A nil provider disables the lookups.
+func (el EthereumLedger) SetTransactionMetadataProvider(provider usbwallet.TransactionMetadataProvider) {
+	el.hub.SetTransactionMetadataProvider(provider)
+}
+
+// SetTypedDataMetadataProvider configures the provider used to look up clear
+// signing metadata of EIP-712 messages, displayed by the Ledger field by field
+// instead of the message hashes. A nil provider disables the lookups.
+func (el EthereumLedger) SetTypedDataMetadataProvider(provider usbwallet.TypedDataMetadataProvider) {
+	el.hub.SetTypedDataMetadataProvider(provider)
+}
+
+// SetCosmosHRP configures the bech32 human readable part used to derive addresses
+// on devices running the Cosmos app instead of the Ethereum one.
+func (el EthereumLedger) SetCosmosHRP(hrp string) {
+	el.hub.SetCosmosHRP(hrp)
+}
+
 func New() (*EthereumLedger, error) {
 	l := &EthereumLedger{}
 	hub, err := usbwallet.NewLedgerHub()
@@ -0,0 +1,71 @@
+package ledger
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evmos/ethereum-ledger-go/internal/testutil"
+	"github.com/evmos/ethereum-ledger-go/usbwallet"
+)
+
+// failingProvider is a metadata provider failing every lookup, to detect which
+// lookups are made.
+type failingProvider struct {
+	err error
+}
+
+func (p failingProvider) TransactionMetadata(tx *coretypes.Transaction, chainID *big.Int) (*usbwallet.TransactionMetadata, error) {
+	return nil, p.err
+}
+
+func (p failingProvider) TypedDataMetadata(typedData apitypes.TypedData) (*usbwallet.TypedDataMetadata, error) {
+	return nil, p.err
+}
+
+func TestEthereumLedgerMetadataProviders(t *testing.T) {
+	hub, _ := testutil.SimulatedHub()
+	ledger := EthereumLedger{hub: hub}
+
+	wallet := ledger.Wallets()[0]
+	require.NoError(t, wallet.Open(""))
+	defer wallet.Close()
+
+	account, err := wallet.Derive(gethaccounts.DefaultBaseDerivationPath, true)
+	require.NoError(t, err)
+
+	typedData := apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {{Name: "name", Type: "string"}},
+			"Mail":         {{Name: "contents", Type: "string"}},
+		},
+		PrimaryType: "Mail",
+		Domain:      apitypes.TypedDataDomain{Name: "Ether Mail"},
+		Message:     apitypes.TypedDataMessage{"contents": "Hello, Bob!"},
+	}
+	tx, err := NewTxBuilder(big.NewInt(1)).ToAddress(account.Address).Gas(50000).GasFees(big.NewInt(1), big.NewInt(1)).Data([]byte{0x01, 0x02, 0x03, 0x04}).Build()
+	require.NoError(t, err)
+
+	// Without providers, messages sign without lookups
+	_, err = wallet.SignTypedData(account, typedData)
+	require.NoError(t, err)
+
+	// The providers configured on the ledger are consulted by its wallets
+	lookupErr := errors.New("lookup failed")
+	ledger.SetTypedDataMetadataProvider(failingProvider{lookupErr})
+	_, err = wallet.SignTypedData(account, typedData)
+	require.ErrorIs(t, err, lookupErr)
+
+	ledger.SetTransactionMetadataProvider(failingProvider{lookupErr})
+	_, err = wallet.SignTx(account, tx, big.NewInt(1))
+	require.ErrorIs(t, err, lookupErr)
+
+	ledger.SetTypedDataMetadataProvider(nil)
+	_, err = wallet.SignTypedData(account, typedData)
+	require.NoError(t, err)
+}
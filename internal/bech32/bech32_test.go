@@ -0,0 +1,33 @@
+package bech32
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	// Known Evmos address of the ethereum account 0x7cb61d4117ae31a12e393a1cfa3bac666481d02e
+	data := []byte{0x7c, 0xb6, 0x1d, 0x41, 0x17, 0xae, 0x31, 0xa1, 0x2e, 0x39, 0x3a, 0x1c, 0xfa, 0x3b, 0xac, 0x66, 0x64, 0x81, 0xd0, 0x2e}
+
+	addr, err := Encode("evmos", data)
+	require.NoError(t, err)
+	require.Equal(t, "evmos10jmp6sgh4cc6zt3e8gw05wavvejgr5pwjnpcky", addr)
+
+	hrp, decoded, err := Decode(addr)
+	require.NoError(t, err)
+	require.Equal(t, "evmos", hrp)
+	require.Equal(t, data, decoded)
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	for _, s := range []string{
+		"evmos10jmp6sgh4cc6zt3e8gw05wavvejgr5pwjnpckz", // bad checksum
+		"Evmos10jmp6sgh4cc6zt3e8gw05wavvejgr5pwjnpcky", // mixed case
+		"evmos1b", // too short
+		"evmos10jmp6sgh4cc6zt3e8gw05wavvejgr5pwjnpckb", // invalid character
+	} {
+		_, _, err := Decode(s)
+		require.Error(t, err, s)
+	}
+}
@@ -0,0 +1,50 @@
+package ethutil
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSignature(t *testing.T) {
+	method, err := ParseSignature("swap((address tokenIn,address tokenOut,uint256 amountIn)[] routes, bytes calldata data,uint256)")
+	require.NoError(t, err)
+
+	require.Equal(t, "swap((address,address,uint256)[],bytes,uint256)", method.Sig)
+	require.Equal(t, []string{"routes", "data", "arg2"}, []string{method.Inputs[0].Name, method.Inputs[1].Name, method.Inputs[2].Name})
+	require.Equal(t, abi.SliceTy, method.Inputs[0].Type.T)
+
+	transfer, err := ParseSignature("transfer(address to,uint256 value)")
+	require.NoError(t, err)
+	require.Equal(t, common.FromHex("0xa9059cbb"), transfer.ID)
+
+	// Canonical signatures without parameter names parse too
+	transfer, err = ParseSignature("transfer(address,uint256)")
+	require.NoError(t, err)
+	require.Equal(t, common.FromHex("0xa9059cbb"), transfer.ID)
+
+	for _, invalid := range []string{"transfer", "transfer(address to", "f((address a) x", "f(foo x)"} {
+		_, err := ParseSignature(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestFormatUnits(t *testing.T) {
+	tests := []struct {
+		amount   int64
+		decimals uint8
+		want     string
+	}{
+		{0, 18, "0"},
+		{1500000, 6, "1.5"},
+		{1, 6, "0.000001"},
+		{-2500, 3, "-2.5"},
+		{42, 0, "42"},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, FormatUnits(big.NewInt(tt.amount), tt.decimals))
+	}
+}
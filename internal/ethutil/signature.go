@@ -0,0 +1,131 @@
+package ethutil
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// ParseSignature parses a human readable function signature with named
+// parameters, e.g. "transfer(address to,uint256 amount)", into an ABI method.
+// Tuple parameters are given as parenthesised component lists, and unnamed
+// parameters are named after their position (arg0, arg1, ...).
+func ParseSignature(sig string) (abi.Method, error) {
+	sig = strings.TrimSpace(sig)
+
+	open := strings.IndexByte(sig, '(')
+	if open <= 0 || !strings.HasSuffix(sig, ")") {
+		return abi.Method{}, fmt.Errorf("invalid function signature %q", sig)
+	}
+	name := strings.TrimSpace(sig[:open])
+
+	params, err := splitParams(sig[open+1 : len(sig)-1])
+	if err != nil {
+		return abi.Method{}, err
+	}
+	inputs := make(abi.Arguments, len(params))
+	for i, param := range params {
+		marshalling, err := parseParam(param, i)
+		if err != nil {
+			return abi.Method{}, err
+		}
+		typ, err := abi.NewType(marshalling.Type, "", marshalling.Components)
+		if err != nil {
+			return abi.Method{}, fmt.Errorf("parameter %q: %w", param, err)
+		}
+		inputs[i] = abi.Argument{Name: marshalling.Name, Type: typ}
+	}
+	return abi.NewMethod(name, name, abi.Function, "", false, false, inputs, nil), nil
+}
+
+// parseParam parses a single, possibly tuple typed function parameter. Unnamed
+// parameters are named after their position.
+func parseParam(param string, index int) (abi.ArgumentMarshaling, error) {
+	var (
+		marshalling abi.ArgumentMarshaling
+		rest        string
+	)
+	if strings.HasPrefix(param, "(") {
+		// Find the end of the tuple's component list
+		depth, end := 0, -1
+		for i, c := range param {
+			if c == '(' {
+				depth++
+			} else if c == ')' {
+				if depth--; depth == 0 {
+					end = i
+					break
+				}
+			}
+		}
+		if end < 0 {
+			return marshalling, fmt.Errorf("unbalanced tuple in parameter %q", param)
+		}
+		components, err := splitParams(param[1:end])
+		if err != nil {
+			return marshalling, err
+		}
+		for i, component := range components {
+			parsed, err := parseParam(component, i)
+			if err != nil {
+				return marshalling, err
+			}
+			marshalling.Components = append(marshalling.Components, parsed)
+		}
+		// Array suffixes stick to the closing parenthesis
+		rest = param[end+1:]
+		suffix := rest
+		if space := strings.IndexByte(rest, ' '); space >= 0 {
+			suffix = rest[:space]
+		}
+		marshalling.Type = "tuple" + suffix
+		rest = rest[len(suffix):]
+	} else {
+		fields := strings.Fields(param)
+		if len(fields) == 0 {
+			return marshalling, fmt.Errorf("empty parameter")
+		}
+		marshalling.Type, rest = fields[0], strings.Join(fields[1:], " ")
+	}
+	// The name is the last word, skipping data location keywords
+	if words := strings.Fields(rest); len(words) > 0 {
+		marshalling.Name = words[len(words)-1]
+	}
+	if marshalling.Name == "" {
+		marshalling.Name = fmt.Sprintf("arg%d", index)
+	}
+	return marshalling, nil
+}
+
+// splitParams splits a comma separated parameter list, leaving the commas within
+// tuples intact.
+func splitParams(list string) ([]string, error) {
+	if strings.TrimSpace(list) == "" {
+		return nil, nil
+	}
+	var (
+		params []string
+		depth  int
+		start  int
+	)
+	for i, c := range list {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth < 0 {
+				return nil, fmt.Errorf("unbalanced parameter list %q", list)
+			}
+		case ',':
+			if depth == 0 {
+				params = append(params, strings.TrimSpace(list[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced parameter list %q", list)
+	}
+	return append(params, strings.TrimSpace(list[start:])), nil
+}
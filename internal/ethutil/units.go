@@ -0,0 +1,30 @@
+// Package ethutil implements the helpers shared by the packages decoding and
+// displaying transactions: function signature parsing and amount formatting.
+package ethutil
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// FormatUnits renders an integer amount as a decimal number with the given
+// number of decimals, trimming trailing zeroes, e.g. wei as ether.
+func FormatUnits(amount *big.Int, decimals uint8) string {
+	if decimals == 0 {
+		return amount.String()
+	}
+	abs := new(big.Int).Abs(amount)
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+
+	whole, frac := new(big.Int).QuoRem(abs, unit, new(big.Int))
+	text := whole.String()
+	if frac.Sign() > 0 {
+		digits := fmt.Sprintf("%0*s", decimals, frac.String())
+		text += "." + strings.TrimRight(digits, "0")
+	}
+	if amount.Sign() < 0 {
+		text = "-" + text
+	}
+	return text
+}
@@ -0,0 +1,30 @@
+// Package flags implements the command line flags shared by the signer daemons.
+package flags
+
+import (
+	"strings"
+
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+)
+
+// Paths collects the derivation paths given by repeated -path flags.
+type Paths []gethaccounts.DerivationPath
+
+// String implements flag.Value, listing the paths comma separated.
+func (p *Paths) String() string {
+	paths := make([]string, len(*p))
+	for i, path := range *p {
+		paths[i] = path.String()
+	}
+	return strings.Join(paths, ",")
+}
+
+// Set implements flag.Value, parsing and appending a derivation path.
+func (p *Paths) Set(value string) error {
+	path, err := gethaccounts.ParseDerivationPath(value)
+	if err != nil {
+		return err
+	}
+	*p = append(*p, path)
+	return nil
+}
@@ -0,0 +1,181 @@
+// Package resolver tracks the accounts derived at a fixed set of paths on the
+// Ledger wallets of a backend, as served by the signer daemons.
+package resolver
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/evmos/ethereum-ledger-go/accounts"
+)
+
+// retryInterval is the time after which wallets failing to open or derive are
+// tried again.
+const retryInterval = 3 * time.Second
+
+var (
+	// ErrUnknownAccount is returned if a request names an account that none of the
+	// Ledger wallets derives.
+	ErrUnknownAccount = errors.New("unknown account")
+
+	// ErrChainID is returned if a transaction requests a chain ID other than the
+	// one the signer is configured with.
+	ErrChainID = errors.New("requested chain ID does not match the signer")
+)
+
+// Account is an account derived on one of the backend's wallets.
+type Account struct {
+	accounts.Account
+	Wallet accounts.Wallet
+	Path   gethaccounts.DerivationPath
+}
+
+// Resolver opens the wallets of a backend as they are discovered and derives the
+// configured paths on them.
+type Resolver struct {
+	backend accounts.Backend
+	paths   []gethaccounts.DerivationPath
+
+	derived  map[accounts.Wallet][]Account // Accounts derived on each wallet
+	failed   map[accounts.Wallet]time.Time // Time after which failed wallets are retried
+	deriving map[accounts.Wallet]struct{}  // Wallets currently being derived
+	lock     sync.Mutex
+}
+
+// New creates a resolver deriving the given paths on the backend's wallets,
+// m/44'/60'/0'/0/0 if none are given.
+func New(backend accounts.Backend, paths []gethaccounts.DerivationPath) *Resolver {
+	if len(paths) == 0 {
+		paths = []gethaccounts.DerivationPath{gethaccounts.DefaultBaseDerivationPath}
+	}
+	return &Resolver{
+		backend:  backend,
+		paths:    paths,
+		derived:  make(map[accounts.Wallet][]Account),
+		failed:   make(map[accounts.Wallet]time.Time),
+		deriving: make(map[accounts.Wallet]struct{}),
+	}
+}
+
+// Accounts returns the accounts derived on all the wallets currently available.
+//
+// Wallets not seen before are opened and derived first, without blocking other
+// callers on the device I/O. Wallets failing to open or derive (e.g. the Ethereum
+// app not running) are retried once a few seconds have passed.
+func (r *Resolver) Accounts() []Account {
+	wallets := r.backend.Wallets()
+
+	// Pick the wallets to derive, claiming them so concurrent calls skip them
+	r.lock.Lock()
+	var (
+		pending []accounts.Wallet
+		live    = make(map[accounts.Wallet]bool)
+		now     = time.Now()
+	)
+	for _, wallet := range wallets {
+		live[wallet] = true
+		if _, ok := r.derived[wallet]; ok {
+			continue
+		}
+		if _, ok := r.deriving[wallet]; ok {
+			continue
+		}
+		if retry, ok := r.failed[wallet]; ok && now.Before(retry) {
+			continue
+		}
+		r.deriving[wallet] = struct{}{}
+		pending = append(pending, wallet)
+	}
+	// Forget about departed wallets
+	for wallet := range r.derived {
+		if !live[wallet] {
+			delete(r.derived, wallet)
+		}
+	}
+	for wallet := range r.failed {
+		if !live[wallet] {
+			delete(r.failed, wallet)
+		}
+	}
+	r.lock.Unlock()
+
+	// Derive the new wallets concurrently outside the lock, each being a separate
+	// device, then collect all the accounts
+	var wg sync.WaitGroup
+	for _, wallet := range pending {
+		wg.Add(1)
+		go func(wallet accounts.Wallet) {
+			defer wg.Done()
+			derived := r.derive(wallet)
+
+			r.lock.Lock()
+			defer r.lock.Unlock()
+
+			delete(r.deriving, wallet)
+			if derived == nil {
+				r.failed[wallet] = time.Now().Add(retryInterval)
+				return
+			}
+			delete(r.failed, wallet)
+			r.derived[wallet] = derived
+		}(wallet)
+	}
+	wg.Wait()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var found []Account
+	for _, wallet := range wallets {
+		found = append(found, r.derived[wallet]...)
+	}
+	return found
+}
+
+// Find looks up the account with the given address, failing with
+// ErrUnknownAccount if none of the wallets derives it.
+func (r *Resolver) Find(addr common.Address) (Account, error) {
+	for _, account := range r.Accounts() {
+		if account.Address == addr {
+			return account, nil
+		}
+	}
+	return Account{}, fmt.Errorf("%w: %s", ErrUnknownAccount, addr)
+}
+
+// ChainID returns the chain ID a transaction is signed for: the requested one if
+// given, otherwise the configured one. Requests for a chain other than the
+// configured one fail with ErrChainID.
+func ChainID(requested *hexutil.Big, configured *big.Int) (*big.Int, error) {
+	if requested == nil {
+		return configured, nil
+	}
+	if configured != nil && requested.ToInt().Cmp(configured) != 0 {
+		return nil, fmt.Errorf("%w: requested %v, configured %v", ErrChainID, requested.ToInt(), configured)
+	}
+	return requested.ToInt(), nil
+}
+
+// derive opens the wallet and derives all the paths on it, returning nil if any
+// of them fail.
+func (r *Resolver) derive(wallet accounts.Wallet) []Account {
+	if err := wallet.Open(""); err != nil && !errors.Is(err, gethaccounts.ErrWalletAlreadyOpen) {
+		return nil
+	}
+	derived := make([]Account, 0, len(r.paths))
+	for _, path := range r.paths {
+		account, err := wallet.Derive(path, true)
+		if err != nil {
+			return nil
+		}
+		derived = append(derived, Account{Account: account, Wallet: wallet, Path: path})
+	}
+	return derived
+}
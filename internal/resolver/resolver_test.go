@@ -0,0 +1,108 @@
+package resolver
+
+import (
+	"errors"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evmos/ethereum-ledger-go/accounts"
+)
+
+// fakeWallet derives a fixed address on every path, optionally blocking until
+// released or failing to open.
+type fakeWallet struct {
+	accounts.Wallet
+
+	addr    common.Address
+	opens   atomic.Int32
+	openErr error
+	entered chan struct{} // Signalled when a derivation starts, if set
+	release chan struct{} // Derivations wait for this to be closed, if set
+}
+
+func (w *fakeWallet) Open(passphrase string) error {
+	w.opens.Add(1)
+	return w.openErr
+}
+
+func (w *fakeWallet) Derive(path gethaccounts.DerivationPath, pin bool) (accounts.Account, error) {
+	if w.entered != nil {
+		w.entered <- struct{}{}
+	}
+	if w.release != nil {
+		<-w.release
+	}
+	return accounts.Account{Address: w.addr}, nil
+}
+
+// fakeBackend is a backend with a fixed set of wallets.
+type fakeBackend struct {
+	accounts.Backend
+	wallets []accounts.Wallet
+}
+
+func (b *fakeBackend) Wallets() []accounts.Wallet {
+	return b.wallets
+}
+
+func TestAccountsDeriveUnlocked(t *testing.T) {
+	slow := &fakeWallet{addr: common.Address{0x01}, entered: make(chan struct{}, 1), release: make(chan struct{})}
+	fast := &fakeWallet{addr: common.Address{0x02}}
+	r := New(&fakeBackend{wallets: []accounts.Wallet{slow, fast}}, nil)
+
+	done := make(chan []Account)
+	go func() { done <- r.Accounts() }()
+	<-slow.entered
+
+	// Lookups must not wait for the device I/O of another wallet
+	require.Eventually(t, func() bool {
+		_, err := r.Find(common.Address{0x02})
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	close(slow.release)
+	require.Len(t, <-done, 2)
+	require.Len(t, r.Accounts(), 2)
+}
+
+func TestAccountsRetryBackoff(t *testing.T) {
+	wallet := &fakeWallet{openErr: errors.New("app not running")}
+	r := New(&fakeBackend{wallets: []accounts.Wallet{wallet}}, nil)
+
+	// Failing wallets are not retried on every call
+	require.Empty(t, r.Accounts())
+	require.Empty(t, r.Accounts())
+	require.Equal(t, int32(1), wallet.opens.Load())
+
+	// Once the backoff passes, they are retried
+	wallet.openErr = nil
+	r.failed[wallet] = time.Now()
+
+	require.Len(t, r.Accounts(), 1)
+	require.Equal(t, int32(2), wallet.opens.Load())
+
+	_, err := r.Find(common.Address{0xff})
+	require.ErrorIs(t, err, ErrUnknownAccount)
+}
+
+func TestChainID(t *testing.T) {
+	configured := big.NewInt(1)
+
+	id, err := ChainID(nil, configured)
+	require.NoError(t, err)
+	require.Equal(t, configured, id)
+
+	id, err = ChainID((*hexutil.Big)(big.NewInt(1)), configured)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), id.Int64())
+
+	_, err = ChainID((*hexutil.Big)(big.NewInt(5)), configured)
+	require.ErrorIs(t, err, ErrChainID)
+}
@@ -0,0 +1,41 @@
+package testutil
+
+import (
+	"testing"
+
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evmos/ethereum-ledger-go/accounts"
+	"github.com/evmos/ethereum-ledger-go/usbwallet"
+	"github.com/evmos/ethereum-ledger-go/usbwallet/simulator"
+)
+
+// Mnemonic is the seed of the simulated Ledgers used across the tests.
+const Mnemonic = "glow spread dentist swamp people siren hint muscle first sausage castle metal cycle abandon accident logic again around mix dial knee organ episode usual"
+
+// Address is the account Mnemonic derives at m/44'/60'/0'/0/0.
+var Address = common.HexToAddress("0xbcf6368dF2C2999893064aDe8C4a4b1b6d3C077B")
+
+// SimulatedHub creates a hub of a single simulated Ledger seeded with Mnemonic,
+// returning the device too, e.g. to make it reject requests.
+func SimulatedHub() (*usbwallet.Hub, *simulator.Device) {
+	device := simulator.New(Mnemonic)
+	return usbwallet.NewSimulatedHub(device), device
+}
+
+// SimulatedWallet opens the wallet of a simulated Ledger seeded with Mnemonic,
+// closed when the test ends, and pins the account at m/44'/60'/0'/0/0.
+func SimulatedWallet(t testing.TB) (accounts.Wallet, accounts.Account, *simulator.Device) {
+	t.Helper()
+
+	hub, device := SimulatedHub()
+	wallet := hub.Wallets()[0]
+	require.NoError(t, wallet.Open(""))
+	t.Cleanup(func() { wallet.Close() })
+
+	account, err := wallet.Derive(gethaccounts.DefaultBaseDerivationPath, true)
+	require.NoError(t, err)
+	return wallet, account, device
+}
@@ -0,0 +1,24 @@
+// Package testutil implements the helpers shared by the tests of several
+// packages.
+package testutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/evmos/ethereum-ledger-go/internal/ethutil"
+)
+
+// PackCall encodes a call to the function with the given signature, e.g.
+// "transfer(address to,uint256 amount)", failing the test on error.
+func PackCall(t testing.TB, signature string, args ...interface{}) []byte {
+	t.Helper()
+
+	method, err := ethutil.ParseSignature(signature)
+	require.NoError(t, err)
+
+	packed, err := method.Inputs.Pack(args...)
+	require.NoError(t, err)
+	return append(method.ID, packed...)
+}
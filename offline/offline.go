@@ -0,0 +1,297 @@
+// Package offline implements an air-gapped signing workflow: transactions are
+// prepared on an online machine into unsigned transaction files, carried over to
+// an offline machine to be signed on its Ledger, and the resulting signed
+// transaction files carried back to be broadcast.
+//
+// Files are JSON documents, e.g.:
+//
+//	{
+//	  "version": 1,
+//	  "chainId": 1,
+//	  "path": "m/44'/60'/0'/0/0",
+//	  "from": "0xbcf6368dF2C2999893064aDe8C4a4b1b6d3C077B",
+//	  "description": "Monthly payroll",
+//	  "tx": {
+//	    "type": "0x2",
+//	    "nonce": "0x7",
+//	    "to": "0x3535353535353535353535353535353535353535",
+//	    "value": "0xde0b6b3a7640000",
+//	    "gas": "0x5208",
+//	    "maxPriorityFeePerGas": "0x3b9aca00",
+//	    "maxFeePerGas": "0x6fc23ac00",
+//	    "input": "0x"
+//	  }
+//	}
+package offline
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+
+	ledger "github.com/evmos/ethereum-ledger-go"
+	"github.com/evmos/ethereum-ledger-go/internal/ethutil"
+)
+
+// Version is the version of the file format written.
+const Version = 1
+
+var (
+	// ErrVersion is returned for files of an unknown format version.
+	ErrVersion = errors.New("offline: unsupported file version")
+
+	// ErrChainID is returned if the chain ID of a file is missing, or doesn't
+	// match the one of the transaction it's created from.
+	ErrChainID = errors.New("offline: invalid chain ID")
+
+	// ErrPath is returned if the derivation path of a file can't be parsed.
+	ErrPath = errors.New("offline: invalid derivation path")
+
+	// ErrSignerMismatch is returned if the account derived on the Ledger, or the
+	// signer recovered from a signed transaction, isn't the expected one.
+	ErrSignerMismatch = errors.New("offline: signer does not match the expected address")
+
+	// ErrTxMismatch is returned if a signed transaction isn't the unsigned one it
+	// was signed from.
+	ErrTxMismatch = errors.New("offline: signed transaction does not match the unsigned one")
+)
+
+// TxFields are the fields of a transaction, in the JSON encoding of the
+// Ethereum RPC API. Blob transactions carry their versioned hashes only, the
+// sidecar being attached by the broadcaster.
+type TxFields struct {
+	Type                 hexutil.Uint64                   `json:"type"`
+	Nonce                hexutil.Uint64                   `json:"nonce"`
+	To                   *common.Address                  `json:"to"` // Nil for contract creations
+	Value                *hexutil.Big                     `json:"value"`
+	Gas                  hexutil.Uint64                   `json:"gas"`
+	GasPrice             *hexutil.Big                     `json:"gasPrice,omitempty"`
+	MaxPriorityFeePerGas *hexutil.Big                     `json:"maxPriorityFeePerGas,omitempty"`
+	MaxFeePerGas         *hexutil.Big                     `json:"maxFeePerGas,omitempty"`
+	Input                hexutil.Bytes                    `json:"input"`
+	AccessList           coretypes.AccessList             `json:"accessList,omitempty"`
+	MaxFeePerBlobGas     *hexutil.Big                     `json:"maxFeePerBlobGas,omitempty"`
+	BlobVersionedHashes  []common.Hash                    `json:"blobVersionedHashes,omitempty"`
+	AuthorizationList    []coretypes.SetCodeAuthorization `json:"authorizationList,omitempty"`
+}
+
+// UnsignedTx is an unsigned transaction file, naming the account expected to
+// sign it.
+type UnsignedTx struct {
+	Version     int            `json:"version"`
+	ChainID     *big.Int       `json:"chainId"`
+	Path        string         `json:"path"` // Derivation path of the signing account
+	From        common.Address `json:"from"` // Address the path is expected to derive
+	Description string         `json:"description,omitempty"`
+	Tx          TxFields       `json:"tx"`
+}
+
+// NewUnsignedTx creates the unsigned transaction file of a transaction, to be
+// signed with the account at the path, expected to derive the given address.
+func NewUnsignedTx(tx *coretypes.Transaction, chainID *big.Int, path gethaccounts.DerivationPath, from common.Address, description string) (*UnsignedTx, error) {
+	if (tx.Type() != coretypes.LegacyTxType || tx.Protected()) && chainID != nil && tx.ChainId().Cmp(chainID) != 0 {
+		return nil, fmt.Errorf("%w: %d, transaction for %d", ErrChainID, chainID, tx.ChainId())
+	}
+	if tx.Type() == coretypes.BlobTxType && tx.BlobTxSidecar() != nil {
+		tx = tx.WithoutBlobTxSidecar()
+	}
+	fields := TxFields{
+		Type:       hexutil.Uint64(tx.Type()),
+		Nonce:      hexutil.Uint64(tx.Nonce()),
+		To:         tx.To(),
+		Value:      (*hexutil.Big)(tx.Value()),
+		Gas:        hexutil.Uint64(tx.Gas()),
+		Input:      tx.Data(),
+		AccessList: tx.AccessList(),
+	}
+	if tx.Type() <= coretypes.AccessListTxType {
+		fields.GasPrice = (*hexutil.Big)(tx.GasPrice())
+	} else {
+		fields.MaxPriorityFeePerGas = (*hexutil.Big)(tx.GasTipCap())
+		fields.MaxFeePerGas = (*hexutil.Big)(tx.GasFeeCap())
+	}
+	if tx.Type() == coretypes.BlobTxType {
+		fields.MaxFeePerBlobGas = (*hexutil.Big)(tx.BlobGasFeeCap())
+		fields.BlobVersionedHashes = tx.BlobHashes()
+	}
+	if tx.Type() == coretypes.SetCodeTxType {
+		fields.AuthorizationList = tx.SetCodeAuthorizations()
+	}
+	file := &UnsignedTx{
+		Version:     Version,
+		ChainID:     chainID,
+		Path:        path.String(),
+		From:        from,
+		Description: description,
+		Tx:          fields,
+	}
+	if _, err := file.Transaction(); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// ReadUnsignedTx reads and validates an unsigned transaction file.
+func ReadUnsignedTx(file string) (*UnsignedTx, error) {
+	blob, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeUnsignedTx(blob)
+}
+
+// DecodeUnsignedTx decodes and validates an unsigned transaction file. Unknown
+// fields are refused, rather than silently dropped from what gets signed.
+func DecodeUnsignedTx(blob []byte) (*UnsignedTx, error) {
+	file := new(UnsignedTx)
+	if err := decodeStrict(blob, file); err != nil {
+		return nil, fmt.Errorf("offline: invalid unsigned transaction file: %v", err)
+	}
+	if _, err := file.Transaction(); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// WriteFile writes the unsigned transaction file.
+func (u *UnsignedTx) WriteFile(file string) error {
+	return writeJSON(file, u)
+}
+
+// DerivationPath parses the derivation path of the signing account.
+func (u *UnsignedTx) DerivationPath() (gethaccounts.DerivationPath, error) {
+	path, err := gethaccounts.ParseDerivationPath(u.Path)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %v", ErrPath, u.Path, err)
+	}
+	return path, nil
+}
+
+// Transaction validates the file and assembles its transaction.
+func (u *UnsignedTx) Transaction() (*coretypes.Transaction, error) {
+	if u.Version != Version {
+		return nil, fmt.Errorf("%w: %d", ErrVersion, u.Version)
+	}
+	if u.ChainID == nil || u.ChainID.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: missing", ErrChainID)
+	}
+	if _, err := u.DerivationPath(); err != nil {
+		return nil, err
+	}
+	if u.From == (common.Address{}) {
+		return nil, fmt.Errorf("%w: expected address missing", ErrSignerMismatch)
+	}
+	f := u.Tx
+	if f.Type > coretypes.SetCodeTxType {
+		return nil, fmt.Errorf("%w: unknown type %d", ledger.ErrTxType, f.Type)
+	}
+	b := ledger.NewTxBuilder(u.ChainID).Type(uint8(f.Type)).Nonce(uint64(f.Nonce)).Value(f.Value.ToInt()).Gas(uint64(f.Gas)).Data(f.Input)
+	if f.To != nil {
+		b.ToAddress(*f.To)
+	}
+	if f.GasPrice != nil {
+		b.GasPrice(f.GasPrice.ToInt())
+	}
+	if f.MaxPriorityFeePerGas != nil || f.MaxFeePerGas != nil {
+		b.GasFees(f.MaxPriorityFeePerGas.ToInt(), f.MaxFeePerGas.ToInt())
+	}
+	if f.AccessList != nil {
+		b.AccessList(f.AccessList)
+	}
+	if f.MaxFeePerBlobGas != nil || f.BlobVersionedHashes != nil {
+		b.BlobHashes(f.MaxFeePerBlobGas.ToInt(), f.BlobVersionedHashes...)
+	}
+	if f.AuthorizationList != nil {
+		b.Authorizations(f.AuthorizationList...)
+	}
+	return b.Build()
+}
+
+// SigningHash returns the hash the Ledger signs for the transaction.
+func (u *UnsignedTx) SigningHash() (common.Hash, error) {
+	tx, err := u.Transaction()
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return coretypes.LatestSignerForChainID(u.ChainID).Hash(tx), nil
+}
+
+// Inspect validates the file and describes the transaction in human readable
+// form, to be reviewed before it's signed.
+func (u *UnsignedTx) Inspect() (string, error) {
+	tx, err := u.Transaction()
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if u.Description != "" {
+		fmt.Fprintf(&b, "Description:  %s\n", u.Description)
+	}
+	fmt.Fprintf(&b, "Chain ID:     %d\n", u.ChainID)
+	fmt.Fprintf(&b, "From:         %s (%s)\n", u.From.Hex(), u.Path)
+	if to := tx.To(); to != nil {
+		fmt.Fprintf(&b, "To:           %s\n", to.Hex())
+	} else {
+		fmt.Fprintf(&b, "To:           contract creation\n")
+	}
+	fmt.Fprintf(&b, "Value:        %s ETH\n", ethutil.FormatUnits(tx.Value(), 18))
+	fmt.Fprintf(&b, "Type:         %d\n", tx.Type())
+	fmt.Fprintf(&b, "Nonce:        %d\n", tx.Nonce())
+	fmt.Fprintf(&b, "Gas limit:    %d\n", tx.Gas())
+	if tx.Type() <= coretypes.AccessListTxType {
+		fmt.Fprintf(&b, "Gas price:    %s gwei\n", ethutil.FormatUnits(tx.GasPrice(), 9))
+	} else {
+		fmt.Fprintf(&b, "Max fee:      %s gwei\n", ethutil.FormatUnits(tx.GasFeeCap(), 9))
+		fmt.Fprintf(&b, "Priority fee: %s gwei\n", ethutil.FormatUnits(tx.GasTipCap(), 9))
+	}
+	maxCost := new(big.Int).Mul(tx.GasFeeCap(), new(big.Int).SetUint64(tx.Gas()))
+	if tx.Type() == coretypes.BlobTxType {
+		fmt.Fprintf(&b, "Blob fee:     %s gwei, %d blobs\n", ethutil.FormatUnits(tx.BlobGasFeeCap(), 9), len(tx.BlobHashes()))
+		maxCost.Add(maxCost, new(big.Int).Mul(tx.BlobGasFeeCap(), new(big.Int).SetUint64(tx.BlobGas())))
+	}
+	fmt.Fprintf(&b, "Max fee cost: %s ETH\n", ethutil.FormatUnits(maxCost, 18))
+	if len(tx.Data()) > 0 {
+		fmt.Fprintf(&b, "Data:         %d bytes, selector %s\n", len(tx.Data()), hexutil.Encode(tx.Data()[:min(4, len(tx.Data()))]))
+	}
+	if n := len(tx.AccessList()); n > 0 {
+		fmt.Fprintf(&b, "Access list:  %d entries\n", n)
+	}
+	for _, auth := range tx.SetCodeAuthorizations() {
+		fmt.Fprintf(&b, "Authorizes:   %s on chain %d, nonce %d\n", auth.Address.Hex(), auth.ChainID.ToBig(), auth.Nonce)
+	}
+	fmt.Fprintf(&b, "Signing hash: %s\n", coretypes.LatestSignerForChainID(u.ChainID).Hash(tx).Hex())
+	return b.String(), nil
+}
+
+// decodeStrict decodes the JSON document, refusing unknown fields and trailing
+// data.
+func decodeStrict(blob []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(blob))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("trailing data")
+	}
+	return nil
+}
+
+// writeJSON writes the value as an indented JSON file, readable by the owner
+// only.
+func writeJSON(file string, v interface{}) error {
+	blob, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(blob, '\n'), 0o600)
+}
@@ -0,0 +1,126 @@
+package offline
+
+import (
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/require"
+
+	ledger "github.com/evmos/ethereum-ledger-go"
+	"github.com/evmos/ethereum-ledger-go/accounts"
+	"github.com/evmos/ethereum-ledger-go/internal/testutil"
+)
+
+var testRecipient = common.HexToAddress("0x3535353535353535353535353535353535353535")
+
+// openWallet opens the wallet of a simulated Ledger.
+func openWallet(t *testing.T) accounts.Wallet {
+	t.Helper()
+
+	wallet, _, _ := testutil.SimulatedWallet(t)
+	return wallet
+}
+
+func TestRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	// Online: prepare the transaction
+	tx, err := ledger.NewTxBuilder(big.NewInt(1)).Nonce(7).ToAddress(testRecipient).Value(big.NewInt(params.Ether)).
+		Gas(21000).GasFees(big.NewInt(params.GWei), big.NewInt(30*params.GWei)).Build()
+	require.NoError(t, err)
+	unsigned, err := NewUnsignedTx(tx, big.NewInt(1), gethaccounts.DefaultBaseDerivationPath, testutil.Address, "Monthly payroll")
+	require.NoError(t, err)
+	require.NoError(t, unsigned.WriteFile(filepath.Join(dir, "unsigned.json")))
+
+	// Offline: review and sign it
+	unsigned, err = ReadUnsignedTx(filepath.Join(dir, "unsigned.json"))
+	require.NoError(t, err)
+	summary, err := unsigned.Inspect()
+	require.NoError(t, err)
+	require.Contains(t, summary, "Monthly payroll")
+	require.Contains(t, summary, "Value:        1 ETH")
+	require.Contains(t, summary, "Max fee:      30 gwei")
+
+	signed, err := Sign(openWallet(t), unsigned)
+	require.NoError(t, err)
+	require.NoError(t, signed.WriteFile(filepath.Join(dir, "signed.json")))
+
+	// Online: validate and broadcast it
+	signed, err = ReadSignedTx(filepath.Join(dir, "signed.json"))
+	require.NoError(t, err)
+	signedTx, err := signed.Transaction()
+	require.NoError(t, err)
+	require.Equal(t, tx.Nonce(), signedTx.Nonce())
+	require.Equal(t, tx.GasFeeCap(), signedTx.GasFeeCap())
+	require.Equal(t, signedTx.Hash(), signed.Hash)
+
+	hash, err := unsigned.SigningHash()
+	require.NoError(t, err)
+	require.Equal(t, coretypes.LatestSignerForChainID(big.NewInt(1)).Hash(signedTx), hash)
+}
+
+func TestSignerMismatch(t *testing.T) {
+	wallet := openWallet(t)
+
+	tx, err := ledger.NewTxBuilder(big.NewInt(5)).ToAddress(testRecipient).Gas(21000).GasPrice(big.NewInt(params.GWei)).Build()
+	require.NoError(t, err)
+
+	// The path derives another account than the expected one
+	unsigned, err := NewUnsignedTx(tx, big.NewInt(5), gethaccounts.DefaultBaseDerivationPath, testRecipient, "")
+	require.NoError(t, err)
+	_, err = Sign(wallet, unsigned)
+	require.ErrorIs(t, err, ErrSignerMismatch)
+
+	// Signed files claiming another signer are refused
+	unsigned.From = testutil.Address
+	signed, err := Sign(wallet, unsigned)
+	require.NoError(t, err)
+	signed.From = testRecipient
+	_, err = signed.Transaction()
+	require.ErrorIs(t, err, ErrSignerMismatch)
+
+	// As are chain IDs differing from the transaction's
+	tx, err = ledger.NewTxBuilder(big.NewInt(5)).ToAddress(testRecipient).Gas(21000).GasFees(big.NewInt(1), big.NewInt(1)).Build()
+	require.NoError(t, err)
+	_, err = NewUnsignedTx(tx, big.NewInt(1), gethaccounts.DefaultBaseDerivationPath, testutil.Address, "")
+	require.ErrorIs(t, err, ErrChainID)
+}
+
+func TestDecodeStrict(t *testing.T) {
+	valid := `{
+		"version": 1,
+		"chainId": 1,
+		"path": "m/44'/60'/0'/0/0",
+		"from": "0xbcf6368dF2C2999893064aDe8C4a4b1b6d3C077B",
+		"tx": {"type": "0x0", "nonce": "0x0", "to": "0x3535353535353535353535353535353535353535", "value": "0x1", "gas": "0x5208", "gasPrice": "0x1", "input": "0x"}
+	}`
+	_, err := DecodeUnsignedTx([]byte(valid))
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		name, old, new string
+		err            error
+	}{
+		{"unknown field", `"version": 1,`, `"version": 1, "memo": "x",`, nil},
+		{"unknown version", `"version": 1`, `"version": 2`, ErrVersion},
+		{"missing chain ID", `"chainId": 1,`, ``, ErrChainID},
+		{"invalid path", `m/44'/60'/0'/0/0`, `m/x`, ErrPath},
+		{"missing signer", `"from": "0xbcf6368dF2C2999893064aDe8C4a4b1b6d3C077B",`, ``, ErrSignerMismatch},
+		{"fees of another type", `"gasPrice": "0x1"`, `"maxFeePerGas": "0x1", "maxPriorityFeePerGas": "0x1"`, ledger.ErrFeeCaps},
+		{"gas below intrinsic", `"gas": "0x5208"`, `"gas": "0x1"`, ledger.ErrGasLimit},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeUnsignedTx([]byte(strings.Replace(valid, tt.old, tt.new, 1)))
+			require.Error(t, err)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package offline
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/evmos/ethereum-ledger-go/accounts"
+)
+
+// SignedTx is a signed transaction file, ready to be broadcast.
+type SignedTx struct {
+	Version     int            `json:"version"`
+	ChainID     *big.Int       `json:"chainId"`
+	From        common.Address `json:"from"`
+	Description string         `json:"description,omitempty"`
+	Hash        common.Hash    `json:"hash"`
+	Raw         hexutil.Bytes  `json:"raw"` // Binary encoding, as taken by eth_sendRawTransaction
+}
+
+// Sign signs the unsigned transaction file on the wallet, which must be open.
+//
+// The account derived at the file's path must be the expected one, which is
+// checked before the Ledger is prompted, and the signed transaction must be the
+// file's and recover to the same account, which is checked after.
+func Sign(wallet accounts.Wallet, file *UnsignedTx) (*SignedTx, error) {
+	tx, err := file.Transaction()
+	if err != nil {
+		return nil, err
+	}
+	path, err := file.DerivationPath()
+	if err != nil {
+		return nil, err
+	}
+	account, err := wallet.Derive(path, true)
+	if err != nil {
+		return nil, err
+	}
+	if account.Address != file.From {
+		return nil, fmt.Errorf("%w: %s derives %s, expected %s", ErrSignerMismatch, file.Path, account.Address.Hex(), file.From.Hex())
+	}
+	raw, err := wallet.SignTx(account, tx, file.ChainID)
+	if err != nil {
+		return nil, err
+	}
+	signed := &SignedTx{
+		Version:     Version,
+		ChainID:     file.ChainID,
+		From:        file.From,
+		Description: file.Description,
+		Raw:         raw,
+	}
+	signedTx, err := signed.Transaction()
+	if err != nil {
+		return nil, err
+	}
+	signer := coretypes.LatestSignerForChainID(file.ChainID)
+	if signer.Hash(signedTx) != signer.Hash(tx) {
+		return nil, ErrTxMismatch
+	}
+	signed.Hash = signedTx.Hash()
+	return signed, nil
+}
+
+// ReadSignedTx reads and validates a signed transaction file.
+func ReadSignedTx(file string) (*SignedTx, error) {
+	blob, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeSignedTx(blob)
+}
+
+// DecodeSignedTx decodes and validates a signed transaction file.
+func DecodeSignedTx(blob []byte) (*SignedTx, error) {
+	file := new(SignedTx)
+	if err := decodeStrict(blob, file); err != nil {
+		return nil, fmt.Errorf("offline: invalid signed transaction file: %v", err)
+	}
+	tx, err := file.Transaction()
+	if err != nil {
+		return nil, err
+	}
+	if tx.Hash() != file.Hash {
+		return nil, fmt.Errorf("%w: hash %s, file states %s", ErrTxMismatch, tx.Hash().Hex(), file.Hash.Hex())
+	}
+	return file, nil
+}
+
+// WriteFile writes the signed transaction file.
+func (s *SignedTx) WriteFile(file string) error {
+	return writeJSON(file, s)
+}
+
+// Transaction decodes the signed transaction, validating it's signed by the
+// expected account for the file's chain.
+func (s *SignedTx) Transaction() (*coretypes.Transaction, error) {
+	if s.Version != Version {
+		return nil, fmt.Errorf("%w: %d", ErrVersion, s.Version)
+	}
+	if s.ChainID == nil || s.ChainID.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: missing", ErrChainID)
+	}
+	tx := new(coretypes.Transaction)
+	if err := tx.UnmarshalBinary(s.Raw); err != nil {
+		return nil, fmt.Errorf("offline: invalid signed transaction: %v", err)
+	}
+	if tx.Type() != coretypes.LegacyTxType || tx.Protected() {
+		if tx.ChainId().Cmp(s.ChainID) != 0 {
+			return nil, fmt.Errorf("%w: %d, transaction signed for %d", ErrChainID, s.ChainID, tx.ChainId())
+		}
+	}
+	from, err := coretypes.Sender(coretypes.LatestSignerForChainID(s.ChainID), tx)
+	if err != nil {
+		return nil, fmt.Errorf("offline: invalid signature: %v", err)
+	}
+	if from != s.From {
+		return nil, fmt.Errorf("%w: signed by %s, expected %s", ErrSignerMismatch, from.Hex(), s.From.Hex())
+	}
+	return tx, nil
+}
@@ -0,0 +1,330 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/gofrs/flock"
+
+	"github.com/evmos/ethereum-ledger-go/accounts"
+)
+
+// limitStoreVersion is the version of the limit store format.
+const limitStoreVersion = 1
+
+// ERC-20 functions moving or granting tokens, counted towards token limits.
+var (
+	selectorTransfer     = [4]byte{0xa9, 0x05, 0x9c, 0xbb} // transfer(address,uint256)
+	selectorTransferFrom = [4]byte{0x23, 0xb8, 0x72, 0xdd} // transferFrom(address,address,uint256)
+	selectorApprove      = [4]byte{0x09, 0x5e, 0xa7, 0xb3} // approve(address,uint256)
+)
+
+// Duration is a time.Duration encoded in JSON as a string, e.g. "24h".
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(input []byte) error {
+	var s string
+	if err := json.Unmarshal(input, &s); err != nil {
+		return err
+	}
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+// Limit caps the value an account sends, and the number of transactions it
+// signs, within a rolling window.
+//
+// Token limits count the amounts of ERC-20 transfer, transferFrom and approve
+// calls to the token contract, approvals being counted as spent since the
+// spender may move the tokens at will.
+type Limit struct {
+	Account  *common.Address       `json:"account,omitempty"`  // Account limited, every account separately if nil
+	Token    *common.Address       `json:"token,omitempty"`    // Token contract limited, ether if nil
+	Window   Duration              `json:"window"`             // Rolling window, e.g. "24h"
+	MaxValue *math.HexOrDecimal256 `json:"maxValue,omitempty"` // Cap on the value (in wei or token units) sent within the window
+	MaxCount uint64                `json:"maxCount,omitempty"` // Cap on the transactions (or token calls) within the window
+}
+
+// limitRecord is a value sent by an account, or reserved to be sent.
+type limitRecord struct {
+	Time    time.Time             `json:"time"`
+	Account common.Address        `json:"account"`
+	Token   *common.Address       `json:"token,omitempty"`
+	Value   *math.HexOrDecimal256 `json:"value"`
+	Hash    common.Hash           `json:"hash"` // Signing hash of the transaction, releasing reservations
+}
+
+// limitStore is the content of the store file.
+type limitStore struct {
+	Version int            `json:"version"`
+	Records []*limitRecord `json:"records"`
+}
+
+// Limiter is a policy enforcing persistent limits on the transactions signed,
+// tracking them in a JSON store file. The store is locked while evaluating a
+// transaction, so limits hold across restarts and across concurrent signers on
+// the same machine sharing the store.
+//
+// Transactions are counted once allowed, and released if their signing fails.
+// A signer crashing in between leaves the transaction counted.
+type Limiter struct {
+	file   string
+	limits []Limit
+	retain time.Duration // Longest window, beyond which records are dropped
+
+	flock *flock.Flock
+	now   func() time.Time
+	lock  sync.Mutex
+}
+
+// NewLimiter creates a limiter enforcing the limits, keeping its state in the
+// store file, created if missing.
+func NewLimiter(file string, limits []Limit) (*Limiter, error) {
+	l := &Limiter{
+		file:   file,
+		limits: limits,
+		flock:  flock.New(file + ".lock"),
+		now:    time.Now,
+	}
+	for i, limit := range limits {
+		if limit.Window <= 0 {
+			return nil, fmt.Errorf("policy: limit %d lacks a window", i)
+		}
+		if limit.MaxValue == nil && limit.MaxCount == 0 {
+			return nil, fmt.Errorf("policy: limit %d caps neither value nor count", i)
+		}
+		l.retain = max(l.retain, time.Duration(limit.Window))
+	}
+	// Ensure the store is usable upfront
+	if err := l.update(func(*limitStore) error { return nil }); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// CheckTx implements Policy, reserving the transaction against the limits if
+// allowed.
+func (l *Limiter) CheckTx(account accounts.Account, tx *coretypes.Transaction, chainID *big.Int) error {
+	now := l.now()
+	records := txRecords(account.Address, tx, chainID, now)
+
+	return l.update(func(store *limitStore) error {
+		for i, limit := range l.limits {
+			if limit.Account != nil && *limit.Account != account.Address {
+				continue
+			}
+			var (
+				value = new(big.Int)
+				count uint64
+				hit   bool
+			)
+			for _, r := range records {
+				if limit.covers(r) {
+					value.Add(value, (*big.Int)(r.Value))
+					count++
+					hit = true
+				}
+			}
+			if !hit {
+				continue
+			}
+			cutoff := now.Add(-time.Duration(limit.Window))
+			for _, r := range store.Records {
+				if r.Account == account.Address && r.Time.After(cutoff) && limit.covers(r) {
+					value.Add(value, (*big.Int)(r.Value))
+					count++
+				}
+			}
+			if limit.MaxValue != nil && value.Cmp((*big.Int)(limit.MaxValue)) > 0 {
+				return reject(limitRule(i), "%s value %v within %v would exceed %v", limit.asset(), value, time.Duration(limit.Window), (*big.Int)(limit.MaxValue))
+			}
+			if limit.MaxCount != 0 && count > limit.MaxCount {
+				return reject(limitRule(i), "%s transactions within %v would exceed %d", limit.asset(), time.Duration(limit.Window), limit.MaxCount)
+			}
+		}
+		store.Records = append(store.Records, records...)
+		return nil
+	})
+}
+
+// CheckTypedData implements Policy, typed data being unlimited.
+func (l *Limiter) CheckTypedData(account accounts.Account, typedData apitypes.TypedData) error {
+	return nil
+}
+
+// CheckAuthorization implements Policy, authorizations moving no value.
+func (l *Limiter) CheckAuthorization(account accounts.Account, auth coretypes.SetCodeAuthorization) error {
+	return nil
+}
+
+// ReleaseTx implements Releaser, uncounting a transaction that failed to sign.
+func (l *Limiter) ReleaseTx(account accounts.Account, tx *coretypes.Transaction, chainID *big.Int) {
+	hash := coretypes.LatestSignerForChainID(chainID).Hash(tx)
+
+	l.update(func(store *limitStore) error {
+		records := store.Records[:0]
+		for _, r := range store.Records {
+			if r.Account != account.Address || r.Hash != hash {
+				records = append(records, r)
+			}
+		}
+		store.Records = records
+		return nil
+	})
+}
+
+// update runs the function on the store content, while holding the store lock,
+// saving the changes if it succeeds. Records beyond the longest window are
+// dropped.
+func (l *Limiter) update(fn func(store *limitStore) error) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if err := l.flock.Lock(); err != nil {
+		return fmt.Errorf("policy: failed to lock limit store: %v", err)
+	}
+	defer l.flock.Unlock()
+
+	store := &limitStore{Version: limitStoreVersion}
+	blob, err := os.ReadFile(l.file)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return err
+	case len(bytes.TrimSpace(blob)) > 0:
+		if err := json.Unmarshal(blob, store); err != nil {
+			return fmt.Errorf("policy: corrupt limit store: %v", err)
+		}
+		if store.Version != limitStoreVersion {
+			return fmt.Errorf("policy: unsupported limit store version %d", store.Version)
+		}
+	}
+	cutoff := l.now().Add(-l.retain)
+	records := store.Records[:0]
+	for _, r := range store.Records {
+		if r.Time.After(cutoff) {
+			records = append(records, r)
+		}
+	}
+	store.Records = records
+
+	if err := fn(store); err != nil {
+		return err
+	}
+	return writeFileAtomic(l.file, store)
+}
+
+// writeFileAtomic writes the value as JSON into a temporary file, renamed over
+// the target so readers never see a partial write.
+func writeFileAtomic(file string, v interface{}) error {
+	blob, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(blob); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// txRecords returns the records a transaction counts as: its ether value, and
+// the token amount of ERC-20 calls.
+func txRecords(account common.Address, tx *coretypes.Transaction, chainID *big.Int, now time.Time) []*limitRecord {
+	hash := coretypes.LatestSignerForChainID(chainID).Hash(tx)
+	records := []*limitRecord{{
+		Time:    now,
+		Account: account,
+		Value:   (*math.HexOrDecimal256)(tx.Value()),
+		Hash:    hash,
+	}}
+	if amount := tokenAmount(tx.Data()); amount != nil && tx.To() != nil {
+		records = append(records, &limitRecord{
+			Time:    now,
+			Account: account,
+			Token:   tx.To(),
+			Value:   (*math.HexOrDecimal256)(amount),
+			Hash:    hash,
+		})
+	}
+	return records
+}
+
+// tokenAmount returns the amount moved or granted by ERC-20 calldata, nil if the
+// calldata isn't a transfer, transferFrom or approve call.
+func tokenAmount(data []byte) *big.Int {
+	if len(data) < 4 {
+		return nil
+	}
+	switch [4]byte(data[:4]) {
+	case selectorTransfer, selectorApprove:
+		if len(data) >= 4+2*32 {
+			return new(big.Int).SetBytes(data[4+32 : 4+2*32])
+		}
+	case selectorTransferFrom:
+		if len(data) >= 4+3*32 {
+			return new(big.Int).SetBytes(data[4+2*32 : 4+3*32])
+		}
+	}
+	return nil
+}
+
+// covers returns whether the record counts towards the limit's asset.
+func (limit *Limit) covers(r *limitRecord) bool {
+	if limit.Token == nil {
+		return r.Token == nil
+	}
+	return r.Token != nil && *r.Token == *limit.Token
+}
+
+// asset describes the asset limited, for rejections.
+func (limit *Limit) asset() string {
+	if limit.Token == nil {
+		return "ether"
+	}
+	return "token " + limit.Token.Hex()
+}
+
+// limitRule names the rule of the limit at the index, for rejections.
+func limitRule(index int) string {
+	return fmt.Sprintf("%s[%d]", RuleLimits, index)
+}
+
+var (
+	_ Policy   = (*Limiter)(nil)
+	_ Releaser = (*Limiter)(nil)
+)
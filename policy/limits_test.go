@@ -0,0 +1,151 @@
+package policy
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/require"
+
+	ledger "github.com/evmos/ethereum-ledger-go"
+	"github.com/evmos/ethereum-ledger-go/accounts"
+	"github.com/evmos/ethereum-ledger-go/internal/testutil"
+	"github.com/evmos/ethereum-ledger-go/usbwallet"
+)
+
+var testToken = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+
+// ethTx creates a transaction sending the ether value, of the given nonce so
+// transactions differ.
+func ethTx(nonce uint64, value int64) *coretypes.Transaction {
+	return coretypes.NewTx(&coretypes.DynamicFeeTx{
+		ChainID: big.NewInt(1), Nonce: nonce, To: &testRecipient, Gas: 21000,
+		Value: new(big.Int).Mul(big.NewInt(value), big.NewInt(params.Ether)), GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(1),
+	})
+}
+
+// tokenTx creates a transaction transferring the token amount.
+func tokenTx(nonce uint64, amount int64) *coretypes.Transaction {
+	data := append(append(selectorTransfer[:], common.LeftPadBytes(testRecipient[:], 32)...), common.LeftPadBytes(big.NewInt(amount).Bytes(), 32)...)
+	return coretypes.NewTx(&coretypes.DynamicFeeTx{
+		ChainID: big.NewInt(1), Nonce: nonce, To: &testToken, Gas: 60000, Data: data, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(1),
+	})
+}
+
+func TestLimiter(t *testing.T) {
+	store := filepath.Join(t.TempDir(), "limits.json")
+	limits := []Limit{
+		{Window: Duration(time.Hour), MaxValue: (*math.HexOrDecimal256)(new(big.Int).Mul(big.NewInt(3), big.NewInt(params.Ether))), MaxCount: 3},
+		{Token: &testToken, Window: Duration(24 * time.Hour), MaxValue: math.NewHexOrDecimal256(1000)},
+	}
+	limiter, err := NewLimiter(store, limits)
+	require.NoError(t, err)
+
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	alice := accounts.Account{Address: common.HexToAddress("0x1111111111111111111111111111111111111111")}
+	bob := accounts.Account{Address: common.HexToAddress("0x2222222222222222222222222222222222222222")}
+
+	// Ether values accumulate per account
+	require.NoError(t, limiter.CheckTx(alice, ethTx(0, 2), big.NewInt(1)))
+	requireRejected(t, limiter.CheckTx(alice, ethTx(1, 2), big.NewInt(1)), "limits[0]")
+	require.NoError(t, limiter.CheckTx(bob, ethTx(0, 3), big.NewInt(1)))
+
+	// Token amounts too, also counting as transactions
+	require.NoError(t, limiter.CheckTx(alice, tokenTx(1, 600), big.NewInt(1)))
+	requireRejected(t, limiter.CheckTx(alice, tokenTx(2, 600), big.NewInt(1)), "limits[1]")
+	require.NoError(t, limiter.CheckTx(alice, ethTx(2, 0), big.NewInt(1)))
+	requireRejected(t, limiter.CheckTx(alice, ethTx(3, 0), big.NewInt(1)), "limits[0]")
+
+	// Released transactions don't count
+	limiter.ReleaseTx(alice, ethTx(2, 0), big.NewInt(1))
+	require.NoError(t, limiter.CheckTx(alice, ethTx(3, 0), big.NewInt(1)))
+
+	// State survives restarts, and windows roll
+	limiter, err = NewLimiter(store, limits)
+	require.NoError(t, err)
+	limiter.now = func() time.Time { return now }
+	requireRejected(t, limiter.CheckTx(alice, ethTx(4, 1), big.NewInt(1)), "limits[0]")
+
+	now = now.Add(time.Hour)
+	require.NoError(t, limiter.CheckTx(alice, ethTx(4, 3), big.NewInt(1)))
+	requireRejected(t, limiter.CheckTx(alice, tokenTx(5, 600), big.NewInt(1)), "limits[1]")
+
+	// Records beyond the longest window are dropped
+	now = now.Add(24 * time.Hour)
+	require.NoError(t, limiter.CheckTx(alice, tokenTx(5, 1000), big.NewInt(1)))
+
+	var content limitStore
+	blob, err := os.ReadFile(store)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(blob, &content))
+	require.Len(t, content.Records, 2) // Ether and token records of the last transaction
+}
+
+func TestLimiterConcurrent(t *testing.T) {
+	store := filepath.Join(t.TempDir(), "limits.json")
+	limits := []Limit{{Window: Duration(time.Hour), MaxCount: 10}}
+	account := accounts.Account{Address: common.HexToAddress("0x1111111111111111111111111111111111111111")}
+
+	// Separate limiters share the store as separate processes would
+	var (
+		allowed int
+		lock    sync.Mutex
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < 4; i++ {
+		limiter, err := NewLimiter(store, limits)
+		require.NoError(t, err)
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if limiter.CheckTx(account, ethTx(uint64(i*10+j), 0), big.NewInt(1)) == nil {
+					lock.Lock()
+					allowed++
+					lock.Unlock()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	require.Equal(t, 10, allowed)
+}
+
+func TestRulePolicyLimits(t *testing.T) {
+	policy, err := NewRulePolicy(&Rules{
+		Limits:     []Limit{{Window: Duration(time.Hour), MaxCount: 1}},
+		LimitStore: filepath.Join(t.TempDir(), "limits.json"),
+	})
+	require.NoError(t, err)
+
+	inner, account, device := testutil.SimulatedWallet(t)
+	wallet := NewWallet(inner, policy)
+
+	builder := ledger.NewTxBuilder(big.NewInt(1)).ToAddress(testRecipient).Gas(21000).GasFees(big.NewInt(1), big.NewInt(1))
+
+	// Transactions denied on the device are released
+	device.SetRejecting(true)
+	_, err = builder.Sign(wallet, account)
+	require.ErrorIs(t, err, usbwallet.ErrLedgerUserRejected)
+
+	device.SetRejecting(false)
+	_, err = builder.Sign(wallet, account)
+	require.NoError(t, err)
+	_, err = builder.Nonce(1).Sign(wallet, account)
+	requireRejected(t, err, "limits[0]")
+
+	// Limits require a store
+	_, err = NewRulePolicy(&Rules{Limits: []Limit{{Window: Duration(time.Hour), MaxCount: 1}}})
+	require.Error(t, err)
+}
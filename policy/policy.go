@@ -0,0 +1,240 @@
+// Package policy evaluates signing requests against server-side rules before the
+// Ledger is asked, so transactions and typed data outside of what an operator
+// allows are refused without ever reaching the device.
+//
+// Wallets and backends are wrapped with a Policy, e.g. one loaded from a rule
+// file with LoadRules, and used in place of the originals.
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+
+	"github.com/evmos/ethereum-ledger-go/accounts"
+	"github.com/evmos/ethereum-ledger-go/ethermint"
+)
+
+// ErrRejected is the error all rejections match with errors.Is.
+var ErrRejected = errors.New("policy: request rejected")
+
+// Rejection is returned if a request violates a rule of the policy.
+type Rejection struct {
+	Rule   string `json:"rule"`   // Name of the violated rule, e.g. "recipients"
+	Reason string `json:"reason"` // Human readable description of the violation
+}
+
+// Error implements error.
+func (r *Rejection) Error() string {
+	return fmt.Sprintf("policy: rejected by rule %s: %s", r.Rule, r.Reason)
+}
+
+// Unwrap makes rejections match ErrRejected.
+func (r *Rejection) Unwrap() error {
+	return ErrRejected
+}
+
+// reject creates a rejection of the named rule with the formatted reason.
+func reject(rule string, format string, args ...interface{}) *Rejection {
+	return &Rejection{Rule: rule, Reason: fmt.Sprintf(format, args...)}
+}
+
+// Policy decides whether signing requests are allowed.
+type Policy interface {
+	// CheckTx returns a *Rejection if the account may not sign the transaction.
+	CheckTx(account accounts.Account, tx *coretypes.Transaction, chainID *big.Int) error
+
+	// CheckTypedData returns a *Rejection if the account may not sign the typed
+	// data.
+	CheckTypedData(account accounts.Account, typedData apitypes.TypedData) error
+
+	// CheckAuthorization returns a *Rejection if the account may not sign the
+	// EIP-7702 authorization, delegating its code.
+	CheckAuthorization(account accounts.Account, auth coretypes.SetCodeAuthorization) error
+}
+
+// Recorder is implemented by policies tracking the transactions signed, e.g. to
+// cap the value sent per day. Transactions are recorded once signed.
+type Recorder interface {
+	RecordTx(account accounts.Account, tx *coretypes.Transaction, chainID *big.Int)
+}
+
+// Releaser is implemented by policies counting transactions once allowed, e.g.
+// to enforce limits across concurrent signers. Transactions failing to sign are
+// released.
+type Releaser interface {
+	ReleaseTx(account accounts.Account, tx *coretypes.Transaction, chainID *big.Int)
+}
+
+// Wallet enforces a policy on the transactions, typed data and authorizations
+// signed by a wallet. Amino JSON sign docs are checked as the EIP-712 typed data
+// Ethermint chains verify them against. Other requests are passed through, except
+// for prehashed EIP-712 data which can't be checked and is rejected.
+type Wallet struct {
+	accounts.Wallet
+	policy Policy
+
+	lock *sync.Mutex // Makes checking, signing and recording atomic, shared across a backend
+}
+
+// NewWallet wraps a wallet, enforcing the policy on its signing requests.
+func NewWallet(wallet accounts.Wallet, policy Policy) *Wallet {
+	return &Wallet{Wallet: wallet, policy: policy, lock: new(sync.Mutex)}
+}
+
+// Unwrap returns the underlying wallet.
+func (w *Wallet) Unwrap() accounts.Wallet {
+	return w.Wallet
+}
+
+// SignTx implements accounts.Wallet, signing the transaction if allowed by the
+// policy.
+func (w *Wallet) SignTx(account accounts.Account, tx *coretypes.Transaction, chainID *big.Int) ([]byte, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if err := w.policy.CheckTx(account, tx, chainID); err != nil {
+		return nil, err
+	}
+	raw, err := w.Wallet.SignTx(account, tx, chainID)
+	if err != nil {
+		if releaser, ok := w.policy.(Releaser); ok {
+			releaser.ReleaseTx(account, tx, chainID)
+		}
+		return nil, err
+	}
+	if recorder, ok := w.policy.(Recorder); ok {
+		recorder.RecordTx(account, tx, chainID)
+	}
+	return raw, nil
+}
+
+// SignTypedData implements accounts.Wallet, signing the typed data if allowed by
+// the policy.
+func (w *Wallet) SignTypedData(account accounts.Account, typedData apitypes.TypedData) ([]byte, error) {
+	if err := w.policy.CheckTypedData(account, typedData); err != nil {
+		return nil, err
+	}
+	return w.Wallet.SignTypedData(account, typedData)
+}
+
+// SignData implements accounts.Wallet, checking Amino JSON sign docs as typed
+// data and rejecting prehashed EIP-712 data.
+func (w *Wallet) SignData(account accounts.Account, mimeType string, data []byte) ([]byte, error) {
+	switch mimeType {
+	case gethaccounts.MimetypeTypedData:
+		return nil, reject("typedData", "prehashed typed data can't be checked, sign it unhashed")
+
+	case accounts.MimetypeAminoJSON:
+		typedData, err := ethermint.WrapSignDoc(data, "")
+		if err != nil {
+			return nil, reject("typedData", "sign doc can't be checked: %v", err)
+		}
+		if err := w.policy.CheckTypedData(account, typedData); err != nil {
+			return nil, err
+		}
+	}
+	return w.Wallet.SignData(account, mimeType, data)
+}
+
+// SignAuthorization implements accounts.Wallet, signing the EIP-7702
+// authorization if allowed by the policy.
+func (w *Wallet) SignAuthorization(account accounts.Account, auth coretypes.SetCodeAuthorization) (coretypes.SetCodeAuthorization, error) {
+	if err := w.policy.CheckAuthorization(account, auth); err != nil {
+		return coretypes.SetCodeAuthorization{}, err
+	}
+	return w.Wallet.SignAuthorization(account, auth)
+}
+
+// Backend enforces a policy on the wallets of a backend.
+type Backend struct {
+	backend accounts.Backend
+	policy  Policy
+
+	wrapped map[accounts.Wallet]*Wallet // Wrappers of the known wallets, kept stable across calls
+	sign    sync.Mutex                  // Signing lock shared by the wallets
+	lock    sync.Mutex
+}
+
+// NewBackend wraps a backend, enforcing the policy on the signing requests of
+// all its wallets.
+func NewBackend(backend accounts.Backend, policy Policy) *Backend {
+	return &Backend{
+		backend: backend,
+		policy:  policy,
+		wrapped: make(map[accounts.Wallet]*Wallet),
+	}
+}
+
+// Wallets implements accounts.Backend, wrapping the current wallets.
+func (b *Backend) Wallets() []accounts.Wallet {
+	wallets := b.backend.Wallets()
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	// Forget the wrappers of departed wallets
+	live := make(map[accounts.Wallet]*Wallet, len(wallets))
+	result := make([]accounts.Wallet, len(wallets))
+	for i, wallet := range wallets {
+		live[wallet] = b.wrapLocked(wallet)
+		result[i] = live[wallet]
+	}
+	b.wrapped = live
+	return result
+}
+
+// Subscribe implements accounts.Backend, forwarding the wallet events of the
+// underlying backend with the wallets wrapped.
+func (b *Backend) Subscribe(sink chan<- accounts.WalletEvent) event.Subscription {
+	events := make(chan accounts.WalletEvent)
+	sub := b.backend.Subscribe(events)
+
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case ev := <-events:
+				ev.Wallet = b.wrap(ev.Wallet)
+				select {
+				case sink <- ev:
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	})
+}
+
+// wrap returns the wrapper of a wallet, creating it if needed.
+func (b *Backend) wrap(wallet accounts.Wallet) *Wallet {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.wrapLocked(wallet)
+}
+
+// wrapLocked returns the wrapper of a wallet, assuming the lock is held.
+func (b *Backend) wrapLocked(wallet accounts.Wallet) *Wallet {
+	if w, ok := b.wrapped[wallet]; ok {
+		return w
+	}
+	w := &Wallet{Wallet: wallet, policy: b.policy, lock: &b.sign}
+	b.wrapped[wallet] = w
+	return w
+}
+
+var (
+	_ accounts.Wallet  = (*Wallet)(nil)
+	_ accounts.Backend = (*Backend)(nil)
+)
@@ -0,0 +1,244 @@
+package policy
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
+
+	ledger "github.com/evmos/ethereum-ledger-go"
+	"github.com/evmos/ethereum-ledger-go/accounts"
+	"github.com/evmos/ethereum-ledger-go/internal/testutil"
+	"github.com/evmos/ethereum-ledger-go/usbwallet"
+)
+
+const (
+	testRules = `{
+		"chainIds": [1, "0xa"],
+		"recipients": ["0x3535353535353535353535353535353535353535"],
+		"maxValue": "2000000000000000000",
+		"maxDailyValue": "3000000000000000000",
+		"selectors": ["transfer(address,uint256)"],
+		"maxFeePerGas": "100000000000",
+		"delegates": ["0x63c0c19a282a1B52b07dD5a65b58948A07DAE32B"],
+		"typedData": {
+			"domains": [{"name": "Ether Mail", "chainId": 1}],
+			"primaryTypes": ["Mail"]
+		}
+	}`
+	testTyped = `{
+		"types": {
+			"EIP712Domain": [{"name": "name", "type": "string"}, {"name": "chainId", "type": "uint256"}],
+			"Mail": [{"name": "contents", "type": "string"}]
+		},
+		"primaryType": "Mail",
+		"domain": {"name": "Ether Mail", "chainId": "1"},
+		"message": {"contents": "Hello, Bob!"}
+	}`
+)
+
+var (
+	testRecipient = common.HexToAddress("0x3535353535353535353535353535353535353535")
+	testDelegate  = common.HexToAddress("0x63c0c19a282a1B52b07dD5a65b58948A07DAE32B")
+)
+
+// loadPolicy loads the test rules from a file.
+func loadPolicy(t *testing.T) *RulePolicy {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), "rules.json")
+	require.NoError(t, os.WriteFile(file, []byte(testRules), 0o600))
+	rules, err := LoadRules(file)
+	require.NoError(t, err)
+	policy, err := NewRulePolicy(rules)
+	require.NoError(t, err)
+	return policy
+}
+
+// requireRejected checks the error is a rejection by the named rule.
+func requireRejected(t *testing.T, err error, rule string) {
+	t.Helper()
+
+	var rejection *Rejection
+	require.ErrorAs(t, err, &rejection)
+	require.Equal(t, rule, rejection.Rule)
+	require.ErrorIs(t, err, ErrRejected)
+}
+
+func TestRulePolicyTx(t *testing.T) {
+	policy := loadPolicy(t)
+	account := accounts.Account{Address: testutil.Address}
+	other := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	transfer := crypto.Keccak256([]byte("transfer(address,uint256)"))[:4]
+
+	tx := func(to *common.Address, value int64, data []byte, feeCap int64) *coretypes.Transaction {
+		return coretypes.NewTx(&coretypes.DynamicFeeTx{
+			ChainID: big.NewInt(1), To: to, Value: new(big.Int).Mul(big.NewInt(value), big.NewInt(params.Ether)),
+			Gas: 100000, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(feeCap * params.GWei), Data: data,
+		})
+	}
+	tests := []struct {
+		name    string
+		tx      *coretypes.Transaction
+		chainID int64
+		rule    string
+	}{
+		{"allowed transfer", tx(&testRecipient, 1, nil, 50), 1, ""},
+		{"allowed call", tx(&testRecipient, 0, append(transfer, make([]byte, 64)...), 50), 10, ""},
+		{"chain", tx(&testRecipient, 1, nil, 50), 5, RuleChainIDs},
+		{"recipient", tx(&other, 1, nil, 50), 1, RuleRecipients},
+		{"creation", tx(nil, 0, []byte{0x60}, 50), 1, RuleRecipients},
+		{"selector", tx(&testRecipient, 0, []byte{1, 2, 3, 4}, 50), 1, RuleSelectors},
+		{"value", tx(&testRecipient, 3, nil, 50), 1, RuleMaxValue},
+		{"fee cap", tx(&testRecipient, 1, nil, 150), 1, RuleMaxFeePerGas},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.CheckTx(account, tt.tx, big.NewInt(tt.chainID))
+			if tt.rule == "" {
+				require.NoError(t, err)
+			} else {
+				requireRejected(t, err, tt.rule)
+			}
+		})
+	}
+	// The daily cap counts the values recorded over the last 24 hours
+	now := time.Now()
+	policy.now = func() time.Time { return now }
+
+	policy.RecordTx(account, tx(&testRecipient, 2, nil, 50), big.NewInt(1))
+	require.NoError(t, policy.CheckTx(account, tx(&testRecipient, 1, nil, 50), big.NewInt(1)))
+	requireRejected(t, policy.CheckTx(account, tx(&testRecipient, 2, nil, 50), big.NewInt(1)), RuleMaxDailyValue)
+
+	now = now.Add(DailyWindow)
+	require.NoError(t, policy.CheckTx(account, tx(&testRecipient, 2, nil, 50), big.NewInt(1)))
+	require.Zero(t, policy.Spent(account.Address).Sign())
+}
+
+func TestRulePolicyTypedData(t *testing.T) {
+	policy := loadPolicy(t)
+
+	var typedData apitypes.TypedData
+	require.NoError(t, json.Unmarshal([]byte(testTyped), &typedData))
+	require.NoError(t, policy.CheckTypedData(accounts.Account{}, typedData))
+
+	typedData.PrimaryType = "Other"
+	requireRejected(t, policy.CheckTypedData(accounts.Account{}, typedData), RuleTypedDataPrimaryTypes)
+
+	typedData.PrimaryType = "Mail"
+	typedData.Domain.Name = "Other"
+	requireRejected(t, policy.CheckTypedData(accounts.Account{}, typedData), RuleTypedDataDomains)
+
+	typedData.Domain.ChainId = nil
+	requireRejected(t, policy.CheckTypedData(accounts.Account{}, typedData), RuleTypedDataDomains)
+
+	require.NoError(t, json.Unmarshal([]byte(testTyped), &typedData))
+	typedData.Domain.ChainId.UnmarshalText([]byte("5"))
+	requireRejected(t, policy.CheckTypedData(accounts.Account{}, typedData), RuleChainIDs)
+}
+
+func TestRulePolicyAuthorization(t *testing.T) {
+	policy := loadPolicy(t)
+
+	require.NoError(t, policy.CheckAuthorization(accounts.Account{}, coretypes.SetCodeAuthorization{ChainID: *uint256.NewInt(10), Address: testDelegate}))
+
+	// Authorizations valid on every chain, or on others, are refused
+	requireRejected(t, policy.CheckAuthorization(accounts.Account{}, coretypes.SetCodeAuthorization{Address: testDelegate}), RuleChainIDs)
+	requireRejected(t, policy.CheckAuthorization(accounts.Account{}, coretypes.SetCodeAuthorization{ChainID: *uint256.NewInt(5), Address: testDelegate}), RuleChainIDs)
+
+	// As are delegations to unknown code
+	requireRejected(t, policy.CheckAuthorization(accounts.Account{}, coretypes.SetCodeAuthorization{ChainID: *uint256.NewInt(1), Address: testRecipient}), RuleDelegates)
+
+	// Even when embedded into a set-code transaction
+	tx := coretypes.NewTx(&coretypes.SetCodeTx{
+		ChainID: uint256.NewInt(1), To: testRecipient, Value: uint256.NewInt(0), GasFeeCap: uint256.NewInt(1), GasTipCap: uint256.NewInt(1),
+		AuthList: []coretypes.SetCodeAuthorization{{ChainID: *uint256.NewInt(1), Address: testRecipient}},
+	})
+	requireRejected(t, policy.CheckTx(accounts.Account{}, tx, big.NewInt(1)), RuleDelegates)
+}
+
+func TestLoadRulesStrict(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rules.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{"recipient": []}`), 0o600))
+	_, err := LoadRules(file)
+	require.Error(t, err)
+
+	_, err = NewRulePolicy(&Rules{Selectors: []string{"0x1234"}})
+	require.Error(t, err)
+}
+
+func TestWallet(t *testing.T) {
+	hub, device := testutil.SimulatedHub()
+	backend := NewBackend(hub, loadPolicy(t))
+
+	wallets := backend.Wallets()
+	require.Len(t, wallets, 1)
+	require.Same(t, wallets[0], backend.Wallets()[0])
+
+	wallet := wallets[0]
+	require.NoError(t, wallet.Open(""))
+	defer wallet.Close()
+	account, err := wallet.Derive(gethaccounts.DefaultBaseDerivationPath, true)
+	require.NoError(t, err)
+
+	// Allowed transactions reach the device and count towards the daily cap
+	builder := func(value int64) *ledger.TxBuilder {
+		return ledger.NewTxBuilder(big.NewInt(1)).ToAddress(testRecipient).Gas(21000).
+			GasFees(big.NewInt(1), big.NewInt(params.GWei)).Value(new(big.Int).Mul(big.NewInt(value), big.NewInt(params.Ether)))
+	}
+	_, err = builder(2).Sign(wallet, account)
+	require.NoError(t, err)
+
+	// Rejected ones never do
+	device.SetRejecting(true)
+	_, err = builder(2).Sign(wallet, account)
+	requireRejected(t, err, RuleMaxDailyValue)
+
+	_, err = builder(1).Sign(wallet, account)
+	require.ErrorIs(t, err, usbwallet.ErrLedgerUserRejected)
+
+	// Neither does typed data outside of the rules, nor prehashed typed data
+	var typedData apitypes.TypedData
+	require.NoError(t, json.Unmarshal([]byte(testTyped), &typedData))
+	typedData.PrimaryType = "Other"
+	_, err = wallet.SignTypedData(account, typedData)
+	requireRejected(t, err, RuleTypedDataPrimaryTypes)
+
+	_, err = wallet.SignData(account, gethaccounts.MimetypeTypedData, make([]byte, 66))
+	require.ErrorIs(t, err, ErrRejected)
+
+	// Authorizations can't bypass the chain and delegate rules
+	_, err = wallet.SignAuthorization(account, coretypes.SetCodeAuthorization{Address: testDelegate})
+	requireRejected(t, err, RuleChainIDs)
+
+	_, err = wallet.SignAuthorization(account, coretypes.SetCodeAuthorization{ChainID: *uint256.NewInt(1), Address: testRecipient})
+	requireRejected(t, err, RuleDelegates)
+
+	_, err = wallet.SignAuthorization(account, coretypes.SetCodeAuthorization{ChainID: *uint256.NewInt(1), Address: testDelegate})
+	require.ErrorIs(t, err, usbwallet.ErrLedgerUserRejected)
+
+	// Nor can Amino JSON sign docs bypass the typed data rules
+	signDoc := func(chainID string) []byte {
+		return []byte(`{"account_number":"1","chain_id":"` + chainID + `","fee":{"amount":[],"gas":"200000"},"memo":"",` +
+			`"msgs":[{"type":"cosmos-sdk/MsgSend","value":{"amount":[{"amount":"1","denom":"aevmos"}],"from_address":"a","to_address":"b"}}],"sequence":"0"}`)
+	}
+	_, err = wallet.SignData(account, accounts.MimetypeAminoJSON, signDoc("evmos_9001-2"))
+	requireRejected(t, err, RuleChainIDs)
+
+	_, err = wallet.SignData(account, accounts.MimetypeAminoJSON, signDoc("evmos_1-1"))
+	requireRejected(t, err, RuleTypedDataDomains)
+
+	_, err = wallet.SignData(account, accounts.MimetypeAminoJSON, signDoc("cosmoshub-4"))
+	require.ErrorIs(t, err, ErrRejected)
+}
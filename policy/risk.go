@@ -0,0 +1,374 @@
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+
+	"github.com/evmos/ethereum-ledger-go/accounts"
+)
+
+// Names of the risk checks, as reported by findings and rejections.
+const (
+	RiskUnlimitedAllowance = "risk.unlimitedAllowance" // Unlimited token allowances and operator approvals
+	RiskSpenders           = "risk.spenders"           // Allowances to spenders not allow-listed
+	RiskPermitDuration     = "risk.permitDuration"     // Permits valid for longer than allowed
+	RiskChainID            = "risk.chainId"            // Chain IDs not matching the expected one
+)
+
+// Actions taken on risk findings.
+const (
+	RiskWarn   = "warn"   // Report the finding, allowing the request
+	RiskFail   = "fail"   // Reject the request
+	RiskIgnore = "ignore" // Disable the check
+)
+
+// Functions granting token allowances.
+var (
+	selectorIncreaseAllowance = [4]byte{0x39, 0x50, 0x93, 0x51} // increaseAllowance(address,uint256)
+	selectorSetApprovalForAll = [4]byte{0xa2, 0x2c, 0xb4, 0x65} // setApprovalForAll(address,bool)
+	selectorPermit2Approve    = [4]byte{0x87, 0x51, 0x7c, 0x45} // approve(address,address,uint160,uint48) of Permit2
+)
+
+// RiskRules configure the detection of dangerous approvals and permits: ERC-20
+// approve and increaseAllowance calls, ERC-721 and ERC-1155 setApprovalForAll,
+// Permit2 approve calls, and EIP-2612, DAI and Permit2 permit signatures.
+//
+// Allowances of at least half the range of their type (e.g. 2^255 for uint256)
+// are taken as unlimited.
+type RiskRules struct {
+	Spenders          []common.Address      `json:"spenders"`                    // Allowed spenders and operators, unrestricted if nil
+	MaxPermitDuration Duration              `json:"maxPermitDuration,omitempty"` // Longest validity of permits from now, e.g. "720h", unrestricted if zero
+	ChainID           *math.HexOrDecimal256 `json:"chainId,omitempty"`           // Chain transactions and typed data must be bound to, if set
+	Actions           map[string]string     `json:"actions,omitempty"`           // Action per check (e.g. "unlimitedAllowance": "fail"), RiskWarn by default
+}
+
+// Finding is a risk detected in a signing request.
+type Finding struct {
+	Rule   string `json:"rule"`   // Name of the check, e.g. RiskUnlimitedAllowance
+	Reason string `json:"reason"` // Human readable description of the risk
+	Fail   bool   `json:"fail"`   // Whether the request is rejected
+}
+
+// WarningHandler is notified of the findings allowing a request.
+type WarningHandler func(account accounts.Account, finding Finding)
+
+// RiskAnalyzer is a policy detecting dangerous approvals and permits, rejecting
+// the requests with findings configured to fail and reporting the others to a
+// warning handler.
+type RiskAnalyzer struct {
+	rules    *RiskRules
+	actions  map[string]string
+	spenders map[common.Address]bool
+
+	warn WarningHandler
+	now  func() time.Time
+	lock sync.Mutex
+}
+
+// NewRiskAnalyzer creates a risk analyzer enforcing the rules.
+func NewRiskAnalyzer(rules *RiskRules) (*RiskAnalyzer, error) {
+	a := &RiskAnalyzer{
+		rules:   rules,
+		actions: make(map[string]string),
+		now:     time.Now,
+	}
+	for check, action := range rules.Actions {
+		rule := "risk." + check
+		switch rule {
+		case RiskUnlimitedAllowance, RiskSpenders, RiskPermitDuration, RiskChainID:
+		default:
+			return nil, fmt.Errorf("policy: unknown risk check %q", check)
+		}
+		switch action {
+		case RiskWarn, RiskFail, RiskIgnore:
+		default:
+			return nil, fmt.Errorf("policy: invalid action %q of risk check %s", action, check)
+		}
+		a.actions[rule] = action
+	}
+	if rules.Spenders != nil {
+		a.spenders = make(map[common.Address]bool)
+		for _, spender := range rules.Spenders {
+			a.spenders[spender] = true
+		}
+	}
+	if rules.MaxPermitDuration < 0 {
+		return nil, errors.New("policy: negative maxPermitDuration")
+	}
+	return a, nil
+}
+
+// SetWarningHandler configures the handler notified of the findings which
+// don't fail requests. A nil handler discards them.
+func (a *RiskAnalyzer) SetWarningHandler(handler WarningHandler) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.warn = handler
+}
+
+// CheckTx implements Policy, rejecting the transaction on the first finding
+// configured to fail, after reporting the warnings.
+func (a *RiskAnalyzer) CheckTx(account accounts.Account, tx *coretypes.Transaction, chainID *big.Int) error {
+	return a.enforce(account, a.AnalyzeTx(tx, chainID))
+}
+
+// CheckTypedData implements Policy, rejecting the typed data on the first
+// finding configured to fail, after reporting the warnings.
+func (a *RiskAnalyzer) CheckTypedData(account accounts.Account, typedData apitypes.TypedData) error {
+	return a.enforce(account, a.AnalyzeTypedData(typedData))
+}
+
+// CheckAuthorization implements Policy, authorizations granting no allowances.
+func (a *RiskAnalyzer) CheckAuthorization(account accounts.Account, auth coretypes.SetCodeAuthorization) error {
+	return nil
+}
+
+// enforce reports the warnings among the findings, returning a rejection for the
+// first failing one.
+func (a *RiskAnalyzer) enforce(account accounts.Account, findings []Finding) error {
+	a.lock.Lock()
+	warn := a.warn
+	a.lock.Unlock()
+
+	var rejection *Rejection
+	for _, finding := range findings {
+		if finding.Fail {
+			if rejection == nil {
+				rejection = reject(finding.Rule, "%s", finding.Reason)
+			}
+		} else if warn != nil {
+			warn(account, finding)
+		}
+	}
+	if rejection != nil {
+		return rejection
+	}
+	return nil
+}
+
+// riskReport collects the findings of an analysis.
+type riskReport struct {
+	analyzer *RiskAnalyzer
+	findings []Finding
+}
+
+// add records a finding, unless its check is disabled.
+func (r *riskReport) add(rule string, format string, args ...interface{}) {
+	action := r.analyzer.actions[rule]
+	if action == RiskIgnore {
+		return
+	}
+	r.findings = append(r.findings, Finding{Rule: rule, Reason: fmt.Sprintf(format, args...), Fail: action == RiskFail})
+}
+
+// checkAllowance reports unlimited allowances and spenders not allow-listed. The
+// allowance is unlimited if nil, e.g. operator approvals.
+func (r *riskReport) checkAllowance(spender common.Address, amount *big.Int, bits int, what string) {
+	if amount != nil && amount.Sign() == 0 {
+		return // Revocation
+	}
+	if amount == nil {
+		r.add(RiskUnlimitedAllowance, "%s grants %s control of all tokens", what, spender.Hex())
+	} else if amount.Cmp(new(big.Int).Lsh(big.NewInt(1), uint(bits-1))) >= 0 {
+		r.add(RiskUnlimitedAllowance, "%s grants %s an unlimited allowance", what, spender.Hex())
+	}
+	if r.analyzer.spenders != nil && !r.analyzer.spenders[spender] {
+		r.add(RiskSpenders, "%s grants an allowance to %s, not an allowed spender", what, spender.Hex())
+	}
+}
+
+// checkExpiry reports permits valid beyond the maximum duration. If infinite is
+// set, a zero expiry means the permit never expires.
+func (r *riskReport) checkExpiry(expiry *big.Int, infinite bool, what string) {
+	max := time.Duration(r.analyzer.rules.MaxPermitDuration)
+	if max == 0 || expiry == nil {
+		return
+	}
+	if expiry.Sign() == 0 && infinite {
+		r.add(RiskPermitDuration, "%s never expires", what)
+		return
+	}
+	deadline := r.analyzer.now().Add(max)
+	if expiry.Cmp(big.NewInt(deadline.Unix())) > 0 {
+		if expiry.IsInt64() && expiry.Int64() < 1<<40 {
+			r.add(RiskPermitDuration, "%s valid until %s, beyond %s", what, time.Unix(expiry.Int64(), 0).UTC().Format(time.RFC3339), max)
+		} else {
+			r.add(RiskPermitDuration, "%s valid until %s, practically never expiring", what, expiry)
+		}
+	}
+}
+
+// AnalyzeTx returns the risks of a transaction: unlimited or unexpected token
+// allowances, long-dated Permit2 approvals, and a chain ID missing, not matching
+// the one embedded in the transaction or not the expected one.
+func (a *RiskAnalyzer) AnalyzeTx(tx *coretypes.Transaction, chainID *big.Int) []Finding {
+	r := &riskReport{analyzer: a}
+
+	switch {
+	case chainID == nil:
+		r.add(RiskChainID, "transaction not bound to a chain, replayable on any chain")
+	// Typed transactions without a chain ID get it filled in on signing, as the
+	// driver does, so only mismatching set ones are flagged
+	case tx.Type() != coretypes.LegacyTxType && tx.ChainId().Sign() != 0 && tx.ChainId().Cmp(chainID) != 0:
+		r.add(RiskChainID, "transaction for chain %v signed for chain %v", tx.ChainId(), chainID)
+	case a.rules.ChainID != nil && chainID.Cmp((*big.Int)(a.rules.ChainID)) != 0:
+		r.add(RiskChainID, "transaction for chain %v, expected chain %v", chainID, (*big.Int)(a.rules.ChainID))
+	}
+	data := tx.Data()
+	if tx.To() == nil || len(data) < 4 {
+		return r.findings
+	}
+	args := data[4:]
+	word := func(i int) []byte {
+		if len(args) < 32*(i+1) {
+			return nil
+		}
+		return args[32*i : 32*(i+1)]
+	}
+	switch [4]byte(data[:4]) {
+	case selectorApprove, selectorIncreaseAllowance:
+		if spender, amount := word(0), word(1); amount != nil {
+			r.checkAllowance(common.BytesToAddress(spender), new(big.Int).SetBytes(amount), 256, "approval of token "+tx.To().Hex())
+		}
+	case selectorSetApprovalForAll:
+		if operator, approved := word(0), word(1); approved != nil && new(big.Int).SetBytes(approved).Sign() != 0 {
+			r.checkAllowance(common.BytesToAddress(operator), nil, 0, "operator approval of collection "+tx.To().Hex())
+		}
+	case selectorPermit2Approve:
+		if token, spender, amount, expiration := word(0), word(1), word(2), word(3); expiration != nil {
+			what := "Permit2 approval of token " + common.BytesToAddress(token).Hex()
+			r.checkAllowance(common.BytesToAddress(spender), new(big.Int).SetBytes(amount), 160, what)
+			r.checkExpiry(new(big.Int).SetBytes(expiration), false, what)
+		}
+	}
+	return r.findings
+}
+
+// AnalyzeTypedData returns the risks of EIP-712 typed data: EIP-2612, DAI and
+// Permit2 permits granting unlimited or unexpected allowances or valid for too
+// long, and domains not bound to the expected chain.
+func (a *RiskAnalyzer) AnalyzeTypedData(typedData apitypes.TypedData) []Finding {
+	r := &riskReport{analyzer: a}
+	permit := isPermit(typedData.PrimaryType)
+
+	domainChain := (*big.Int)(typedData.Domain.ChainId)
+	switch {
+	case domainChain == nil && permit:
+		r.add(RiskChainID, "%s not bound to a chain, replayable on any chain", typedData.PrimaryType)
+	case domainChain != nil && a.rules.ChainID != nil && domainChain.Cmp((*big.Int)(a.rules.ChainID)) != 0:
+		r.add(RiskChainID, "typed data for chain %v, expected chain %v", domainChain, (*big.Int)(a.rules.ChainID))
+	}
+	msg := typedData.Message
+	switch typedData.PrimaryType {
+	case "Permit":
+		spender, _ := toAddress(msg["spender"])
+		if allowed, ok := msg["allowed"]; ok {
+			// DAI style permits approve unlimited amounts, or revoke
+			if b, _ := allowed.(bool); b {
+				what := "permit of token " + typedData.Domain.VerifyingContract
+				r.checkAllowance(spender, nil, 0, what)
+				r.checkExpiry(toBigInt(msg["expiry"]), true, what)
+			}
+			break
+		}
+		what := "EIP-2612 permit of token " + typedData.Domain.VerifyingContract
+		r.checkAllowance(spender, toBigInt(msg["value"]), typeBits(typedData, "Permit", "value"), what)
+		r.checkExpiry(toBigInt(msg["deadline"]), false, what)
+
+	case "PermitSingle", "PermitBatch":
+		spender, _ := toAddress(msg["spender"])
+		details, _ := msg["details"].([]interface{})
+		if single, ok := msg["details"].(map[string]interface{}); ok {
+			details = []interface{}{single}
+		}
+		for _, detail := range details {
+			detail, _ := detail.(map[string]interface{})
+			token, _ := toAddress(detail["token"])
+			what := "Permit2 permit of token " + token.Hex()
+			r.checkAllowance(spender, toBigInt(detail["amount"]), typeBits(typedData, "PermitDetails", "amount"), what)
+			r.checkExpiry(toBigInt(detail["expiration"]), false, what)
+		}
+		r.checkExpiry(toBigInt(msg["sigDeadline"]), false, "Permit2 signature")
+
+	case "PermitTransferFrom", "PermitBatchTransferFrom", "PermitWitnessTransferFrom", "PermitBatchWitnessTransferFrom":
+		spender, _ := toAddress(msg["spender"])
+		permitted, _ := msg["permitted"].([]interface{})
+		if single, ok := msg["permitted"].(map[string]interface{}); ok {
+			permitted = []interface{}{single}
+		}
+		for _, tokenPermission := range permitted {
+			tokenPermission, _ := tokenPermission.(map[string]interface{})
+			token, _ := toAddress(tokenPermission["token"])
+			r.checkAllowance(spender, toBigInt(tokenPermission["amount"]), typeBits(typedData, "TokenPermissions", "amount"), "Permit2 transfer of token "+token.Hex())
+		}
+		r.checkExpiry(toBigInt(msg["deadline"]), false, "Permit2 signature")
+	}
+	return r.findings
+}
+
+// isPermit returns whether the primary type is a known permit.
+func isPermit(primaryType string) bool {
+	switch primaryType {
+	case "Permit", "PermitSingle", "PermitBatch", "PermitTransferFrom", "PermitBatchTransferFrom",
+		"PermitWitnessTransferFrom", "PermitBatchWitnessTransferFrom":
+		return true
+	}
+	return false
+}
+
+// typeBits returns the size of an integer field of a struct type, 256 if unknown.
+func typeBits(typedData apitypes.TypedData, name, field string) int {
+	for _, f := range typedData.Types[name] {
+		if f.Name != field {
+			continue
+		}
+		if bits, err := strconv.Atoi(strings.TrimPrefix(f.Type, "uint")); err == nil && bits > 0 && bits <= 256 {
+			return bits
+		}
+	}
+	return 256
+}
+
+// toBigInt converts a typed data message value into an integer, nil if missing
+// or invalid.
+func toBigInt(value interface{}) *big.Int {
+	switch v := value.(type) {
+	case *big.Int:
+		return v
+	case string:
+		if n, ok := math.ParseBig256(v); ok {
+			return n
+		}
+	case json.Number:
+		if n, ok := math.ParseBig256(v.String()); ok {
+			return n
+		}
+	case float64:
+		if n, acc := big.NewFloat(v).Int(nil); acc == big.Exact {
+			return n
+		}
+	}
+	return nil
+}
+
+// toAddress converts a typed data message value into an address.
+func toAddress(value interface{}) (common.Address, bool) {
+	if s, ok := value.(string); ok && common.IsHexAddress(s) {
+		return common.HexToAddress(s), true
+	}
+	return common.Address{}, false
+}
+
+var _ Policy = (*RiskAnalyzer)(nil)
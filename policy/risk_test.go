@@ -0,0 +1,249 @@
+package policy
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evmos/ethereum-ledger-go/accounts"
+	"github.com/evmos/ethereum-ledger-go/internal/testutil"
+)
+
+var (
+	testRouter  = common.HexToAddress("0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD")
+	testPermit2 = common.HexToAddress("0x000000000022D473030F116dDEE9F6B43aC78BA3")
+	maxUint160  = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 160), big.NewInt(1))
+)
+
+// callTx creates a transaction calling the function with the ABI encoded static
+// arguments.
+func callTx(to common.Address, signature string, args ...interface{}) *coretypes.Transaction {
+	data := crypto.Keccak256([]byte(signature))[:4]
+	for _, arg := range args {
+		switch arg := arg.(type) {
+		case common.Address:
+			data = append(data, common.LeftPadBytes(arg[:], 32)...)
+		case *big.Int:
+			data = append(data, math.U256Bytes(new(big.Int).Set(arg))...)
+		case int64:
+			data = append(data, math.U256Bytes(big.NewInt(arg))...)
+		case bool:
+			word := make([]byte, 32)
+			if arg {
+				word[31] = 1
+			}
+			data = append(data, word...)
+		}
+	}
+	return coretypes.NewTx(&coretypes.DynamicFeeTx{
+		ChainID: big.NewInt(1), To: &to, Gas: 60000, Data: data, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(1),
+	})
+}
+
+// findingRules returns the rules of the findings.
+func findingRules(findings []Finding) []string {
+	rules := make([]string, len(findings))
+	for i, finding := range findings {
+		rules[i] = finding.Rule
+	}
+	return rules
+}
+
+func TestRiskAnalyzerTx(t *testing.T) {
+	analyzer, err := NewRiskAnalyzer(&RiskRules{
+		Spenders:          []common.Address{testRouter, testPermit2},
+		MaxPermitDuration: Duration(30 * 24 * time.Hour),
+		ChainID:           math.NewHexOrDecimal256(1),
+	})
+	require.NoError(t, err)
+
+	now := time.Now()
+	analyzer.now = func() time.Time { return now }
+	farExpiry := now.Add(365 * 24 * time.Hour).Unix()
+
+	tests := []struct {
+		name    string
+		tx      *coretypes.Transaction
+		chainID *big.Int
+		rules   []string
+	}{
+		{"limited approval", callTx(testToken, "approve(address,uint256)", testRouter, int64(1000)), big.NewInt(1), []string{}},
+		{"revocation", callTx(testToken, "approve(address,uint256)", testRecipient, int64(0)), big.NewInt(1), []string{}},
+		{"unlimited approval", callTx(testToken, "approve(address,uint256)", testRouter, math.MaxBig256), big.NewInt(1), []string{RiskUnlimitedAllowance}},
+		{"unknown spender", callTx(testToken, "increaseAllowance(address,uint256)", testRecipient, math.BigPow(2, 255)), big.NewInt(1), []string{RiskUnlimitedAllowance, RiskSpenders}},
+		{"operator approval", callTx(testToken, "setApprovalForAll(address,bool)", testRecipient, true), big.NewInt(1), []string{RiskUnlimitedAllowance, RiskSpenders}},
+		{"operator revocation", callTx(testToken, "setApprovalForAll(address,bool)", testRecipient, false), big.NewInt(1), []string{}},
+		{
+			"long-dated Permit2 approval",
+			callTx(testPermit2, "approve(address,address,uint160,uint48)", testToken, testRouter, maxUint160, farExpiry),
+			big.NewInt(1), []string{RiskUnlimitedAllowance, RiskPermitDuration},
+		},
+		{"chain mismatch", callTx(testRecipient, "deposit()"), big.NewInt(10), []string{RiskChainID}},
+		{"unset chain", coretypes.NewTx(&coretypes.DynamicFeeTx{To: &testRecipient, Gas: 21000, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(1)}), big.NewInt(1), []string{}},
+		{"unset chain elsewhere", coretypes.NewTx(&coretypes.DynamicFeeTx{To: &testRecipient, Gas: 21000, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(1)}), big.NewInt(10), []string{RiskChainID}},
+		{"unexpected chain", coretypes.NewTx(&coretypes.LegacyTx{To: &testRecipient, Gas: 21000, GasPrice: big.NewInt(1)}), big.NewInt(10), []string{RiskChainID}},
+		{"unprotected", coretypes.NewTx(&coretypes.LegacyTx{To: &testRecipient, Gas: 21000, GasPrice: big.NewInt(1)}), nil, []string{RiskChainID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.rules, findingRules(analyzer.AnalyzeTx(tt.tx, tt.chainID)))
+		})
+	}
+}
+
+func TestRiskAnalyzerTypedData(t *testing.T) {
+	analyzer, err := NewRiskAnalyzer(&RiskRules{
+		Spenders:          []common.Address{testRouter},
+		MaxPermitDuration: Duration(30 * 24 * time.Hour),
+		ChainID:           math.NewHexOrDecimal256(1),
+	})
+	require.NoError(t, err)
+
+	now := time.Now()
+	analyzer.now = func() time.Time { return now }
+	soon, far := now.Add(time.Hour).Unix(), now.Add(365*24*time.Hour).Unix()
+
+	permit := func(chainID int64, value *big.Int, deadline int64) apitypes.TypedData {
+		typedData := apitypes.TypedData{
+			Types: apitypes.Types{
+				"EIP712Domain": {{Name: "name", Type: "string"}, {Name: "chainId", Type: "uint256"}, {Name: "verifyingContract", Type: "address"}},
+				"Permit": {
+					{Name: "owner", Type: "address"}, {Name: "spender", Type: "address"}, {Name: "value", Type: "uint256"},
+					{Name: "nonce", Type: "uint256"}, {Name: "deadline", Type: "uint256"},
+				},
+			},
+			PrimaryType: "Permit",
+			Domain:      apitypes.TypedDataDomain{Name: "USD Coin", VerifyingContract: testToken.Hex()},
+			Message: apitypes.TypedDataMessage{
+				"owner": testRecipient.Hex(), "spender": testRouter.Hex(), "value": value.String(), "nonce": "0", "deadline": json.Number(big.NewInt(deadline).String()),
+			},
+		}
+		if chainID != 0 {
+			typedData.Domain.ChainId = math.NewHexOrDecimal256(chainID)
+		}
+		return typedData
+	}
+	daiPermit := apitypes.TypedData{
+		PrimaryType: "Permit",
+		Domain:      apitypes.TypedDataDomain{Name: "Dai Stablecoin", ChainId: math.NewHexOrDecimal256(1)},
+		Message: apitypes.TypedDataMessage{
+			"holder": testRecipient.Hex(), "spender": testRecipient.Hex(), "nonce": "0", "expiry": "0", "allowed": true,
+		},
+	}
+	permitSingle := apitypes.TypedData{
+		Types: apitypes.Types{
+			"PermitDetails": {{Name: "token", Type: "address"}, {Name: "amount", Type: "uint160"}, {Name: "expiration", Type: "uint48"}, {Name: "nonce", Type: "uint48"}},
+		},
+		PrimaryType: "PermitSingle",
+		Domain:      apitypes.TypedDataDomain{Name: "Permit2", ChainId: math.NewHexOrDecimal256(1), VerifyingContract: testPermit2.Hex()},
+		Message: apitypes.TypedDataMessage{
+			"details":     map[string]interface{}{"token": testToken.Hex(), "amount": maxUint160.String(), "expiration": big.NewInt(far).String(), "nonce": "0"},
+			"spender":     testRouter.Hex(),
+			"sigDeadline": big.NewInt(soon).String(),
+		},
+	}
+	transferFrom := apitypes.TypedData{
+		PrimaryType: "PermitBatchTransferFrom",
+		Domain:      apitypes.TypedDataDomain{Name: "Permit2", ChainId: math.NewHexOrDecimal256(1), VerifyingContract: testPermit2.Hex()},
+		Message: apitypes.TypedDataMessage{
+			"permitted": []interface{}{
+				map[string]interface{}{"token": testToken.Hex(), "amount": "1000"},
+				map[string]interface{}{"token": testRecipient.Hex(), "amount": "2000"},
+			},
+			"spender":  testRecipient.Hex(),
+			"nonce":    "0",
+			"deadline": big.NewInt(soon).String(),
+		},
+	}
+	tests := []struct {
+		name      string
+		typedData apitypes.TypedData
+		rules     []string
+	}{
+		{"limited permit", permit(1, big.NewInt(1000), soon), []string{}},
+		{"unlimited permit", permit(1, math.MaxBig256, soon), []string{RiskUnlimitedAllowance}},
+		{"long-dated permit", permit(1, big.NewInt(1000), far), []string{RiskPermitDuration}},
+		{"never expiring permit", permit(1, big.NewInt(1000), math.MaxBig256.Int64()), []string{}},
+		{"unbound permit", permit(0, big.NewInt(1000), soon), []string{RiskChainID}},
+		{"other chain permit", permit(10, big.NewInt(1000), soon), []string{RiskChainID}},
+		{"dai permit", daiPermit, []string{RiskUnlimitedAllowance, RiskSpenders, RiskPermitDuration}},
+		{"permit2 single", permitSingle, []string{RiskUnlimitedAllowance, RiskPermitDuration}},
+		{"permit2 transfer", transferFrom, []string{RiskSpenders, RiskSpenders}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.rules, findingRules(analyzer.AnalyzeTypedData(tt.typedData)))
+		})
+	}
+	// Deadlines beyond any date are reported too
+	typedData := permit(1, big.NewInt(1000), 0)
+	typedData.Message["deadline"] = math.MaxBig256.String()
+	findings := analyzer.AnalyzeTypedData(typedData)
+	require.Equal(t, []string{RiskPermitDuration}, findingRules(findings))
+	require.Contains(t, findings[0].Reason, "practically never expiring")
+}
+
+func TestRiskAnalyzerActions(t *testing.T) {
+	_, err := NewRiskAnalyzer(&RiskRules{Actions: map[string]string{"approvals": RiskFail}})
+	require.Error(t, err)
+	_, err = NewRiskAnalyzer(&RiskRules{Actions: map[string]string{"spenders": "deny"}})
+	require.Error(t, err)
+
+	analyzer, err := NewRiskAnalyzer(&RiskRules{
+		Spenders: []common.Address{testRouter},
+		Actions:  map[string]string{"unlimitedAllowance": RiskFail, "chainId": RiskIgnore},
+	})
+	require.NoError(t, err)
+
+	var warnings []Finding
+	analyzer.SetWarningHandler(func(account accounts.Account, finding Finding) {
+		warnings = append(warnings, finding)
+	})
+	account := accounts.Account{Address: testRecipient}
+
+	// Warnings are reported while failures reject
+	require.NoError(t, analyzer.CheckTx(account, callTx(testToken, "approve(address,uint256)", testRecipient, int64(1)), nil))
+	require.Equal(t, []string{RiskSpenders}, findingRules(warnings))
+
+	warnings = nil
+	err = analyzer.CheckTx(account, callTx(testToken, "approve(address,uint256)", testRecipient, math.MaxBig256), big.NewInt(1))
+	requireRejected(t, err, RiskUnlimitedAllowance)
+	require.Equal(t, []string{RiskSpenders}, findingRules(warnings))
+}
+
+func TestRulePolicyRisk(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rules.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{
+		"risk": {
+			"spenders": ["0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD"],
+			"actions": {"unlimitedAllowance": "fail"}
+		}
+	}`), 0o600))
+	policy, err := LoadRulePolicy(file)
+	require.NoError(t, err)
+
+	var warnings []Finding
+	policy.SetWarningHandler(func(account accounts.Account, finding Finding) {
+		warnings = append(warnings, finding)
+	})
+	inner, account, _ := testutil.SimulatedWallet(t)
+	wallet := NewWallet(inner, policy)
+
+	_, err = wallet.SignTx(account, callTx(testToken, "approve(address,uint256)", testRouter, math.MaxBig256), big.NewInt(1))
+	requireRejected(t, err, RiskUnlimitedAllowance)
+
+	_, err = wallet.SignTx(account, callTx(testToken, "approve(address,uint256)", testRecipient, int64(1000)), big.NewInt(1))
+	require.NoError(t, err)
+	require.Len(t, warnings, 1)
+	require.Equal(t, RiskSpenders, warnings[0].Rule)
+}
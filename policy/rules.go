@@ -0,0 +1,424 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+
+	"github.com/evmos/ethereum-ledger-go/accounts"
+)
+
+// DailyWindow is the rolling window the daily value cap is enforced over.
+const DailyWindow = 24 * time.Hour
+
+// Names of the rules, as reported by rejections.
+const (
+	RuleChainIDs              = "chainIds"
+	RuleRecipients            = "recipients"
+	RuleMaxValue              = "maxValue"
+	RuleMaxDailyValue         = "maxDailyValue"
+	RuleSelectors             = "selectors"
+	RuleMaxFeePerGas          = "maxFeePerGas"
+	RuleMaxPriorityFeePerGas  = "maxPriorityFeePerGas"
+	RuleTypedDataDomains      = "typedData.domains"
+	RuleTypedDataPrimaryTypes = "typedData.primaryTypes"
+	RuleDelegates             = "delegates"
+	RuleLimits                = "limits" // Suffixed with the index of the limit, e.g. "limits[0]"
+)
+
+// Rules are the declarative rules of a RulePolicy. Lists left out (nil) don't
+// restrict anything, while empty ones allow nothing. Amounts are in wei, given
+// as decimal or hex.
+//
+// EIP-7702 authorizations must be for one of the chainIds, chain 0 (valid on all
+// chains) included only if listed.
+type Rules struct {
+	ChainIDs             []*math.HexOrDecimal256 `json:"chainIds"`                       // Chains transactions and typed data may be signed for
+	Recipients           []common.Address        `json:"recipients"`                     // Allowed transaction recipients, excluding contract creations
+	MaxValue             *math.HexOrDecimal256   `json:"maxValue,omitempty"`             // Value cap per transaction
+	MaxDailyValue        *math.HexOrDecimal256   `json:"maxDailyValue,omitempty"`        // Value cap per account over the last 24 hours
+	Selectors            []string                `json:"selectors"`                      // Allowed function selectors, as hex or signatures (e.g. "transfer(address,uint256)")
+	MaxFeePerGas         *math.HexOrDecimal256   `json:"maxFeePerGas,omitempty"`         // Cap on the fee cap (or gas price)
+	MaxPriorityFeePerGas *math.HexOrDecimal256   `json:"maxPriorityFeePerGas,omitempty"` // Cap on the tip
+	Delegates            []common.Address        `json:"delegates"`                      // Allowed EIP-7702 delegation targets, the zero address revoking
+	TypedData            *TypedDataRules         `json:"typedData,omitempty"`
+
+	Limits     []Limit `json:"limits,omitempty"`     // Persistent limits per account and token
+	LimitStore string  `json:"limitStore,omitempty"` // Store file of the limits, required with them
+
+	Risk *RiskRules `json:"risk,omitempty"` // Detection of dangerous approvals and permits
+}
+
+// TypedDataRules are the rules on EIP-712 typed data.
+type TypedDataRules struct {
+	Domains      []DomainRule `json:"domains"`      // Allowed domains, any of which must match
+	PrimaryTypes []string     `json:"primaryTypes"` // Allowed primary types
+}
+
+// DomainRule matches EIP-712 domains, on the fields set.
+type DomainRule struct {
+	Name              string                `json:"name,omitempty"`
+	Version           string                `json:"version,omitempty"`
+	ChainID           *math.HexOrDecimal256 `json:"chainId,omitempty"`
+	VerifyingContract *common.Address       `json:"verifyingContract,omitempty"`
+}
+
+// LoadRules parses a JSON rule file, e.g.:
+//
+//	{
+//	  "chainIds": [1],
+//	  "recipients": ["0x3535353535353535353535353535353535353535"],
+//	  "maxValue": "1000000000000000000",
+//	  "maxDailyValue": "5000000000000000000",
+//	  "selectors": ["transfer(address,uint256)", "0x095ea7b3"],
+//	  "maxFeePerGas": "100000000000",
+//	  "typedData": {
+//	    "domains": [{"name": "Permit2", "chainId": 1}],
+//	    "primaryTypes": ["PermitSingle"]
+//	  },
+//	  "delegates": ["0x63c0c19a282a1B52b07dD5a65b58948A07DAE32B"],
+//	  "limits": [
+//	    {"window": "24h", "maxValue": "10000000000000000000", "maxCount": 50},
+//	    {"token": "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48", "window": "168h", "maxValue": "50000000000"}
+//	  ],
+//	  "limitStore": "/var/lib/ledger/limits.json",
+//	  "risk": {
+//	    "spenders": ["0x000000000022D473030F116dDEE9F6B43aC78BA3"],
+//	    "maxPermitDuration": "720h",
+//	    "chainId": 1,
+//	    "actions": {"unlimitedAllowance": "fail", "spenders": "fail", "permitDuration": "warn"}
+//	  }
+//	}
+//
+// Unknown fields are refused, so misspelled rules don't go unnoticed.
+func LoadRules(file string) (*Rules, error) {
+	blob, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(blob))
+	dec.DisallowUnknownFields()
+
+	rules := new(Rules)
+	if err := dec.Decode(rules); err != nil {
+		return nil, fmt.Errorf("policy: invalid rule file: %v", err)
+	}
+	return rules, nil
+}
+
+// LoadRulePolicy creates a policy enforcing the rules of a JSON rule file.
+func LoadRulePolicy(file string) (*RulePolicy, error) {
+	rules, err := LoadRules(file)
+	if err != nil {
+		return nil, err
+	}
+	return NewRulePolicy(rules)
+}
+
+// spend is a value sent by an account.
+type spend struct {
+	time  time.Time
+	value *big.Int
+}
+
+// RulePolicy is a policy enforcing declarative rules. The values sent towards the
+// daily cap are tracked in memory, while limits are persisted by a Limiter.
+type RulePolicy struct {
+	rules   *Rules
+	limiter *Limiter      // Enforcer of the persistent limits, nil if none
+	risk    *RiskAnalyzer // Detector of dangerous approvals and permits, nil if none
+
+	chainIDs   map[string]bool // Decimal chain IDs, nil if unrestricted
+	recipients map[common.Address]bool
+	selectors  map[[4]byte]bool
+	delegates  map[common.Address]bool
+
+	spent map[common.Address][]spend // Values sent within the daily window, oldest first
+	now   func() time.Time
+	lock  sync.Mutex
+}
+
+// NewRulePolicy creates a policy enforcing the rules.
+func NewRulePolicy(rules *Rules) (*RulePolicy, error) {
+	p := &RulePolicy{
+		rules: rules,
+		spent: make(map[common.Address][]spend),
+		now:   time.Now,
+	}
+	if rules.ChainIDs != nil {
+		p.chainIDs = make(map[string]bool)
+		for _, id := range rules.ChainIDs {
+			if id == nil {
+				return nil, fmt.Errorf("policy: null chain ID in %s", RuleChainIDs)
+			}
+			p.chainIDs[(*big.Int)(id).String()] = true
+		}
+	}
+	if rules.Recipients != nil {
+		p.recipients = make(map[common.Address]bool)
+		for _, addr := range rules.Recipients {
+			p.recipients[addr] = true
+		}
+	}
+	if rules.Delegates != nil {
+		p.delegates = make(map[common.Address]bool)
+		for _, addr := range rules.Delegates {
+			p.delegates[addr] = true
+		}
+	}
+	if rules.Selectors != nil {
+		p.selectors = make(map[[4]byte]bool)
+		for _, sel := range rules.Selectors {
+			id, err := parseSelector(sel)
+			if err != nil {
+				return nil, err
+			}
+			p.selectors[id] = true
+		}
+	}
+	if rules.Limits != nil {
+		if rules.LimitStore == "" {
+			return nil, errors.New("policy: limits require a limitStore")
+		}
+		limiter, err := NewLimiter(rules.LimitStore, rules.Limits)
+		if err != nil {
+			return nil, err
+		}
+		p.limiter = limiter
+	}
+	if rules.Risk != nil {
+		risk, err := NewRiskAnalyzer(rules.Risk)
+		if err != nil {
+			return nil, err
+		}
+		p.risk = risk
+	}
+	return p, nil
+}
+
+// SetWarningHandler configures the handler notified of the risks found in
+// allowed requests, see RiskAnalyzer.
+func (p *RulePolicy) SetWarningHandler(handler WarningHandler) {
+	if p.risk != nil {
+		p.risk.SetWarningHandler(handler)
+	}
+}
+
+// parseSelector parses a 4 byte function selector given as hex or derives it
+// from the function signature.
+func parseSelector(sel string) ([4]byte, error) {
+	var id [4]byte
+	if strings.HasPrefix(sel, "0x") {
+		blob, err := hexutil.Decode(sel)
+		if err != nil || len(blob) != 4 {
+			return id, fmt.Errorf("policy: invalid selector %q in %s", sel, RuleSelectors)
+		}
+		copy(id[:], blob)
+		return id, nil
+	}
+	if !strings.Contains(sel, "(") || !strings.HasSuffix(sel, ")") {
+		return id, fmt.Errorf("policy: invalid function signature %q in %s", sel, RuleSelectors)
+	}
+	copy(id[:], crypto.Keccak256([]byte(strings.ReplaceAll(sel, " ", ""))))
+	return id, nil
+}
+
+// CheckTx implements Policy.
+func (p *RulePolicy) CheckTx(account accounts.Account, tx *coretypes.Transaction, chainID *big.Int) error {
+	if p.chainIDs != nil && (chainID == nil || !p.chainIDs[chainID.String()]) {
+		return reject(RuleChainIDs, "chain %v not allowed", chainID)
+	}
+	to := tx.To()
+	if p.recipients != nil {
+		if to == nil {
+			return reject(RuleRecipients, "contract creations not allowed")
+		}
+		if !p.recipients[*to] {
+			return reject(RuleRecipients, "recipient %s not allowed", to.Hex())
+		}
+	}
+	if p.selectors != nil && len(tx.Data()) > 0 {
+		if to == nil {
+			return reject(RuleSelectors, "contract creations not allowed")
+		}
+		if len(tx.Data()) < 4 {
+			return reject(RuleSelectors, "calldata shorter than a selector")
+		}
+		if !p.selectors[[4]byte(tx.Data()[:4])] {
+			return reject(RuleSelectors, "function %s not allowed", hexutil.Encode(tx.Data()[:4]))
+		}
+	}
+	if p.delegates != nil {
+		for _, auth := range tx.SetCodeAuthorizations() {
+			if !p.delegates[auth.Address] {
+				return reject(RuleDelegates, "delegation to %s not allowed", auth.Address.Hex())
+			}
+		}
+	}
+	if limit := p.rules.MaxValue; limit != nil && tx.Value().Cmp((*big.Int)(limit)) > 0 {
+		return reject(RuleMaxValue, "value %v exceeds %v", tx.Value(), (*big.Int)(limit))
+	}
+	if limit := p.rules.MaxFeePerGas; limit != nil && tx.GasFeeCap().Cmp((*big.Int)(limit)) > 0 {
+		return reject(RuleMaxFeePerGas, "fee cap %v exceeds %v", tx.GasFeeCap(), (*big.Int)(limit))
+	}
+	if limit := p.rules.MaxPriorityFeePerGas; limit != nil && tx.GasTipCap().Cmp((*big.Int)(limit)) > 0 {
+		return reject(RuleMaxPriorityFeePerGas, "tip cap %v exceeds %v", tx.GasTipCap(), (*big.Int)(limit))
+	}
+	if limit := p.rules.MaxDailyValue; limit != nil {
+		spent := new(big.Int).Add(p.Spent(account.Address), tx.Value())
+		if spent.Cmp((*big.Int)(limit)) > 0 {
+			return reject(RuleMaxDailyValue, "value %v over the last 24 hours would exceed %v", spent, (*big.Int)(limit))
+		}
+	}
+	if p.risk != nil {
+		if err := p.risk.CheckTx(account, tx, chainID); err != nil {
+			return err
+		}
+	}
+	// Limits reserve the transaction, check them last
+	if p.limiter != nil {
+		return p.limiter.CheckTx(account, tx, chainID)
+	}
+	return nil
+}
+
+// ReleaseTx implements Releaser, uncounting the transaction from the limits.
+func (p *RulePolicy) ReleaseTx(account accounts.Account, tx *coretypes.Transaction, chainID *big.Int) {
+	if p.limiter != nil {
+		p.limiter.ReleaseTx(account, tx, chainID)
+	}
+}
+
+// RecordTx implements Recorder, counting the value sent towards the daily cap.
+func (p *RulePolicy) RecordTx(account accounts.Account, tx *coretypes.Transaction, chainID *big.Int) {
+	if p.rules.MaxDailyValue == nil || tx.Value().Sign() == 0 {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.spent[account.Address] = append(p.spent[account.Address], spend{time: p.now(), value: tx.Value()})
+}
+
+// Spent returns the value the account sent within the daily window.
+func (p *RulePolicy) Spent(addr common.Address) *big.Int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	// Drop the values sent before the window
+	spends := p.spent[addr]
+	cutoff := p.now().Add(-DailyWindow)
+	for len(spends) > 0 && !spends[0].time.After(cutoff) {
+		spends = spends[1:]
+	}
+	if len(spends) == 0 {
+		delete(p.spent, addr)
+	} else {
+		p.spent[addr] = spends
+	}
+	total := new(big.Int)
+	for _, s := range spends {
+		total.Add(total, s.value)
+	}
+	return total
+}
+
+// CheckTypedData implements Policy.
+func (p *RulePolicy) CheckTypedData(account accounts.Account, typedData apitypes.TypedData) error {
+	domain := typedData.Domain
+	if p.chainIDs != nil && domain.ChainId != nil && !p.chainIDs[(*big.Int)(domain.ChainId).String()] {
+		return reject(RuleChainIDs, "domain chain %v not allowed", (*big.Int)(domain.ChainId))
+	}
+	if rules := p.rules.TypedData; rules != nil {
+		if err := rules.check(typedData); err != nil {
+			return err
+		}
+	}
+	if p.risk != nil {
+		return p.risk.CheckTypedData(account, typedData)
+	}
+	return nil
+}
+
+// CheckAuthorization implements Policy.
+func (p *RulePolicy) CheckAuthorization(account accounts.Account, auth coretypes.SetCodeAuthorization) error {
+	if chainID := auth.ChainID.ToBig(); p.chainIDs != nil && !p.chainIDs[chainID.String()] {
+		if chainID.Sign() == 0 {
+			return reject(RuleChainIDs, "authorizations valid on all chains not allowed")
+		}
+		return reject(RuleChainIDs, "authorization chain %v not allowed", chainID)
+	}
+	if p.delegates != nil && !p.delegates[auth.Address] {
+		return reject(RuleDelegates, "delegation to %s not allowed", auth.Address.Hex())
+	}
+	return nil
+}
+
+// check returns a *Rejection if the typed data violates the rules.
+func (rules *TypedDataRules) check(typedData apitypes.TypedData) error {
+	domain := typedData.Domain
+	if rules.Domains != nil {
+		allowed := false
+		for _, rule := range rules.Domains {
+			if rule.matches(domain) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return reject(RuleTypedDataDomains, "domain %q (version %q, chain %v, contract %q) not allowed",
+				domain.Name, domain.Version, (*big.Int)(domain.ChainId), domain.VerifyingContract)
+		}
+	}
+	if rules.PrimaryTypes != nil {
+		allowed := false
+		for _, primaryType := range rules.PrimaryTypes {
+			if primaryType == typedData.PrimaryType {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return reject(RuleTypedDataPrimaryTypes, "primary type %q not allowed", typedData.PrimaryType)
+		}
+	}
+	return nil
+}
+
+// matches returns whether the domain matches all the fields set in the rule.
+func (r *DomainRule) matches(domain apitypes.TypedDataDomain) bool {
+	if r.Name != "" && r.Name != domain.Name {
+		return false
+	}
+	if r.Version != "" && r.Version != domain.Version {
+		return false
+	}
+	if r.ChainID != nil && (domain.ChainId == nil || (*big.Int)(r.ChainID).Cmp((*big.Int)(domain.ChainId)) != 0) {
+		return false
+	}
+	if r.VerifyingContract != nil {
+		if !common.IsHexAddress(domain.VerifyingContract) || common.HexToAddress(domain.VerifyingContract) != *r.VerifyingContract {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	_ Policy   = (*RulePolicy)(nil)
+	_ Recorder = (*RulePolicy)(nil)
+	_ Releaser = (*RulePolicy)(nil)
+)
@@ -0,0 +1,419 @@
+// Package preview renders human readable summaries of transactions before they
+// are signed, decoding their calldata with known ABIs or a 4 byte selector
+// database, identifying ERC-20 and ERC-721 transfers and approvals, and warning
+// when the Ledger will only display them as blind signed data. EIP-712 typed
+// data is validated and rendered along with the hashes the Ledger displays.
+package preview
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/evmos/ethereum-ledger-go/internal/ethutil"
+	"github.com/evmos/ethereum-ledger-go/usbwallet"
+)
+
+// Kinds of transactions.
+const (
+	KindTransfer      = "transfer"      // Plain ether transfer
+	KindCreate        = "create"        // Contract creation
+	KindCall          = "call"          // Contract call
+	KindTokenTransfer = "tokenTransfer" // ERC-20 or ERC-721 transfer
+	KindTokenApproval = "tokenApproval" // ERC-20 or ERC-721 approval
+)
+
+// Sources of decoded calls.
+const (
+	SourceABI       = "abi"       // ABI of the called contract
+	SourceStandard  = "standard"  // Standard ERC-20 or ERC-721 function
+	SourceSelectors = "selectors" // 4 byte selector database
+)
+
+// Token actions.
+const (
+	ActionTransfer          = "transfer"
+	ActionApprove           = "approve"
+	ActionSetApprovalForAll = "setApprovalForAll"
+)
+
+// Summary is the structured preview of a transaction.
+type Summary struct {
+	ChainID              *hexutil.Big    `json:"chainId"`
+	Type                 uint8           `json:"type"`
+	Nonce                uint64          `json:"nonce"`
+	To                   *common.Address `json:"to"` // Nil for contract creations
+	Value                *hexutil.Big    `json:"value"`
+	Gas                  uint64          `json:"gas"`
+	GasPrice             *hexutil.Big    `json:"gasPrice,omitempty"`
+	MaxFeePerGas         *hexutil.Big    `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *hexutil.Big    `json:"maxPriorityFeePerGas,omitempty"`
+	SigningHash          common.Hash     `json:"signingHash"`
+
+	Kind  string       `json:"kind"`
+	Call  *Call        `json:"call,omitempty"`  // Decoded calldata, nil without calldata
+	Token *TokenAction `json:"token,omitempty"` // Token transfer or approval
+
+	BlindSigned bool     `json:"blindSigned"` // Whether the Ledger will only display the calldata hash
+	Warnings    []string `json:"warnings,omitempty"`
+}
+
+// Call is the decoded calldata of a contract call.
+type Call struct {
+	Selector  hexutil.Bytes `json:"selector"`
+	Signature string        `json:"signature,omitempty"` // Empty if the function is unknown
+	Source    string        `json:"source,omitempty"`
+	Args      []Arg         `json:"args,omitempty"`
+}
+
+// Arg is a decoded argument of a call.
+type Arg struct {
+	Name  string `json:"name,omitempty"` // Empty if unnamed, e.g. decoded from the selector database
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// TokenAction is an ERC-20 or ERC-721 transfer or approval.
+type TokenAction struct {
+	Standard string          `json:"standard"`
+	Action   string          `json:"action"`
+	Contract common.Address  `json:"contract"`
+	Token    *Token          `json:"token,omitempty"` // Metadata of the token, nil if unknown
+	From     *common.Address `json:"from,omitempty"`  // Owner the tokens are transferred from, nil for the sender
+	To       common.Address  `json:"to"`              // Recipient, spender or operator
+	Amount   *hexutil.Big    `json:"amount,omitempty"`
+	TokenID  *hexutil.Big    `json:"tokenId,omitempty"`
+	Approved *bool           `json:"approved,omitempty"` // Whether the operator is approved or revoked
+}
+
+// tokenMethod is a standard token function.
+type tokenMethod struct {
+	method   abi.Method
+	standard string
+	action   string
+}
+
+// tokenMethods are the standard token functions, by selector.
+var tokenMethods = map[[4]byte]tokenMethod{}
+
+func init() {
+	for _, m := range []struct{ signature, standard, action string }{
+		{"transfer(address,uint256)", StandardERC20, ActionTransfer},
+		{"transferFrom(address,address,uint256)", StandardUnknown, ActionTransfer},
+		{"approve(address,uint256)", StandardUnknown, ActionApprove},
+		{"safeTransferFrom(address,address,uint256)", StandardERC721, ActionTransfer},
+		{"safeTransferFrom(address,address,uint256,bytes)", StandardERC721, ActionTransfer},
+		{"setApprovalForAll(address,bool)", StandardERC721, ActionSetApprovalForAll},
+	} {
+		method, err := ethutil.ParseSignature(m.signature)
+		if err != nil {
+			panic(err)
+		}
+		tokenMethods[[4]byte(method.ID)] = tokenMethod{method, m.standard, m.action}
+	}
+}
+
+// Previewer summarizes transactions, decoding them with a registry.
+type Previewer struct {
+	registry *Registry
+	provider usbwallet.TransactionMetadataProvider
+}
+
+// New creates a previewer decoding calls with the registry. The provider is the
+// one the hub looks up clear signing metadata with, telling which calls the
+// Ledger will display rather than blind sign; nil if none is configured.
+func New(registry *Registry, provider usbwallet.TransactionMetadataProvider) *Previewer {
+	return &Previewer{registry: registry, provider: provider}
+}
+
+// Preview summarizes the transaction, given as it would be to SignTx.
+func (p *Previewer) Preview(tx *coretypes.Transaction, chainID *big.Int) (*Summary, error) {
+	if chainID == nil {
+		chainID = new(big.Int)
+	}
+	s := &Summary{
+		ChainID:     (*hexutil.Big)(chainID),
+		Type:        tx.Type(),
+		Nonce:       tx.Nonce(),
+		To:          tx.To(),
+		Value:       (*hexutil.Big)(tx.Value()),
+		Gas:         tx.Gas(),
+		SigningHash: coretypes.LatestSignerForChainID(chainID).Hash(tx),
+	}
+	if tx.Type() <= coretypes.AccessListTxType {
+		s.GasPrice = (*hexutil.Big)(tx.GasPrice())
+	} else {
+		s.MaxFeePerGas = (*hexutil.Big)(tx.GasFeeCap())
+		s.MaxPriorityFeePerGas = (*hexutil.Big)(tx.GasTipCap())
+	}
+	switch {
+	case tx.To() == nil:
+		s.Kind = KindCreate
+	case len(tx.Data()) == 0:
+		s.Kind = KindTransfer
+	default:
+		s.Kind = KindCall
+		p.decodeCall(s, tx, chainID)
+	}
+	if len(tx.Data()) > 0 {
+		s.BlindSigned = true
+		if p.provider != nil && tx.To() != nil {
+			meta, err := p.provider.TransactionMetadata(tx, chainID)
+			if err != nil {
+				return nil, fmt.Errorf("preview: failed to retrieve transaction metadata: %w", err)
+			}
+			s.BlindSigned = meta == nil
+		}
+		if s.BlindSigned {
+			s.warn("the Ledger will only display a hash of the calldata (blind signing): check the details here before approving")
+		}
+	}
+	return s, nil
+}
+
+// warn appends a warning to the summary.
+func (s *Summary) warn(format string, args ...interface{}) {
+	s.Warnings = append(s.Warnings, fmt.Sprintf(format, args...))
+}
+
+// decodeCall decodes the calldata of a contract call into the summary, with the
+// ABI of the contract, as a standard token function or from the selector
+// database, in that order.
+func (p *Previewer) decodeCall(s *Summary, tx *coretypes.Transaction, chainID *big.Int) {
+	data := tx.Data()
+	if len(data) < 4 {
+		s.Call = &Call{Selector: common.CopyBytes(data)}
+		s.warn("calldata shorter than a function selector")
+		return
+	}
+	selector := [4]byte(data[:4])
+	s.Call = &Call{Selector: selector[:]}
+
+	decoded := false
+	if contract := p.registry.contract(chainID, *tx.To()); contract != nil {
+		method, err := contract.MethodById(selector[:])
+		switch {
+		case err != nil:
+			s.warn("function %s not found in the ABI of %s", hexutil.Encode(selector[:]), tx.To().Hex())
+		case decodeArgs(s.Call, *method, data, SourceABI):
+			decoded = true
+			if !method.IsPayable() && tx.Value().Sign() > 0 {
+				s.warn("value sent to the non-payable function %s, the call will revert", method.Sig)
+			}
+		default:
+			s.warn("calldata doesn't match the ABI of %s", method.Sig)
+		}
+	}
+	token, isToken := tokenMethods[selector]
+	if !decoded && isToken {
+		decoded = decodeArgs(s.Call, token.method, data, SourceStandard)
+	}
+	if !decoded {
+		var matches []string
+		for _, method := range p.registry.methods(selector) {
+			call := &Call{Selector: selector[:]}
+			if !decodeArgs(call, method, data, SourceSelectors) {
+				continue
+			}
+			if matches = append(matches, method.Sig); len(matches) == 1 {
+				s.Call, decoded = call, true
+			}
+		}
+		if len(matches) > 1 {
+			s.warn("selector %s matches several functions: %s", hexutil.Encode(selector[:]), strings.Join(matches, ", "))
+		}
+	}
+	if !decoded {
+		s.warn("unknown function %s", hexutil.Encode(selector[:]))
+	}
+	if isToken {
+		p.decodeToken(s, token, tx, chainID)
+	}
+}
+
+// decodeArgs decodes the calldata into the call, returning whether it matches
+// the method, encoded canonically.
+func decodeArgs(call *Call, method abi.Method, data []byte, source string) bool {
+	values, err := method.Inputs.UnpackValues(data[4:])
+	if err != nil {
+		return false
+	}
+	if packed, err := method.Inputs.Pack(values...); err != nil || !bytes.Equal(packed, data[4:]) {
+		return false
+	}
+	call.Signature, call.Source = method.Sig, source
+	call.Args = make([]Arg, len(values))
+	for i, input := range method.Inputs {
+		call.Args[i] = Arg{Type: input.Type.String(), Value: formatValue(input.Type, values[i])}
+		if source == SourceABI {
+			call.Args[i].Name = input.Name
+		}
+	}
+	return true
+}
+
+// decodeToken decodes the token transfer or approval of a standard token call.
+func (p *Previewer) decodeToken(s *Summary, method tokenMethod, tx *coretypes.Transaction, chainID *big.Int) {
+	values, err := method.method.Inputs.UnpackValues(tx.Data()[4:])
+	if err != nil {
+		return
+	}
+	action := &TokenAction{
+		Standard: method.standard,
+		Action:   method.action,
+		Contract: *tx.To(),
+		Token:    p.registry.token(chainID, *tx.To()),
+	}
+	if action.Token != nil {
+		if action.Standard != StandardUnknown && action.Token.Standard != action.Standard {
+			s.warn("%s function called on the %s token %s", action.Standard, action.Token.Standard, tx.To().Hex())
+			return
+		}
+		action.Standard = action.Token.Standard
+	}
+	// Transfers from an owner shift the recipient and amount
+	if len(values) > 2 && method.action == ActionTransfer {
+		from := values[0].(common.Address)
+		action.From, values = &from, values[1:]
+	}
+	action.To = values[0].(common.Address)
+
+	switch {
+	case method.action == ActionSetApprovalForAll:
+		approved := values[1].(bool)
+		action.Approved = &approved
+	case action.Standard == StandardERC721:
+		action.TokenID = (*hexutil.Big)(values[1].(*big.Int))
+	default:
+		action.Amount = (*hexutil.Big)(values[1].(*big.Int))
+	}
+	if action.Standard == StandardUnknown {
+		s.warn("unknown token %s: the amount may be an ERC-721 token ID", tx.To().Hex())
+	}
+	if action.Action == ActionTransfer {
+		s.Kind = KindTokenTransfer
+	} else {
+		s.Kind = KindTokenApproval
+	}
+	s.Token = action
+}
+
+// Description describes the token action in a sentence.
+func (a *TokenAction) Description() string {
+	name := a.Contract.Hex()
+	if a.Token != nil && a.Token.Symbol != "" {
+		name = a.Token.Symbol
+	}
+	var amount string
+	switch {
+	case a.TokenID != nil:
+		amount = fmt.Sprintf("%s #%s", name, a.TokenID.ToInt())
+	case a.Amount != nil && a.Action == ActionApprove && a.Amount.ToInt().Cmp(math.MaxBig256) == 0:
+		amount = "unlimited " + name
+	case a.Amount != nil && a.Token != nil && a.Standard == StandardERC20:
+		amount = ethutil.FormatUnits(a.Amount.ToInt(), a.Token.Decimals) + " " + name
+	case a.Amount != nil:
+		amount = a.Amount.ToInt().String() + " " + name + " (base units)"
+	}
+	switch a.Action {
+	case ActionTransfer:
+		if a.From != nil {
+			return fmt.Sprintf("Transfer %s from %s to %s", amount, a.From.Hex(), a.To.Hex())
+		}
+		return fmt.Sprintf("Transfer %s to %s", amount, a.To.Hex())
+	case ActionApprove:
+		return fmt.Sprintf("Approve %s to spend %s", a.To.Hex(), amount)
+	default:
+		if a.Approved != nil && !*a.Approved {
+			return fmt.Sprintf("Revoke %s as operator of all %s tokens", a.To.Hex(), name)
+		}
+		return fmt.Sprintf("Approve %s as operator of all %s tokens", a.To.Hex(), name)
+	}
+}
+
+// String implements fmt.Stringer, rendering the summary as text.
+func (s *Summary) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Chain ID:     %s\n", s.ChainID.ToInt())
+	if s.To != nil {
+		fmt.Fprintf(&b, "To:           %s\n", s.To.Hex())
+	} else {
+		fmt.Fprintf(&b, "To:           contract creation\n")
+	}
+	fmt.Fprintf(&b, "Value:        %s ETH\n", ethutil.FormatUnits(s.Value.ToInt(), 18))
+	fmt.Fprintf(&b, "Type:         %d\n", s.Type)
+	fmt.Fprintf(&b, "Nonce:        %d\n", s.Nonce)
+	fmt.Fprintf(&b, "Gas limit:    %d\n", s.Gas)
+	if s.GasPrice != nil {
+		fmt.Fprintf(&b, "Gas price:    %s gwei\n", ethutil.FormatUnits(s.GasPrice.ToInt(), 9))
+	} else {
+		fmt.Fprintf(&b, "Max fee:      %s gwei\n", ethutil.FormatUnits(s.MaxFeePerGas.ToInt(), 9))
+		fmt.Fprintf(&b, "Priority fee: %s gwei\n", ethutil.FormatUnits(s.MaxPriorityFeePerGas.ToInt(), 9))
+	}
+	if s.Call != nil {
+		if s.Call.Signature != "" {
+			fmt.Fprintf(&b, "Function:     %s\n", s.Call.Signature)
+		} else {
+			fmt.Fprintf(&b, "Function:     unknown %s\n", s.Call.Selector)
+		}
+		for i, arg := range s.Call.Args {
+			name := arg.Name
+			if name == "" {
+				name = fmt.Sprintf("#%d", i)
+			}
+			fmt.Fprintf(&b, "  %s %s: %s\n", arg.Type, name, arg.Value)
+		}
+	}
+	if s.Token != nil {
+		fmt.Fprintf(&b, "%-14s%s\n", s.Token.Standard+":", s.Token.Description())
+	}
+	fmt.Fprintf(&b, "Signing hash: %s\n", s.SigningHash.Hex())
+	for _, warning := range s.Warnings {
+		fmt.Fprintf(&b, "WARNING: %s\n", warning)
+	}
+	return b.String()
+}
+
+// formatValue renders a decoded argument.
+func formatValue(typ abi.Type, value interface{}) string {
+	rv := reflect.ValueOf(value)
+
+	switch typ.T {
+	case abi.TupleTy:
+		elems := make([]string, len(typ.TupleElems))
+		for i, elem := range typ.TupleElems {
+			elems[i] = formatValue(*elem, rv.Field(i).Interface())
+		}
+		return "(" + strings.Join(elems, ", ") + ")"
+
+	case abi.SliceTy, abi.ArrayTy:
+		elems := make([]string, rv.Len())
+		for i := range elems {
+			elems[i] = formatValue(*typ.Elem, rv.Index(i).Interface())
+		}
+		return "[" + strings.Join(elems, ", ") + "]"
+
+	case abi.AddressTy:
+		return value.(common.Address).Hex()
+
+	case abi.BytesTy:
+		return hexutil.Encode(value.([]byte))
+
+	case abi.FixedBytesTy, abi.FunctionTy:
+		fixed := make([]byte, rv.Len())
+		reflect.Copy(reflect.ValueOf(fixed), rv)
+		return hexutil.Encode(fixed)
+
+	case abi.StringTy:
+		return fmt.Sprintf("%q", value)
+	}
+	return fmt.Sprint(value)
+}
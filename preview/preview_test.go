@@ -0,0 +1,379 @@
+package preview
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evmos/ethereum-ledger-go/internal/testutil"
+	"github.com/evmos/ethereum-ledger-go/usbwallet"
+)
+
+var (
+	testChainID   = big.NewInt(1)
+	testRecipient = common.HexToAddress("0x3535353535353535353535353535353535353535")
+	testUSDC      = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	testNFT       = common.HexToAddress("0xBC4CA0EdA7647A8aB7C2061c2E118A18a936f13D")
+	testVault     = common.HexToAddress("0x1111111111111111111111111111111111111111")
+)
+
+const testVaultABI = `[
+  {"type": "function", "name": "deposit", "stateMutability": "nonpayable",
+   "inputs": [{"name": "assets", "type": "uint256"}, {"name": "receiver", "type": "address"}], "outputs": []}
+]`
+
+func newTestRegistry(t *testing.T) *Registry {
+	t.Helper()
+
+	registry := NewRegistry()
+	registry.AddToken(testChainID, testUSDC, Token{Standard: StandardERC20, Symbol: "USDC", Decimals: 6})
+	registry.AddToken(testChainID, testNFT, Token{Standard: StandardERC721, Symbol: "BAYC"})
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, testVault.Hex()+".json"), []byte(testVaultABI), 0o600))
+	require.NoError(t, registry.LoadABIDir(testChainID, dir))
+	return registry
+}
+
+func callTx(to common.Address, value int64, data []byte) *coretypes.Transaction {
+	return coretypes.NewTx(&coretypes.DynamicFeeTx{
+		ChainID: testChainID, To: &to, Gas: 100000, Value: big.NewInt(value), Data: data,
+		GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(1),
+	})
+}
+
+func TestPreviewTransfer(t *testing.T) {
+	summary, err := New(NewRegistry(), nil).Preview(callTx(testRecipient, 1e18, nil), testChainID)
+	require.NoError(t, err)
+	require.Equal(t, KindTransfer, summary.Kind)
+	require.False(t, summary.BlindSigned)
+	require.Empty(t, summary.Warnings)
+	require.Contains(t, summary.String(), "Value:        1 ETH")
+}
+
+func TestPreviewTokens(t *testing.T) {
+	previewer := New(newTestRegistry(t), nil)
+	owner := common.HexToAddress("0x2222222222222222222222222222222222222222")
+
+	tests := []struct {
+		name        string
+		tx          *coretypes.Transaction
+		kind        string
+		standard    string
+		description string
+		warnings    int
+	}{
+		{
+			"erc20 transfer", callTx(testUSDC, 0, testutil.PackCall(t, "transfer(address,uint256)", testRecipient, big.NewInt(1_500_000))),
+			KindTokenTransfer, StandardERC20, "Transfer 1.5 USDC to " + testRecipient.Hex(), 1,
+		},
+		{
+			"erc20 transferFrom", callTx(testUSDC, 0, testutil.PackCall(t, "transferFrom(address,address,uint256)", owner, testRecipient, big.NewInt(2_000_000))),
+			KindTokenTransfer, StandardERC20, "Transfer 2 USDC from " + owner.Hex() + " to " + testRecipient.Hex(), 1,
+		},
+		{
+			"unknown token approve", callTx(testRecipient, 0, testutil.PackCall(t, "approve(address,uint256)", testVault, math.MaxBig256)),
+			KindTokenApproval, StandardUnknown, "Approve " + testVault.Hex() + " to spend unlimited " + testRecipient.Hex(), 2,
+		},
+		{
+			"erc721 safeTransferFrom", callTx(testNFT, 0, testutil.PackCall(t, "safeTransferFrom(address,address,uint256)", owner, testRecipient, big.NewInt(42))),
+			KindTokenTransfer, StandardERC721, "Transfer BAYC #42 from " + owner.Hex() + " to " + testRecipient.Hex(), 1,
+		},
+		{
+			"erc721 approve", callTx(testNFT, 0, testutil.PackCall(t, "approve(address,uint256)", testVault, big.NewInt(7))),
+			KindTokenApproval, StandardERC721, "Approve " + testVault.Hex() + " to spend BAYC #7", 1,
+		},
+		{
+			"erc721 revoke operator", callTx(testNFT, 0, testutil.PackCall(t, "setApprovalForAll(address,bool)", testVault, false)),
+			KindTokenApproval, StandardERC721, "Revoke " + testVault.Hex() + " as operator of all BAYC tokens", 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary, err := previewer.Preview(tt.tx, testChainID)
+			require.NoError(t, err)
+			require.Equal(t, tt.kind, summary.Kind)
+			require.Equal(t, SourceStandard, summary.Call.Source)
+			require.Equal(t, tt.standard, summary.Token.Standard)
+			require.Equal(t, tt.description, summary.Token.Description())
+			require.True(t, summary.BlindSigned)
+			require.Len(t, summary.Warnings, tt.warnings)
+		})
+	}
+	// Functions of another standard aren't taken as token actions
+	summary, err := previewer.Preview(callTx(testNFT, 0, testutil.PackCall(t, "transfer(address,uint256)", testRecipient, big.NewInt(1))), testChainID)
+	require.NoError(t, err)
+	require.Equal(t, KindCall, summary.Kind)
+	require.Nil(t, summary.Token)
+}
+
+func TestPreviewABI(t *testing.T) {
+	previewer := New(newTestRegistry(t), nil)
+
+	contract, err := abi.JSON(strings.NewReader(testVaultABI))
+	require.NoError(t, err)
+	data, err := contract.Pack("deposit", big.NewInt(100), testRecipient)
+	require.NoError(t, err)
+
+	summary, err := previewer.Preview(callTx(testVault, 0, data), testChainID)
+	require.NoError(t, err)
+	require.Equal(t, &Call{
+		Selector:  data[:4],
+		Signature: "deposit(uint256,address)",
+		Source:    SourceABI,
+		Args: []Arg{
+			{Name: "assets", Type: "uint256", Value: "100"},
+			{Name: "receiver", Type: "address", Value: testRecipient.Hex()},
+		},
+	}, summary.Call)
+	require.Contains(t, summary.String(), "  address receiver: "+testRecipient.Hex())
+
+	// Value sent to non-payable functions is flagged
+	summary, err = previewer.Preview(callTx(testVault, 1, data), testChainID)
+	require.NoError(t, err)
+	require.Len(t, summary.Warnings, 2)
+	require.Contains(t, summary.Warnings[0], "non-payable")
+
+	// The ABI is bound to the chain
+	summary, err = previewer.Preview(callTx(testVault, 0, data), big.NewInt(10))
+	require.NoError(t, err)
+	require.Empty(t, summary.Call.Signature)
+	require.Contains(t, summary.Warnings[0], "unknown function")
+}
+
+func TestPreviewSelectors(t *testing.T) {
+	db := map[string]interface{}{
+		"0xb6b55f25": "deposit(uint256)",
+		"0x2e1a7d4d": []string{"withdraw(uint256)"},
+		"0x3ccfd60b": "withdraw()",
+	}
+	blob, err := json.Marshal(db)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "selectors.json")
+	require.NoError(t, os.WriteFile(path, blob, 0o600))
+
+	registry := NewRegistry()
+	require.NoError(t, registry.LoadSelectors(path))
+
+	summary, err := New(registry, nil).Preview(callTx(testRecipient, 0, testutil.PackCall(t, "withdraw(uint256)", big.NewInt(5))), testChainID)
+	require.NoError(t, err)
+	require.Equal(t, "withdraw(uint256)", summary.Call.Signature)
+	require.Equal(t, SourceSelectors, summary.Call.Source)
+	require.Equal(t, []Arg{{Type: "uint256", Value: "5"}}, summary.Call.Args)
+	require.Contains(t, summary.String(), "  uint256 #0: 5")
+
+	// Calldata not encoded as the signature is left undecoded
+	data := append(testutil.PackCall(t, "withdraw(uint256)", big.NewInt(5)), 0x01)
+	summary, err = New(registry, nil).Preview(callTx(testRecipient, 0, data), testChainID)
+	require.NoError(t, err)
+	require.Empty(t, summary.Call.Signature)
+
+	// Signatures must hash to their selector
+	db["0x12345678"] = "withdraw(uint128)"
+	blob, err = json.Marshal(db)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, blob, 0o600))
+	require.ErrorContains(t, NewRegistry().LoadSelectors(path), "doesn't match selector")
+}
+
+// staticProvider provides clear signing metadata for the calls of a contract.
+type staticProvider common.Address
+
+func (p staticProvider) TransactionMetadata(tx *coretypes.Transaction, chainID *big.Int) (*usbwallet.TransactionMetadata, error) {
+	if *tx.To() != common.Address(p) {
+		return nil, nil
+	}
+	return &usbwallet.TransactionMetadata{Info: []byte{0x01}}, nil
+}
+
+func TestPreviewBlindSigning(t *testing.T) {
+	previewer := New(newTestRegistry(t), staticProvider(testUSDC))
+
+	summary, err := previewer.Preview(callTx(testUSDC, 0, testutil.PackCall(t, "transfer(address,uint256)", testRecipient, big.NewInt(1))), testChainID)
+	require.NoError(t, err)
+	require.False(t, summary.BlindSigned)
+	require.Empty(t, summary.Warnings)
+
+	summary, err = previewer.Preview(callTx(testNFT, 0, testutil.PackCall(t, "setApprovalForAll(address,bool)", testVault, true)), testChainID)
+	require.NoError(t, err)
+	require.True(t, summary.BlindSigned)
+	require.Contains(t, summary.String(), "WARNING: the Ledger will only display a hash of the calldata")
+
+	// Contract creations are always blind signed
+	create := coretypes.NewTx(&coretypes.DynamicFeeTx{ChainID: testChainID, Gas: 100000, Data: []byte{0x60, 0x00}, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(1)})
+	summary, err = previewer.Preview(create, testChainID)
+	require.NoError(t, err)
+	require.Equal(t, KindCreate, summary.Kind)
+	require.True(t, summary.BlindSigned)
+	require.Contains(t, summary.String(), "To:           contract creation")
+}
+
+const testTypedData = `{
+  "types": {
+    "EIP712Domain": [
+      {"name": "name", "type": "string"},
+      {"name": "version", "type": "string"},
+      {"name": "chainId", "type": "uint256"},
+      {"name": "verifyingContract", "type": "address"}
+    ],
+    "Person": [
+      {"name": "name", "type": "string"},
+      {"name": "wallets", "type": "address[]"}
+    ],
+    "Mail": [
+      {"name": "from", "type": "Person"},
+      {"name": "to", "type": "Person[]"},
+      {"name": "contents", "type": "string"}
+    ]
+  },
+  "primaryType": "Mail",
+  "domain": {
+    "name": "Ether Mail",
+    "version": "1",
+    "chainId": 1,
+    "verifyingContract": "0xcccccccccccccccccccccccccccccccccccccccc"
+  },
+  "message": {
+    "from": {"name": "Cow", "wallets": ["0xcd2a3d9f938e13cd947ec05abc7fe734df8dd826"]},
+    "to": [{"name": "Bob", "wallets": []}],
+    "contents": "Hello, Bob!"
+  }
+}`
+
+func loadTypedData(t *testing.T) apitypes.TypedData {
+	t.Helper()
+
+	var typedData apitypes.TypedData
+	require.NoError(t, json.Unmarshal([]byte(testTypedData), &typedData))
+	return typedData
+}
+
+func TestPreviewTypedData(t *testing.T) {
+	typedData := loadTypedData(t)
+
+	summary, err := PreviewTypedData(typedData, testChainID)
+	require.NoError(t, err)
+
+	hash, raw, err := apitypes.TypedDataAndHash(typedData)
+	require.NoError(t, err)
+	require.Equal(t, common.BytesToHash([]byte(raw[2:34])), summary.DomainHash)
+	require.Equal(t, common.BytesToHash([]byte(raw[34:])), summary.MessageHash)
+	require.Equal(t, common.BytesToHash(hash), summary.Hash)
+
+	require.Equal(t, `EIP712Domain
+  name (string): "Ether Mail"
+  version (string): "1"
+  chainId (uint256): 1
+  verifyingContract (address): 0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC
+Mail
+  from (Person)
+    name (string): "Cow"
+    wallets (address[])
+      [0] (address): 0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826
+  to (Person[])
+    [0] (Person)
+      name (string): "Bob"
+      wallets (address[])
+  contents (string): "Hello, Bob!"
+Domain hash:  `+summary.DomainHash.Hex()+`
+Message hash: `+summary.MessageHash.Hex()+`
+`, summary.String())
+}
+
+func TestValidateTypedData(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(typedData *apitypes.TypedData)
+		chainID  *big.Int
+		problems []string
+	}{
+		{
+			"missing type",
+			func(typedData *apitypes.TypedData) { delete(typedData.Types, "Person") },
+			nil,
+			[]string{"type Person of field Mail.from is not declared", "type Person[] of field Mail.to is not declared"},
+		},
+		{
+			"unused type",
+			func(typedData *apitypes.TypedData) {
+				typedData.Types["Attachment"] = []apitypes.Type{{Name: "data", Type: "bytes"}}
+			},
+			nil,
+			[]string{"type Attachment is unused"},
+		},
+		{
+			"undeclared domain field",
+			func(typedData *apitypes.TypedData) {
+				typedData.Types["EIP712Domain"] = typedData.Types["EIP712Domain"][1:]
+			},
+			nil,
+			[]string{"domain field name is not declared in EIP712Domain"},
+		},
+		{
+			"missing domain field",
+			func(typedData *apitypes.TypedData) { typedData.Domain.Version = "" },
+			nil,
+			[]string{"domain field version is declared in EIP712Domain but missing"},
+		},
+		{
+			"nonstandard domain field",
+			func(typedData *apitypes.TypedData) {
+				typedData.Types["EIP712Domain"] = append(typedData.Types["EIP712Domain"], apitypes.Type{Name: "owner", Type: "address"})
+			},
+			nil,
+			[]string{"EIP712Domain field owner is not a standard domain field"},
+		},
+		{
+			"chain mismatch",
+			func(typedData *apitypes.TypedData) {},
+			big.NewInt(10),
+			[]string{"domain chainId 1 doesn't match the expected chain 10"},
+		},
+		{
+			"unbound chain",
+			func(typedData *apitypes.TypedData) {
+				typedData.Types["EIP712Domain"] = slices.DeleteFunc(typedData.Types["EIP712Domain"], func(field apitypes.Type) bool {
+					return field.Name == "chainId"
+				})
+				typedData.Domain.ChainId = nil
+			},
+			big.NewInt(10),
+			[]string{"domain not bound to chain 10"},
+		},
+		{
+			"message mismatch",
+			func(typedData *apitypes.TypedData) {
+				delete(typedData.Message, "contents")
+				typedData.Message["to"].([]interface{})[0].(map[string]interface{})["age"] = 3.0
+			},
+			nil,
+			[]string{"message field Mail.to[0].age is not declared in Person", "message Mail lacks field contents"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			typedData := loadTypedData(t)
+			tt.modify(&typedData)
+
+			err := ValidateTypedData(typedData, tt.chainID)
+			var terr *TypedDataError
+			require.ErrorAs(t, err, &terr)
+			require.ElementsMatch(t, tt.problems, terr.Problems)
+
+			_, err = PreviewTypedData(typedData, tt.chainID)
+			require.ErrorAs(t, err, &terr)
+		})
+	}
+	require.NoError(t, ValidateTypedData(loadTypedData(t), testChainID))
+}
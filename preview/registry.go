@@ -0,0 +1,217 @@
+package preview
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/evmos/ethereum-ledger-go/internal/ethutil"
+)
+
+// Token standards.
+const (
+	StandardERC20   = "ERC-20"
+	StandardERC721  = "ERC-721"
+	StandardUnknown = "ERC-20/ERC-721" // Token of unknown standard, called through a selector both share
+)
+
+// Token is the metadata of a token contract.
+type Token struct {
+	Standard string `json:"standard"`
+	Name     string `json:"name,omitempty"`
+	Symbol   string `json:"symbol,omitempty"`
+	Decimals uint8  `json:"decimals,omitempty"` // ERC-20 only
+}
+
+// contractKey identifies a contract on a chain.
+type contractKey struct {
+	chainID string
+	address common.Address
+}
+
+func newContractKey(chainID *big.Int, address common.Address) contractKey {
+	return contractKey{chainID: chainID.String(), address: address}
+}
+
+// Registry holds the ABIs of known contracts, a 4 byte selector database for the
+// calls of other contracts, and token metadata, used to decode transactions.
+type Registry struct {
+	abis      map[contractKey]*abi.ABI
+	selectors map[[4]byte][]abi.Method
+	tokens    map[contractKey]*Token
+
+	lock sync.RWMutex
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		abis:      make(map[contractKey]*abi.ABI),
+		selectors: make(map[[4]byte][]abi.Method),
+		tokens:    make(map[contractKey]*Token),
+	}
+}
+
+// AddABI registers the ABI of a contract deployed on the given chain.
+func (r *Registry) AddABI(chainID *big.Int, address common.Address, contract abi.ABI) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.abis[newContractKey(chainID, address)] = &contract
+}
+
+// LoadABIFile registers the JSON ABI file of a contract deployed on the given
+// chain.
+func (r *Registry) LoadABIFile(chainID *big.Int, address common.Address, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	contract, err := abi.JSON(file)
+	if err != nil {
+		return fmt.Errorf("preview: invalid ABI %s: %v", path, err)
+	}
+	r.AddABI(chainID, address, contract)
+	return nil
+}
+
+// LoadABIDir registers all the JSON ABI files within a directory, each named
+// after the address of the contract (e.g. 0xA0b8...eB48.json) deployed on the
+// given chain.
+func (r *Registry) LoadABIDir(chainID *big.Int, dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".json")
+		if !common.IsHexAddress(name) {
+			return fmt.Errorf("preview: ABI file %s not named after a contract address", file)
+		}
+		if err := r.LoadABIFile(chainID, common.HexToAddress(name), file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddSignature registers a function signature (e.g. "transfer(address,uint256)")
+// into the selector database.
+func (r *Registry) AddSignature(signature string) error {
+	method, err := ethutil.ParseSignature(signature)
+	if err != nil {
+		return fmt.Errorf("preview: %w", err)
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.addMethod(method)
+	return nil
+}
+
+// addMethod inserts a method into the selector database, unless known already.
+func (r *Registry) addMethod(method abi.Method) {
+	selector := [4]byte(method.ID)
+	for _, known := range r.selectors[selector] {
+		if known.Sig == method.Sig {
+			return
+		}
+	}
+	r.selectors[selector] = append(r.selectors[selector], method)
+}
+
+// LoadSelectors loads a 4 byte selector database file into the registry: a JSON
+// object mapping hex selectors to a function signature or a list of colliding
+// ones:
+//
+//	{
+//	  "0xa9059cbb": "transfer(address,uint256)",
+//	  "0x12345678": ["foo(uint256)", "bar(bytes32)"]
+//	}
+//
+// Signatures not hashing to their selector are refused.
+func (r *Registry) LoadSelectors(path string) error {
+	blob, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var db map[string]json.RawMessage
+	if err := json.Unmarshal(blob, &db); err != nil {
+		return fmt.Errorf("preview: invalid selector database %s: %v", path, err)
+	}
+	var methods []abi.Method
+	for key, raw := range db {
+		selector, err := hexutil.Decode(key)
+		if err != nil || len(selector) != 4 {
+			return fmt.Errorf("preview: invalid selector %q in %s", key, path)
+		}
+		var signatures []string
+		if err := json.Unmarshal(raw, &signatures); err != nil {
+			var signature string
+			if err := json.Unmarshal(raw, &signature); err != nil {
+				return fmt.Errorf("preview: invalid signatures of selector %s in %s", key, path)
+			}
+			signatures = []string{signature}
+		}
+		for _, signature := range signatures {
+			method, err := ethutil.ParseSignature(signature)
+			if err != nil {
+				return fmt.Errorf("preview: %w in %s", err, path)
+			}
+			if string(method.ID) != string(selector) {
+				return fmt.Errorf("preview: signature %q doesn't match selector %s in %s", signature, key, path)
+			}
+			methods = append(methods, method)
+		}
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, method := range methods {
+		r.addMethod(method)
+	}
+	return nil
+}
+
+// AddToken registers the metadata of a token contract deployed on the given
+// chain.
+func (r *Registry) AddToken(chainID *big.Int, address common.Address, token Token) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.tokens[newContractKey(chainID, address)] = &token
+}
+
+// contract returns the ABI of a contract, if known.
+func (r *Registry) contract(chainID *big.Int, address common.Address) *abi.ABI {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.abis[newContractKey(chainID, address)]
+}
+
+// methods returns the functions of the selector database matching a selector.
+func (r *Registry) methods(selector [4]byte) []abi.Method {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.selectors[selector]
+}
+
+// token returns the metadata of a token, if known.
+func (r *Registry) token(chainID *big.Int, address common.Address) *Token {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.tokens[newContractKey(chainID, address)]
+}
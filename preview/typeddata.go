@@ -0,0 +1,356 @@
+package preview
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// domainType is the name of the EIP-712 domain struct.
+const domainType = "EIP712Domain"
+
+// domainFields are the types of the standard EIP-712 domain fields.
+var domainFields = map[string]string{
+	"name":              "string",
+	"version":           "string",
+	"chainId":           "uint256",
+	"verifyingContract": "address",
+	"salt":              "bytes32",
+}
+
+// primitiveType matches the EIP-712 atomic and dynamic types.
+var primitiveType = regexp.MustCompile(`^(address|bool|string|bytes([1-9]|[12][0-9]|3[0-2])?|u?int(8|16|24|32|40|48|56|64|72|80|88|96|104|112|120|128|136|144|152|160|168|176|184|192|200|208|216|224|232|240|248|256)?)$`)
+
+// arraySuffix matches the array dimensions of a type, e.g. [] or [3].
+var arraySuffix = regexp.MustCompile(`\[[0-9]*\]$`)
+
+// TypedDataError lists the problems found validating EIP-712 typed data.
+type TypedDataError struct {
+	Problems []string
+}
+
+// Error implements error.
+func (e *TypedDataError) Error() string {
+	return "preview: invalid typed data: " + strings.Join(e.Problems, "; ")
+}
+
+// ValidateTypedData checks EIP-712 typed data before it's hashed and signed:
+// every referenced type must be declared and every declared type used, the
+// domain fields must match the ones declared in EIP712Domain, and the message
+// must match its types. If an expected chain ID is given, the domain must be
+// bound to it with a matching chainId. The problems found are reported as a
+// *TypedDataError.
+func ValidateTypedData(typedData apitypes.TypedData, chainID *big.Int) error {
+	v := new(typedDataValidator)
+
+	// Types must be declared, used and well formed
+	if _, ok := typedData.Types[domainType]; !ok {
+		v.report("type %s is not declared", domainType)
+	}
+	if typedData.PrimaryType == "" {
+		v.report("primary type missing")
+	} else if _, ok := typedData.Types[typedData.PrimaryType]; !ok {
+		v.report("primary type %s is not declared", typedData.PrimaryType)
+	}
+	used := make(map[string]bool)
+	for _, name := range typedData.Dependencies(typedData.PrimaryType, typedData.Dependencies(domainType, nil)) {
+		used[name] = true
+	}
+	names := make([]string, 0, len(typedData.Types))
+	for name := range typedData.Types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !used[name] {
+			v.report("type %s is unused", name)
+		}
+		seen := make(map[string]bool)
+		for _, field := range typedData.Types[name] {
+			switch {
+			case field.Name == "":
+				v.report("field of %s without a name", name)
+			case seen[field.Name]:
+				v.report("field %s.%s declared twice", name, field.Name)
+			}
+			seen[field.Name] = true
+
+			base := baseType(field.Type)
+			if _, ok := typedData.Types[base]; !ok && !primitiveType.MatchString(base) {
+				v.report("type %s of field %s.%s is not declared", field.Type, name, field.Name)
+			}
+		}
+	}
+	// Domain fields must be declared, with their standard types
+	declared := make(map[string]bool)
+	for _, field := range typedData.Types[domainType] {
+		declared[field.Name] = true
+
+		want, ok := domainFields[field.Name]
+		switch {
+		case !ok:
+			v.report("%s field %s is not a standard domain field", domainType, field.Name)
+		case field.Type != want:
+			v.report("%s field %s has type %s, expected %s", domainType, field.Name, field.Type, want)
+		}
+	}
+	domain := typedData.Domain.Map()
+	for _, name := range sortedKeys(domain) {
+		if !declared[name] {
+			v.report("domain field %s is not declared in %s", name, domainType)
+		}
+	}
+	for _, field := range typedData.Types[domainType] {
+		if _, ok := domain[field.Name]; !ok && domainFields[field.Name] != "" {
+			v.report("domain field %s is declared in %s but missing", field.Name, domainType)
+		}
+	}
+	if chainID != nil {
+		switch have := (*big.Int)(typedData.Domain.ChainId); {
+		case have == nil:
+			v.report("domain not bound to chain %s", chainID)
+		case have.Cmp(chainID) != 0:
+			v.report("domain chainId %s doesn't match the expected chain %s", have, chainID)
+		}
+	}
+	if len(v.problems) > 0 {
+		return &TypedDataError{Problems: v.problems}
+	}
+	// With sound types, the message must match them and encode
+	v.checkStruct(typedData, typedData.PrimaryType, typedData.PrimaryType, typedData.Message)
+	if len(v.problems) == 0 {
+		if _, _, err := apitypes.TypedDataAndHash(typedData); err != nil {
+			v.report("%v", err)
+		}
+	}
+	if len(v.problems) > 0 {
+		return &TypedDataError{Problems: v.problems}
+	}
+	return nil
+}
+
+// typedDataValidator collects the problems found validating typed data.
+type typedDataValidator struct {
+	problems []string
+}
+
+// report records a problem.
+func (v *typedDataValidator) report(format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+// checkStruct checks that a message value has exactly the fields of its struct
+// type, recursing into nested structs.
+func (v *typedDataValidator) checkStruct(typedData apitypes.TypedData, path, name string, value interface{}) {
+	data, ok := value.(map[string]interface{})
+	if !ok {
+		v.report("message %s is not a %s struct", path, name)
+		return
+	}
+	fields := make(map[string]bool)
+	for _, field := range typedData.Types[name] {
+		fields[field.Name] = true
+
+		child, ok := data[field.Name]
+		if !ok {
+			v.report("message %s lacks field %s", path, field.Name)
+			continue
+		}
+		v.checkValue(typedData, path+"."+field.Name, field.Type, child)
+	}
+	for _, key := range sortedKeys(data) {
+		if !fields[key] {
+			v.report("message field %s.%s is not declared in %s", path, key, name)
+		}
+	}
+}
+
+// checkValue checks the nested structs of a message value, recursing into
+// arrays.
+func (v *typedDataValidator) checkValue(typedData apitypes.TypedData, path, typ string, value interface{}) {
+	if elem := arraySuffix.ReplaceAllString(typ, ""); elem != typ {
+		items, ok := value.([]interface{})
+		if !ok {
+			v.report("message %s is not an array", path)
+			return
+		}
+		for i, item := range items {
+			v.checkValue(typedData, fmt.Sprintf("%s[%d]", path, i), elem, item)
+		}
+		return
+	}
+	if _, ok := typedData.Types[typ]; ok {
+		v.checkStruct(typedData, path, typ, value)
+	}
+}
+
+// TypedDataNode is a field of a rendered EIP-712 struct: either a value, or a
+// struct or array with its children.
+type TypedDataNode struct {
+	Name     string           `json:"name"`
+	Type     string           `json:"type"`
+	Value    string           `json:"value,omitempty"`
+	Children []*TypedDataNode `json:"children,omitempty"`
+}
+
+// TypedDataSummary is the rendered preview of EIP-712 typed data, along with the
+// hashes the Ledger displays when it can't show the fields themselves.
+type TypedDataSummary struct {
+	PrimaryType string           `json:"primaryType"`
+	Domain      []*TypedDataNode `json:"domain"`
+	Message     []*TypedDataNode `json:"message"`
+	DomainHash  common.Hash      `json:"domainHash"`  // Domain separator
+	MessageHash common.Hash      `json:"messageHash"` // Struct hash of the message
+	Hash        common.Hash      `json:"hash"`        // Signing hash
+}
+
+// PreviewTypedData validates EIP-712 typed data, see ValidateTypedData, and
+// renders it as a tree of fields, along with its hashes.
+func PreviewTypedData(typedData apitypes.TypedData, chainID *big.Int) (*TypedDataSummary, error) {
+	if err := ValidateTypedData(typedData, chainID); err != nil {
+		return nil, err
+	}
+	domainHash, err := typedData.HashStruct(domainType, typedData.Domain.Map())
+	if err != nil {
+		return nil, fmt.Errorf("preview: %v", err)
+	}
+	messageHash, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+	if err != nil {
+		return nil, fmt.Errorf("preview: %v", err)
+	}
+	return &TypedDataSummary{
+		PrimaryType: typedData.PrimaryType,
+		Domain:      renderStruct(typedData, domainType, typedData.Domain.Map()),
+		Message:     renderStruct(typedData, typedData.PrimaryType, typedData.Message),
+		DomainHash:  common.BytesToHash(domainHash),
+		MessageHash: common.BytesToHash(messageHash),
+		Hash:        crypto.Keccak256Hash([]byte{0x19, 0x01}, domainHash, messageHash),
+	}, nil
+}
+
+// renderStruct renders the fields of a struct, in declaration order.
+func renderStruct(typedData apitypes.TypedData, name string, data map[string]interface{}) []*TypedDataNode {
+	nodes := make([]*TypedDataNode, len(typedData.Types[name]))
+	for i, field := range typedData.Types[name] {
+		nodes[i] = renderValue(typedData, field.Name, field.Type, data[field.Name])
+	}
+	return nodes
+}
+
+// renderValue renders a field, recursing into structs and arrays.
+func renderValue(typedData apitypes.TypedData, name, typ string, value interface{}) *TypedDataNode {
+	node := &TypedDataNode{Name: name, Type: typ}
+	if elem := arraySuffix.ReplaceAllString(typ, ""); elem != typ {
+		items, _ := value.([]interface{})
+		node.Children = make([]*TypedDataNode, len(items))
+		for i, item := range items {
+			node.Children[i] = renderValue(typedData, fmt.Sprintf("[%d]", i), elem, item)
+		}
+		return node
+	}
+	if _, ok := typedData.Types[typ]; ok {
+		data, _ := value.(map[string]interface{})
+		node.Children = renderStruct(typedData, typ, data)
+		return node
+	}
+	node.Value = formatTypedValue(typ, value)
+	return node
+}
+
+// formatTypedValue renders a primitive value of a message.
+func formatTypedValue(typ string, value interface{}) string {
+	switch {
+	case typ == "address":
+		if s, ok := value.(string); ok && common.IsHexAddress(s) {
+			return common.HexToAddress(s).Hex()
+		}
+	case typ == "string":
+		if s, ok := value.(string); ok {
+			return fmt.Sprintf("%q", s)
+		}
+	case strings.HasPrefix(typ, "uint") || strings.HasPrefix(typ, "int"):
+		switch v := value.(type) {
+		case *math.HexOrDecimal256:
+			return (*big.Int)(v).String()
+		case *big.Int:
+			return v.String()
+		case string:
+			if n, ok := math.ParseBig256(v); ok {
+				return n.String()
+			}
+		case json.Number:
+			if n, ok := math.ParseBig256(v.String()); ok {
+				return n.String()
+			}
+		case float64:
+			if n, acc := big.NewFloat(v).Int(nil); acc == big.Exact {
+				return n.String()
+			}
+		}
+	case strings.HasPrefix(typ, "bytes"):
+		switch v := value.(type) {
+		case []byte:
+			return hexutil.Encode(v)
+		case hexutil.Bytes:
+			return v.String()
+		}
+	}
+	return fmt.Sprint(value)
+}
+
+// String implements fmt.Stringer, rendering the summary as an indented tree
+// followed by the hashes.
+func (s *TypedDataSummary) String() string {
+	var b strings.Builder
+	b.WriteString(domainType + "\n")
+	writeNodes(&b, s.Domain, 1)
+	b.WriteString(s.PrimaryType + "\n")
+	writeNodes(&b, s.Message, 1)
+	fmt.Fprintf(&b, "Domain hash:  %s\n", s.DomainHash.Hex())
+	fmt.Fprintf(&b, "Message hash: %s\n", s.MessageHash.Hex())
+	return b.String()
+}
+
+// writeNodes renders nodes with their children, indented by depth.
+func writeNodes(b *strings.Builder, nodes []*TypedDataNode, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for _, node := range nodes {
+		if node.Children != nil {
+			fmt.Fprintf(b, "%s%s (%s)\n", indent, node.Name, node.Type)
+			writeNodes(b, node.Children, depth+1)
+		} else {
+			fmt.Fprintf(b, "%s%s (%s): %s\n", indent, node.Name, node.Type, node.Value)
+		}
+	}
+}
+
+// baseType returns the type of the elements of possibly nested array types.
+func baseType(typ string) string {
+	for {
+		elem := arraySuffix.ReplaceAllString(typ, "")
+		if elem == typ {
+			return typ
+		}
+		typ = elem
+	}
+}
+
+// sortedKeys returns the keys of a map, sorted.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
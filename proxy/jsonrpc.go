@@ -0,0 +1,147 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// JSON-RPC error codes returned by the proxy itself.
+const (
+	errcodeParse          = -32700
+	errcodeInvalidRequest = -32600
+	errcodeInvalidParams  = -32602
+	errcodeDefault        = -32000
+)
+
+// jsonrpcMessage is a JSON-RPC 2.0 request or response.
+type jsonrpcMessage struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *jsonError      `json:"error,omitempty"`
+
+	invalid error // Reason the message is not a valid request, if any
+}
+
+// jsonError is the error of a JSON-RPC response.
+type jsonError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// paramsError is returned for requests with malformed parameters.
+type paramsError struct{ msg string }
+
+func (e *paramsError) Error() string { return e.msg }
+
+// requestError is returned for messages that are not valid requests.
+type requestError struct{ msg string }
+
+func (e *requestError) Error() string { return e.msg }
+
+// parseMessages splits the request body into its messages, reporting whether it
+// was a batch. Batch entries that are not requests (e.g. null) are flagged as
+// invalid, to be answered with an error.
+func parseMessages(body []byte) ([]*jsonrpcMessage, bool, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var raws []json.RawMessage
+		if err := json.Unmarshal(body, &raws); err != nil {
+			return nil, true, err
+		}
+		msgs := make([]*jsonrpcMessage, len(raws))
+		for i, raw := range raws {
+			msg := new(jsonrpcMessage)
+			if err := json.Unmarshal(raw, msg); err != nil || raw[0] != '{' {
+				msg = &jsonrpcMessage{invalid: &requestError{"invalid request"}}
+			}
+			msgs[i] = msg.validate()
+		}
+		return msgs, true, nil
+	}
+	msg := new(jsonrpcMessage)
+	if err := json.Unmarshal(body, msg); err != nil {
+		return nil, false, err
+	}
+	if body[0] != '{' {
+		msg = &jsonrpcMessage{invalid: &requestError{"invalid request"}}
+	}
+	return []*jsonrpcMessage{msg.validate()}, false, nil
+}
+
+// validate flags the message invalid if it doesn't name a method, making sure
+// invalid messages are answered with a null ID if they lack one.
+func (msg *jsonrpcMessage) validate() *jsonrpcMessage {
+	if msg.invalid == nil && msg.Method == "" {
+		msg.invalid = &requestError{"invalid request: missing method"}
+	}
+	if msg.invalid != nil && len(msg.ID) == 0 {
+		msg.ID = json.RawMessage("null")
+	}
+	return msg
+}
+
+// response creates the response to a request, carrying either the result or the
+// error, keeping the code and data of errors returned upstream.
+func (msg *jsonrpcMessage) response(result interface{}, err error) *jsonrpcMessage {
+	res := &jsonrpcMessage{Version: "2.0", ID: msg.ID}
+	if err == nil {
+		blob, merr := json.Marshal(result)
+		if merr == nil {
+			res.Result = blob
+			return res
+		}
+		err = merr
+	}
+	res.Error = &jsonError{Code: errcodeDefault, Message: err.Error()}
+
+	var (
+		rpcErr   rpc.Error
+		dataErr  rpc.DataError
+		paramErr *paramsError
+		reqErr   *requestError
+	)
+	if errors.As(err, &rpcErr) {
+		res.Error.Code = rpcErr.ErrorCode()
+	}
+	if errors.As(err, &paramErr) {
+		res.Error.Code = errcodeInvalidParams
+	}
+	if errors.As(err, &reqErr) {
+		res.Error.Code = errcodeInvalidRequest
+	}
+	if errors.As(err, &dataErr) {
+		res.Error.Data = dataErr.ErrorData()
+	}
+	return res
+}
+
+// params decodes the positional parameters of the request into the given values,
+// allowing trailing optional ones to be omitted.
+func (msg *jsonrpcMessage) params(required int, values ...interface{}) error {
+	var raw []json.RawMessage
+	if len(msg.Params) > 0 {
+		if err := json.Unmarshal(msg.Params, &raw); err != nil {
+			return &paramsError{"non-array params"}
+		}
+	}
+	if len(raw) < required {
+		return &paramsError{"missing value for required argument"}
+	}
+	if len(raw) > len(values) {
+		return &paramsError{"too many arguments"}
+	}
+	for i, arg := range raw {
+		if err := json.Unmarshal(arg, values[i]); err != nil {
+			return &paramsError{fmt.Sprintf("invalid argument %d: %v", i, err)}
+		}
+	}
+	return nil
+}
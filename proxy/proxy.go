@@ -0,0 +1,364 @@
+// Package proxy implements a JSON-RPC proxy between dapps or scripts and an
+// upstream node, signing with Ledger accounts on their behalf. Reads are passed
+// through, while transaction sending and message signing are intercepted and
+// signed by the Ledger, transactions being forwarded upstream signed.
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+
+	ledger "github.com/evmos/ethereum-ledger-go"
+	"github.com/evmos/ethereum-ledger-go/accounts"
+	"github.com/evmos/ethereum-ledger-go/internal/resolver"
+)
+
+// maxRequestSize is the maximum size of request bodies accepted.
+const maxRequestSize = 5 * 1024 * 1024
+
+// acceptedContentTypes are the content types of requests served, like geth's.
+// Anything else (notably text/plain, which browsers send cross-origin without a
+// preflight) is rejected.
+var acceptedContentTypes = []string{"application/json", "application/json-rpc", "application/jsonrequest"}
+
+// ErrUnknownAccount is returned if a request names an account that none of the
+// Ledger wallets derives.
+var ErrUnknownAccount = resolver.ErrUnknownAccount
+
+// Proxy serves JSON-RPC requests over HTTP, forwarding them to the upstream node
+// except for the ones requiring a signature by a Ledger account:
+//
+//   - eth_accounts and eth_requestAccounts list the Ledger accounts
+//   - eth_sendTransaction fills in the missing fields from the upstream node,
+//     signs the transaction and forwards it as eth_sendRawTransaction
+//   - eth_sign and personal_sign sign EIP-191 personal messages
+//   - eth_signTypedData_v4 signs EIP-712 typed data
+type Proxy struct {
+	upstream *rpc.Client
+	client   *ethclient.Client
+	resolver *resolver.Resolver
+
+	origins map[string]bool // Origins browsers may send requests from, "*" for any
+	hosts   map[string]bool // Host names requests may be addressed to, "*" for any
+
+	fillers map[common.Address]*ledger.Filler // Fillers tracking the nonces of each account
+	lock    sync.Mutex
+}
+
+// New creates a proxy forwarding requests to the upstream node, signing with the
+// accounts derived at the given paths on the backend's wallets (m/44'/60'/0'/0/0
+// if none are given).
+func New(backend accounts.Backend, upstream *rpc.Client, paths []gethaccounts.DerivationPath) *Proxy {
+	return &Proxy{
+		upstream: upstream,
+		client:   ethclient.NewClient(upstream),
+		resolver: resolver.New(backend, paths),
+		origins:  make(map[string]bool),
+		hosts:    map[string]bool{"localhost": true},
+		fillers:  make(map[common.Address]*ledger.Filler),
+	}
+}
+
+// Dial creates a proxy forwarding requests to the node at the given RPC endpoint.
+func Dial(ctx context.Context, backend accounts.Backend, rawurl string, paths []gethaccounts.DerivationPath) (*Proxy, error) {
+	upstream, err := rpc.DialContext(ctx, rawurl)
+	if err != nil {
+		return nil, err
+	}
+	return New(backend, upstream, paths), nil
+}
+
+// SetAllowedOrigins sets the origins browsers may send requests from, "*" allowing
+// any. By default requests carrying an Origin header are rejected, so web pages
+// can't prompt the Ledger. It must be called before serving requests.
+func (p *Proxy) SetAllowedOrigins(origins []string) {
+	p.origins = make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		p.origins[strings.ToLower(origin)] = true
+	}
+}
+
+// SetAllowedHosts sets the host names requests may be addressed to, "*" allowing
+// any, guarding against DNS rebinding. Requests to IP addresses are always served,
+// names other than localhost are rejected by default. It must be called before
+// serving requests.
+func (p *Proxy) SetAllowedHosts(hosts []string) {
+	p.hosts = make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		p.hosts[strings.ToLower(host)] = true
+	}
+}
+
+// ServeHTTP implements http.Handler, serving single and batched JSON-RPC requests.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !p.hostAllowed(r.Host) {
+		http.Error(w, "invalid host specified", http.StatusForbidden)
+		return
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if !p.origins["*"] && !p.origins[strings.ToLower(origin)] {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", http.MethodPost)
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || !slices.Contains(acceptedContentTypes, mediaType) {
+		http.Error(w, "invalid content type, only application/json is supported", http.StatusUnsupportedMediaType)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	msgs, batch, err := parseMessages(body)
+	if err != nil {
+		json.NewEncoder(w).Encode(&jsonrpcMessage{
+			Version: "2.0", ID: json.RawMessage("null"),
+			Error: &jsonError{Code: errcodeParse, Message: err.Error()},
+		})
+		return
+	}
+	responses := make([]*jsonrpcMessage, len(msgs))
+	for i, msg := range msgs {
+		responses[i] = msg.response(p.handle(r.Context(), msg))
+	}
+	if batch {
+		json.NewEncoder(w).Encode(responses)
+	} else {
+		json.NewEncoder(w).Encode(responses[0])
+	}
+}
+
+// hostAllowed reports whether requests addressed to the host are served.
+func (p *Proxy) hostAllowed(host string) bool {
+	if host == "" {
+		return true
+	}
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	if net.ParseIP(strings.Trim(host, "[]")) != nil {
+		return true
+	}
+	return p.hosts["*"] || p.hosts[strings.ToLower(host)]
+}
+
+// handle serves a single request, either locally or by forwarding it upstream.
+func (p *Proxy) handle(ctx context.Context, msg *jsonrpcMessage) (interface{}, error) {
+	if msg.invalid != nil {
+		return nil, msg.invalid
+	}
+	switch msg.Method {
+	case "eth_accounts", "eth_requestAccounts":
+		addrs := make([]common.Address, 0)
+		for _, account := range p.resolver.Accounts() {
+			addrs = append(addrs, account.Address)
+		}
+		return addrs, nil
+
+	case "eth_sendTransaction":
+		var args TransactionArgs
+		if err := msg.params(1, &args); err != nil {
+			return nil, err
+		}
+		return p.sendTransaction(ctx, &args)
+
+	case "eth_sign":
+		var (
+			addr common.Address
+			data hexutil.Bytes
+		)
+		if err := msg.params(2, &addr, &data); err != nil {
+			return nil, err
+		}
+		return p.signText(addr, data)
+
+	case "personal_sign":
+		var (
+			data     hexutil.Bytes
+			addr     common.Address
+			password string // Ignored, the Ledger doesn't need any
+		)
+		if err := msg.params(2, &data, &addr, &password); err != nil {
+			return nil, err
+		}
+		return p.signText(addr, data)
+
+	case "eth_signTypedData_v4":
+		var (
+			addr common.Address
+			data json.RawMessage
+		)
+		if err := msg.params(2, &addr, &data); err != nil {
+			return nil, err
+		}
+		return p.signTypedData(addr, data)
+
+	default:
+		return p.forward(ctx, msg)
+	}
+}
+
+// forward passes the request through to the upstream node.
+func (p *Proxy) forward(ctx context.Context, msg *jsonrpcMessage) (interface{}, error) {
+	var args []interface{}
+	if len(msg.Params) > 0 {
+		var params []json.RawMessage
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &paramsError{"non-array params"}
+		}
+		for _, param := range params {
+			args = append(args, param)
+		}
+	}
+	var result json.RawMessage
+	if err := p.upstream.CallContext(ctx, &result, msg.Method, args...); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// sendTransaction completes the transaction from the upstream node, signs it and
+// forwards it, returning its hash.
+func (p *Proxy) sendTransaction(ctx context.Context, args *TransactionArgs) (common.Hash, error) {
+	if args.From == nil {
+		return common.Hash{}, &paramsError{"from address not set"}
+	}
+	filler, err := p.filler(*args.From)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	tx, err := filler.SignTx(ctx, args.builder())
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if err := p.client.SendTransaction(ctx, tx); err != nil {
+		// The nonce wasn't used after all, don't leave a gap behind
+		filler.ResetNonce()
+		return common.Hash{}, err
+	}
+	return tx.Hash(), nil
+}
+
+// signText signs the data as an EIP-191 personal message.
+func (p *Proxy) signText(addr common.Address, data []byte) (hexutil.Bytes, error) {
+	account, err := p.resolver.Find(addr)
+	if err != nil {
+		return nil, err
+	}
+	return account.Wallet.SignText(account.Account, data)
+}
+
+// signTypedData signs the EIP-712 typed data, given as a JSON object or as a
+// string holding one.
+func (p *Proxy) signTypedData(addr common.Address, data json.RawMessage) (hexutil.Bytes, error) {
+	var str string
+	if json.Unmarshal(data, &str) == nil {
+		data = json.RawMessage(str)
+	}
+	var typedData apitypes.TypedData
+	if err := json.Unmarshal(data, &typedData); err != nil {
+		return nil, &paramsError{"invalid typed data: " + err.Error()}
+	}
+	account, err := p.resolver.Find(addr)
+	if err != nil {
+		return nil, err
+	}
+	return account.Wallet.SignTypedData(account.Account, typedData)
+}
+
+// filler returns the transaction filler of the account, creating it on first use.
+func (p *Proxy) filler(addr common.Address) (*ledger.Filler, error) {
+	account, err := p.resolver.Find(addr)
+	if err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	filler, ok := p.fillers[addr]
+	if !ok {
+		filler = ledger.NewFiller(account.Wallet, account.Account, p.client)
+		p.fillers[addr] = filler
+	}
+	return filler, nil
+}
+
+// TransactionArgs are the arguments of eth_sendTransaction. Fields left unset
+// are filled in from the upstream node.
+type TransactionArgs struct {
+	From                 *common.Address       `json:"from"`
+	To                   *common.Address       `json:"to"`
+	Gas                  *hexutil.Uint64       `json:"gas"`
+	GasPrice             *hexutil.Big          `json:"gasPrice"`
+	MaxFeePerGas         *hexutil.Big          `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big          `json:"maxPriorityFeePerGas"`
+	Value                *hexutil.Big          `json:"value"`
+	Nonce                *hexutil.Uint64       `json:"nonce"`
+	Data                 *hexutil.Bytes        `json:"data"`
+	Input                *hexutil.Bytes        `json:"input"`
+	AccessList           *coretypes.AccessList `json:"accessList,omitempty"`
+	ChainID              *hexutil.Big          `json:"chainId,omitempty"`
+}
+
+// builder creates a transaction builder with the fields set in the arguments.
+func (args *TransactionArgs) builder() *ledger.TxBuilder {
+	b := ledger.NewTxBuilder(args.ChainID.ToInt())
+	if args.To != nil {
+		b.ToAddress(*args.To)
+	}
+	if args.Gas != nil {
+		b.Gas(uint64(*args.Gas))
+	}
+	if args.GasPrice != nil {
+		b.GasPrice(args.GasPrice.ToInt())
+	}
+	if args.MaxFeePerGas != nil || args.MaxPriorityFeePerGas != nil {
+		b.GasFees(args.MaxPriorityFeePerGas.ToInt(), args.MaxFeePerGas.ToInt())
+	}
+	if args.Value != nil {
+		b.Value(args.Value.ToInt())
+	}
+	if args.Nonce != nil {
+		b.Nonce(uint64(*args.Nonce))
+	}
+	if args.Input != nil {
+		b.Data(*args.Input)
+	} else if args.Data != nil {
+		b.Data(*args.Data)
+	}
+	if args.AccessList != nil {
+		b.AccessList(*args.AccessList)
+	}
+	return b
+}
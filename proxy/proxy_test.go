@@ -0,0 +1,290 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evmos/ethereum-ledger-go/internal/testutil"
+)
+
+const testTyped = `{
+	"types": {
+		"EIP712Domain": [{"name": "name", "type": "string"}, {"name": "chainId", "type": "uint256"}],
+		"Mail": [{"name": "contents", "type": "string"}]
+	},
+	"primaryType": "Mail",
+	"domain": {"name": "Ether Mail", "chainId": "1337"},
+	"message": {"contents": "Hello, Bob!"}
+}`
+
+var testRecipient = common.HexToAddress("0x3535353535353535353535353535353535353535")
+
+// startProxy starts a simulated chain funding the Ledger account as upstream
+// node, and a proxy in front of it, returning a client connected to the proxy.
+func startProxy(t *testing.T) (*rpc.Client, *simulated.Backend, string) {
+	t.Helper()
+
+	// Socket paths are length limited, keep it short
+	dir, err := os.MkdirTemp("", "proxy")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	ipc := filepath.Join(dir, "node.ipc")
+	backend := simulated.NewBackend(coretypes.GenesisAlloc{
+		testutil.Address: {Balance: new(big.Int).Mul(big.NewInt(100), big.NewInt(params.Ether))},
+	}, func(nodeConf *node.Config, ethConf *ethconfig.Config) {
+		nodeConf.IPCPath = ipc
+	})
+	t.Cleanup(func() { backend.Close() })
+
+	upstream, err := rpc.Dial(ipc)
+	require.NoError(t, err)
+	t.Cleanup(upstream.Close)
+
+	hub, _ := testutil.SimulatedHub()
+
+	server := httptest.NewServer(New(hub, upstream, []gethaccounts.DerivationPath{gethaccounts.DefaultBaseDerivationPath}))
+	t.Cleanup(server.Close)
+
+	client, err := rpc.DialHTTP(server.URL)
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+
+	return client, backend, server.URL
+}
+
+func TestProxyPassthrough(t *testing.T) {
+	client, _, url := startProxy(t)
+	eth := ethclient.NewClient(client)
+
+	chainID, err := eth.ChainID(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(1337), chainID)
+
+	balance, err := eth.BalanceAt(context.Background(), testutil.Address, nil)
+	require.NoError(t, err)
+	require.Equal(t, new(big.Int).Mul(big.NewInt(100), big.NewInt(params.Ether)), balance)
+
+	// Upstream errors keep their codes
+	err = client.Call(nil, "eth_noSuchMethod")
+	var rpcErr rpc.Error
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, -32601, rpcErr.ErrorCode())
+
+	// Batches mix local and forwarded requests
+	res, err := http.Post(url, "application/json", bytes.NewReader([]byte(`[
+		{"jsonrpc": "2.0", "id": 1, "method": "eth_accounts"},
+		{"jsonrpc": "2.0", "id": 2, "method": "eth_chainId"}
+	]`)))
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	var batch []struct {
+		ID     int             `json:"id"`
+		Result json.RawMessage `json:"result"`
+	}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&batch))
+	require.Len(t, batch, 2)
+	require.JSONEq(t, `["`+hexutil.Encode(testutil.Address.Bytes())+`"]`, string(batch[0].Result))
+	require.JSONEq(t, `"0x539"`, string(batch[1].Result))
+}
+
+func TestProxySendTransaction(t *testing.T) {
+	client, backend, _ := startProxy(t)
+	eth := ethclient.NewClient(client)
+
+	var addrs []common.Address
+	require.NoError(t, client.Call(&addrs, "eth_accounts"))
+	require.Equal(t, []common.Address{testutil.Address}, addrs)
+
+	// Unmodified tooling sends partial transactions, completed from upstream
+	var hashes []common.Hash
+	for i := 0; i < 2; i++ {
+		var hash common.Hash
+		require.NoError(t, client.Call(&hash, "eth_sendTransaction", map[string]interface{}{
+			"from":  testutil.Address,
+			"to":    testRecipient,
+			"value": hexutil.EncodeBig(big.NewInt(params.GWei)),
+		}))
+		hashes = append(hashes, hash)
+	}
+	backend.Commit()
+
+	for i, hash := range hashes {
+		receipt, err := eth.TransactionReceipt(context.Background(), hash)
+		require.NoError(t, err)
+		require.Equal(t, coretypes.ReceiptStatusSuccessful, receipt.Status)
+
+		tx, _, err := eth.TransactionByHash(context.Background(), hash)
+		require.NoError(t, err)
+		require.Equal(t, uint64(i), tx.Nonce())
+	}
+	balance, err := eth.BalanceAt(context.Background(), testRecipient, nil)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(2*params.GWei), balance)
+
+	// Transactions from unknown accounts are refused
+	err = client.Call(nil, "eth_sendTransaction", map[string]interface{}{"from": testRecipient, "to": testRecipient})
+	require.ErrorContains(t, err, ErrUnknownAccount.Error())
+}
+
+func TestProxySign(t *testing.T) {
+	client, _, _ := startProxy(t)
+	msg := []byte("hello ledger")
+
+	// eth_sign and personal_sign take their parameters in reverse order
+	var sig, personal hexutil.Bytes
+	require.NoError(t, client.Call(&sig, "eth_sign", testutil.Address, hexutil.Bytes(msg)))
+	require.NoError(t, client.Call(&personal, "personal_sign", hexutil.Bytes(msg), testutil.Address))
+	require.Equal(t, sig, personal)
+
+	sig[64] -= 27
+	pub, err := crypto.SigToPub(gethaccounts.TextHash(msg), sig)
+	require.NoError(t, err)
+	require.Equal(t, testutil.Address, crypto.PubkeyToAddress(*pub))
+
+	err = client.Call(&sig, "eth_sign", testutil.Address)
+	var rpcErr rpc.Error
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, -32602, rpcErr.ErrorCode())
+}
+
+func TestProxySignTypedData(t *testing.T) {
+	client, _, _ := startProxy(t)
+
+	var typedData apitypes.TypedData
+	require.NoError(t, json.Unmarshal([]byte(testTyped), &typedData))
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	require.NoError(t, err)
+
+	// Wallets send the typed data as a JSON string, some tooling as an object
+	for _, param := range []interface{}{testTyped, json.RawMessage(testTyped)} {
+		var sig hexutil.Bytes
+		require.NoError(t, client.Call(&sig, "eth_signTypedData_v4", testutil.Address, param))
+
+		sig[64] -= 27
+		pub, err := crypto.SigToPub(hash, sig)
+		require.NoError(t, err)
+		require.Equal(t, testutil.Address, crypto.PubkeyToAddress(*pub))
+	}
+}
+
+func TestProxyRejectsBrowsers(t *testing.T) {
+	_, _, url := startProxy(t)
+
+	post := func(contentType string, header http.Header, host string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(`{"jsonrpc": "2.0", "id": 1, "method": "eth_accounts"}`))
+		require.NoError(t, err)
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		req.Header.Set("Content-Type", contentType)
+		if host != "" {
+			req.Host = host
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+		return res
+	}
+	// Content types browsers send without a preflight are refused
+	require.Equal(t, http.StatusUnsupportedMediaType, post("text/plain", nil, "").StatusCode)
+	require.Equal(t, http.StatusUnsupportedMediaType, post("application/x-www-form-urlencoded", nil, "").StatusCode)
+	require.Equal(t, http.StatusOK, post("application/json; charset=utf-8", nil, "").StatusCode)
+
+	// Cross-origin requests are refused unless allowed
+	origin := http.Header{"Origin": {"https://evil.example"}}
+	require.Equal(t, http.StatusForbidden, post("application/json", origin, "").StatusCode)
+
+	// Host names other than localhost are refused, guarding against DNS rebinding
+	require.Equal(t, http.StatusForbidden, post("application/json", nil, "evil.example:8555").StatusCode)
+	require.Equal(t, http.StatusOK, post("application/json", nil, "localhost:8555").StatusCode)
+}
+
+func TestProxyAllowedOrigins(t *testing.T) {
+	p := New(nil, nil, nil)
+	p.SetAllowedOrigins([]string{"https://app.example"})
+	p.SetAllowedHosts([]string{"signer.example"})
+
+	// Preflights of allowed origins are answered with the CORS headers
+	req := httptest.NewRequest(http.MethodOptions, "http://signer.example/", nil)
+	req.Header.Set("Origin", "https://app.example")
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.Equal(t, "https://app.example", rec.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "Content-Type", rec.Header().Get("Access-Control-Allow-Headers"))
+
+	req = httptest.NewRequest(http.MethodOptions, "http://signer.example/", nil)
+	req.Header.Set("Origin", "https://other.example")
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+	req = httptest.NewRequest(http.MethodOptions, "http://localhost/", nil)
+	req.Header.Set("Origin", "https://app.example")
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestProxyInvalidBatch(t *testing.T) {
+	_, _, url := startProxy(t)
+
+	res, err := http.Post(url, "application/json", strings.NewReader(`[null, 1, {"jsonrpc": "2.0", "id": 7}, {"jsonrpc": "2.0", "id": 8, "method": "eth_chainId"}]`))
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	var batch []struct {
+		ID     json.RawMessage `json:"id"`
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code int `json:"code"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&batch))
+	require.Len(t, batch, 4)
+	for i, want := range []string{"null", "null", "7"} {
+		require.JSONEq(t, want, string(batch[i].ID), "entry %d", i)
+		require.NotNil(t, batch[i].Error, "entry %d", i)
+		require.Equal(t, -32600, batch[i].Error.Code, "entry %d", i)
+	}
+	require.Nil(t, batch[3].Error)
+	require.JSONEq(t, `"0x539"`, string(batch[3].Result))
+
+	// A lone null is no request either
+	res, err = http.Post(url, "application/json", strings.NewReader(`null`))
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	var single struct {
+		Error *struct {
+			Code int `json:"code"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&single))
+	require.NotNil(t, single.Error)
+	require.Equal(t, -32600, single.Error.Code)
+}
@@ -0,0 +1,329 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// SenderClient is the RPC access a Sender needs to broadcast transactions and
+// track them until confirmed. It is satisfied by *ethclient.Client and
+// go-ethereum's simulated backend client.
+type SenderClient interface {
+	ChainReader
+
+	// SendTransaction injects the signed transaction into the pending pool.
+	SendTransaction(ctx context.Context, tx *coretypes.Transaction) error
+
+	// TransactionReceipt retrieves the receipt of a mined transaction, failing
+	// with ethereum.NotFound if it's not (yet) included.
+	TransactionReceipt(ctx context.Context, hash common.Hash) (*coretypes.Receipt, error)
+
+	// NonceAt retrieves the account nonce at the given block, the latest if nil.
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+
+	// BlockNumber retrieves the number of the latest block.
+	BlockNumber(ctx context.Context) (uint64, error)
+}
+
+// TxEventKind is the lifecycle stage a transaction event reports.
+type TxEventKind int
+
+const (
+	// TxSigned is fired when the Ledger signed a transaction (or a replacement).
+	TxSigned TxEventKind = iota
+
+	// TxBroadcast is fired when a signed transaction was accepted by the node.
+	TxBroadcast
+
+	// TxMined is fired when a transaction was included with enough confirmations.
+	TxMined
+
+	// TxReplaced is fired when a stuck transaction was replaced by a fee bumped one
+	// accepted by the node, right before its TxBroadcast.
+	TxReplaced
+
+	// TxDropped is fired when the nonce was consumed by a transaction not sent
+	// through the Sender, so none of the sent ones can be mined anymore.
+	TxDropped
+)
+
+// String implements fmt.Stringer.
+func (k TxEventKind) String() string {
+	switch k {
+	case TxSigned:
+		return "signed"
+	case TxBroadcast:
+		return "broadcast"
+	case TxMined:
+		return "mined"
+	case TxReplaced:
+		return "replaced"
+	case TxDropped:
+		return "dropped"
+	default:
+		return "unknown"
+	}
+}
+
+// TxEvent is a lifecycle event of a transaction sent through a Sender.
+type TxEvent struct {
+	Kind     TxEventKind
+	Tx       *coretypes.Transaction // Transaction the event is about
+	Replaced *coretypes.Transaction // Transaction replaced by Tx, for TxReplaced
+	Receipt  *coretypes.Receipt     // Receipt of Tx, for TxMined
+}
+
+// ErrTxDropped is returned if the nonce of a sent transaction was consumed by
+// another one, not sent through the Sender.
+var ErrTxDropped = errors.New("transaction dropped")
+
+// SenderConfig tunes how a Sender tracks and replaces transactions.
+type SenderConfig struct {
+	Confirmations   uint64        // Blocks to wait for on top of the inclusion, 1 meaning included
+	PollInterval    time.Duration // Time between checks for the transaction's receipt
+	StuckTimeout    time.Duration // Time after which an unmined transaction is replaced
+	FeeBump         int64         // Percentage the fees of replacements are raised by (at least 10)
+	MaxReplacements int           // Replacements to attempt before just waiting, negative for none
+}
+
+// DefaultSenderConfig is the configuration used by senders unless overridden.
+var DefaultSenderConfig = SenderConfig{
+	Confirmations:   1,
+	PollInterval:    time.Second,
+	StuckTimeout:    time.Minute,
+	FeeBump:         10,
+	MaxReplacements: 3,
+}
+
+// Sender signs transactions with the Ledger wallet, broadcasts them and waits
+// until they are mined, replacing stuck ones with fee bumped transactions of the
+// same nonce (prompting the device again).
+type Sender struct {
+	filler *Filler
+	client SenderClient
+	config SenderConfig
+
+	feed  event.Feed
+	scope event.SubscriptionScope
+	lock  sync.Mutex // Serializes sending, so nonces are used in order
+}
+
+// NewSender creates a sender completing transactions with the filler, and
+// broadcasting them through the client. Zero fields of the config are set to
+// their defaults.
+func NewSender(filler *Filler, client SenderClient, config SenderConfig) *Sender {
+	if config.Confirmations == 0 {
+		config.Confirmations = DefaultSenderConfig.Confirmations
+	}
+	if config.PollInterval == 0 {
+		config.PollInterval = DefaultSenderConfig.PollInterval
+	}
+	if config.StuckTimeout == 0 {
+		config.StuckTimeout = DefaultSenderConfig.StuckTimeout
+	}
+	if config.MaxReplacements == 0 {
+		config.MaxReplacements = DefaultSenderConfig.MaxReplacements
+	}
+	if config.FeeBump < DefaultSenderConfig.FeeBump {
+		config.FeeBump = DefaultSenderConfig.FeeBump
+	}
+	return &Sender{
+		filler: filler,
+		client: client,
+		config: config,
+	}
+}
+
+// Subscribe creates an async subscription to receive the lifecycle events of the
+// transactions sent.
+func (s *Sender) Subscribe(sink chan<- TxEvent) event.Subscription {
+	return s.scope.Track(s.feed.Subscribe(sink))
+}
+
+// Close unsubscribes all event subscriptions.
+func (s *Sender) Close() {
+	s.scope.Close()
+}
+
+// Send completes, signs and broadcasts the transaction, then waits until it (or
+// a replacement) is mined with the configured confirmations, returning the
+// receipt of the mined transaction.
+func (s *Sender) Send(ctx context.Context, b *TxBuilder) (*coretypes.Receipt, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	tx, err := s.filler.SignTx(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+	s.feed.Send(TxEvent{Kind: TxSigned, Tx: tx})
+
+	if tx, err = s.broadcast(ctx, tx, nil); err != nil {
+		return nil, err
+	}
+	var (
+		sent     = []*coretypes.Transaction{tx} // All transactions sent with the nonce
+		bumps    int
+		deadline = time.Now().Add(s.config.StuckTimeout)
+	)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(s.config.PollInterval):
+		}
+		// Check whether any of the sent transactions got mined
+		receipt, mined := s.receipt(ctx, sent)
+		if receipt != nil {
+			head, err := s.client.BlockNumber(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if head+1 >= receipt.BlockNumber.Uint64()+s.config.Confirmations {
+				s.feed.Send(TxEvent{Kind: TxMined, Tx: mined, Receipt: receipt})
+				return receipt, nil
+			}
+			continue
+		}
+		// None mined, check whether the nonce was used by something else
+		nonce, err := s.client.NonceAt(ctx, s.filler.account.Address, nil)
+		if err != nil {
+			return nil, err
+		}
+		if nonce > tx.Nonce() {
+			// Receipts may lag behind the state, make sure it's really gone
+			if receipt, _ := s.receipt(ctx, sent); receipt == nil {
+				s.feed.Send(TxEvent{Kind: TxDropped, Tx: tx})
+				return nil, ErrTxDropped
+			}
+			continue
+		}
+		// Still pending, replace it with a fee bumped one if stuck for too long
+		if time.Now().Before(deadline) || bumps >= s.config.MaxReplacements {
+			continue
+		}
+		replacement, err := s.replace(ctx, tx)
+		if err != nil {
+			// The pending transaction may still be mined (e.g. the user rejected the
+			// bump on the device), keep waiting for it without bumping again
+			bumps = s.config.MaxReplacements
+			continue
+		}
+		// The original may have been mined while the user was confirming the
+		// replacement on the device, so don't fail on a rejection before checking
+		broadcast, err := s.broadcast(ctx, replacement, tx)
+		switch {
+		case err == nil:
+		case strings.Contains(err.Error(), "already known"):
+			broadcast = replacement // Already pooled, track it like a broadcast one
+			s.feed.Send(TxEvent{Kind: TxReplaced, Tx: replacement, Replaced: tx})
+		case strings.Contains(err.Error(), "nonce too low"):
+			continue // Nonce used up, the receipt and nonce checks sort it out
+		default:
+			if receipt, _ := s.receipt(ctx, sent); receipt == nil {
+				return nil, err
+			}
+			continue
+		}
+		tx, sent = broadcast, append(sent, broadcast)
+		bumps++
+		deadline = time.Now().Add(s.config.StuckTimeout)
+	}
+}
+
+// broadcast sends the transaction to the node, bumping its fees (and signing it
+// again) for as long as the node rejects it as underpriced. If the transaction
+// replaces another one, or gets bumped, the replacement is only reported once
+// the node accepted it.
+func (s *Sender) broadcast(ctx context.Context, tx *coretypes.Transaction, replaced *coretypes.Transaction) (*coretypes.Transaction, error) {
+	for bumps := 0; ; bumps++ {
+		err := s.client.SendTransaction(ctx, tx)
+		if err == nil {
+			if replaced != nil {
+				s.feed.Send(TxEvent{Kind: TxReplaced, Tx: tx, Replaced: replaced})
+			}
+			s.feed.Send(TxEvent{Kind: TxBroadcast, Tx: tx})
+			return tx, nil
+		}
+		if !strings.Contains(err.Error(), "underpriced") || bumps >= s.config.MaxReplacements {
+			return nil, err
+		}
+		replacement, err := s.replace(ctx, tx)
+		if err != nil {
+			return nil, err
+		}
+		if replaced == nil {
+			replaced = tx
+		}
+		tx = replacement
+	}
+}
+
+// receipt looks up the receipt of any of the sent transactions, returning the one
+// mined along with it (or nils if none are mined).
+//
+// Like bind.WaitMined, lookup failures are treated as the transaction not being
+// mined yet, as nodes also fail while (re)indexing transactions.
+func (s *Sender) receipt(ctx context.Context, sent []*coretypes.Transaction) (*coretypes.Receipt, *coretypes.Transaction) {
+	for _, tx := range sent {
+		if receipt, err := s.client.TransactionReceipt(ctx, tx.Hash()); err == nil {
+			return receipt, tx
+		}
+	}
+	return nil, nil
+}
+
+// replace signs a replacement of the transaction with the same nonce and fees
+// raised by the configured percentage, or to the current suggestions if higher.
+func (s *Sender) replace(ctx context.Context, tx *coretypes.Transaction) (*coretypes.Transaction, error) {
+	b := txBuilderFrom(tx)
+
+	if tx.Type() <= coretypes.AccessListTxType {
+		price, err := s.client.SuggestGasPrice(ctx)
+		if err != nil {
+			return nil, err
+		}
+		b.GasPrice(bigMax(s.bump(tx.GasPrice()), price))
+	} else {
+		tip, err := s.client.SuggestGasTipCap(ctx)
+		if err != nil {
+			return nil, err
+		}
+		head, err := s.client.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		tip = bigMax(s.bump(tx.GasTipCap()), tip)
+
+		feeCap := new(big.Int).Mul(head.BaseFee, big.NewInt(2))
+		b.GasFees(tip, bigMax(s.bump(tx.GasFeeCap()), feeCap.Add(feeCap, tip)))
+	}
+	// Blob pools demand the blob fee cap to double
+	if tx.Type() == coretypes.BlobTxType {
+		b.blobFeeCap = new(big.Int).Mul(tx.BlobGasFeeCap(), big.NewInt(2))
+	}
+	return s.filler.SignTx(ctx, b)
+}
+
+// bump raises the fee by the configured percentage, rounding up.
+func (s *Sender) bump(fee *big.Int) *big.Int {
+	bumped := new(big.Int).Mul(fee, big.NewInt(100+s.config.FeeBump))
+	bumped.Add(bumped, big.NewInt(99))
+	return bumped.Div(bumped, big.NewInt(100))
+}
+
+// bigMax returns the larger of the two numbers.
+func bigMax(a, b *big.Int) *big.Int {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,228 @@
+package ledger
+
+import (
+	"context"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evmos/ethereum-ledger-go/accounts"
+	"github.com/evmos/ethereum-ledger-go/usbwallet"
+)
+
+// sendResult is the outcome of a Sender.Send running in the background.
+type sendResult struct {
+	receipt *coretypes.Receipt
+	err     error
+}
+
+// startSend subscribes to the events of the sender, then sends the transaction
+// in the background.
+func startSend(t *testing.T, sender *Sender, b *TxBuilder) (chan TxEvent, chan sendResult) {
+	t.Helper()
+
+	events := make(chan TxEvent, 16)
+	sub := sender.Subscribe(events)
+	t.Cleanup(sub.Unsubscribe)
+
+	result := make(chan sendResult, 1)
+	go func() {
+		receipt, err := sender.Send(context.Background(), b)
+		result <- sendResult{receipt, err}
+	}()
+	return events, result
+}
+
+// waitEvent waits for the next event of the sender, failing if it's not the
+// expected kind.
+func waitEvent(t *testing.T, events chan TxEvent, kind TxEventKind) TxEvent {
+	t.Helper()
+
+	select {
+	case ev := <-events:
+		require.Equal(t, kind, ev.Kind, "event %v instead of %v", ev.Kind, kind)
+		return ev
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timeout waiting for %v event", kind)
+		return TxEvent{}
+	}
+}
+
+// newTestSender creates a sender for the simulated Ledger and chain.
+func newTestSender(t *testing.T, config SenderConfig) (*Sender, *simulated.Backend) {
+	t.Helper()
+
+	wallet, account, backend := newSimulatedLedger(t)
+	client := backend.Client()
+
+	sender := NewSender(NewFiller(wallet, account, client), client, config)
+	t.Cleanup(sender.Close)
+
+	return sender, backend
+}
+
+func TestSenderSend(t *testing.T) {
+	sender, backend := newTestSender(t, SenderConfig{Confirmations: 2, PollInterval: 10 * time.Millisecond})
+	events, result := startSend(t, sender, NewTxBuilder(nil).To(testRecipient).Value(big.NewInt(params.GWei)))
+
+	signed := waitEvent(t, events, TxSigned)
+	sent := waitEvent(t, events, TxBroadcast)
+	require.Equal(t, signed.Tx.Hash(), sent.Tx.Hash())
+
+	// Inclusion alone isn't enough, wait for a confirmation on top
+	backend.Commit()
+	select {
+	case res := <-result:
+		t.Fatalf("returned before confirmation: %v", res.err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	backend.Commit()
+
+	mined := waitEvent(t, events, TxMined)
+	require.Equal(t, sent.Tx.Hash(), mined.Tx.Hash())
+
+	res := <-result
+	require.NoError(t, res.err)
+	require.Equal(t, sent.Tx.Hash(), res.receipt.TxHash)
+	require.Equal(t, coretypes.ReceiptStatusSuccessful, res.receipt.Status)
+}
+
+func TestSenderReplace(t *testing.T) {
+	sender, backend := newTestSender(t, SenderConfig{PollInterval: 10 * time.Millisecond, StuckTimeout: 50 * time.Millisecond})
+	events, result := startSend(t, sender, NewTxBuilder(nil).To(testRecipient))
+
+	waitEvent(t, events, TxSigned)
+	stuck := waitEvent(t, events, TxBroadcast).Tx
+
+	// Without a new block the transaction gets replaced with higher fees
+	replaced := waitEvent(t, events, TxReplaced)
+	require.Equal(t, stuck.Hash(), replaced.Replaced.Hash())
+	require.Equal(t, stuck.Nonce(), replaced.Tx.Nonce())
+	require.GreaterOrEqual(t, replaced.Tx.GasTipCap().Cmp(new(big.Int).Div(new(big.Int).Mul(stuck.GasTipCap(), big.NewInt(110)), big.NewInt(100))), 0)
+	require.GreaterOrEqual(t, replaced.Tx.GasFeeCap().Cmp(new(big.Int).Div(new(big.Int).Mul(stuck.GasFeeCap(), big.NewInt(110)), big.NewInt(100))), 0)
+
+	sent := waitEvent(t, events, TxBroadcast)
+	require.Equal(t, replaced.Tx.Hash(), sent.Tx.Hash())
+
+	backend.Commit()
+	mined := waitEvent(t, events, TxMined)
+	require.Equal(t, sent.Tx.Hash(), mined.Tx.Hash())
+
+	res := <-result
+	require.NoError(t, res.err)
+	require.Equal(t, sent.Tx.Hash(), res.receipt.TxHash)
+}
+
+func TestSenderDropped(t *testing.T) {
+	sender, backend := newTestSender(t, SenderConfig{PollInterval: 10 * time.Millisecond, StuckTimeout: time.Hour})
+	events, result := startSend(t, sender, NewTxBuilder(nil).To(testRecipient))
+
+	waitEvent(t, events, TxSigned)
+	sent := waitEvent(t, events, TxBroadcast).Tx
+
+	// Replace the transaction behind the sender's back
+	b := txBuilderFrom(sent).GasFees(new(big.Int).Mul(sent.GasTipCap(), big.NewInt(2)), new(big.Int).Mul(sent.GasFeeCap(), big.NewInt(2)))
+	raw, err := b.Sign(sender.filler.wallet, sender.filler.account)
+	require.NoError(t, err)
+
+	other := new(coretypes.Transaction)
+	require.NoError(t, other.UnmarshalBinary(raw))
+	require.NoError(t, backend.Client().SendTransaction(context.Background(), other))
+	backend.Commit()
+
+	dropped := waitEvent(t, events, TxDropped)
+	require.Equal(t, sent.Hash(), dropped.Tx.Hash())
+
+	res := <-result
+	require.ErrorIs(t, res.err, ErrTxDropped)
+}
+
+// minedClient mines a block instead of broadcasting replacements, as if the
+// original transaction got included while the user was confirming on the device.
+type minedClient struct {
+	SenderClient
+	backend *simulated.Backend
+	sends   int
+}
+
+func (c *minedClient) SendTransaction(ctx context.Context, tx *coretypes.Transaction) error {
+	if c.sends++; c.sends > 1 {
+		c.backend.Commit()
+		return core.ErrNonceTooLow
+	}
+	return c.SenderClient.SendTransaction(ctx, tx)
+}
+
+func TestSenderReplaceMined(t *testing.T) {
+	wallet, account, backend := newSimulatedLedger(t)
+	client := &minedClient{SenderClient: backend.Client(), backend: backend}
+
+	sender := NewSender(NewFiller(wallet, account, backend.Client()), client, SenderConfig{PollInterval: 10 * time.Millisecond, StuckTimeout: 50 * time.Millisecond})
+	t.Cleanup(sender.Close)
+
+	events, result := startSend(t, sender, NewTxBuilder(nil).To(testRecipient))
+
+	waitEvent(t, events, TxSigned)
+	original := waitEvent(t, events, TxBroadcast).Tx
+
+	// The replacement is refused with the nonce used up, so it's never reported,
+	// only the original being mined
+	mined := waitEvent(t, events, TxMined)
+	require.Equal(t, original.Hash(), mined.Tx.Hash())
+
+	res := <-result
+	require.NoError(t, res.err)
+	require.Equal(t, original.Hash(), res.receipt.TxHash)
+}
+
+// rejectingWallet refuses to sign anything but the first transaction, as if the
+// user rejected the fee bumps on the device.
+type rejectingWallet struct {
+	accounts.Wallet
+	signs atomic.Int32
+}
+
+func (w *rejectingWallet) SignTx(account accounts.Account, tx *coretypes.Transaction, chainID *big.Int) ([]byte, error) {
+	if w.signs.Add(1) > 1 {
+		return nil, usbwallet.ErrLedgerUserRejected
+	}
+	return w.Wallet.SignTx(account, tx, chainID)
+}
+
+func TestSenderReplaceRejected(t *testing.T) {
+	wallet, account, backend := newSimulatedLedger(t)
+	rejecting := &rejectingWallet{Wallet: wallet}
+	client := backend.Client()
+
+	sender := NewSender(NewFiller(rejecting, account, client), client, SenderConfig{PollInterval: 10 * time.Millisecond, StuckTimeout: 50 * time.Millisecond})
+	t.Cleanup(sender.Close)
+
+	events, result := startSend(t, sender, NewTxBuilder(nil).To(testRecipient))
+
+	waitEvent(t, events, TxSigned)
+	original := waitEvent(t, events, TxBroadcast).Tx
+
+	// The rejected bump doesn't abort tracking the pending original
+	require.Eventually(t, func() bool { return rejecting.signs.Load() > 1 }, 10*time.Second, 10*time.Millisecond)
+	select {
+	case res := <-result:
+		t.Fatalf("returned before the original was mined: %v", res.err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	require.Equal(t, int32(2), rejecting.signs.Load())
+
+	backend.Commit()
+	mined := waitEvent(t, events, TxMined)
+	require.Equal(t, original.Hash(), mined.Tx.Hash())
+
+	res := <-result
+	require.NoError(t, res.err)
+	require.Equal(t, original.Hash(), res.receipt.TxHash)
+}
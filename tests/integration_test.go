@@ -2,7 +2,6 @@ package ledger_test
 
 import (
 	"encoding/hex"
-	"fmt"
 	"math/big"
 	"strings"
 	"testing"
@@ -10,7 +9,6 @@ import (
 	gethaccounts "github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
-	coretypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/signer/core/apitypes"
 
@@ -105,11 +103,11 @@ func createTypedDataPayload(message map[string]interface{}) apitypes.TypedData {
 	}
 }
 
-// Test transaction is generated correctly using CreateTx
+// Test transaction is generated correctly using ledger.CreateTx
 func TestSanityCreateTx(t *testing.T) {
 	addr := "0x3535353535353535353535353535353535353535"
 
-	tx, err := CreateTx(
+	tx, err := ledger.CreateTx(
 		3,               // Nonce
 		addr,            // To
 		10,              // Gas
@@ -149,7 +147,7 @@ func TestInvalidAccount(t *testing.T) {
 	account.Address = common.HexToAddress("0x3535353535353535353535353535353535353535")
 
 	sendAddr := "0x3636363636363636363636363636363636363636"
-	tx, err := CreateTx(
+	tx, err := ledger.CreateTx(
 		3, sendAddr, 10, big.NewInt(10), big.NewInt(10), make([]byte, 0),
 	)
 	require.NoError(t, err)
@@ -178,7 +176,7 @@ func TestLedgerSignTx1(t *testing.T) {
 
 	addr := "0x3535353535353535353535353535353535353535"
 
-	tx, err := CreateTx(
+	tx, err := ledger.CreateTx(
 		3, addr, 10, big.NewInt(10), big.NewInt(10), make([]byte, 0),
 	)
 	require.NoError(t, err)
@@ -198,7 +196,7 @@ func TestLedgerSignTx2(t *testing.T) {
 
 	addr := "0x4646464646464646464646464646464646464646"
 
-	tx, err := CreateTx(
+	tx, err := ledger.CreateTx(
 		8, addr, 50, big.NewInt(5), big.NewInt(70), []byte{4, 6, 8, 10},
 	)
 	require.NoError(t, err)
@@ -219,7 +217,7 @@ func TestLedgerSignTx3(t *testing.T) {
 
 	addr := "0x4646464646464646464646464646464646464646"
 
-	tx, err := CreateTx(
+	tx, err := ledger.CreateTx(
 		8, addr, 50, big.NewInt(5), big.NewInt(70), []byte{4, 6, 8, 10},
 	)
 	require.NoError(t, err)
@@ -244,7 +242,7 @@ func TestLedgerSignTx4(t *testing.T) {
 	defer wallet.Close()
 
 	sendAddr := "0x4646464646464646464646464646464646464646"
-	tx, err := CreateTx(
+	tx, err := ledger.CreateTx(
 		8, sendAddr, 50, big.NewInt(5), big.NewInt(70), []byte{4, 6, 8, 10},
 	)
 	require.NoError(t, err)
@@ -341,27 +339,3 @@ func TestLedgerSignTyped3(t *testing.T) {
 
 	require.Equal(t, sigHex, "76984ce659f841975bdab7762ed9cb3c936791d1dcded3c0554147fca7accfdc543313669dcda04350990884e9e10c382fb20b722c123409a97c42ef6df617ca1c")
 }
-
-func CreateTx(
-	nonce uint64,
-	to string,
-	gas uint64,
-	gasPrice *big.Int,
-	amount *big.Int,
-	data []byte,
-) (*coretypes.Transaction, error) {
-	if !common.IsHexAddress(to) {
-		return nil, fmt.Errorf("invalid 'to' address: %s", to)
-	}
-
-	toAddr := common.HexToAddress(to)
-
-	return coretypes.NewTransaction(
-		nonce,
-		toAddr,
-		amount,
-		gas,
-		gasPrice,
-		data,
-	), nil
-}
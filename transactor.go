@@ -0,0 +1,55 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/v2"
+	"github.com/ethereum/go-ethereum/common"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/evmos/ethereum-ledger-go/accounts"
+)
+
+var (
+	// ErrNoChainID is returned if a transactor is requested without a chain ID.
+	ErrNoChainID = errors.New("no chain id specified")
+
+	// errSignedTxMismatch is returned if the transaction signed by the wallet is
+	// not the one handed over by the contract bindings.
+	errSignedTxMismatch = errors.New("signed transaction mismatch")
+)
+
+// NewLedgerTransactor is a utility method to easily create a transaction signer
+// for contract bindings, routing the signing through the Ledger wallet. Both legacy
+// and dynamic fee transactions are supported, depending on the fields the bindings
+// are configured with.
+func NewLedgerTransactor(wallet accounts.Wallet, account accounts.Account, chainID *big.Int) (*bind.TransactOpts, error) {
+	if chainID == nil {
+		return nil, ErrNoChainID
+	}
+	signer := coretypes.LatestSignerForChainID(chainID)
+	return &bind.TransactOpts{
+		From: account.Address,
+		Signer: func(address common.Address, tx *coretypes.Transaction) (*coretypes.Transaction, error) {
+			if address != account.Address {
+				return nil, bind.ErrNotAuthorized
+			}
+			raw, err := wallet.SignTx(account, tx, chainID)
+			if err != nil {
+				return nil, err
+			}
+			signed := new(coretypes.Transaction)
+			if err := signed.UnmarshalBinary(raw); err != nil {
+				return nil, err
+			}
+			// Make sure the wallet signed exactly what was requested
+			if signer.Hash(signed) != signer.Hash(tx) {
+				return nil, errSignedTxMismatch
+			}
+			return signed, nil
+		},
+		Context: context.Background(),
+	}, nil
+}
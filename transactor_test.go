@@ -0,0 +1,99 @@
+package ledger
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/v2"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evmos/ethereum-ledger-go/accounts"
+	"github.com/evmos/ethereum-ledger-go/internal/testutil"
+)
+
+// storeABI and storeCode describe a contract storing the argument of set(uint256)
+// in its first storage slot.
+const storeABI = `[{"type":"function","name":"set","inputs":[{"name":"value","type":"uint256"}],"outputs":[],"stateMutability":"nonpayable"}]`
+
+var storeCode = hexutil.MustDecode("0x6007600c60003960076000f3" + "60043560005500")
+
+// newSimulatedLedger opens a simulated Ledger, pinning its first account, and
+// starts a simulated chain funding it.
+func newSimulatedLedger(t *testing.T) (accounts.Wallet, accounts.Account, *simulated.Backend) {
+	t.Helper()
+
+	wallet, account, _ := testutil.SimulatedWallet(t)
+	backend := simulated.NewBackend(coretypes.GenesisAlloc{
+		account.Address: {Balance: new(big.Int).Mul(big.NewInt(100), big.NewInt(params.Ether))},
+	})
+	t.Cleanup(func() { backend.Close() })
+
+	return wallet, account, backend
+}
+
+func TestLedgerTransactor(t *testing.T) {
+	wallet, account, backend := newSimulatedLedger(t)
+	client := backend.Client()
+
+	chainID, err := client.ChainID(context.Background())
+	require.NoError(t, err)
+
+	parsed, err := abi.JSON(strings.NewReader(storeABI))
+	require.NoError(t, err)
+
+	// Deploy the contract with a dynamic fee transaction
+	opts, err := NewLedgerTransactor(wallet, account, chainID)
+	require.NoError(t, err)
+	addr, tx, err := bind.DeployContract(opts, storeCode, client, nil)
+	require.NoError(t, err)
+	require.Equal(t, uint8(coretypes.DynamicFeeTxType), tx.Type())
+	backend.Commit()
+
+	receipt, err := bind.WaitMined(context.Background(), client, tx.Hash())
+	require.NoError(t, err)
+	require.Equal(t, coretypes.ReceiptStatusSuccessful, receipt.Status)
+	require.Equal(t, addr, receipt.ContractAddress)
+
+	// Transact through the bindings, with a legacy transaction this time
+	contract := bind.NewBoundContract(addr, parsed, client, client, client)
+
+	opts.GasPrice = big.NewInt(params.GWei)
+	tx, err = contract.Transact(opts, "set", big.NewInt(42))
+	require.NoError(t, err)
+	require.Equal(t, uint8(coretypes.LegacyTxType), tx.Type())
+	backend.Commit()
+
+	receipt, err = bind.WaitMined(context.Background(), client, tx.Hash())
+	require.NoError(t, err)
+	require.Equal(t, coretypes.ReceiptStatusSuccessful, receipt.Status)
+
+	value, err := client.StorageAt(context.Background(), addr, common.Hash{}, nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(42), new(big.Int).SetBytes(value).Int64())
+}
+
+func TestLedgerTransactorUnauthorized(t *testing.T) {
+	wallet, account, _ := testutil.SimulatedWallet(t)
+
+	opts, err := NewLedgerTransactor(wallet, account, big.NewInt(1337))
+	require.NoError(t, err)
+	tx := coretypes.NewTransaction(0, common.Address{}, nil, 21000, big.NewInt(1), nil)
+
+	_, err = opts.Signer(common.Address{0x01}, tx)
+	require.ErrorIs(t, err, bind.ErrNotAuthorized)
+}
+
+func TestLedgerTransactorNoChainID(t *testing.T) {
+	wallet, account, _ := testutil.SimulatedWallet(t)
+
+	_, err := NewLedgerTransactor(wallet, account, nil)
+	require.ErrorIs(t, err, ErrNoChainID)
+}
@@ -0,0 +1,413 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto/kzg4844"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+
+	"github.com/evmos/ethereum-ledger-go/accounts"
+)
+
+// MaxTxDataSize is the largest calldata the builder accepts. The Ethereum app
+// streams calldata in 255 byte chunks, each needing a round trip over USB, and
+// nodes don't relay transactions beyond 128KB anyway.
+const MaxTxDataSize = 128 * 1024
+
+var (
+	// ErrInvalidAddress is returned if an address is not 20 hex encoded bytes.
+	ErrInvalidAddress = errors.New("invalid address")
+
+	// ErrGasLimit is returned if the gas limit is below the intrinsic gas of the
+	// transaction or above the protocol's per transaction cap.
+	ErrGasLimit = errors.New("invalid gas limit")
+
+	// ErrFeeCaps is returned if the fee fields are missing, negative, or the tip
+	// exceeds the fee cap.
+	ErrFeeCaps = errors.New("invalid fee caps")
+
+	// ErrDataTooLarge is returned if the calldata exceeds MaxTxDataSize.
+	ErrDataTooLarge = errors.New("transaction data too large")
+
+	// ErrTxType is returned if the fields set don't fit the transaction type.
+	ErrTxType = errors.New("invalid transaction type")
+)
+
+// TxBuilder assembles legacy, access list, dynamic fee, blob and set-code
+// transactions, validating their fields before they are handed to the Ledger
+// for signing. The transaction type is derived from the fields set, unless
+// explicitly configured with Type.
+//
+// Setters record the first error encountered, which is returned by Build.
+type TxBuilder struct {
+	chainID *big.Int
+	txType  *uint8
+
+	nonce    uint64
+	nonceSet bool // Whether the nonce was set explicitly, or is left to fill in
+	to       *common.Address
+	value    *big.Int
+	gas      uint64
+	data     []byte
+
+	gasPrice  *big.Int
+	gasTipCap *big.Int
+	gasFeeCap *big.Int
+
+	accessList coretypes.AccessList
+
+	blobFeeCap *big.Int
+	blobHashes []common.Hash
+	sidecar    *coretypes.BlobTxSidecar
+
+	authorizations []coretypes.SetCodeAuthorization
+
+	err error
+}
+
+// NewTxBuilder creates a transaction builder for the given chain. A nil chain ID
+// is only allowed for legacy transactions without replay protection.
+func NewTxBuilder(chainID *big.Int) *TxBuilder {
+	return &TxBuilder{chainID: chainID}
+}
+
+// Type explicitly sets the transaction type, instead of deriving it from the
+// fields set.
+func (b *TxBuilder) Type(txType uint8) *TxBuilder {
+	b.txType = &txType
+	return b
+}
+
+// Nonce sets the sender's account nonce.
+func (b *TxBuilder) Nonce(nonce uint64) *TxBuilder {
+	b.nonce, b.nonceSet = nonce, true
+	return b
+}
+
+// To sets the recipient from its hex encoding. Without a recipient, the
+// transaction creates a contract.
+func (b *TxBuilder) To(to string) *TxBuilder {
+	if !common.IsHexAddress(to) {
+		b.fail(fmt.Errorf("%w: recipient %q", ErrInvalidAddress, to))
+		return b
+	}
+	return b.ToAddress(common.HexToAddress(to))
+}
+
+// ToAddress sets the recipient.
+func (b *TxBuilder) ToAddress(to common.Address) *TxBuilder {
+	b.to = &to
+	return b
+}
+
+// Value sets the amount of wei transferred.
+func (b *TxBuilder) Value(value *big.Int) *TxBuilder {
+	b.value = value
+	return b
+}
+
+// Gas sets the gas limit.
+func (b *TxBuilder) Gas(gas uint64) *TxBuilder {
+	b.gas = gas
+	return b
+}
+
+// Data sets the calldata, or the init code for contract creations.
+func (b *TxBuilder) Data(data []byte) *TxBuilder {
+	b.data = common.CopyBytes(data)
+	return b
+}
+
+// GasPrice sets the gas price of legacy and access list transactions.
+func (b *TxBuilder) GasPrice(price *big.Int) *TxBuilder {
+	b.gasPrice = price
+	return b
+}
+
+// GasFees sets the tip and fee cap of dynamic fee, blob and set-code transactions.
+func (b *TxBuilder) GasFees(tipCap, feeCap *big.Int) *TxBuilder {
+	b.gasTipCap, b.gasFeeCap = tipCap, feeCap
+	return b
+}
+
+// AccessList sets the EIP-2930 access list.
+func (b *TxBuilder) AccessList(list coretypes.AccessList) *TxBuilder {
+	b.accessList = list
+	return b
+}
+
+// BlobHashes sets the versioned hashes of the blobs carried and the fee cap paid
+// per blob gas, making the transaction an EIP-4844 blob transaction.
+func (b *TxBuilder) BlobHashes(feeCap *big.Int, hashes ...common.Hash) *TxBuilder {
+	b.blobFeeCap, b.blobHashes = feeCap, hashes
+	return b
+}
+
+// BlobSidecar attaches the blobs of a blob transaction, along with their fee cap
+// per blob gas, deriving the versioned hashes from the commitments.
+func (b *TxBuilder) BlobSidecar(feeCap *big.Int, sidecar *coretypes.BlobTxSidecar) *TxBuilder {
+	b.sidecar = sidecar
+	return b.BlobHashes(feeCap, sidecar.BlobHashes()...)
+}
+
+// Authorizations sets the signed EIP-7702 authorizations, making the transaction
+// a set-code transaction.
+func (b *TxBuilder) Authorizations(auths ...coretypes.SetCodeAuthorization) *TxBuilder {
+	b.authorizations = auths
+	return b
+}
+
+// Build validates the fields set and assembles the transaction.
+func (b *TxBuilder) Build() (*coretypes.Transaction, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	txType := b.inferType()
+	if b.txType != nil {
+		txType = *b.txType
+	}
+	if err := b.validate(txType); err != nil {
+		return nil, err
+	}
+	value := b.value
+	if value == nil {
+		value = new(big.Int)
+	}
+	switch txType {
+	case coretypes.LegacyTxType:
+		return coretypes.NewTx(&coretypes.LegacyTx{
+			Nonce: b.nonce, GasPrice: b.gasPrice, Gas: b.gas, To: b.to, Value: value, Data: b.data,
+		}), nil
+
+	case coretypes.AccessListTxType:
+		return coretypes.NewTx(&coretypes.AccessListTx{
+			ChainID: b.chainID, Nonce: b.nonce, GasPrice: b.gasPrice, Gas: b.gas, To: b.to, Value: value, Data: b.data,
+			AccessList: b.accessList,
+		}), nil
+
+	case coretypes.DynamicFeeTxType:
+		return coretypes.NewTx(&coretypes.DynamicFeeTx{
+			ChainID: b.chainID, Nonce: b.nonce, GasTipCap: b.gasTipCap, GasFeeCap: b.gasFeeCap, Gas: b.gas, To: b.to,
+			Value: value, Data: b.data, AccessList: b.accessList,
+		}), nil
+
+	case coretypes.BlobTxType:
+		return coretypes.NewTx(&coretypes.BlobTx{
+			ChainID: uint256.MustFromBig(b.chainID), Nonce: b.nonce, GasTipCap: uint256.MustFromBig(b.gasTipCap),
+			GasFeeCap: uint256.MustFromBig(b.gasFeeCap), Gas: b.gas, To: *b.to, Value: uint256.MustFromBig(value),
+			Data: b.data, AccessList: b.accessList, BlobFeeCap: uint256.MustFromBig(b.blobFeeCap),
+			BlobHashes: b.blobHashes, Sidecar: b.sidecar,
+		}), nil
+
+	default: // coretypes.SetCodeTxType, as validated
+		return coretypes.NewTx(&coretypes.SetCodeTx{
+			ChainID: uint256.MustFromBig(b.chainID), Nonce: b.nonce, GasTipCap: uint256.MustFromBig(b.gasTipCap),
+			GasFeeCap: uint256.MustFromBig(b.gasFeeCap), Gas: b.gas, To: *b.to, Value: uint256.MustFromBig(value),
+			Data: b.data, AccessList: b.accessList, AuthList: b.authorizations,
+		}), nil
+	}
+}
+
+// Sign builds the transaction and requests the wallet to sign it with the
+// account, returning the binary encoding of the signed transaction.
+func (b *TxBuilder) Sign(wallet accounts.Wallet, account accounts.Account) ([]byte, error) {
+	tx, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	return wallet.SignTx(account, tx, b.chainID)
+}
+
+// txBuilderFrom creates a builder with all the fields of the transaction set,
+// including its nonce, e.g. to re-sign it with modified fees.
+func txBuilderFrom(tx *coretypes.Transaction) *TxBuilder {
+	var chainID *big.Int
+	if tx.Type() != coretypes.LegacyTxType || tx.Protected() {
+		chainID = tx.ChainId()
+	}
+	b := NewTxBuilder(chainID).Type(tx.Type()).Nonce(tx.Nonce()).Value(tx.Value()).Gas(tx.Gas()).Data(tx.Data())
+	if to := tx.To(); to != nil {
+		b.ToAddress(*to)
+	}
+	if tx.Type() <= coretypes.AccessListTxType {
+		b.GasPrice(tx.GasPrice())
+	} else {
+		b.GasFees(tx.GasTipCap(), tx.GasFeeCap())
+	}
+	if tx.Type() != coretypes.LegacyTxType {
+		b.AccessList(tx.AccessList())
+	}
+	if tx.Type() == coretypes.BlobTxType {
+		if sidecar := tx.BlobTxSidecar(); sidecar != nil {
+			b.BlobSidecar(tx.BlobGasFeeCap(), sidecar)
+		} else {
+			b.BlobHashes(tx.BlobGasFeeCap(), tx.BlobHashes()...)
+		}
+	}
+	if tx.Type() == coretypes.SetCodeTxType {
+		b.Authorizations(tx.SetCodeAuthorizations()...)
+	}
+	return b
+}
+
+// fail records the first error encountered by the setters.
+func (b *TxBuilder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// inferType derives the transaction type from the fields set.
+func (b *TxBuilder) inferType() uint8 {
+	switch {
+	case len(b.authorizations) > 0:
+		return coretypes.SetCodeTxType
+	case len(b.blobHashes) > 0:
+		return coretypes.BlobTxType
+	case b.gasTipCap != nil || b.gasFeeCap != nil:
+		return coretypes.DynamicFeeTxType
+	case len(b.accessList) > 0:
+		return coretypes.AccessListTxType
+	default:
+		return coretypes.LegacyTxType
+	}
+}
+
+// validate checks the fields set against the transaction type, the protocol
+// rules and the device limits.
+func (b *TxBuilder) validate(txType uint8) error {
+	if txType > coretypes.SetCodeTxType {
+		return fmt.Errorf("%w: unknown type %d", ErrTxType, txType)
+	}
+	// Only legacy transactions may lack replay protection
+	if txType != coretypes.LegacyTxType && (b.chainID == nil || b.chainID.Sign() <= 0) {
+		return fmt.Errorf("%w: type %d requires a chain ID", ErrTxType, txType)
+	}
+	if b.chainID != nil && b.chainID.Sign() < 0 {
+		return fmt.Errorf("%w: negative chain ID", ErrTxType)
+	}
+	if b.chainID != nil && b.chainID.BitLen() > 256 {
+		return fmt.Errorf("%w: chain ID overflows 256 bits", ErrTxType)
+	}
+	// Ensure the fields set are carried by the transaction type
+	if txType < coretypes.AccessListTxType && len(b.accessList) > 0 {
+		return fmt.Errorf("%w: legacy transactions can't carry an access list", ErrTxType)
+	}
+	if txType != coretypes.BlobTxType && (len(b.blobHashes) > 0 || b.blobFeeCap != nil) {
+		return fmt.Errorf("%w: only blob transactions carry blobs", ErrTxType)
+	}
+	if txType != coretypes.SetCodeTxType && len(b.authorizations) > 0 {
+		return fmt.Errorf("%w: only set-code transactions carry authorizations", ErrTxType)
+	}
+	if (txType == coretypes.BlobTxType || txType == coretypes.SetCodeTxType) && b.to == nil {
+		return fmt.Errorf("%w: type %d can't create contracts", ErrTxType, txType)
+	}
+	if b.value != nil && (b.value.Sign() < 0 || b.value.BitLen() > 256) {
+		return fmt.Errorf("invalid value %v", b.value)
+	}
+	// Validate the fees of the transaction type
+	if txType <= coretypes.AccessListTxType {
+		if b.gasTipCap != nil || b.gasFeeCap != nil {
+			return fmt.Errorf("%w: type %d uses a gas price, not fee caps", ErrFeeCaps, txType)
+		}
+		if b.gasPrice == nil || b.gasPrice.Sign() < 0 {
+			return fmt.Errorf("%w: missing or negative gas price", ErrFeeCaps)
+		}
+	} else {
+		if b.gasPrice != nil {
+			return fmt.Errorf("%w: type %d uses fee caps, not a gas price", ErrFeeCaps, txType)
+		}
+		if b.gasTipCap == nil || b.gasFeeCap == nil || b.gasTipCap.Sign() < 0 || b.gasFeeCap.Sign() < 0 {
+			return fmt.Errorf("%w: missing or negative fee caps", ErrFeeCaps)
+		}
+		if b.gasTipCap.Cmp(b.gasFeeCap) > 0 {
+			return fmt.Errorf("%w: tip %v above fee cap %v", ErrFeeCaps, b.gasTipCap, b.gasFeeCap)
+		}
+		if b.gasFeeCap.BitLen() > 256 {
+			return fmt.Errorf("%w: fee cap overflows 256 bits", ErrFeeCaps)
+		}
+	}
+	// Validate the blobs and authorizations
+	if txType == coretypes.BlobTxType {
+		if len(b.blobHashes) == 0 || len(b.blobHashes) > params.BlobTxMaxBlobs {
+			return fmt.Errorf("%w: %d blobs, want 1 to %d", ErrTxType, len(b.blobHashes), params.BlobTxMaxBlobs)
+		}
+		for i, hash := range b.blobHashes {
+			if !kzg4844.IsValidVersionedHash(hash[:]) {
+				return fmt.Errorf("%w: blob hash %d has invalid version", ErrTxType, i)
+			}
+		}
+		if b.blobFeeCap == nil || b.blobFeeCap.Sign() < 0 || b.blobFeeCap.BitLen() > 256 {
+			return fmt.Errorf("%w: missing or invalid blob fee cap", ErrFeeCaps)
+		}
+	}
+	if txType == coretypes.SetCodeTxType {
+		if len(b.authorizations) == 0 {
+			return fmt.Errorf("%w: set-code transactions need authorizations", ErrTxType)
+		}
+		for i, auth := range b.authorizations {
+			if _, err := auth.Authority(); err != nil {
+				return fmt.Errorf("authorization %d is not signed: %w", i, err)
+			}
+		}
+	}
+	// Validate the sizes and gas limit
+	if len(b.data) > MaxTxDataSize {
+		return fmt.Errorf("%w: %d bytes, max %d", ErrDataTooLarge, len(b.data), MaxTxDataSize)
+	}
+	if b.to == nil && len(b.data) > params.MaxInitCodeSize {
+		return fmt.Errorf("%w: init code of %d bytes, max %d", ErrDataTooLarge, len(b.data), params.MaxInitCodeSize)
+	}
+	intrinsic := intrinsicGas(b.data, b.accessList, len(b.authorizations), b.to == nil)
+	if b.gas < intrinsic || b.gas > params.MaxTxGas {
+		return fmt.Errorf("%w: %d, want %d to %d", ErrGasLimit, b.gas, intrinsic, params.MaxTxGas)
+	}
+	return nil
+}
+
+// intrinsicGas computes the minimum gas a transaction needs to be valid, based on
+// the rules of the latest fork.
+func intrinsicGas(data []byte, accessList coretypes.AccessList, authorizations int, creation bool) uint64 {
+	var (
+		gas    = params.TxGas
+		tokens uint64 // EIP-7623 calldata tokens, pricing the data gas floor
+	)
+	if creation {
+		gas = params.TxGasContractCreation
+		gas += params.InitCodeWordGas * ((uint64(len(data)) + 31) / 32)
+	}
+	for _, b := range data {
+		if b == 0 {
+			gas += params.TxDataZeroGas
+			tokens++
+		} else {
+			gas += params.TxDataNonZeroGasEIP2028
+			tokens += params.TxTokenPerNonZeroByte
+		}
+	}
+	gas += uint64(len(accessList)) * params.TxAccessListAddressGas
+	gas += uint64(accessList.StorageKeys()) * params.TxAccessListStorageKeyGas
+	gas += uint64(authorizations) * params.CallNewAccountGas
+
+	// Since Prague, calldata heavy transactions pay at least the data gas floor
+	if floor := params.TxGas + tokens*params.TxCostFloorPerToken; floor > gas {
+		return floor
+	}
+	return gas
+}
+
+// CreateTx creates a legacy transaction, only checking the recipient's address.
+//
+// Deprecated: use NewTxBuilder, which supports all transaction types and validates
+// the gas limit and fees too.
+func CreateTx(nonce uint64, to string, gas uint64, gasPrice *big.Int, amount *big.Int, data []byte) (*coretypes.Transaction, error) {
+	if !common.IsHexAddress(to) {
+		return nil, fmt.Errorf("%w: recipient %q", ErrInvalidAddress, to)
+	}
+	return coretypes.NewTransaction(nonce, common.HexToAddress(to), amount, gas, gasPrice, data), nil
+}
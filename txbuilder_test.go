@@ -0,0 +1,153 @@
+package ledger
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evmos/ethereum-ledger-go/internal/testutil"
+)
+
+const testRecipient = "0x3535353535353535353535353535353535353535"
+
+// signedAuthorization creates an EIP-7702 authorization signed by a random key.
+func signedAuthorization(t *testing.T) coretypes.SetCodeAuthorization {
+	t.Helper()
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	auth, err := coretypes.SignSetCode(key, coretypes.SetCodeAuthorization{
+		ChainID: *uint256.NewInt(1),
+		Address: common.HexToAddress(testRecipient),
+		Nonce:   1,
+	})
+	require.NoError(t, err)
+	return auth
+}
+
+func TestTxBuilderTypes(t *testing.T) {
+	accessList := coretypes.AccessList{{Address: common.Address{0x01}, StorageKeys: []common.Hash{{0x02}}}}
+
+	tests := []struct {
+		name    string
+		builder *TxBuilder
+		txType  uint8
+	}{
+		{
+			name:    "legacy",
+			builder: NewTxBuilder(big.NewInt(1)).GasPrice(big.NewInt(10)),
+			txType:  coretypes.LegacyTxType,
+		},
+		{
+			name:    "access list",
+			builder: NewTxBuilder(big.NewInt(1)).GasPrice(big.NewInt(10)).AccessList(accessList),
+			txType:  coretypes.AccessListTxType,
+		},
+		{
+			name:    "dynamic fee",
+			builder: NewTxBuilder(big.NewInt(1)).GasFees(big.NewInt(1), big.NewInt(10)),
+			txType:  coretypes.DynamicFeeTxType,
+		},
+		{
+			name:    "dynamic fee with explicit type",
+			builder: NewTxBuilder(big.NewInt(1)).Type(coretypes.DynamicFeeTxType).GasFees(big.NewInt(1), big.NewInt(10)),
+			txType:  coretypes.DynamicFeeTxType,
+		},
+		{
+			name:    "blob",
+			builder: NewTxBuilder(big.NewInt(1)).GasFees(big.NewInt(1), big.NewInt(10)).BlobHashes(big.NewInt(5), common.Hash{0x01}),
+			txType:  coretypes.BlobTxType,
+		},
+		{
+			name:    "set code",
+			builder: NewTxBuilder(big.NewInt(1)).GasFees(big.NewInt(1), big.NewInt(10)).Authorizations(signedAuthorization(t)),
+			txType:  coretypes.SetCodeTxType,
+		},
+	}
+	wallet, account, _ := testutil.SimulatedWallet(t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := tt.builder.Nonce(3).To(testRecipient).Gas(100000).Value(big.NewInt(7)).Data([]byte{0xde, 0xad})
+
+			tx, err := builder.Build()
+			require.NoError(t, err)
+			require.Equal(t, tt.txType, tx.Type())
+			require.Equal(t, uint64(3), tx.Nonce())
+			require.Equal(t, common.HexToAddress(testRecipient), *tx.To())
+			require.Equal(t, big.NewInt(7), tx.Value())
+
+			// The built transaction goes straight to the wallet
+			raw, err := builder.Sign(wallet, account)
+			require.NoError(t, err)
+
+			signed := new(coretypes.Transaction)
+			require.NoError(t, signed.UnmarshalBinary(raw))
+			require.Equal(t, tt.txType, signed.Type())
+
+			sender, err := coretypes.Sender(coretypes.LatestSignerForChainID(big.NewInt(1)), signed)
+			require.NoError(t, err)
+			require.Equal(t, account.Address, sender)
+		})
+	}
+}
+
+func TestTxBuilderValidation(t *testing.T) {
+	valid := func() *TxBuilder {
+		return NewTxBuilder(big.NewInt(1)).To(testRecipient).Gas(params.TxGas).GasFees(big.NewInt(1), big.NewInt(10))
+	}
+	tests := []struct {
+		name    string
+		builder *TxBuilder
+		err     error
+	}{
+		{"invalid recipient", valid().To("0x1234"), ErrInvalidAddress},
+		{"gas below intrinsic", valid().Gas(params.TxGas - 1), ErrGasLimit},
+		{"gas below calldata cost", valid().Data([]byte{0x01}), ErrGasLimit},
+		{"gas below creation cost", NewTxBuilder(big.NewInt(1)).Gas(params.TxGas).GasFees(big.NewInt(1), big.NewInt(10)), ErrGasLimit},
+		{"gas above cap", valid().Gas(params.MaxTxGas + 1), ErrGasLimit},
+		{"tip above fee cap", valid().GasFees(big.NewInt(11), big.NewInt(10)), ErrFeeCaps},
+		{"missing fee cap", valid().GasFees(big.NewInt(1), nil), ErrFeeCaps},
+		{"missing gas price", valid().GasFees(nil, nil), ErrFeeCaps},
+		{"mixed fees", valid().GasPrice(big.NewInt(1)), ErrFeeCaps},
+		{"data too large", valid().Gas(params.MaxTxGas).Data(make([]byte, MaxTxDataSize+1)), ErrDataTooLarge},
+		{"typed without chain", NewTxBuilder(nil).To(testRecipient).Gas(params.TxGas).GasFees(big.NewInt(1), big.NewInt(10)), ErrTxType},
+		{"legacy with access list", valid().Type(coretypes.LegacyTxType).GasFees(nil, nil).GasPrice(big.NewInt(1)).AccessList(coretypes.AccessList{{}}), ErrTxType},
+		{"blob without recipient", NewTxBuilder(big.NewInt(1)).Gas(params.TxGasContractCreation).GasFees(big.NewInt(1), big.NewInt(10)).BlobHashes(big.NewInt(1), common.Hash{0x01}), ErrTxType},
+		{"blob with invalid hash", valid().BlobHashes(big.NewInt(1), common.Hash{0x02}), ErrTxType},
+		{"blob without fee cap", valid().BlobHashes(nil, common.Hash{0x01}), ErrFeeCaps},
+		{"too many blobs", valid().BlobHashes(big.NewInt(1), make([]common.Hash, params.BlobTxMaxBlobs+1)...), ErrTxType},
+		{"set code without authorizations", valid().Type(coretypes.SetCodeTxType), ErrTxType},
+		{"unknown type", valid().Type(0x7f), ErrTxType},
+		{"oversized chain", NewTxBuilder(new(big.Int).Lsh(big.NewInt(1), 256)).To(testRecipient).Gas(params.TxGas).GasFees(big.NewInt(1), big.NewInt(10)).BlobHashes(big.NewInt(1), common.Hash{0x01}), ErrTxType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.builder.Build()
+			require.ErrorIs(t, err, tt.err)
+		})
+	}
+	// Unsigned authorizations are rejected
+	_, err := valid().Gas(100000).Authorizations(coretypes.SetCodeAuthorization{}).Build()
+	require.ErrorContains(t, err, "authorization 0 is not signed")
+}
+
+func TestTxBuilderIntrinsicGas(t *testing.T) {
+	// Calldata heavy transactions are priced by the EIP-7623 floor
+	data := bytes.Repeat([]byte{0xff}, 1000)
+	floor := params.TxGas + 4*1000*params.TxCostFloorPerToken
+
+	_, err := NewTxBuilder(big.NewInt(1)).To(testRecipient).GasFees(big.NewInt(1), big.NewInt(10)).Data(data).Gas(floor - 1).Build()
+	require.ErrorIs(t, err, ErrGasLimit)
+
+	_, err = NewTxBuilder(big.NewInt(1)).To(testRecipient).GasFees(big.NewInt(1), big.NewInt(10)).Data(data).Gas(floor).Build()
+	require.NoError(t, err)
+}
@@ -0,0 +1,92 @@
+package usbwallet
+
+import (
+	"errors"
+	"io"
+)
+
+// ledgerClaDashboard is the instruction class of the APDUs handled by the Ledger
+// OS itself, independently of the open app.
+const ledgerClaDashboard = 0xb0
+
+// ledgerOpGetAppAndVersion returns the name and version of the currently open app.
+const ledgerOpGetAppAndVersion ledgerOpcode = 0x01
+
+// appDriver picks the protocol driver matching the app open on the Ledger when
+// the wallet is opened, delegating all operations to it. The Ethereum app driver
+// is used unless the Cosmos app is detected.
+type appDriver struct {
+	driver // Protocol driver of the currently open app
+
+	hrp string // Bech32 human readable part for the Cosmos app
+}
+
+// newAppDriver creates a driver detecting the open app, defaulting to the
+// Ethereum app until a device is opened.
+func newAppDriver() driver {
+	return &appDriver{driver: newLedgerDriver(), hrp: DefaultCosmosHRP}
+}
+
+// setHRP configures the bech32 human readable part used if the Cosmos app is open.
+func (a *appDriver) setHRP(hrp string) {
+	a.hrp = hrp
+	if d, ok := a.driver.(hrpDriver); ok {
+		d.setHRP(hrp)
+	}
+}
+
+// Open implements usbwallet.driver, detecting the app open on the Ledger and
+// initializing the connection via the matching driver.
+func (a *appDriver) Open(device io.ReadWriter, passphrase string) error {
+	name, _, err := ledgerAppAndVersion(device)
+	if err == nil && name == "Cosmos" {
+		d := newCosmosDriver()
+		d.(hrpDriver).setHRP(a.hrp)
+		a.driver = d
+	} else {
+		a.driver = newLedgerDriver()
+	}
+	return a.driver.Open(device, passphrase)
+}
+
+// hrpDriver is implemented by drivers deriving bech32 addresses.
+type hrpDriver interface {
+	setHRP(hrp string)
+}
+
+// ledgerAppAndVersion retrieves the name and version of the app currently open on
+// the Ledger (BOLOS if none).
+//
+// The app retrieval protocol is defined as follows:
+//
+//	CLA | INS | P1 | P2 | Lc | Le
+//	----+-----+----+----+----+---
+//	 B0 | 01  | 00 | 00 | 00 | variable
+//
+// With no input data, and the output data being:
+//
+//	Description         | Length
+//	--------------------+---------
+//	Format (01)         | 1 byte
+//	App name length     | 1 byte
+//	App name            | variable
+//	App version length  | 1 byte
+//	App version         | variable
+//	Flags length        | 1 byte
+//	Flags               | variable
+func ledgerAppAndVersion(device io.ReadWriter) (string, string, error) {
+	reply, err := ledgerAPDU(device, ledgerClaDashboard, byte(ledgerOpGetAppAndVersion), 0, 0, nil)
+	if err != nil {
+		return "", "", err
+	}
+	if len(reply) < 2 || reply[0] != 0x01 || len(reply) < 3+int(reply[1]) {
+		return "", "", errors.New("reply lacks app name")
+	}
+	name := string(reply[2 : 2+reply[1]])
+	reply = reply[2+reply[1]:]
+
+	if len(reply) < 1+int(reply[0]) {
+		return "", "", errors.New("reply lacks app version")
+	}
+	return name, string(reply[1 : 1+reply[0]]), nil
+}
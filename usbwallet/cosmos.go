@@ -0,0 +1,393 @@
+// This file contains the implementation for interacting with the Cosmos app on
+// Ledger hardware wallets. The wire protocol spec can be found in the app's repo:
+// https://github.com/cosmos/ledger-cosmos/blob/main/docs/APDUSPEC.md
+
+package usbwallet
+
+import (
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+
+	"github.com/evmos/ethereum-ledger-go/accounts"
+)
+
+// DefaultCosmosHRP is the bech32 human readable part the Cosmos app derives
+// addresses with, unless configured otherwise on the hub.
+const DefaultCosmosHRP = "evmos"
+
+// cosmosClaApp is the instruction class of the Cosmos app's APDUs.
+const cosmosClaApp = 0x55
+
+const (
+	cosmosOpGetVersion    ledgerOpcode = 0x00 // Returns the version of the Cosmos app
+	cosmosOpSignSECP256K1 ledgerOpcode = 0x02 // Signs an Amino JSON sign doc after having the user validate it
+	cosmosOpGetAddress    ledgerOpcode = 0x04 // Returns the compressed public key and bech32 address for a path
+
+	cosmosP1SignInit          ledgerParam1 = 0x00 // First chunk of a signing request, carrying the path
+	cosmosP1SignAdd           ledgerParam1 = 0x01 // Intermediate chunk of the sign doc
+	cosmosP1SignLast          ledgerParam1 = 0x02 // Last chunk of the sign doc
+	cosmosP1DirectlyFetchAddr ledgerParam1 = 0x00 // Return address directly from the wallet
+	cosmosP1ConfirmFetchAddr  ledgerParam1 = 0x01 // Display address and require confirmation before returning
+	cosmosP2SignModeAminoJSON ledgerParam2 = 0x00 // Sign doc is Amino JSON
+)
+
+// cosmosPathLength is the number of components of the paths the Cosmos app accepts.
+const cosmosPathLength = 5
+
+// cosmosDriver implements the communication with the Cosmos app of a Ledger
+// hardware wallet, deriving Ethermint (eth_secp256k1) accounts.
+type cosmosDriver struct {
+	device  io.ReadWriter // USB device connection to communicate through
+	version [3]byte       // Current version of the Cosmos app (zero if app is offline)
+	hrp     string        // Bech32 human readable part to derive addresses with
+	failure error         // Any failure that would make the device unusable
+}
+
+// newCosmosDriver creates a new instance of a Ledger Cosmos app protocol driver.
+func newCosmosDriver() driver {
+	return &cosmosDriver{hrp: DefaultCosmosHRP}
+}
+
+// setHRP configures the bech32 human readable part to derive addresses with.
+func (w *cosmosDriver) setHRP(hrp string) {
+	w.hrp = hrp
+}
+
+// Status implements usbwallet.driver, returning various states the Cosmos app can
+// currently be in.
+func (w *cosmosDriver) Status() (string, error) {
+	if w.failure != nil {
+		return fmt.Sprintf("Failed: %v", w.failure), w.failure
+	}
+	if w.offline() {
+		return "Cosmos app offline", w.failure
+	}
+	return fmt.Sprintf("Cosmos app v%d.%d.%d online", w.version[0], w.version[1], w.version[2]), w.failure
+}
+
+// offline returns whether the wallet and the Cosmos app is offline or not.
+func (w *cosmosDriver) offline() bool {
+	return w.version == [3]byte{0, 0, 0}
+}
+
+// Open implements usbwallet.driver, attempting to initialize the connection to the
+// Cosmos app. The Ledger does not require a user passphrase, so that parameter is
+// silently discarded.
+func (w *cosmosDriver) Open(device io.ReadWriter, passphrase string) error {
+	w.device, w.failure = device, nil
+
+	version, err := w.cosmosVersion()
+	if err != nil {
+		// Cosmos app is not running, nothing more to do, return
+		return nil
+	}
+	w.version = version
+	return nil
+}
+
+// Close implements usbwallet.driver, cleaning up and metadata maintained within
+// the Cosmos driver.
+func (w *cosmosDriver) Close() error {
+	w.version = [3]byte{}
+	return nil
+}
+
+// Heartbeat implements usbwallet.driver, performing a sanity check against the
+// Ledger to see if it's still online.
+func (w *cosmosDriver) Heartbeat() error {
+	// Any reply, even one carrying an error status, means the device is alive
+	var status *StatusError
+	if _, err := w.cosmosVersion(); err != nil && !errors.As(err, &status) {
+		w.failure = err
+		return err
+	}
+	return nil
+}
+
+// Derive implements usbwallet.driver, sending a derivation request to the Cosmos
+// app and returning the Ethermint account located on that path. The address the
+// device reports is verified to be the Ethereum address of the key, so only
+// eth_secp256k1 (coin type 60) paths can be derived.
+func (w *cosmosDriver) Derive(path gethaccounts.DerivationPath) (common.Address, *ecdsa.PublicKey, error) {
+	if w.offline() {
+		return common.Address{}, nil, gethaccounts.ErrWalletClosed
+	}
+	return w.cosmosDerive(path, cosmosP1DirectlyFetchAddr)
+}
+
+// ConfirmAddress implements usbwallet.driver, sending a derivation request to the
+// Cosmos app which displays the bech32 address and waits for the user to confirm it.
+func (w *cosmosDriver) ConfirmAddress(path gethaccounts.DerivationPath) (common.Address, *ecdsa.PublicKey, error) {
+	if w.offline() {
+		return common.Address{}, nil, gethaccounts.ErrWalletClosed
+	}
+	return w.cosmosDerive(path, cosmosP1ConfirmFetchAddr)
+}
+
+// SignAminoJSON implements usbwallet.driver, sending the Amino JSON sign doc to
+// the Cosmos app and waiting for the user to confirm or deny it.
+func (w *cosmosDriver) SignAminoJSON(path gethaccounts.DerivationPath, signDoc []byte) ([]byte, error) {
+	if w.offline() {
+		return nil, gethaccounts.ErrWalletClosed
+	}
+	return w.cosmosSign(path, signDoc)
+}
+
+// SignTx implements usbwallet.driver, however the Cosmos app can't sign Ethereum
+// transactions, so this method will always return an error.
+func (w *cosmosDriver) SignTx(gethaccounts.DerivationPath, *coretypes.Transaction, *big.Int, *TransactionMetadata) (common.Address, []byte, error) {
+	return common.Address{}, nil, gethaccounts.ErrNotSupported
+}
+
+// SignTypedMessage implements usbwallet.driver, however the Cosmos app can't sign
+// EIP-712 messages, so this method will always return an error.
+func (w *cosmosDriver) SignTypedMessage(gethaccounts.DerivationPath, []byte, []byte) ([]byte, error) {
+	return nil, gethaccounts.ErrNotSupported
+}
+
+// SignTypedData implements usbwallet.driver, however the Cosmos app can't sign
+// EIP-712 messages, so this method will always return an error.
+func (w *cosmosDriver) SignTypedData(gethaccounts.DerivationPath, apitypes.TypedData, *TypedDataMetadata) ([]byte, error) {
+	return nil, gethaccounts.ErrNotSupported
+}
+
+// SignPersonalMessage implements usbwallet.driver, however the Cosmos app can't
+// sign EIP-191 messages, so this method will always return an error.
+func (w *cosmosDriver) SignPersonalMessage(gethaccounts.DerivationPath, []byte) ([]byte, error) {
+	return nil, gethaccounts.ErrNotSupported
+}
+
+// SignAuthorization implements usbwallet.driver, however the Cosmos app can't sign
+// EIP-7702 authorizations, so this method will always return an error.
+func (w *cosmosDriver) SignAuthorization(gethaccounts.DerivationPath, coretypes.SetCodeAuthorization) ([]byte, error) {
+	return nil, gethaccounts.ErrNotSupported
+}
+
+// Eth2PublicKey implements usbwallet.driver, however the Cosmos app has no BLS
+// keys, so this method will always return an error.
+func (w *cosmosDriver) Eth2PublicKey(gethaccounts.DerivationPath) (accounts.BLSPublicKey, error) {
+	return accounts.BLSPublicKey{}, gethaccounts.ErrNotSupported
+}
+
+// SetEth2WithdrawalIndex implements usbwallet.driver, however the Cosmos app has
+// no BLS keys, so this method will always return an error.
+func (w *cosmosDriver) SetEth2WithdrawalIndex(uint32) error {
+	return gethaccounts.ErrNotSupported
+}
+
+// EncryptionPublicKey implements usbwallet.driver, however the Cosmos app has no
+// privacy operations, so this method will always return an error.
+func (w *cosmosDriver) EncryptionPublicKey(gethaccounts.DerivationPath) ([32]byte, error) {
+	return [32]byte{}, gethaccounts.ErrNotSupported
+}
+
+// SharedSecret implements usbwallet.driver, however the Cosmos app has no privacy
+// operations, so this method will always return an error.
+func (w *cosmosDriver) SharedSecret(gethaccounts.DerivationPath, [32]byte) ([32]byte, error) {
+	return [32]byte{}, gethaccounts.ErrNotSupported
+}
+
+// Challenge implements usbwallet.driver, however the Cosmos app has no trusted
+// names, so this method will always return an error.
+func (w *cosmosDriver) Challenge() (uint32, error) {
+	return 0, gethaccounts.ErrNotSupported
+}
+
+// ProvideTrustedName implements usbwallet.driver, however the Cosmos app has no
+// trusted names, so this method will always return an error.
+func (w *cosmosDriver) ProvideTrustedName([]byte) error {
+	return gethaccounts.ErrNotSupported
+}
+
+// cosmosVersion retrieves the current version of the Cosmos app running on the
+// Ledger wallet.
+//
+// The version retrieval protocol is defined as follows:
+//
+//	CLA | INS | P1 | P2 | Lc | Le
+//	----+-----+----+----+----+---
+//	 55 | 00  | 00 | 00 | 00 | 09
+//
+// With no input data, and the output data being:
+//
+//	Description               | Length
+//	--------------------------+--------
+//	Test mode flag            | 1 byte
+//	Application major version | 1 byte
+//	Application minor version | 1 byte
+//	Application patch version | 1 byte
+//	Device locked flag        | 1 byte
+//	Target ID                 | 4 bytes
+func (w *cosmosDriver) cosmosVersion() ([3]byte, error) {
+	reply, err := w.cosmosExchange(cosmosOpGetVersion, 0, 0, nil)
+	if err != nil {
+		return [3]byte{}, err
+	}
+	if len(reply) < 5 {
+		return [3]byte{}, errLedgerInvalidVersionReply
+	}
+	if reply[4] != 0 {
+		return [3]byte{}, ErrLedgerLocked
+	}
+	return [3]byte{reply[1], reply[2], reply[3]}, nil
+}
+
+// cosmosDerive retrieves the compressed public key and bech32 address of the key
+// at the derivation path from the Cosmos app.
+//
+// The address derivation protocol is defined as follows:
+//
+//	CLA | INS | P1 | P2 | Lc       | Le
+//	----+-----+----+----+----------+---
+//	 55 | 04  | 00 | 00 | variable | variable
+//
+// Where the input data is:
+//
+//	Description                       | Length
+//	----------------------------------+---------
+//	HRP length                        | 1 byte
+//	HRP                               | variable
+//	Derivation path (5 little endian) | 20 bytes
+//
+// And the output data is:
+//
+//	Description           | Length
+//	----------------------+----------
+//	Compressed public key | 33 bytes
+//	Bech32 address        | variable
+func (w *cosmosDriver) cosmosDerive(derivationPath gethaccounts.DerivationPath, mode ledgerParam1) (common.Address, *ecdsa.PublicKey, error) {
+	path, err := cosmosPath(derivationPath)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	if len(w.hrp) == 0 || len(w.hrp) > 83 {
+		return common.Address{}, nil, fmt.Errorf("invalid bech32 human readable part %q", w.hrp)
+	}
+	data := append([]byte{byte(len(w.hrp))}, w.hrp...)
+	data = append(data, path...)
+
+	// Send the request and wait for the response
+	reply, err := w.cosmosExchange(cosmosOpGetAddress, mode, 0, data)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	if len(reply) < 33 {
+		return common.Address{}, nil, errors.New("reply lacks public key entry")
+	}
+	publicKey, err := crypto.DecompressPubkey(reply[:33])
+	if err != nil {
+		return common.Address{}, nil, fmt.Errorf("failed to unmarshal public key: %w", err)
+	}
+	// Ensure the device derived the Ethermint address of the key
+	hrp, address, err := accounts.Bech32ToAddress(string(reply[33:]))
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	derivedAddr := crypto.PubkeyToAddress(*publicKey)
+	if hrp != w.hrp || derivedAddr != address {
+		return common.Address{}, nil, fmt.Errorf("address mismatch, expected %s, got %s (not an eth_secp256k1 path?)", derivedAddr, address)
+	}
+	return address, publicKey, nil
+}
+
+// cosmosSign sends the Amino JSON sign doc to the Cosmos app, and waits for the
+// user to confirm or deny signing it.
+//
+// The signing protocol is defined as follows:
+//
+//	CLA | INS | P1 | P2 | Lc       | Le
+//	----+-----+----+----+----------+---
+//	 55 | 02  | 00: init (derivation path)
+//	            01: add (sign doc chunk)
+//	            02: last (sign doc chunk)
+//	               | 00 | variable | variable
+//
+// Where the input of the init chunk is the derivation path as 5 little endian
+// components, followed by chunks of up to 250 bytes of the sign doc. The output
+// data is the DER encoded signature, which is converted into the 64 byte compact
+// [R || S] form Cosmos SDK transactions carry.
+//
+// The Cosmos app only signs for coin type 60 paths in expert mode, hashing the
+// sign doc with keccak256 as eth_secp256k1 keys require.
+func (w *cosmosDriver) cosmosSign(derivationPath gethaccounts.DerivationPath, signDoc []byte) ([]byte, error) {
+	path, err := cosmosPath(derivationPath)
+	if err != nil {
+		return nil, err
+	}
+	chunks := [][]byte{path}
+	for len(signDoc) > 0 {
+		chunk := 250
+		if chunk > len(signDoc) {
+			chunk = len(signDoc)
+		}
+		chunks = append(chunks, signDoc[:chunk])
+		signDoc = signDoc[chunk:]
+	}
+	if len(chunks) == 1 {
+		return nil, errors.New("empty sign doc")
+	}
+	// Send the request and wait for the response
+	var reply []byte
+	for i, chunk := range chunks {
+		op := cosmosP1SignAdd
+		switch i {
+		case 0:
+			op = cosmosP1SignInit
+		case len(chunks) - 1:
+			op = cosmosP1SignLast
+		}
+		if reply, err = w.cosmosExchange(cosmosOpSignSECP256K1, op, cosmosP2SignModeAminoJSON, chunk); err != nil {
+			return nil, err
+		}
+	}
+	return derToCompact(reply)
+}
+
+// cosmosExchange performs a data exchange with the Cosmos app on the Ledger.
+func (w *cosmosDriver) cosmosExchange(opcode ledgerOpcode, p1 ledgerParam1, p2 ledgerParam2, data []byte) ([]byte, error) {
+	return ledgerAPDU(w.device, cosmosClaApp, byte(opcode), byte(p1), byte(p2), data)
+}
+
+// cosmosPath serializes a derivation path the way the Cosmos app expects it, as
+// exactly 5 little endian components.
+func cosmosPath(derivationPath gethaccounts.DerivationPath) ([]byte, error) {
+	if len(derivationPath) != cosmosPathLength {
+		return nil, fmt.Errorf("invalid derivation path length %d, want %d", len(derivationPath), cosmosPathLength)
+	}
+	path := make([]byte, 4*cosmosPathLength)
+	for i, component := range derivationPath {
+		binary.LittleEndian.PutUint32(path[4*i:], component)
+	}
+	return path, nil
+}
+
+// derToCompact converts a DER encoded ECDSA signature into the 64 byte [R || S]
+// form, normalizing S into the lower half of the curve order.
+func derToCompact(der []byte) ([]byte, error) {
+	var sig struct{ R, S *big.Int }
+	if rest, err := asn1.Unmarshal(der, &sig); err != nil || len(rest) > 0 {
+		return nil, errors.New("reply lacks DER signature")
+	}
+	n := crypto.S256().Params().N
+	if sig.R.Sign() <= 0 || sig.S.Sign() <= 0 || sig.R.Cmp(n) >= 0 || sig.S.Cmp(n) >= 0 {
+		return nil, errors.New("invalid signature values")
+	}
+	if sig.S.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		sig.S.Sub(n, sig.S)
+	}
+	compact := make([]byte, 64)
+	sig.R.FillBytes(compact[:32])
+	sig.S.FillBytes(compact[32:])
+	return compact, nil
+}
@@ -0,0 +1,190 @@
+package usbwallet
+
+import (
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"encoding/binary"
+	"math/big"
+	"testing"
+
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evmos/ethereum-ledger-go/accounts"
+	"github.com/evmos/ethereum-ledger-go/internal/bech32"
+)
+
+// newMockCosmosApp creates a mock device running the Cosmos app, deriving every
+// path to the given key and signing sign docs eth_secp256k1 style.
+func newMockCosmosApp(key *ecdsa.PrivateKey) *mockLedger {
+	var signDoc []byte // Sign doc chunks received so far
+
+	return &mockLedger{handler: func(apdu mockAPDU) ([]byte, uint16) {
+		if apdu.cla == ledgerClaDashboard && apdu.ins == byte(ledgerOpGetAppAndVersion) {
+			return append([]byte{0x01, 6}, append([]byte("Cosmos"), 6, '2', '.', '3', '4', '.', '1', 1, 0)...), ledgerStatusOK
+		}
+		if apdu.cla != cosmosClaApp {
+			return nil, 0x6e00
+		}
+		switch apdu.ins {
+		case byte(cosmosOpGetVersion):
+			return []byte{0, 2, 34, 1, 0, 0x31, 0x10, 0x00, 0x04}, ledgerStatusOK
+
+		case byte(cosmosOpGetAddress):
+			hrp := string(apdu.data[1 : 1+apdu.data[0]])
+			if binary.LittleEndian.Uint32(apdu.data[1+len(hrp)+4:]) != 0x80000000+60 {
+				return nil, 0x6a80
+			}
+			addr, _ := bech32.Encode(hrp, crypto.PubkeyToAddress(key.PublicKey).Bytes())
+			return append(crypto.CompressPubkey(&key.PublicKey), addr...), ledgerStatusOK
+
+		case byte(cosmosOpSignSECP256K1):
+			switch apdu.p1 {
+			case byte(cosmosP1SignInit):
+				signDoc = nil
+				return nil, ledgerStatusOK
+			case byte(cosmosP1SignAdd):
+				signDoc = append(signDoc, apdu.data...)
+				return nil, ledgerStatusOK
+			}
+			signDoc = append(signDoc, apdu.data...)
+
+			sig, err := crypto.Sign(crypto.Keccak256(signDoc), key)
+			if err != nil {
+				return nil, 0x6f00
+			}
+			// Devices may return high S values, make sure they are normalized
+			r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:64])
+			s.Sub(crypto.S256().Params().N, s)
+
+			der, _ := asn1.Marshal(struct{ R, S *big.Int }{r, s})
+			return der, ledgerStatusOK
+		}
+		return nil, 0x6d00
+	}}
+}
+
+// newMockAppWallet opens a wallet detecting the open app on top of the given
+// mock device.
+func newMockAppWallet(t *testing.T, device *mockLedger) *wallet {
+	t.Helper()
+
+	w := &wallet{
+		hub:       &Hub{},
+		driver:    newAppDriver(),
+		url:       &gethaccounts.URL{Scheme: LedgerScheme, Path: "mock"},
+		device:    device,
+		commsLock: make(chan struct{}, 1),
+	}
+	w.commsLock <- struct{}{}
+
+	require.NoError(t, w.Open(""))
+	t.Cleanup(func() { w.Close() })
+	return w
+}
+
+func TestAppDriverDetectsCosmos(t *testing.T) {
+	key := mustGenerateKey(t)
+	w := newMockAppWallet(t, newMockCosmosApp(key))
+
+	status, err := w.Status()
+	require.NoError(t, err)
+	require.Equal(t, "Cosmos app v2.34.1 online", status)
+
+	account, err := w.Derive(gethaccounts.DefaultBaseDerivationPath, true)
+	require.NoError(t, err)
+	require.Equal(t, crypto.PubkeyToAddress(key.PublicKey), account.Address)
+
+	// Ethereum only operations are refused
+	tx := coretypes.NewTransaction(0, common.Address{0x01}, big.NewInt(1), 21000, big.NewInt(1), nil)
+	_, err = w.SignTx(account, tx, big.NewInt(9001))
+	require.ErrorIs(t, err, gethaccounts.ErrNotSupported)
+}
+
+func TestAppDriverFallsBackToEthereum(t *testing.T) {
+	// The mock Ethereum app rejects the dashboard instruction like old firmwares do
+	w := newMockAppWallet(t, newMockEthereumApp(mustGenerateKey(t), nil))
+
+	status, err := w.Status()
+	require.NoError(t, err)
+	require.Equal(t, "Ethereum app v1.10.3 online", status)
+}
+
+func TestCosmosDeriveHRP(t *testing.T) {
+	key := mustGenerateKey(t)
+	device := newMockCosmosApp(key)
+
+	w := &wallet{
+		hub:       &Hub{},
+		driver:    newAppDriver(),
+		url:       &gethaccounts.URL{Scheme: LedgerScheme, Path: "mock"},
+		device:    device,
+		commsLock: make(chan struct{}, 1),
+	}
+	w.commsLock <- struct{}{}
+	w.hub.SetCosmosHRP("cosmos")
+
+	require.NoError(t, w.Open(""))
+	defer w.Close()
+
+	_, err := w.Derive(gethaccounts.DefaultBaseDerivationPath, false)
+	require.NoError(t, err)
+
+	apdu := device.history[len(device.history)-1]
+	require.Equal(t, []byte("\x06cosmos"), apdu.data[:7])
+	require.Equal(t, []byte{44, 0, 0, 0x80, 60, 0, 0, 0x80, 0, 0, 0, 0x80, 0, 0, 0, 0, 0, 0, 0, 0}, apdu.data[7:])
+
+	// Non eth_secp256k1 paths derive a different address scheme and are refused
+	_, err = w.Derive(gethaccounts.DerivationPath{44, 118, 0, 0, 0}, false)
+	require.Error(t, err)
+
+	// Only 5 component paths are supported by the Cosmos app
+	_, err = w.Derive(gethaccounts.DerivationPath{44, 60, 0, 0}, false)
+	require.ErrorContains(t, err, "invalid derivation path length")
+}
+
+func TestCosmosSignAminoJSON(t *testing.T) {
+	key := mustGenerateKey(t)
+	device := newMockCosmosApp(key)
+	w := newMockAppWallet(t, device)
+
+	account, err := w.Derive(gethaccounts.DefaultBaseDerivationPath, true)
+	require.NoError(t, err)
+
+	signDoc := make([]byte, 600)
+	for i := range signDoc {
+		signDoc[i] = 'a' + byte(i%26)
+	}
+	sig, err := w.SignData(account, accounts.MimetypeAminoJSON, signDoc)
+	require.NoError(t, err)
+	require.Len(t, sig, 64)
+
+	// The signature must be normalized and verify against the keccak256 hash
+	require.True(t, crypto.VerifySignature(crypto.CompressPubkey(&key.PublicKey), crypto.Keccak256(signDoc), sig))
+
+	// The sign doc is streamed after the path in chunks of 250 bytes
+	var p1s []byte
+	for _, apdu := range device.history {
+		if apdu.ins == byte(cosmosOpSignSECP256K1) {
+			p1s = append(p1s, apdu.p1)
+		}
+	}
+	require.Equal(t, []byte{0, 1, 1, 2}, p1s)
+}
+
+func TestCosmosSignAminoJSONSignerMismatch(t *testing.T) {
+	device := newMockCosmosApp(mustGenerateKey(t))
+	w := newMockAppWallet(t, device)
+
+	account, err := w.Derive(gethaccounts.DefaultBaseDerivationPath, true)
+	require.NoError(t, err)
+
+	// A device signing with a different key than it derived must be caught
+	device.handler = newMockCosmosApp(mustGenerateKey(t)).handler
+
+	_, err = w.SignData(account, accounts.MimetypeAminoJSON, []byte(`{"msgs":[]}`))
+	require.ErrorContains(t, err, "signer mismatch")
+}
@@ -18,12 +18,15 @@ package usbwallet
 
 import (
 	"errors"
+	"fmt"
+	"io"
 	"runtime"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/evmos/ethereum-ledger-go/accounts"
 	usb "github.com/zondax/hid"
 )
@@ -50,6 +53,16 @@ type Hub struct {
 
 	refreshed time.Time         // Time instance when the list of wallets was last refreshed
 	wallets   []accounts.Wallet // List of USB wallet devices currently tracking
+	simulated bool              // Whether the wallets are in-process devices instead of USB ones
+
+	nameResolver  TrustedNameResolver         // Optional resolver for recipient names displayed on the devices
+	metaProvider  TransactionMetadataProvider // Optional provider of clear signing metadata for calldata
+	typedProvider TypedDataMetadataProvider   // Optional provider of clear signing metadata for EIP-712 messages
+	cosmosHRP     string                      // Bech32 human readable part for Cosmos app addresses
+
+	updateFeed  event.Feed              // Event feed to notify wallet additions/removals
+	updateScope event.SubscriptionScope // Subscription scope tracking current live listeners
+	updating    bool                    // Whether the event notification loop is running
 
 	quit chan chan error
 
@@ -85,7 +98,28 @@ func NewLedgerHub() (*Hub, error) {
 		0x4011, /* HID + WebUSB Ledger Nano X */
 		0x5011, /* HID + WebUSB Ledger Nano S Plus */
 		0x6011, /* HID + WebUSB Ledger Nano FTS */
-	}, 0xffa0, 0, newLedgerDriver)
+	}, 0xffa0, 0, newAppDriver)
+}
+
+// NewSimulatedHub creates a hardware wallet manager tracking the given in-process
+// devices (e.g. simulator.Device) instead of the attached USB ones. The devices
+// are never enumerated again, so the list of wallets doesn't change.
+func NewSimulatedHub(devices ...io.ReadWriteCloser) *Hub {
+	hub := &Hub{
+		scheme:     LedgerScheme,
+		makeDriver: newAppDriver,
+		simulated:  true,
+		quit:       make(chan chan error),
+	}
+	for i, device := range devices {
+		hub.wallets = append(hub.wallets, &wallet{
+			hub:    hub,
+			driver: hub.makeDriver(),
+			url:    &gethaccounts.URL{Scheme: hub.scheme, Path: fmt.Sprintf("simulator/%d", i)},
+			dial:   func() (io.ReadWriteCloser, error) { return device, nil },
+		})
+	}
+	return hub
 }
 
 // newHub creates a new hardware wallet manager for generic USB devices.
@@ -120,9 +154,107 @@ func (hub *Hub) Wallets() []accounts.Wallet {
 	return cpy
 }
 
+// Subscribe implements accounts.Backend, creating an async subscription to receive
+// notifications on the addition or removal of USB wallets.
+func (hub *Hub) Subscribe(sink chan<- accounts.WalletEvent) event.Subscription {
+	// We need the mutex to reliably start/stop the update loop
+	hub.stateLock.Lock()
+	defer hub.stateLock.Unlock()
+
+	// Subscribe the caller and track the subscriber count
+	sub := hub.updateScope.Track(hub.updateFeed.Subscribe(sink))
+
+	// Subscribers require an active notification loop, start it
+	if !hub.updating {
+		hub.updating = true
+		go hub.updater()
+	}
+	return sub
+}
+
+// updater is responsible for maintaining an up-to-date list of wallets managed
+// by the USB hub, and for firing wallet addition/removal events.
+func (hub *Hub) updater() {
+	for {
+		// TODO: Wait for a USB hotplug event (not supported yet) or a refresh timeout
+		// <-hub.changes
+		time.Sleep(refreshCycle)
+
+		// Run the wallet refresher
+		hub.refreshWallets()
+
+		// If all our subscribers left, stop the updater
+		hub.stateLock.Lock()
+		if hub.updateScope.Count() == 0 {
+			hub.updating = false
+			hub.stateLock.Unlock()
+			return
+		}
+		hub.stateLock.Unlock()
+	}
+}
+
+// SetTrustedNameResolver configures the resolver used by all the hub's wallets
+// to look up the recipients of transactions before signing them, so the device
+// can display their trusted name (e.g. an ENS domain) instead of the address.
+// A nil resolver disables the lookups.
+func (hub *Hub) SetTrustedNameResolver(resolver TrustedNameResolver) {
+	hub.stateLock.Lock()
+	defer hub.stateLock.Unlock()
+
+	hub.nameResolver = resolver
+}
+
+// SetTransactionMetadataProvider configures the provider used by all the hub's
+// wallets to look up clear signing metadata of transactions before signing them,
+// so the device can display their calldata in a human readable form. A nil
+// provider disables the lookups, falling back to blind signing.
+func (hub *Hub) SetTransactionMetadataProvider(provider TransactionMetadataProvider) {
+	hub.stateLock.Lock()
+	defer hub.stateLock.Unlock()
+
+	hub.metaProvider = provider
+}
+
+// SetTypedDataMetadataProvider configures the provider used by all the hub's
+// wallets to look up clear signing metadata of EIP-712 messages before signing
+// them, so the device can display their fields instead of the message hashes.
+// A nil provider disables the lookups.
+func (hub *Hub) SetTypedDataMetadataProvider(provider TypedDataMetadataProvider) {
+	hub.stateLock.Lock()
+	defer hub.stateLock.Unlock()
+
+	hub.typedProvider = provider
+}
+
+// SetCosmosHRP configures the bech32 human readable part the Cosmos app derives
+// addresses with, taking effect for wallets opened afterwards. An empty prefix
+// reverts to DefaultCosmosHRP.
+func (hub *Hub) SetCosmosHRP(hrp string) {
+	hub.stateLock.Lock()
+	defer hub.stateLock.Unlock()
+
+	hub.cosmosHRP = hrp
+}
+
+// cosmosPrefix returns the bech32 human readable part for Cosmos app addresses.
+func (hub *Hub) cosmosPrefix() string {
+	hub.stateLock.RLock()
+	defer hub.stateLock.RUnlock()
+
+	if hub.cosmosHRP == "" {
+		return DefaultCosmosHRP
+	}
+	return hub.cosmosHRP
+}
+
 // refreshWallets scans the USB devices attached to the machine and updates the
 // list of wallets based on the found devices.
 func (hub *Hub) refreshWallets() {
+	// Simulated devices are fixed, there's nothing to scan
+	if hub.simulated {
+		return
+	}
 	// Don't scan the USB like crazy it the user fetches wallets in a loop
 	hub.stateLock.RLock()
 	elapsed := time.Since(hub.refreshed)
@@ -181,7 +313,10 @@ func (hub *Hub) refreshWallets() {
 	// Transform the current list of wallets into the new one
 	hub.stateLock.Lock()
 
-	wallets := make([]accounts.Wallet, 0, len(devices))
+	var (
+		wallets = make([]accounts.Wallet, 0, len(devices))
+		events  []accounts.WalletEvent
+	)
 
 	for _, device := range devices {
 		url := gethaccounts.URL{
@@ -197,6 +332,7 @@ func (hub *Hub) refreshWallets() {
 				break
 			}
 			// Drop the stale and failed devices
+			events = append(events, accounts.WalletEvent{Wallet: hub.wallets[0], Kind: gethaccounts.WalletDropped})
 			hub.wallets = hub.wallets[1:]
 		}
 
@@ -209,6 +345,7 @@ func (hub *Hub) refreshWallets() {
 				info:   device,
 			}
 
+			events = append(events, accounts.WalletEvent{Wallet: wallet, Kind: gethaccounts.WalletArrived})
 			wallets = append(wallets, wallet)
 			continue
 		}
@@ -220,7 +357,16 @@ func (hub *Hub) refreshWallets() {
 		}
 	}
 
+	// Drop any leftover wallets and set the new batch
+	for _, wallet := range hub.wallets {
+		events = append(events, accounts.WalletEvent{Wallet: wallet, Kind: gethaccounts.WalletDropped})
+	}
 	hub.refreshed = time.Now()
 	hub.wallets = wallets
 	hub.stateLock.Unlock()
+
+	// Fire all wallet events and return
+	for _, event := range events {
+		hub.updateFeed.Send(event)
+	}
 }
@@ -24,16 +24,25 @@ import (
 	"crypto/ecdsa"
 	"encoding/binary"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 
 	gethaccounts "github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
 	coretypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/evmos/ethereum-ledger-go/accounts"
 )
 
 // ledgerOpcode is an enumeration encoding the supported Ledger opcodes.
@@ -47,17 +56,61 @@ type ledgerParam1 byte
 // specific opcodes. The same parameter values may be reused between opcodes.
 type ledgerParam2 byte
 
+// ledgerClaEthereum is the instruction class of the Ethereum app's APDUs.
+const ledgerClaEthereum = 0xe0
+
 const (
 	ledgerOpRetrieveAddress  ledgerOpcode = 0x02 // Returns the public key and Ethereum address for a given BIP 32 path
 	ledgerOpSignTransaction  ledgerOpcode = 0x04 // Signs an Ethereum transaction after having the user validate the parameters
 	ledgerOpGetConfiguration ledgerOpcode = 0x06 // Returns specific wallet application configuration
+	ledgerOpSignPersonalMsg  ledgerOpcode = 0x08 // Signs an EIP-191 personal message after having the user validate it
 	ledgerOpSignTypedMessage ledgerOpcode = 0x0c // Signs an Ethereum message following the EIP 712 specification
+	ledgerOpEth2GetPublicKey ledgerOpcode = 0x0e // Returns the BLS12-381 public key for a given EIP-2334 path
+	ledgerOpEth2SetWithdraw  ledgerOpcode = 0x10 // Sets the withdrawal index used when generating deposit data
+	ledgerOpPrivacy          ledgerOpcode = 0x18 // Returns the X25519 public key or a shared secret for a given BIP 32 path
+	ledgerOpGetChallenge     ledgerOpcode = 0x20 // Returns a random challenge to be embedded into signed metadata
+	ledgerOpProvideName      ledgerOpcode = 0x22 // Provides a signed trusted name (ENS or other domain) for an address
+	ledgerOpProvideTxInfo    ledgerOpcode = 0x26 // Provides a signed clear signing descriptor of the next transaction
+	ledgerOpProvideTxField   ledgerOpcode = 0x28 // Provides a signed clear signing descriptor of a transaction field
+	ledgerOpEIP712StructDef  ledgerOpcode = 0x1a // Provides the definition of an EIP-712 struct type
+	ledgerOpEIP712StructImpl ledgerOpcode = 0x1c // Provides the values of an EIP-712 struct, field by field
+	ledgerOpEIP712Filtering  ledgerOpcode = 0x1e // Provides signed descriptors of how to display EIP-712 fields
+	ledgerOpSignAuthority    ledgerOpcode = 0x34 // Signs an EIP-7702 authorization after having the user validate it
 
 	ledgerP1DirectlyFetchAddress    ledgerParam1 = 0x00 // Return address directly from the wallet
+	ledgerP1ConfirmFetchAddress     ledgerParam1 = 0x01 // Display address and require confirmation before returning
+	ledgerP1DirectlyFetchPublicKey  ledgerParam1 = 0x00 // Return BLS public key directly from the wallet
+	ledgerP1ConfirmPrivacyOperation ledgerParam1 = 0x01 // Display the privacy operation and require confirmation
 	ledgerP1InitTypedMessageData    ledgerParam1 = 0x00 // First chunk of Typed Message data
 	ledgerP1InitTransactionData     ledgerParam1 = 0x00 // First transaction data block for signing
 	ledgerP1ContTransactionData     ledgerParam1 = 0x80 // Subsequent transaction data block for signing
+	ledgerP1InitPersonalMessage     ledgerParam1 = 0x00 // First personal message data block for signing
+	ledgerP1ContPersonalMessage     ledgerParam1 = 0x80 // Subsequent personal message data block for signing
+	ledgerP1InitMetadataChunk       ledgerParam1 = 0x01 // First chunk of a signed metadata payload
+	ledgerP1ContMetadataChunk       ledgerParam1 = 0x00 // Subsequent chunk of a signed metadata payload
+	ledgerP1InitAuthorizationData   ledgerParam1 = 0x01 // First chunk of an EIP-7702 authorization
+	ledgerP1ContAuthorizationData   ledgerParam1 = 0x00 // Subsequent chunk of an EIP-7702 authorization
 	ledgerP2DiscardAddressChainCode ledgerParam2 = 0x00 // Do not return the chain code along with the address
+	ledgerP2ProcessAndSign          ledgerParam2 = 0x00 // Display and sign the transaction as soon as it's streamed
+	ledgerP2StoreTransaction        ledgerParam2 = 0x01 // Only store the streamed transaction, awaiting its metadata
+	ledgerP2StartSigningFlow        ledgerParam2 = 0x02 // Display and sign the previously stored transaction
+	ledgerP2PrivacyPublicKey        ledgerParam2 = 0x01 // Return the X25519 public encryption key
+	ledgerP2PrivacySharedSecret     ledgerParam2 = 0x02 // Return the X25519 shared secret with a peer key
+	ledgerP2TypedMessageHashes      ledgerParam2 = 0x00 // Sign the domain and message hashes of an EIP-712 message
+	ledgerP2TypedMessageStreamed    ledgerParam2 = 0x01 // Sign the previously streamed EIP-712 message
+	ledgerP1CompleteValue           ledgerParam1 = 0x00 // Last chunk of an EIP-712 struct value
+	ledgerP1PartialValue            ledgerParam1 = 0x01 // Non-last chunk of an EIP-712 struct value
+	ledgerP2StructName              ledgerParam2 = 0x00 // Name of an EIP-712 struct type being defined
+	ledgerP2StructField             ledgerParam2 = 0xff // Field of the EIP-712 struct type being defined
+	ledgerP2RootStruct              ledgerParam2 = 0x00 // Name of the EIP-712 root struct being streamed
+	ledgerP2ArrayLength             ledgerParam2 = 0x0f // Length of the EIP-712 array being streamed
+	ledgerP2FieldValue              ledgerParam2 = 0xff // Value of the EIP-712 field being streamed
+	ledgerP2FilterActivate          ledgerParam2 = 0x00 // Activate the display of described EIP-712 fields only
+	ledgerP2FilterMessageInfo       ledgerParam2 = 0x0f // Descriptor of the EIP-712 message
+	ledgerP2FilterDateTime          ledgerParam2 = 0xfc // Descriptor of the next EIP-712 field, displayed as a date
+	ledgerP2FilterAmountToken       ledgerParam2 = 0xfd // Descriptor of the next EIP-712 field, the token of an amount
+	ledgerP2FilterAmount            ledgerParam2 = 0xfe // Descriptor of the next EIP-712 field, an amount of a token
+	ledgerP2FilterRaw               ledgerParam2 = 0xff // Descriptor of the next EIP-712 field, displayed as is
 )
 
 // errLedgerReplyInvalidHeader is the error message returned by a Ledger data exchange
@@ -69,6 +122,62 @@ var errLedgerReplyInvalidHeader = errors.New("ledger: invalid reply header")
 // when a response does arrive, but it does not contain the expected data.
 var errLedgerInvalidVersionReply = errors.New("ledger: invalid version reply")
 
+// ledgerStatusOK is the status word appended by the Ledger to successful replies.
+const ledgerStatusOK = 0x9000
+
+// StatusError is the error returned by a Ledger data exchange if the device
+// answers with a status word other than success.
+type StatusError struct {
+	Code uint16 // Status word returned by the device
+}
+
+// Error implements error, describing the status word if it is a well known one.
+func (e *StatusError) Error() string {
+	if desc, ok := ledgerStatusDescriptions[e.Code]; ok {
+		return fmt.Sprintf("ledger: %s (0x%04x)", desc, e.Code)
+	}
+	return fmt.Sprintf("ledger: unexpected status word 0x%04x", e.Code)
+}
+
+// Is allows matching status errors against the predefined ones via errors.Is.
+func (e *StatusError) Is(target error) bool {
+	t, ok := target.(*StatusError)
+	return ok && t.Code == e.Code
+}
+
+var (
+	// ErrLedgerLocked is returned if the device is locked with its PIN.
+	ErrLedgerLocked = &StatusError{Code: 0x5515}
+
+	// ErrLedgerUserRejected is returned if the user denied the request on the device.
+	ErrLedgerUserRejected = &StatusError{Code: 0x6985}
+
+	// ErrLedgerInvalidData is returned if the device rejected the request payload.
+	ErrLedgerInvalidData = &StatusError{Code: 0x6a80}
+
+	// ErrLedgerInstructionNotSupported is returned if the running app does not
+	// know the requested instruction.
+	ErrLedgerInstructionNotSupported = &StatusError{Code: 0x6d00}
+
+	// ErrLedgerClassNotSupported is returned if the running app does not accept
+	// the requested instruction class, usually because another app is open.
+	ErrLedgerClassNotSupported = &StatusError{Code: 0x6e00}
+)
+
+// ledgerStatusDescriptions maps the well known status words to human readable
+// descriptions.
+var ledgerStatusDescriptions = map[uint16]string{
+	0x5515: "device is locked",
+	0x6982: "security status not satisfied",
+	0x6985: "request denied by the user",
+	0x6986: "command not allowed",
+	0x6a80: "invalid data",
+	0x6a84: "not enough memory space",
+	0x6b00: "incorrect parameters",
+	0x6d00: "instruction not supported",
+	0x6e00: "class not supported",
+}
+
 // ledgerDriver implements the communication with a Ledger hardware wallet.
 type ledgerDriver struct {
 	device  io.ReadWriter // USB device connection to communicate through
@@ -110,7 +219,7 @@ func (w *ledgerDriver) offline() bool {
 func (w *ledgerDriver) Open(device io.ReadWriter, passphrase string) error {
 	w.device, w.failure = device, nil
 
-	_, _, err := w.ledgerDerive(gethaccounts.DefaultBaseDerivationPath)
+	_, _, err := w.ledgerDerive(gethaccounts.DefaultBaseDerivationPath, ledgerP1DirectlyFetchAddress)
 	if err != nil {
 		// Ethereum app is not running or in browser mode, nothing more to do, return
 		if err == errLedgerReplyInvalidHeader {
@@ -135,7 +244,9 @@ func (w *ledgerDriver) Close() error {
 // Heartbeat implements usbwallet.driver, performing a sanity check against the
 // Ledger to see if it's still online.
 func (w *ledgerDriver) Heartbeat() error {
-	if _, err := w.ledgerVersion(); err != nil && err != errLedgerInvalidVersionReply {
+	// Any reply, even one carrying an error status, means the device is alive
+	var status *StatusError
+	if _, err := w.ledgerVersion(); err != nil && err != errLedgerInvalidVersionReply && !errors.As(err, &status) {
 		w.failure = err
 		return err
 	}
@@ -145,7 +256,13 @@ func (w *ledgerDriver) Heartbeat() error {
 // Derive implements usbwallet.driver, sending a derivation request to the Ledger
 // and returning the Ethereum address located on that derivation path.
 func (w *ledgerDriver) Derive(path gethaccounts.DerivationPath) (common.Address, *ecdsa.PublicKey, error) {
-	return w.ledgerDerive(path)
+	return w.ledgerDerive(path, ledgerP1DirectlyFetchAddress)
+}
+
+// ConfirmAddress implements usbwallet.driver, sending a derivation request to the
+// Ledger which displays the address and waits for the user to confirm it.
+func (w *ledgerDriver) ConfirmAddress(path gethaccounts.DerivationPath) (common.Address, *ecdsa.PublicKey, error) {
+	return w.ledgerDerive(path, ledgerP1ConfirmFetchAddress)
 }
 
 // SignTx implements usbwallet.driver, sending the transaction to the Ledger and
@@ -154,7 +271,7 @@ func (w *ledgerDriver) Derive(path gethaccounts.DerivationPath) (common.Address,
 // Note, if the version of the Ethereum application running on the Ledger wallet is
 // too old to sign EIP-155 transactions, but such is requested nonetheless, an error
 // will be returned opposed to silently signing in Homestead mode.
-func (w *ledgerDriver) SignTx(path gethaccounts.DerivationPath, tx *coretypes.Transaction, chainID *big.Int) (common.Address, []byte, error) {
+func (w *ledgerDriver) SignTx(path gethaccounts.DerivationPath, tx *coretypes.Transaction, chainID *big.Int, meta *TransactionMetadata) (common.Address, []byte, error) {
 	// If the Ethereum app doesn't run, abort
 	if w.offline() {
 		return common.Address{}, nil, gethaccounts.ErrWalletClosed
@@ -166,12 +283,17 @@ func (w *ledgerDriver) SignTx(path gethaccounts.DerivationPath, tx *coretypes.Tr
 	}
 
 	// Allow chainID of zero to default to nil
-	if chainID.Cmp(common.Big0) == 0 {
+	if chainID != nil && chainID.Sign() == 0 {
 		chainID = nil
 	}
+	// Typed transactions embed the chain ID, which must match the requested one if
+	// set (unset ones are filled in on signing, like go-ethereum's signers do)
+	if tx.Type() != coretypes.LegacyTxType && (chainID == nil || (tx.ChainId().Sign() != 0 && tx.ChainId().Cmp(chainID) != 0)) {
+		return common.Address{}, nil, fmt.Errorf("chain ID mismatch: transaction has %v, requested %v", tx.ChainId(), chainID)
+	}
 
 	// All infos gathered and metadata checks out, request signing
-	return w.ledgerSign(path, tx, chainID)
+	return w.ledgerSign(path, tx, chainID, meta)
 }
 
 // SignTypedMessage implements usbwallet.driver, sending the message to the Ledger and
@@ -192,6 +314,111 @@ func (w *ledgerDriver) SignTypedMessage(path gethaccounts.DerivationPath, domain
 	return w.ledgerSignTypedMessage(path, domainHash, messageHash)
 }
 
+// SignTypedData implements usbwallet.driver, streaming the EIP-712 message and
+// the descriptors of its fields to the Ledger and waiting for the user to confirm
+// or deny signing it.
+//
+// Note: this was introduced in the ledger 1.10.0 firmware, older apps return an
+// accounts.ErrNotSupported error so the message hashes can be signed instead.
+func (w *ledgerDriver) SignTypedData(path gethaccounts.DerivationPath, typedData apitypes.TypedData, meta *TypedDataMetadata) ([]byte, error) {
+	// If the Ethereum app doesn't run, abort
+	if w.offline() {
+		return nil, gethaccounts.ErrWalletClosed
+	}
+	// Ensure the app can display the fields of streamed messages
+	if w.version[0] < 1 || (w.version[0] == 1 && w.version[1] < 10) {
+		return nil, gethaccounts.ErrNotSupported
+	}
+	return w.ledgerSignTypedData(path, typedData, meta)
+}
+
+// SignPersonalMessage implements usbwallet.driver, sending the message to the
+// Ledger and waiting for the user to confirm or deny signing it.
+func (w *ledgerDriver) SignPersonalMessage(path gethaccounts.DerivationPath, message []byte) ([]byte, error) {
+	// If the Ethereum app doesn't run, abort
+	if w.offline() {
+		return nil, gethaccounts.ErrWalletClosed
+	}
+	return w.ledgerSignPersonalMessage(path, message)
+}
+
+// SignAminoJSON implements usbwallet.driver, however the Ethereum app can't sign
+// Cosmos SDK sign docs directly (they need to be wrapped into EIP-712), so this
+// method will always return an error.
+func (w *ledgerDriver) SignAminoJSON(gethaccounts.DerivationPath, []byte) ([]byte, error) {
+	return nil, gethaccounts.ErrNotSupported
+}
+
+// SignAuthorization implements usbwallet.driver, sending the EIP-7702 authorization
+// to the Ledger and waiting for the user to confirm or deny the delegation.
+func (w *ledgerDriver) SignAuthorization(path gethaccounts.DerivationPath, auth coretypes.SetCodeAuthorization) ([]byte, error) {
+	// If the Ethereum app doesn't run, abort
+	if w.offline() {
+		return nil, gethaccounts.ErrWalletClosed
+	}
+	return w.ledgerSignAuthorization(path, auth)
+}
+
+// Eth2PublicKey implements usbwallet.driver, retrieving the BLS12-381 public key
+// at the given EIP-2334 derivation path.
+func (w *ledgerDriver) Eth2PublicKey(path gethaccounts.DerivationPath) (accounts.BLSPublicKey, error) {
+	// If the Ethereum app doesn't run, abort
+	if w.offline() {
+		return accounts.BLSPublicKey{}, gethaccounts.ErrWalletClosed
+	}
+	return w.ledgerEth2PublicKey(path)
+}
+
+// SetEth2WithdrawalIndex implements usbwallet.driver, setting the withdrawal key
+// index used by the Ledger when generating deposit data.
+func (w *ledgerDriver) SetEth2WithdrawalIndex(index uint32) error {
+	// If the Ethereum app doesn't run, abort
+	if w.offline() {
+		return gethaccounts.ErrWalletClosed
+	}
+	return w.ledgerEth2SetWithdrawalIndex(index)
+}
+
+// EncryptionPublicKey implements usbwallet.driver, retrieving the X25519 public
+// encryption key of the account at the given derivation path.
+func (w *ledgerDriver) EncryptionPublicKey(path gethaccounts.DerivationPath) ([32]byte, error) {
+	// If the Ethereum app doesn't run, abort
+	if w.offline() {
+		return [32]byte{}, gethaccounts.ErrWalletClosed
+	}
+	return w.ledgerPrivacy(path, nil)
+}
+
+// SharedSecret implements usbwallet.driver, computing the X25519 shared secret of
+// the account at the given derivation path with the peer public key.
+func (w *ledgerDriver) SharedSecret(path gethaccounts.DerivationPath, peer [32]byte) ([32]byte, error) {
+	// If the Ethereum app doesn't run, abort
+	if w.offline() {
+		return [32]byte{}, gethaccounts.ErrWalletClosed
+	}
+	return w.ledgerPrivacy(path, peer[:])
+}
+
+// Challenge implements usbwallet.driver, retrieving a fresh challenge from the
+// Ledger to be embedded into the next signed metadata payload.
+func (w *ledgerDriver) Challenge() (uint32, error) {
+	// If the Ethereum app doesn't run, abort
+	if w.offline() {
+		return 0, gethaccounts.ErrWalletClosed
+	}
+	return w.ledgerChallenge()
+}
+
+// ProvideTrustedName implements usbwallet.driver, sending a signed trusted name
+// payload to the Ledger to be displayed in place of the matching address.
+func (w *ledgerDriver) ProvideTrustedName(payload []byte) error {
+	// If the Ethereum app doesn't run, abort
+	if w.offline() {
+		return gethaccounts.ErrWalletClosed
+	}
+	return w.ledgerProvideMetadata(ledgerOpProvideName, payload)
+}
+
 // ledgerVersion retrieves the current version of the Ethereum wallet app running
 // on the Ledger wallet.
 //
@@ -255,7 +482,7 @@ func (w *ledgerDriver) ledgerVersion() ([3]byte, error) {
 //	Ethereum address length | 1 byte
 //	Ethereum address        | 40 bytes hex ascii
 //	Chain code if requested | 32 bytes
-func (w *ledgerDriver) ledgerDerive(derivationPath gethaccounts.DerivationPath) (common.Address, *ecdsa.PublicKey, error) {
+func (w *ledgerDriver) ledgerDerive(derivationPath gethaccounts.DerivationPath, mode ledgerParam1) (common.Address, *ecdsa.PublicKey, error) {
 	// Flatten the derivation path into the Ledger request
 	path := make([]byte, 1+4*len(derivationPath))
 	path[0] = byte(len(derivationPath))
@@ -264,7 +491,7 @@ func (w *ledgerDriver) ledgerDerive(derivationPath gethaccounts.DerivationPath)
 	}
 
 	// Send the request and wait for the response
-	reply, err := w.ledgerExchange(ledgerOpRetrieveAddress, ledgerP1DirectlyFetchAddress, ledgerP2DiscardAddressChainCode, path)
+	reply, err := w.ledgerExchange(ledgerOpRetrieveAddress, mode, ledgerP2DiscardAddressChainCode, path)
 	if err != nil {
 		return common.Address{}, nil, err
 	}
@@ -339,7 +566,12 @@ func (w *ledgerDriver) ledgerDerive(derivationPath gethaccounts.DerivationPath)
 //	signature V | 1 byte
 //	signature R | 32 bytes
 //	signature S | 32 bytes
-func (w *ledgerDriver) ledgerSign(derivationPath gethaccounts.DerivationPath, tx *coretypes.Transaction, chainID *big.Int) (common.Address, []byte, error) {
+//
+// If clear signing metadata is available for the transaction, it is streamed
+// with P2 set to 01 so the device only stores it. The metadata descriptors are
+// sent afterwards, and a final empty request with P2 set to 02 starts the
+// confirmation flow, returning the signature.
+func (w *ledgerDriver) ledgerSign(derivationPath gethaccounts.DerivationPath, tx *coretypes.Transaction, chainID *big.Int, meta *TransactionMetadata) (common.Address, []byte, error) {
 	// Flatten the derivation path into the Ledger request
 	path := make([]byte, 1+4*len(derivationPath))
 	path[0] = byte(len(derivationPath))
@@ -354,10 +586,12 @@ func (w *ledgerDriver) ledgerSign(derivationPath gethaccounts.DerivationPath, tx
 		err    error
 	)
 
-	// TODO: why can't we just use tx.MarshalBinary() instead?
-	if chainID == nil {
+	switch {
+	case tx.Type() != coretypes.LegacyTxType:
+		txRLP, err = ledgerTypedTxPayload(tx, chainID)
+	case chainID == nil:
 		txRLP, err = rlp.EncodeToBytes([]interface{}{tx.Nonce(), tx.GasPrice(), tx.Gas(), tx.To(), tx.Value(), tx.Data()})
-	} else {
+	default:
 		txRLP, err = rlp.EncodeToBytes([]interface{}{tx.Nonce(), tx.GasPrice(), tx.Gas(), tx.To(), tx.Value(), tx.Data(), chainID, big.NewInt(0), big.NewInt(0)})
 	}
 
@@ -370,9 +604,12 @@ func (w *ledgerDriver) ledgerSign(derivationPath gethaccounts.DerivationPath, tx
 	// Send the request and wait for the response
 	var (
 		op    = ledgerP1InitTransactionData
+		mode  = ledgerP2ProcessAndSign
 		reply []byte
 	)
-
+	if meta != nil {
+		mode = ledgerP2StoreTransaction
+	}
 	for len(payload) > 0 {
 		// Calculate the size of the next data chunk
 		chunk := 255
@@ -381,7 +618,7 @@ func (w *ledgerDriver) ledgerSign(derivationPath gethaccounts.DerivationPath, tx
 		}
 
 		// Send the chunk over, ensuring it's processed correctly
-		reply, err = w.ledgerExchange(ledgerOpSignTransaction, op, 0, payload[:chunk])
+		reply, err = w.ledgerExchange(ledgerOpSignTransaction, op, mode, payload[:chunk])
 		if err != nil {
 			return common.Address{}, nil, err
 		}
@@ -390,6 +627,20 @@ func (w *ledgerDriver) ledgerSign(derivationPath gethaccounts.DerivationPath, tx
 		payload = payload[chunk:]
 		op = ledgerP1ContTransactionData
 	}
+	// If the transaction was only stored, describe it and start the signing flow
+	if meta != nil {
+		if err = w.ledgerProvideMetadata(ledgerOpProvideTxInfo, meta.Info); err != nil {
+			return common.Address{}, nil, err
+		}
+		for _, field := range meta.Fields {
+			if err = w.ledgerProvideMetadata(ledgerOpProvideTxField, field); err != nil {
+				return common.Address{}, nil, err
+			}
+		}
+		if reply, err = w.ledgerExchange(ledgerOpSignTransaction, ledgerP1InitTransactionData, ledgerP2StartSigningFlow, nil); err != nil {
+			return common.Address{}, nil, err
+		}
+	}
 
 	// Extract the Ethereum signature and do a sanity validation
 	if len(reply) != crypto.SignatureLength {
@@ -398,22 +649,34 @@ func (w *ledgerDriver) ledgerSign(derivationPath gethaccounts.DerivationPath, tx
 
 	signature := append(reply[1:], reply[0])
 
+	// Typed transactions carry the plain signature parity, let go-ethereum assemble them
+	if tx.Type() != coretypes.LegacyTxType {
+		return ledgerSignedTypedTx(tx, chainID, signature)
+	}
+
 	// Generate signature payload
 	r := signature[:crypto.DigestLength]
 	s := signature[crypto.DigestLength:crypto.RecoveryIDOffset]
 	v := signature[crypto.RecoveryIDOffset]
 
-	if sigRLP, err = rlp.EncodeToBytes([]interface{}{tx.Nonce(), tx.GasPrice(), tx.Gas(), tx.To(), tx.Value(), tx.Data(), v, r, s}); err != nil {
-		return common.Address{}, nil, err
-	}
-
-	// Return address from signature to be verified later
-	var recV byte
+	// The device only returns the lowest byte of EIP-155 V values, so recover the
+	// parity and rebuild the full value for large chain IDs
+	var (
+		recV  byte
+		fullV = new(big.Int)
+	)
 	if chainID == nil {
 		recV = v - byte(27)
+		fullV.SetUint64(27 + uint64(recV))
 	} else {
 		recV = v - byte(chainID.Uint64()*2+35)
+		fullV.Mul(chainID, big.NewInt(2)).Add(fullV, big.NewInt(35+int64(recV)))
 	}
+	if sigRLP, err = rlp.EncodeToBytes([]interface{}{tx.Nonce(), tx.GasPrice(), tx.Gas(), tx.To(), tx.Value(), tx.Data(), fullV, new(big.Int).SetBytes(r), new(big.Int).SetBytes(s)}); err != nil {
+		return common.Address{}, nil, err
+	}
+
+	// Return address from signature to be verified later
 
 	hash := crypto.Keccak256(txRLP)
 	sig := append(r[:], append(s[:], recV)...)
@@ -432,6 +695,170 @@ func (w *ledgerDriver) ledgerSign(derivationPath gethaccounts.DerivationPath, tx
 	return addr, sigRLP, nil
 }
 
+// ledgerTypedTxPayload creates the EIP-2718 signing payload of a typed transaction,
+// consisting of the transaction type followed by the RLP list of its fields (the
+// same payload go-ethereum's signers hash).
+func ledgerTypedTxPayload(tx *coretypes.Transaction, chainID *big.Int) ([]byte, error) {
+	var fields []interface{}
+
+	switch tx.Type() {
+	case coretypes.AccessListTxType:
+		fields = []interface{}{chainID, tx.Nonce(), tx.GasPrice(), tx.Gas(), tx.To(), tx.Value(), tx.Data(), tx.AccessList()}
+	case coretypes.DynamicFeeTxType:
+		fields = []interface{}{chainID, tx.Nonce(), tx.GasTipCap(), tx.GasFeeCap(), tx.Gas(), tx.To(), tx.Value(), tx.Data(), tx.AccessList()}
+	case coretypes.BlobTxType:
+		fields = []interface{}{chainID, tx.Nonce(), tx.GasTipCap(), tx.GasFeeCap(), tx.Gas(), tx.To(), tx.Value(), tx.Data(), tx.AccessList(), tx.BlobGasFeeCap(), tx.BlobHashes()}
+	case coretypes.SetCodeTxType:
+		fields = []interface{}{chainID, tx.Nonce(), tx.GasTipCap(), tx.GasFeeCap(), tx.Gas(), tx.To(), tx.Value(), tx.Data(), tx.AccessList(), tx.SetCodeAuthorizations()}
+	default:
+		return nil, fmt.Errorf("unsupported transaction type %d", tx.Type())
+	}
+	payload, err := rlp.EncodeToBytes(fields)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{tx.Type()}, payload...), nil
+}
+
+// ledgerSignedTypedTx attaches a signature returned by the Ledger to a typed
+// transaction, returning the sender recovered from it and the binary encoding
+// of the signed transaction.
+func ledgerSignedTypedTx(tx *coretypes.Transaction, chainID *big.Int, signature []byte) (common.Address, []byte, error) {
+	// Older apps report the parity with the legacy offset applied, strip it
+	if signature[crypto.RecoveryIDOffset] >= 27 {
+		signature[crypto.RecoveryIDOffset] -= 27
+	}
+	signer := coretypes.LatestSignerForChainID(chainID)
+
+	signed, err := tx.WithSignature(signer, signature)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	sender, err := coretypes.Sender(signer, signed)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	raw, err := signed.MarshalBinary()
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	return sender, raw, nil
+}
+
+// ledgerSignAuthorization sends an EIP-7702 authorization to the Ledger wallet,
+// and waits for the user to confirm or deny delegating the account's code.
+//
+// The authorization signing protocol is defined as follows:
+//
+//	CLA | INS | P1 | P2 | Lc  | Le
+//	----+-----+----+----+-----+---
+//	 E0 | 34  | 01: first authorization data block
+//	            00: subsequent authorization data block
+//	               | 00 | variable | variable
+//
+// Where the input for the first authorization block (first 255 bytes) is:
+//
+//	Description                                      | Length
+//	-------------------------------------------------+----------
+//	Number of BIP 32 derivations to perform (max 10) | 1 byte
+//	First derivation index (big endian)              | 4 bytes
+//	...                                              | 4 bytes
+//	Last derivation index (big endian)               | 4 bytes
+//	Authorization length (big endian)                | 2 bytes
+//	Authorization TLV chunk                          | arbitrary
+//
+// And the input for subsequent authorization blocks (first 255 bytes) are:
+//
+//	Description             | Length
+//	------------------------+----------
+//	Authorization TLV chunk | arbitrary
+//
+// The authorization is encoded as a list of tag, length and value entries:
+//
+//	Tag | Description           | Length
+//	----+-----------------------+------------
+//	 00 | Structure version (1) | 1 byte
+//	 01 | Delegate address      | 20 bytes
+//	 02 | Chain ID (big endian) | 1-8 bytes
+//	 03 | Nonce (big endian)    | 1-8 bytes
+//
+// And the output data is:
+//
+//	Description | Length
+//	------------+---------
+//	signature V | 1 byte
+//	signature R | 32 bytes
+//	signature S | 32 bytes
+//
+// Apps predating EIP-7702 reject the instruction, in which case the error
+// gethaccounts.ErrNotSupported is returned.
+func (w *ledgerDriver) ledgerSignAuthorization(derivationPath gethaccounts.DerivationPath, auth coretypes.SetCodeAuthorization) ([]byte, error) {
+	if !auth.ChainID.IsUint64() {
+		return nil, fmt.Errorf("authorization chain ID too large: %v", &auth.ChainID)
+	}
+	// Flatten the derivation path into the Ledger request
+	path := make([]byte, 1+4*len(derivationPath))
+	path[0] = byte(len(derivationPath))
+	for i, component := range derivationPath {
+		binary.BigEndian.PutUint32(path[1+4*i:], component)
+	}
+	// Encode the authorization fields and prefix them with their length
+	var tlv []byte
+	tlv = append(tlv, 0x00, 1, 1)
+	tlv = append(tlv, 0x01, common.AddressLength)
+	tlv = append(tlv, auth.Address.Bytes()...)
+	tlv = appendUintTLV(tlv, 0x02, auth.ChainID.Uint64())
+	tlv = appendUintTLV(tlv, 0x03, auth.Nonce)
+
+	payload := binary.BigEndian.AppendUint16(path, uint16(len(tlv)))
+	payload = append(payload, tlv...)
+
+	// Send the request and wait for the response
+	var (
+		op    = ledgerP1InitAuthorizationData
+		reply []byte
+		err   error
+	)
+	for len(payload) > 0 {
+		// Calculate the size of the next data chunk
+		chunk := 255
+		if chunk > len(payload) {
+			chunk = len(payload)
+		}
+		// Send the chunk over, ensuring it's processed correctly
+		reply, err = w.ledgerExchange(ledgerOpSignAuthority, op, 0, payload[:chunk])
+		if err != nil {
+			if errors.Is(err, ErrLedgerInstructionNotSupported) {
+				return nil, gethaccounts.ErrNotSupported
+			}
+			return nil, err
+		}
+		// Shift the payload and ensure subsequent chunks are marked as such
+		payload = payload[chunk:]
+		op = ledgerP1ContAuthorizationData
+	}
+	// Extract the Ethereum signature and do a sanity validation
+	if len(reply) != crypto.SignatureLength {
+		return nil, errors.New("reply lacks signature")
+	}
+	signature := append(reply[1:], reply[0])
+	if signature[crypto.RecoveryIDOffset] >= 27 {
+		signature[crypto.RecoveryIDOffset] -= 27
+	}
+	return signature, nil
+}
+
+// appendUintTLV appends a TLV entry holding an integer in its shortest big endian
+// encoding, keeping at least one byte for zero.
+func appendUintTLV(tlv []byte, tag byte, value uint64) []byte {
+	encoded := binary.BigEndian.AppendUint64(nil, value)
+	for len(encoded) > 1 && encoded[0] == 0 {
+		encoded = encoded[1:]
+	}
+	tlv = append(tlv, tag, byte(len(encoded)))
+	return append(tlv, encoded...)
+}
+
 // ledgerSignTypedMessage sends the transaction to the Ledger wallet, and waits for the user
 // to confirm or deny the transaction.
 //
@@ -478,7 +905,7 @@ func (w *ledgerDriver) ledgerSignTypedMessage(derivationPath gethaccounts.Deriva
 	)
 
 	// Send the message over, ensuring it's processed correctly
-	reply, err = w.ledgerExchange(ledgerOpSignTypedMessage, op, 0, payload)
+	reply, err = w.ledgerExchange(ledgerOpSignTypedMessage, op, ledgerP2TypedMessageHashes, payload)
 	if err != nil {
 		return nil, err
 	}
@@ -492,8 +919,701 @@ func (w *ledgerDriver) ledgerSignTypedMessage(derivationPath gethaccounts.Deriva
 	return signature, nil
 }
 
-// ledgerExchange performs a data exchange with the Ledger wallet, sending it a
-// message and retrieving the response.
+// ledgerSignTypedData streams an EIP-712 message to the Ledger wallet along with
+// the descriptors of its fields, and waits for the user to confirm or deny it.
+//
+// The struct types are defined first, each by its name followed by its fields:
+//
+//	CLA | INS | P1 | P2                 | Lc       | Le
+//	----+-----+----+--------------------+----------+---
+//	 E0 | 1A  | 00 | 00: struct name    | variable | 00
+//	                 FF: struct field
+//
+// Where the input for a struct field is:
+//
+//	Description                                           | Length
+//	------------------------------------------------------+----------
+//	Type (80: array, 40: sized, 0-7: custom to bytes)     | 1 byte
+//	Custom type name length and name (custom types only)  | variable
+//	Type size in bytes (sized types only)                 | 1 byte
+//	Array level count, each 00 or 01 and size (arrays)    | variable
+//	Field name length and name                            | variable
+//
+// Filtering is activated next, and the domain and message values are streamed
+// depth first: each root struct by its name, arrays by their length and values
+// prefixed with their 2 byte length, in chunks of 255 bytes:
+//
+//	CLA | INS | P1                 | P2               | Lc       | Le
+//	----+-----+--------------------+------------------+----------+---
+//	 E0 | 1C  | 00: complete value | 00: root struct  | variable | 00
+//	            01: partial value  | 0F: array length
+//	                               | FF: field value
+//
+// The message descriptor is provided between the domain and the message, and
+// every field descriptor right before the value of the field it describes:
+//
+//	CLA | INS | P1 | P2                   | Lc       | Le
+//	----+-----+----+----------------------+----------+---
+//	 E0 | 1E  | 00 | 00: activate         | variable | 00
+//	                 0F: message info
+//	                 FC: date time field
+//	                 FD: amount token field
+//	                 FE: amount value field
+//	                 FF: raw field
+//
+// Finally the message is signed with a typed message request with P2 set to 01,
+// the input being the derivation path only, and the output data is:
+//
+//	Description | Length
+//	------------+---------
+//	signature V | 1 byte
+//	signature R | 32 bytes
+//	signature S | 32 bytes
+func (w *ledgerDriver) ledgerSignTypedData(derivationPath gethaccounts.DerivationPath, typedData apitypes.TypedData, meta *TypedDataMetadata) ([]byte, error) {
+	// Define all the struct types, in a deterministic order
+	names := make([]string, 0, len(typedData.Types))
+	for name := range typedData.Types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, err := w.ledgerExchange(ledgerOpEIP712StructDef, 0, ledgerP2StructName, []byte(name)); err != nil {
+			return nil, err
+		}
+		for _, field := range typedData.Types[name] {
+			def, err := ledgerTypedDataFieldDef(typedData, field)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := w.ledgerExchange(ledgerOpEIP712StructDef, 0, ledgerP2StructField, def); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// Stream the domain, then the message with the descriptors of its fields
+	if len(meta.Info) > 255 {
+		return nil, fmt.Errorf("typed data descriptor too large: %d bytes", len(meta.Info))
+	}
+	if _, err := w.ledgerExchange(ledgerOpEIP712Filtering, 0, ledgerP2FilterActivate, nil); err != nil {
+		return nil, err
+	}
+	stream := &ledgerTypedDataStream{driver: w, typedData: typedData}
+	if err := stream.root("EIP712Domain", typedData.Domain.Map()); err != nil {
+		return nil, err
+	}
+	if _, err := w.ledgerExchange(ledgerOpEIP712Filtering, 0, ledgerP2FilterMessageInfo, meta.Info); err != nil {
+		return nil, err
+	}
+	stream.fields = make(map[string][]TypedDataField)
+	for _, field := range meta.Fields {
+		stream.fields[field.Path] = append(stream.fields[field.Path], field)
+	}
+	if err := stream.root(typedData.PrimaryType, typedData.Message); err != nil {
+		return nil, err
+	}
+	// Flatten the derivation path into the Ledger request
+	path := make([]byte, 1+4*len(derivationPath))
+	path[0] = byte(len(derivationPath))
+	for i, component := range derivationPath {
+		binary.BigEndian.PutUint32(path[1+4*i:], component)
+	}
+	reply, err := w.ledgerExchange(ledgerOpSignTypedMessage, ledgerP1InitTypedMessageData, ledgerP2TypedMessageStreamed, path)
+	if err != nil {
+		return nil, err
+	}
+	// Extract the Ethereum signature and do a sanity validation
+	if len(reply) != crypto.SignatureLength {
+		return nil, errors.New("reply lacks signature")
+	}
+	signature := append(reply[1:], reply[0])
+	return signature, nil
+}
+
+// ledgerTypedDataFilters maps the formats of described typed data fields to the
+// filtering instruction displaying them.
+var ledgerTypedDataFilters = map[TypedDataFieldFormat]ledgerParam2{
+	TypedDataFormatRaw:         ledgerP2FilterRaw,
+	TypedDataFormatDateTime:    ledgerP2FilterDateTime,
+	TypedDataFormatAmountToken: ledgerP2FilterAmountToken,
+	TypedDataFormatAmount:      ledgerP2FilterAmount,
+}
+
+// ledgerTypedDataStream streams the values of an EIP-712 struct to the Ledger,
+// providing the descriptors of the fields right before their values.
+type ledgerTypedDataStream struct {
+	driver    *ledgerDriver
+	typedData apitypes.TypedData
+	fields    map[string][]TypedDataField // Field descriptors keyed by their path
+}
+
+// root streams a root struct (the domain or the message) by its name, followed
+// by its fields.
+func (s *ledgerTypedDataStream) root(name string, data map[string]interface{}) error {
+	if _, err := s.driver.ledgerExchange(ledgerOpEIP712StructImpl, ledgerP1CompleteValue, ledgerP2RootStruct, []byte(name)); err != nil {
+		return err
+	}
+	return s.structure(name, data, "")
+}
+
+// structure streams the fields of a struct in their declaration order.
+func (s *ledgerTypedDataStream) structure(name string, data map[string]interface{}, path string) error {
+	for _, field := range s.typedData.Types[name] {
+		fieldPath := field.Name
+		if path != "" {
+			fieldPath = path + "." + field.Name
+		}
+		if err := s.value(field.Type, data[field.Name], fieldPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// value streams a single value, recursing into arrays and structs.
+func (s *ledgerTypedDataStream) value(typ string, value interface{}, path string) error {
+	// Arrays are streamed as their length followed by their elements
+	if strings.HasSuffix(typ, "]") {
+		items := reflect.ValueOf(value)
+		if items.Kind() != reflect.Slice && items.Kind() != reflect.Array {
+			return fmt.Errorf("invalid array value for typed data field %s", path)
+		}
+		if items.Len() > 255 {
+			return fmt.Errorf("typed data field %s too long: %d items", path, items.Len())
+		}
+		if _, err := s.driver.ledgerExchange(ledgerOpEIP712StructImpl, ledgerP1CompleteValue, ledgerP2ArrayLength, []byte{byte(items.Len())}); err != nil {
+			return err
+		}
+		elem := typ[:strings.LastIndex(typ, "[")]
+		for i := 0; i < items.Len(); i++ {
+			if err := s.value(elem, items.Index(i).Interface(), path+".[]"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	// Structs are streamed field by field
+	if _, ok := s.typedData.Types[typ]; ok {
+		data, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("invalid struct value for typed data field %s", path)
+		}
+		return s.structure(typ, data, path)
+	}
+	// Describe the field if known, then stream its value
+	for _, field := range s.fields[path] {
+		filter, ok := ledgerTypedDataFilters[field.Format]
+		if !ok {
+			return fmt.Errorf("unknown format %d of typed data field %s", field.Format, path)
+		}
+		if _, err := s.driver.ledgerExchange(ledgerOpEIP712Filtering, 0, filter, field.Descriptor); err != nil {
+			return err
+		}
+	}
+	encoded, err := ledgerTypedDataValue(typ, value)
+	if err != nil {
+		return fmt.Errorf("invalid typed data field %s: %w", path, err)
+	}
+	if len(encoded) > 0xffff {
+		return fmt.Errorf("typed data field %s too large: %d bytes", path, len(encoded))
+	}
+	data := binary.BigEndian.AppendUint16(nil, uint16(len(encoded)))
+	data = append(data, encoded...)
+
+	for len(data) > 0 {
+		// Calculate the size of the next data chunk, marking the last one complete
+		chunk, op := 255, ledgerP1PartialValue
+		if chunk >= len(data) {
+			chunk, op = len(data), ledgerP1CompleteValue
+		}
+		if _, err := s.driver.ledgerExchange(ledgerOpEIP712StructImpl, op, ledgerP2FieldValue, data[:chunk]); err != nil {
+			return err
+		}
+		data = data[chunk:]
+	}
+	return nil
+}
+
+// ledgerTypedDataFieldDef encodes the definition of an EIP-712 struct field.
+func ledgerTypedDataFieldDef(typedData apitypes.TypedData, field apitypes.Type) ([]byte, error) {
+	// Split off the array levels, outermost last
+	var (
+		base   = field.Type
+		levels []string
+	)
+	for strings.HasSuffix(base, "]") {
+		i := strings.LastIndex(base, "[")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid typed data type %q", field.Type)
+		}
+		levels = append([]string{base[i+1 : len(base)-1]}, levels...)
+		base = base[:i]
+	}
+	// Encode the base type along with its size, if any
+	var (
+		kind byte
+		size int
+		err  error
+	)
+	switch {
+	case typedData.Types[base] != nil:
+		kind = 0
+	case base == "address":
+		kind = 3
+	case base == "bool":
+		kind = 4
+	case base == "string":
+		kind = 5
+	case base == "bytes":
+		kind = 7
+	case strings.HasPrefix(base, "bytes"):
+		kind = 6
+		if size, err = strconv.Atoi(base[5:]); err != nil || size < 1 || size > 32 {
+			return nil, fmt.Errorf("invalid typed data type %q", field.Type)
+		}
+	case strings.HasPrefix(base, "uint"), strings.HasPrefix(base, "int"):
+		kind, size = 1, 32
+		if base[0] == 'u' {
+			kind = 2
+		}
+		if bits := strings.TrimPrefix(strings.TrimPrefix(base, "u"), "int"); bits != "" {
+			n, err := strconv.Atoi(bits)
+			if err != nil || n < 8 || n > 256 || n%8 != 0 {
+				return nil, fmt.Errorf("invalid typed data type %q", field.Type)
+			}
+			size = n / 8
+		}
+	default:
+		return nil, fmt.Errorf("undefined typed data type %q", field.Type)
+	}
+	def := []byte{kind}
+	if kind == 0 {
+		def = append(def, byte(len(base)))
+		def = append(def, base...)
+	}
+	if size > 0 {
+		def[0] |= 0x40
+		def = append(def, byte(size))
+	}
+	if len(levels) > 0 {
+		def[0] |= 0x80
+		def = append(def, byte(len(levels)))
+		for _, level := range levels {
+			if level == "" {
+				def = append(def, 0x00)
+				continue
+			}
+			n, err := strconv.Atoi(level)
+			if err != nil || n < 0 || n > 255 {
+				return nil, fmt.Errorf("invalid typed data type %q", field.Type)
+			}
+			def = append(def, 0x01, byte(n))
+		}
+	}
+	def = append(def, byte(len(field.Name)))
+	return append(def, field.Name...), nil
+}
+
+// ledgerTypedDataValue encodes the value of an atomic EIP-712 field the way the
+// Ledger expects it: integers big endian, addresses as 20 bytes, booleans as a
+// single byte and strings and byte arrays as is.
+func ledgerTypedDataValue(typ string, value interface{}) ([]byte, error) {
+	switch {
+	case typ == "address":
+		switch value := value.(type) {
+		case string:
+			if common.IsHexAddress(value) {
+				return common.HexToAddress(value).Bytes(), nil
+			}
+		case common.Address:
+			return value.Bytes(), nil
+		}
+	case typ == "bool":
+		switch value := value.(type) {
+		case bool:
+			if value {
+				return []byte{1}, nil
+			}
+			return []byte{0}, nil
+		case string:
+			if b, err := strconv.ParseBool(value); err == nil {
+				return ledgerTypedDataValue(typ, b)
+			}
+		}
+	case typ == "string":
+		if value, ok := value.(string); ok {
+			return []byte(value), nil
+		}
+	case strings.HasPrefix(typ, "bytes"):
+		switch value := value.(type) {
+		case string:
+			return hexutil.Decode(value)
+		case []byte:
+			return value, nil
+		case hexutil.Bytes:
+			return value, nil
+		}
+	case strings.HasPrefix(typ, "uint"), strings.HasPrefix(typ, "int"):
+		n, ok := ledgerTypedDataInteger(value)
+		if !ok {
+			break
+		}
+		bits := 256
+		if size := strings.TrimPrefix(strings.TrimPrefix(typ, "u"), "int"); size != "" {
+			bits, _ = strconv.Atoi(size)
+		}
+		if typ[0] == 'u' {
+			if n.Sign() < 0 || n.BitLen() > bits {
+				return nil, fmt.Errorf("%v overflows %s", value, typ)
+			}
+			if n.Sign() == 0 {
+				return []byte{0}, nil
+			}
+			return n.Bytes(), nil
+		}
+		// Signed integers are sent as two's complement of the type's size
+		limit := new(big.Int).Lsh(big.NewInt(1), uint(bits-1))
+		if n.Cmp(limit) >= 0 || n.Cmp(new(big.Int).Neg(limit)) < 0 {
+			return nil, fmt.Errorf("%v overflows %s", value, typ)
+		}
+		if n.Sign() < 0 {
+			n = new(big.Int).Add(n, new(big.Int).Lsh(limit, 1))
+		}
+		return n.FillBytes(make([]byte, bits/8)), nil
+	}
+	return nil, fmt.Errorf("invalid %s value %v", typ, value)
+}
+
+// ledgerTypedDataInteger converts the integer representations allowed in typed
+// data (decimal or hex strings, JSON numbers and big integers) to a big integer.
+func ledgerTypedDataInteger(value interface{}) (*big.Int, bool) {
+	switch value := value.(type) {
+	case *big.Int:
+		return value, value != nil
+	case *math.HexOrDecimal256:
+		return (*big.Int)(value), value != nil
+	case string:
+		return math.ParseBig256(value)
+	case json.Number:
+		return math.ParseBig256(string(value))
+	case float64:
+		f := big.NewFloat(value)
+		if !f.IsInt() {
+			return nil, false
+		}
+		n, _ := f.Int(nil)
+		return n, true
+	case int:
+		return big.NewInt(int64(value)), true
+	case int64:
+		return big.NewInt(value), true
+	case uint64:
+		return new(big.Int).SetUint64(value), true
+	}
+	return nil, false
+}
+
+// ledgerSignPersonalMessage sends the message to the Ledger wallet, and waits for
+// the user to confirm or deny signing it as an EIP-191 personal message.
+//
+// The personal message signing protocol is defined as follows:
+//
+//	CLA | INS | P1 | P2 | Lc  | Le
+//	----+-----+----+----+-----+---
+//	 E0 | 08  | 00: first message data block
+//	            80: subsequent message data block
+//	               | 00 | variable | variable
+//
+// Where the input for the first message block (first 255 bytes) is:
+//
+//	Description                                      | Length
+//	-------------------------------------------------+----------
+//	Number of BIP 32 derivations to perform (max 10) | 1 byte
+//	First derivation index (big endian)              | 4 bytes
+//	...                                              | 4 bytes
+//	Last derivation index (big endian)               | 4 bytes
+//	Message length (big endian)                      | 4 bytes
+//	Message chunk                                    | arbitrary
+//
+// And the input for subsequent message blocks (first 255 bytes) are:
+//
+//	Description   | Length
+//	--------------+----------
+//	Message chunk | arbitrary
+//
+// And the output data is:
+//
+//	Description | Length
+//	------------+---------
+//	signature V | 1 byte
+//	signature R | 32 bytes
+//	signature S | 32 bytes
+func (w *ledgerDriver) ledgerSignPersonalMessage(derivationPath gethaccounts.DerivationPath, message []byte) ([]byte, error) {
+	// Flatten the derivation path into the Ledger request
+	path := make([]byte, 1+4*len(derivationPath))
+	path[0] = byte(len(derivationPath))
+	for i, component := range derivationPath {
+		binary.BigEndian.PutUint32(path[1+4*i:], component)
+	}
+	// Prefix the message with its length so the device can hash it
+	payload := binary.BigEndian.AppendUint32(path, uint32(len(message)))
+	payload = append(payload, message...)
+
+	// Send the request and wait for the response
+	var (
+		op    = ledgerP1InitPersonalMessage
+		reply []byte
+		err   error
+	)
+	for len(payload) > 0 {
+		// Calculate the size of the next data chunk
+		chunk := 255
+		if chunk > len(payload) {
+			chunk = len(payload)
+		}
+		// Send the chunk over, ensuring it's processed correctly
+		reply, err = w.ledgerExchange(ledgerOpSignPersonalMsg, op, 0, payload[:chunk])
+		if err != nil {
+			if errors.Is(err, ErrLedgerInstructionNotSupported) {
+				return nil, gethaccounts.ErrNotSupported
+			}
+			return nil, err
+		}
+		// Shift the payload and ensure subsequent chunks are marked as such
+		payload = payload[chunk:]
+		op = ledgerP1ContPersonalMessage
+	}
+	// Extract the Ethereum signature and do a sanity validation
+	if len(reply) != crypto.SignatureLength {
+		return nil, errors.New("reply lacks signature")
+	}
+	signature := append(reply[1:], reply[0])
+	return signature, nil
+}
+
+// ledgerEth2PublicKey retrieves the BLS12-381 public key of an EIP-2334 derivation
+// path from the Ledger wallet.
+//
+// The ETH2 public key retrieval protocol is defined as follows:
+//
+//	CLA | INS | P1 | P2 | Lc       | Le
+//	----+-----+----+----+----------+---
+//	 E0 | 0E  | 00 | 00 | variable | 30
+//
+// Where the input data is:
+//
+//	Description                                      | Length
+//	-------------------------------------------------+--------
+//	Number of BIP 32 derivations to perform (max 10) | 1 byte
+//	First derivation index (big endian)              | 4 bytes
+//	...                                              | 4 bytes
+//	Last derivation index (big endian)               | 4 bytes
+//
+// And the output data is:
+//
+//	Description               | Length
+//	--------------------------+---------
+//	Compressed BLS public key | 48 bytes
+//
+// Apps built without ETH2 support reject the instruction, in which case the error
+// gethaccounts.ErrNotSupported is returned.
+func (w *ledgerDriver) ledgerEth2PublicKey(derivationPath gethaccounts.DerivationPath) (accounts.BLSPublicKey, error) {
+	// Flatten the derivation path into the Ledger request
+	path := make([]byte, 1+4*len(derivationPath))
+	path[0] = byte(len(derivationPath))
+	for i, component := range derivationPath {
+		binary.BigEndian.PutUint32(path[1+4*i:], component)
+	}
+	// Send the request and wait for the response
+	reply, err := w.ledgerExchange(ledgerOpEth2GetPublicKey, ledgerP1DirectlyFetchPublicKey, 0, path)
+	if err != nil {
+		if errors.Is(err, ErrLedgerInstructionNotSupported) {
+			return accounts.BLSPublicKey{}, gethaccounts.ErrNotSupported
+		}
+		return accounts.BLSPublicKey{}, err
+	}
+	return accounts.ParseBLSPublicKey(reply)
+}
+
+// ledgerEth2SetWithdrawalIndex sets the index of the withdrawal key the Ledger
+// uses when generating Ethereum 2 deposit data.
+//
+// The withdrawal index protocol is defined as follows:
+//
+//	CLA | INS | P1 | P2 | Lc | Le
+//	----+-----+----+----+----+---
+//	 E0 | 10  | 00 | 00 | 04 | 00
+//
+// Where the input data is:
+//
+//	Description                   | Length
+//	------------------------------+--------
+//	Withdrawal index (big endian) | 4 bytes
+//
+// With no output data. Apps built without ETH2 support reject the instruction,
+// in which case the error gethaccounts.ErrNotSupported is returned.
+func (w *ledgerDriver) ledgerEth2SetWithdrawalIndex(index uint32) error {
+	// Send the request and wait for the response
+	_, err := w.ledgerExchange(ledgerOpEth2SetWithdraw, 0, 0, binary.BigEndian.AppendUint32(nil, index))
+	if errors.Is(err, ErrLedgerInstructionNotSupported) {
+		return gethaccounts.ErrNotSupported
+	}
+	return err
+}
+
+// ledgerPrivacy performs a privacy operation with the X25519 key the Ledger derives
+// from the account at the given path, returning either its public key or, if a
+// peer key is given, the shared secret with it. The user confirms the operation.
+//
+// The privacy operation protocol is defined as follows:
+//
+//	CLA | INS | P1 | P2 | Lc       | Le
+//	----+-----+----+----+----------+---
+//	 E0 | 18  | 01 | 01: public key
+//	                 02: shared secret
+//	                    | variable | 20
+//
+// Where the input data is:
+//
+//	Description                                      | Length
+//	-------------------------------------------------+--------
+//	Number of BIP 32 derivations to perform (max 10) | 1 byte
+//	First derivation index (big endian)              | 4 bytes
+//	...                                              | 4 bytes
+//	Last derivation index (big endian)               | 4 bytes
+//	Peer X25519 public key (shared secret only)      | 32 bytes
+//
+// And the output data is:
+//
+//	Description                     | Length
+//	--------------------------------+---------
+//	X25519 public key/shared secret | 32 bytes
+//
+// Apps built without privacy support reject the instruction, in which case the
+// error gethaccounts.ErrNotSupported is returned.
+func (w *ledgerDriver) ledgerPrivacy(derivationPath gethaccounts.DerivationPath, peer []byte) ([32]byte, error) {
+	// Flatten the derivation path into the Ledger request
+	path := make([]byte, 1+4*len(derivationPath))
+	path[0] = byte(len(derivationPath))
+	for i, component := range derivationPath {
+		binary.BigEndian.PutUint32(path[1+4*i:], component)
+	}
+	op := ledgerP2PrivacyPublicKey
+	if peer != nil {
+		op = ledgerP2PrivacySharedSecret
+	}
+	// Send the request and wait for the response
+	reply, err := w.ledgerExchange(ledgerOpPrivacy, ledgerP1ConfirmPrivacyOperation, op, append(path, peer...))
+	if err != nil {
+		if errors.Is(err, ErrLedgerInstructionNotSupported) {
+			return [32]byte{}, gethaccounts.ErrNotSupported
+		}
+		return [32]byte{}, err
+	}
+	if len(reply) != 32 {
+		return [32]byte{}, fmt.Errorf("invalid privacy operation reply length: have %d, want 32", len(reply))
+	}
+	return [32]byte(reply), nil
+}
+
+// ledgerChallenge retrieves a random challenge from the Ledger, which must be
+// part of the next signed trusted name payload to prevent replays.
+//
+// The challenge retrieval protocol is defined as follows:
+//
+//	CLA | INS | P1 | P2 | Lc | Le
+//	----+-----+----+----+----+---
+//	 E0 | 20  | 00 | 00 | 00 | 04
+//
+// With no input data, and the output data being:
+//
+//	Description            | Length
+//	-----------------------+--------
+//	Challenge (big endian) | 4 bytes
+//
+// Apps predating trusted names reject the instruction, in which case the error
+// gethaccounts.ErrNotSupported is returned.
+func (w *ledgerDriver) ledgerChallenge() (uint32, error) {
+	// Send the request and wait for the response
+	reply, err := w.ledgerExchange(ledgerOpGetChallenge, 0, 0, nil)
+	if err != nil {
+		if errors.Is(err, ErrLedgerInstructionNotSupported) {
+			return 0, gethaccounts.ErrNotSupported
+		}
+		return 0, err
+	}
+	if len(reply) != 4 {
+		return 0, errors.New("reply lacks challenge")
+	}
+	return binary.BigEndian.Uint32(reply), nil
+}
+
+// ledgerProvideMetadata sends a signed metadata payload (trusted name or clear
+// signing descriptor) to the Ledger.
+//
+// The metadata protocol is shared between the instructions and is defined as
+// follows:
+//
+//	CLA | INS | P1 | P2 | Lc  | Le
+//	----+-----+----+----+-----+---
+//	 E0 | 22: trusted name
+//	      26: transaction info descriptor
+//	      28: transaction field descriptor
+//	          | 01: first payload chunk
+//	            00: subsequent payload chunk
+//	               | 00 | variable | 00
+//
+// Where the input for the first chunk (first 255 bytes) is:
+//
+//	Description                 | Length
+//	----------------------------+----------
+//	Payload length (big endian) | 2 bytes
+//	Metadata payload chunk      | arbitrary
+//
+// And the input for subsequent chunks (first 255 bytes) are:
+//
+//	Description            | Length
+//	-----------------------+----------
+//	Metadata payload chunk | arbitrary
+//
+// The payload itself is an opaque TLV structure signed by a source the Ethereum
+// app trusts. Trusted names additionally embed the challenge retrieved via
+// ledgerChallenge to prevent replays.
+func (w *ledgerDriver) ledgerProvideMetadata(opcode ledgerOpcode, payload []byte) error {
+	if len(payload) > 0xffff {
+		return fmt.Errorf("metadata payload too large: %d bytes", len(payload))
+	}
+	// Prefix the payload with its length so the device knows when it's complete
+	data := make([]byte, 2, 2+len(payload))
+	binary.BigEndian.PutUint16(data, uint16(len(payload)))
+	data = append(data, payload...)
+
+	op := ledgerP1InitMetadataChunk
+	for len(data) > 0 {
+		// Calculate the size of the next data chunk
+		chunk := 255
+		if chunk > len(data) {
+			chunk = len(data)
+		}
+		// Send the chunk over, ensuring it's processed correctly
+		if _, err := w.ledgerExchange(opcode, op, 0, data[:chunk]); err != nil {
+			return err
+		}
+		// Shift the payload and ensure subsequent chunks are marked as such
+		data = data[chunk:]
+		op = ledgerP1ContMetadataChunk
+	}
+	return nil
+}
+
+// ledgerExchange performs a data exchange with the Ethereum app on the Ledger
+// wallet, sending it a message and retrieving the response.
+func (w *ledgerDriver) ledgerExchange(opcode ledgerOpcode, p1 ledgerParam1, p2 ledgerParam2, data []byte) ([]byte, error) {
+	return ledgerAPDU(w.device, ledgerClaEthereum, byte(opcode), byte(p1), byte(p2), data)
+}
+
+// ledgerAPDU performs a data exchange with the Ledger wallet, sending an APDU of
+// the given instruction class to the open app and retrieving the response.
 //
 // The common transport header is defined as follows:
 //
@@ -525,12 +1645,12 @@ func (w *ledgerDriver) ledgerSignTypedMessage(derivationPath gethaccounts.Deriva
 //	APDU P2                  | 1 byte
 //	APDU length              | 1 byte
 //	Optional APDU data       | arbitrary
-func (w *ledgerDriver) ledgerExchange(opcode ledgerOpcode, p1 ledgerParam1, p2 ledgerParam2, data []byte) ([]byte, error) {
+func ledgerAPDU(device io.ReadWriter, cla, ins, p1, p2 byte, data []byte) ([]byte, error) {
 	// Construct the message payload, possibly split into multiple chunks
 	apdu := make([]byte, 2, 7+len(data))
 
 	binary.BigEndian.PutUint16(apdu, uint16(5+len(data)))
-	apdu = append(apdu, []byte{0xe0, byte(opcode), byte(p1), byte(p2), byte(len(data))}...)
+	apdu = append(apdu, []byte{cla, ins, p1, p2, byte(len(data))}...)
 	apdu = append(apdu, data...)
 
 	// Stream all the chunks to the device
@@ -551,7 +1671,7 @@ func (w *ledgerDriver) ledgerExchange(opcode ledgerOpcode, p1 ledgerParam1, p2 l
 			apdu = nil
 		}
 		// Send over to the device
-		if _, err := w.device.Write(chunk); err != nil {
+		if _, err := device.Write(chunk); err != nil {
 			return nil, err
 		}
 	}
@@ -560,7 +1680,7 @@ func (w *ledgerDriver) ledgerExchange(opcode ledgerOpcode, p1 ledgerParam1, p2 l
 	chunk = chunk[:64] // Yeah, we surely have enough space
 	for {
 		// Read the next chunk from the Ledger wallet
-		if _, err := io.ReadFull(w.device, chunk); err != nil {
+		if _, err := io.ReadFull(device, chunk); err != nil {
 			return nil, err
 		}
 
@@ -585,5 +1705,12 @@ func (w *ledgerDriver) ledgerExchange(opcode ledgerOpcode, p1 ledgerParam1, p2 l
 			break
 		}
 	}
+	// Split off the status word and ensure the request succeeded
+	if len(reply) < 2 {
+		return nil, errors.New("ledger: reply lacks status word")
+	}
+	if status := binary.BigEndian.Uint16(reply[len(reply)-2:]); status != ledgerStatusOK {
+		return nil, &StatusError{Code: status}
+	}
 	return reply[:len(reply)-2], nil
 }
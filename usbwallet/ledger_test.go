@@ -0,0 +1,862 @@
+package usbwallet
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/evmos/ethereum-ledger-go/accounts"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/curve25519"
+)
+
+// mockAPDU is a single command received by the mock Ledger device.
+type mockAPDU struct {
+	cla, ins, p1, p2 byte
+	data             []byte
+}
+
+// mockLedger is an in-memory Ledger device speaking the HID transport framing,
+// dispatching every reassembled APDU to a handler returning the reply data and
+// status word.
+type mockLedger struct {
+	handler func(apdu mockAPDU) ([]byte, uint16)
+	history []mockAPDU
+
+	request []byte // Partially received APDU, including its length prefix
+	pending []byte // Reply frames not yet read by the driver
+}
+
+func (m *mockLedger) Write(frame []byte) (int, error) {
+	if len(frame) < 5 || frame[0] != 0x01 || frame[1] != 0x01 || frame[2] != 0x05 {
+		return 0, errors.New("invalid frame header")
+	}
+	m.request = append(m.request, frame[5:]...)
+
+	size := int(binary.BigEndian.Uint16(m.request))
+	if len(m.request) < 2+size {
+		return len(frame), nil
+	}
+	raw := m.request[2 : 2+size]
+	m.request = nil
+
+	apdu := mockAPDU{cla: raw[0], ins: raw[1], p1: raw[2], p2: raw[3], data: common.CopyBytes(raw[5:])}
+	m.history = append(m.history, apdu)
+
+	data, status := m.handler(apdu)
+	reply := binary.BigEndian.AppendUint16(data, status)
+
+	// Frame the reply the same way the device would
+	reply = append(binary.BigEndian.AppendUint16(nil, uint16(len(reply))), reply...)
+	for seq := 0; len(reply) > 0; seq++ {
+		out := []byte{0x01, 0x01, 0x05, byte(seq >> 8), byte(seq)}
+		n := 64 - len(out)
+		if n > len(reply) {
+			n = len(reply)
+		}
+		out = append(out, reply[:n]...)
+		out = append(out, make([]byte, 64-len(out))...)
+		m.pending = append(m.pending, out...)
+		reply = reply[n:]
+	}
+	return len(frame), nil
+}
+
+func (m *mockLedger) Read(buf []byte) (int, error) {
+	n := copy(buf, m.pending)
+	m.pending = m.pending[n:]
+	return n, nil
+}
+
+func (m *mockLedger) Close() error { return nil }
+
+// newMockEthereumApp creates a mock device running an Ethereum app which signs
+// with the given key, delegating unknown instructions to extra (if any).
+func newMockEthereumApp(key *ecdsa.PrivateKey, extra func(apdu mockAPDU) ([]byte, uint16)) *mockLedger {
+	var (
+		stored  []byte // Transaction stored awaiting its clear signing metadata
+		message []byte // Personal message being streamed, including its length
+	)
+
+	return &mockLedger{handler: func(apdu mockAPDU) ([]byte, uint16) {
+		switch apdu.ins {
+		case byte(ledgerOpGetConfiguration):
+			return []byte{0x01, 1, 10, 3}, ledgerStatusOK
+
+		case byte(ledgerOpRetrieveAddress):
+			pubkey := crypto.FromECDSAPub(&key.PublicKey)
+			addr := hex.EncodeToString(crypto.PubkeyToAddress(key.PublicKey).Bytes())
+
+			reply := append([]byte{byte(len(pubkey))}, pubkey...)
+			reply = append(reply, byte(len(addr)))
+			return append(reply, addr...), ledgerStatusOK
+
+		case byte(ledgerOpSignTransaction):
+			// Single chunk transactions only, skip the derivation path
+			var txRLP []byte
+			switch apdu.p2 {
+			case byte(ledgerP2StoreTransaction):
+				stored = apdu.data[1+4*int(apdu.data[0]):]
+				return nil, ledgerStatusOK
+			case byte(ledgerP2StartSigningFlow):
+				txRLP, stored = stored, nil
+			default:
+				txRLP = apdu.data[1+4*int(apdu.data[0]):]
+			}
+			sig, err := crypto.Sign(crypto.Keccak256(txRLP), key)
+			if err != nil {
+				return nil, 0x6f00
+			}
+			// Typed transactions use the plain parity as V
+			if txRLP[0] < 0xc0 {
+				return append([]byte{sig[64]}, sig[:64]...), ledgerStatusOK
+			}
+			// Legacy transactions carry the chain ID for EIP-155 replay protection
+			var fields []rlp.RawValue
+			if err := rlp.DecodeBytes(txRLP, &fields); err != nil {
+				return nil, 0x6a80
+			}
+			v := 27 + sig[64]
+			if len(fields) == 9 {
+				var chainID uint64
+				if err := rlp.DecodeBytes(fields[6], &chainID); err != nil {
+					return nil, 0x6a80
+				}
+				v = byte(chainID*2+35) + sig[64]
+			}
+			return append([]byte{v}, sig[:64]...), ledgerStatusOK
+
+		case byte(ledgerOpSignPersonalMsg):
+			// Accumulate the chunks, skipping the derivation path of the first
+			if apdu.p1 == byte(ledgerP1InitPersonalMessage) {
+				message = apdu.data[1+4*int(apdu.data[0]):]
+			} else {
+				message = append(message, apdu.data...)
+			}
+			if len(message) < 4 || len(message)-4 < int(binary.BigEndian.Uint32(message)) {
+				return nil, ledgerStatusOK
+			}
+			sig, err := crypto.Sign(gethaccounts.TextHash(message[4:]), key)
+			if err != nil {
+				return nil, 0x6f00
+			}
+			return append([]byte{27 + sig[64]}, sig[:64]...), ledgerStatusOK
+
+		case byte(ledgerOpSignAuthority):
+			// Single chunk authorizations only, skip the derivation path and length
+			var (
+				auth coretypes.SetCodeAuthorization
+				tlv  = apdu.data[1+4*int(apdu.data[0])+2:]
+			)
+			for len(tlv) > 0 {
+				tag, value := tlv[0], tlv[2:2+int(tlv[1])]
+				switch tag {
+				case 0x01:
+					auth.Address = common.BytesToAddress(value)
+				case 0x02:
+					auth.ChainID.SetBytes(value)
+				case 0x03:
+					auth.Nonce = new(big.Int).SetBytes(value).Uint64()
+				}
+				tlv = tlv[2+len(value):]
+			}
+			sighash := auth.SigHash()
+			sig, err := crypto.Sign(sighash[:], key)
+			if err != nil {
+				return nil, 0x6f00
+			}
+			return append([]byte{sig[64]}, sig[:64]...), ledgerStatusOK
+		}
+		if extra != nil {
+			return extra(apdu)
+		}
+		return nil, 0x6d00
+	}}
+}
+
+// newMockWallet opens a wallet on top of the given mock device and pins the
+// account at the default derivation path.
+func newMockWallet(t *testing.T, device *mockLedger) (*wallet, gethaccounts.DerivationPath) {
+	t.Helper()
+
+	w := &wallet{
+		hub:       &Hub{},
+		driver:    newLedgerDriver(),
+		url:       &gethaccounts.URL{Scheme: LedgerScheme, Path: "mock"},
+		device:    device,
+		commsLock: make(chan struct{}, 1),
+	}
+	w.commsLock <- struct{}{}
+
+	require.NoError(t, w.Open(""))
+	t.Cleanup(func() { w.Close() })
+
+	path := make(gethaccounts.DerivationPath, len(gethaccounts.DefaultBaseDerivationPath))
+	copy(path, gethaccounts.DefaultBaseDerivationPath)
+	return w, path
+}
+
+func TestLedgerStatusErrors(t *testing.T) {
+	device := newMockEthereumApp(mustGenerateKey(t), func(apdu mockAPDU) ([]byte, uint16) {
+		return nil, 0x6985
+	})
+	driver := &ledgerDriver{device: device}
+
+	_, err := driver.ledgerExchange(ledgerOpSignTypedMessage, 0, 0, nil)
+	require.ErrorIs(t, err, ErrLedgerUserRejected)
+	require.NotErrorIs(t, err, ErrLedgerInvalidData)
+	require.EqualError(t, err, "ledger: request denied by the user (0x6985)")
+}
+
+func TestLedgerChallengeUnsupported(t *testing.T) {
+	driver := &ledgerDriver{device: newMockEthereumApp(mustGenerateKey(t), nil), version: [3]byte{1, 9, 0}}
+
+	_, err := driver.Challenge()
+	require.ErrorIs(t, err, gethaccounts.ErrNotSupported)
+}
+
+func TestLedgerProvideTrustedNameChunking(t *testing.T) {
+	device := newMockEthereumApp(mustGenerateKey(t), func(apdu mockAPDU) ([]byte, uint16) {
+		return nil, ledgerStatusOK
+	})
+	driver := &ledgerDriver{device: device, version: [3]byte{1, 11, 0}}
+
+	payload := bytes.Repeat([]byte{0xab}, 300)
+	require.NoError(t, driver.ProvideTrustedName(payload))
+
+	require.Len(t, device.history, 2)
+	require.Equal(t, byte(ledgerP1InitMetadataChunk), device.history[0].p1)
+	require.Equal(t, byte(ledgerP1ContMetadataChunk), device.history[1].p1)
+	require.Len(t, device.history[0].data, 255)
+
+	sent := append(device.history[0].data, device.history[1].data...)
+	require.Equal(t, uint16(len(payload)), binary.BigEndian.Uint16(sent))
+	require.Equal(t, payload, sent[2:])
+}
+
+func TestWalletSignTxTrustedName(t *testing.T) {
+	key := mustGenerateKey(t)
+	recipient := common.HexToAddress("0xd8da6bf26964af9d7eed9e03e53415d37aa96045")
+	payload := []byte("signed trusted name payload")
+
+	device := newMockEthereumApp(key, func(apdu mockAPDU) ([]byte, uint16) {
+		switch apdu.ins {
+		case byte(ledgerOpGetChallenge):
+			return []byte{0xde, 0xad, 0xbe, 0xef}, ledgerStatusOK
+		case byte(ledgerOpProvideName):
+			return nil, ledgerStatusOK
+		}
+		return nil, 0x6d00
+	})
+	w, path := newMockWallet(t, device)
+
+	var resolved []common.Address
+	w.hub.SetTrustedNameResolver(TrustedNameResolverFunc(func(address common.Address, chainID *big.Int, challenge uint32) ([]byte, error) {
+		require.Equal(t, uint32(0xdeadbeef), challenge)
+		require.Equal(t, int64(1), chainID.Int64())
+
+		resolved = append(resolved, address)
+		return payload, nil
+	}))
+	account, err := w.Derive(path, true)
+	require.NoError(t, err)
+
+	tx := coretypes.NewTransaction(3, recipient, big.NewInt(10), 21000, big.NewInt(10), nil)
+	_, err = w.SignTx(account, tx, big.NewInt(1))
+	require.NoError(t, err)
+	require.Equal(t, []common.Address{recipient}, resolved)
+
+	// The trusted name must be provided right before the transaction is signed
+	var ins []byte
+	for _, apdu := range device.history {
+		ins = append(ins, apdu.ins)
+	}
+	require.Equal(t, []byte{byte(ledgerOpGetChallenge), byte(ledgerOpProvideName), byte(ledgerOpSignTransaction)}, ins[len(ins)-3:])
+	require.Equal(t, payload, device.history[len(ins)-2].data[2:])
+}
+
+func TestWalletSignTxUnknownTrustedName(t *testing.T) {
+	key := mustGenerateKey(t)
+	device := newMockEthereumApp(key, func(apdu mockAPDU) ([]byte, uint16) {
+		if apdu.ins == byte(ledgerOpGetChallenge) {
+			return []byte{0, 0, 0, 1}, ledgerStatusOK
+		}
+		return nil, 0x6d00
+	})
+	w, path := newMockWallet(t, device)
+
+	w.hub.SetTrustedNameResolver(TrustedNameResolverFunc(func(common.Address, *big.Int, uint32) ([]byte, error) {
+		return nil, nil
+	}))
+	account, err := w.Derive(path, true)
+	require.NoError(t, err)
+
+	tx := coretypes.NewTransaction(0, common.Address{0x01}, big.NewInt(1), 21000, big.NewInt(1), nil)
+	_, err = w.SignTx(account, tx, big.NewInt(1))
+	require.NoError(t, err)
+
+	for _, apdu := range device.history {
+		require.NotEqual(t, byte(ledgerOpProvideName), apdu.ins)
+	}
+}
+
+func TestWalletSignTxMetadata(t *testing.T) {
+	key := mustGenerateKey(t)
+	device := newMockEthereumApp(key, func(apdu mockAPDU) ([]byte, uint16) {
+		switch apdu.ins {
+		case byte(ledgerOpProvideTxInfo), byte(ledgerOpProvideTxField):
+			return nil, ledgerStatusOK
+		}
+		return nil, 0x6d00
+	})
+	w, path := newMockWallet(t, device)
+
+	meta := &TransactionMetadata{
+		Info:   []byte("info"),
+		Fields: [][]byte{[]byte("field 1"), []byte("field 2")},
+	}
+	w.hub.SetTransactionMetadataProvider(providerFunc(func(tx *coretypes.Transaction, chainID *big.Int) (*TransactionMetadata, error) {
+		return meta, nil
+	}))
+	account, err := w.Derive(path, true)
+	require.NoError(t, err)
+
+	// Plain transfers have no calldata to describe
+	tx := coretypes.NewTransaction(0, common.Address{0x01}, big.NewInt(1), 21000, big.NewInt(1), nil)
+	_, err = w.SignTx(account, tx, big.NewInt(1))
+	require.NoError(t, err)
+	require.Equal(t, byte(ledgerP2ProcessAndSign), device.history[len(device.history)-1].p2)
+
+	// Contract calls get stored, described and only then signed
+	device.history = nil
+
+	tx = coretypes.NewTransaction(1, common.Address{0x01}, big.NewInt(0), 50000, big.NewInt(1), []byte{0xa9, 0x05, 0x9c, 0xbb})
+	signed, err := w.SignTx(account, tx, big.NewInt(1))
+	require.NoError(t, err)
+	require.NotEmpty(t, signed)
+
+	require.Len(t, device.history, 5)
+	require.Equal(t, byte(ledgerP2StoreTransaction), device.history[0].p2)
+	require.Equal(t, byte(ledgerOpProvideTxInfo), device.history[1].ins)
+	require.Equal(t, []byte("info"), device.history[1].data[2:])
+	require.Equal(t, byte(ledgerOpProvideTxField), device.history[2].ins)
+	require.Equal(t, []byte("field 1"), device.history[2].data[2:])
+	require.Equal(t, []byte("field 2"), device.history[3].data[2:])
+	require.Equal(t, byte(ledgerP2StartSigningFlow), device.history[4].p2)
+	require.Empty(t, device.history[4].data)
+}
+
+func TestWalletSignTxLegacyEncoding(t *testing.T) {
+	key := mustGenerateKey(t)
+	w, path := newMockWallet(t, newMockEthereumApp(key, nil))
+
+	account, err := w.Derive(path, true)
+	require.NoError(t, err)
+
+	// Chain IDs above 109 overflow the single V byte returned by the device
+	tx := coretypes.NewTransaction(7, common.Address{0x01}, big.NewInt(1), 21000, big.NewInt(1), []byte{0x01})
+	for _, chainID := range []int64{1, 1337, 9001} {
+		signed, err := w.SignTx(account, tx, big.NewInt(chainID))
+		require.NoError(t, err)
+
+		expected, err := coretypes.SignTx(tx, coretypes.NewEIP155Signer(big.NewInt(chainID)), key)
+		require.NoError(t, err)
+		raw, err := expected.MarshalBinary()
+		require.NoError(t, err)
+		require.Equal(t, raw, signed, "chain %d", chainID)
+	}
+}
+
+func TestWalletSignTxTyped(t *testing.T) {
+	key := mustGenerateKey(t)
+	w, path := newMockWallet(t, newMockEthereumApp(key, nil))
+
+	account, err := w.Derive(path, true)
+	require.NoError(t, err)
+
+	recipient := common.Address{0x01}
+	accessList := coretypes.AccessList{{Address: recipient, StorageKeys: []common.Hash{{0x02}}}}
+
+	txs := []*coretypes.Transaction{
+		coretypes.NewTx(&coretypes.AccessListTx{
+			ChainID: big.NewInt(1), Nonce: 1, GasPrice: big.NewInt(1), Gas: 30000, To: &recipient, Value: big.NewInt(1), AccessList: accessList,
+		}),
+		coretypes.NewTx(&coretypes.DynamicFeeTx{
+			ChainID: big.NewInt(1), Nonce: 2, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(2), Gas: 30000, To: &recipient, Value: big.NewInt(1), Data: []byte{0xff},
+		}),
+		coretypes.NewTx(&coretypes.BlobTx{
+			ChainID: uint256.NewInt(1), Nonce: 3, GasTipCap: uint256.NewInt(1), GasFeeCap: uint256.NewInt(2), Gas: 30000, To: recipient,
+			Value: uint256.NewInt(1), BlobFeeCap: uint256.NewInt(3), BlobHashes: []common.Hash{{0x01}},
+		}),
+		// Unset chain IDs are filled in with the requested one
+		coretypes.NewTx(&coretypes.DynamicFeeTx{
+			Nonce: 4, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(2), Gas: 30000, To: &recipient, Value: big.NewInt(1),
+		}),
+	}
+	for _, tx := range txs {
+		signed, err := w.SignTx(account, tx, big.NewInt(1))
+		require.NoError(t, err)
+
+		decoded := new(coretypes.Transaction)
+		require.NoError(t, decoded.UnmarshalBinary(signed))
+		require.Equal(t, tx.Type(), decoded.Type())
+		require.Equal(t, big.NewInt(1), decoded.ChainId())
+
+		sender, err := coretypes.Sender(coretypes.LatestSignerForChainID(big.NewInt(1)), decoded)
+		require.NoError(t, err)
+		require.Equal(t, account.Address, sender)
+	}
+	// Typed transactions must be signed for the chain they embed
+	_, err = w.SignTx(account, txs[1], big.NewInt(5))
+	require.Error(t, err)
+}
+
+func TestWalletSignTypedDataMetadata(t *testing.T) {
+	key := mustGenerateKey(t)
+	typedData := apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {{Name: "name", Type: "string"}, {Name: "chainId", Type: "uint256"}, {Name: "verifyingContract", Type: "address"}},
+			"Permit":       {{Name: "spender", Type: "address"}, {Name: "amounts", Type: "uint256[]"}, {Name: "deadline", Type: "uint256"}},
+		},
+		PrimaryType: "Permit",
+		Domain: apitypes.TypedDataDomain{
+			Name:              strings.Repeat("a", 300), // Streamed in two chunks
+			ChainId:           math.NewHexOrDecimal256(1),
+			VerifyingContract: "0x000000000022D473030F116dDEE9F6B43aC78BA3",
+		},
+		Message: apitypes.TypedDataMessage{
+			"spender":  "0xd8da6bf26964af9d7eed9e03e53415d37aa96045",
+			"amounts":  []interface{}{"1000", "0x10000"},
+			"deadline": "1700000000",
+		},
+	}
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	require.NoError(t, err)
+
+	device := newMockEthereumApp(key, func(apdu mockAPDU) ([]byte, uint16) {
+		switch apdu.ins {
+		case byte(ledgerOpEIP712StructDef), byte(ledgerOpEIP712StructImpl), byte(ledgerOpEIP712Filtering):
+			return nil, ledgerStatusOK
+		case byte(ledgerOpSignTypedMessage):
+			if apdu.p2 != byte(ledgerP2TypedMessageStreamed) {
+				return nil, 0x6a80
+			}
+			sig, err := crypto.Sign(hash, key)
+			if err != nil {
+				return nil, 0x6f00
+			}
+			return append([]byte{27 + sig[64]}, sig[:64]...), ledgerStatusOK
+		}
+		return nil, 0x6d00
+	})
+	w, path := newMockWallet(t, device)
+
+	meta := &TypedDataMetadata{
+		Info: []byte("info"),
+		Fields: []TypedDataField{
+			{Path: "spender", Format: TypedDataFormatRaw, Descriptor: []byte("spender")},
+			{Path: "amounts.[]", Format: TypedDataFormatAmount, Descriptor: []byte("amount")},
+			{Path: "deadline", Format: TypedDataFormatDateTime, Descriptor: []byte("deadline")},
+		},
+	}
+	var matched []apitypes.TypedData
+	w.hub.SetTypedDataMetadataProvider(typedProviderFunc(func(typedData apitypes.TypedData) (*TypedDataMetadata, error) {
+		matched = append(matched, typedData)
+		return meta, nil
+	}))
+	account, err := w.Derive(path, true)
+	require.NoError(t, err)
+
+	device.history = nil
+	sig, err := w.SignTypedData(account, typedData)
+	require.NoError(t, err)
+	require.Len(t, sig, crypto.SignatureLength)
+	require.Len(t, matched, 1)
+
+	// Types are defined, the domain streamed and the message fields described
+	// right before their values
+	type step struct {
+		ins, p1, p2 byte
+		data        []byte
+	}
+	var steps []step
+	for _, apdu := range device.history {
+		steps = append(steps, step{apdu.ins, apdu.p1, apdu.p2, apdu.data})
+	}
+	def, impl, filter := byte(ledgerOpEIP712StructDef), byte(ledgerOpEIP712StructImpl), byte(ledgerOpEIP712Filtering)
+	require.Equal(t, []step{
+		{def, 0x00, 0x00, []byte("EIP712Domain")},
+		{def, 0x00, 0xff, append([]byte{0x05, 4}, "name"...)},
+		{def, 0x00, 0xff, append([]byte{0x42, 32, 7}, "chainId"...)},
+		{def, 0x00, 0xff, append([]byte{0x03, 17}, "verifyingContract"...)},
+		{def, 0x00, 0x00, []byte("Permit")},
+		{def, 0x00, 0xff, append([]byte{0x03, 7}, "spender"...)},
+		{def, 0x00, 0xff, append([]byte{0xc2, 32, 1, 0x00, 7}, "amounts"...)},
+		{def, 0x00, 0xff, append([]byte{0x42, 32, 8}, "deadline"...)},
+		{filter, 0x00, 0x00, []byte{}},
+		{impl, 0x00, 0x00, []byte("EIP712Domain")},
+		{impl, 0x01, 0xff, append([]byte{0x01, 0x2c}, strings.Repeat("a", 253)...)},
+		{impl, 0x00, 0xff, []byte(strings.Repeat("a", 47))},
+		{impl, 0x00, 0xff, []byte{0x00, 0x01, 0x01}},
+		{impl, 0x00, 0xff, append([]byte{0x00, 20}, common.HexToAddress(typedData.Domain.VerifyingContract).Bytes()...)},
+		{filter, 0x00, 0x0f, []byte("info")},
+		{impl, 0x00, 0x00, []byte("Permit")},
+		{filter, 0x00, 0xff, []byte("spender")},
+		{impl, 0x00, 0xff, append([]byte{0x00, 20}, common.HexToAddress("0xd8da6bf26964af9d7eed9e03e53415d37aa96045").Bytes()...)},
+		{impl, 0x00, 0x0f, []byte{2}},
+		{filter, 0x00, 0xfe, []byte("amount")},
+		{impl, 0x00, 0xff, []byte{0x00, 0x02, 0x03, 0xe8}},
+		{filter, 0x00, 0xfe, []byte("amount")},
+		{impl, 0x00, 0xff, []byte{0x00, 0x03, 0x01, 0x00, 0x00}},
+		{filter, 0x00, 0xfc, []byte("deadline")},
+		{impl, 0x00, 0xff, []byte{0x00, 0x04, 0x65, 0x53, 0xf1, 0x00}},
+		{byte(ledgerOpSignTypedMessage), 0x00, 0x01, []byte{5, 0x80, 0, 0, 44, 0x80, 0, 0, 60, 0x80, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}},
+	}, steps)
+
+	// Without metadata only the hashes are signed
+	w.hub.SetTypedDataMetadataProvider(typedProviderFunc(func(apitypes.TypedData) (*TypedDataMetadata, error) {
+		return nil, nil
+	}))
+	device.history = nil
+
+	_, err = w.SignTypedData(account, typedData)
+	require.Error(t, err) // The mock only signs streamed messages
+	require.Len(t, device.history, 1)
+	require.Equal(t, byte(ledgerP2TypedMessageHashes), device.history[0].p2)
+}
+
+func TestLedgerTypedDataValues(t *testing.T) {
+	tests := []struct {
+		typ   string
+		value interface{}
+		want  []byte
+	}{
+		{"uint8", "0", []byte{0x00}},
+		{"uint256", json.Number("65536"), []byte{0x01, 0x00, 0x00}},
+		{"uint48", float64(1700000000), []byte{0x65, 0x53, 0xf1, 0x00}},
+		{"int16", "-2", []byte{0xff, 0xfe}},
+		{"int16", big.NewInt(2), []byte{0x00, 0x02}},
+		{"bool", true, []byte{0x01}},
+		{"bytes4", "0xa9059cbb", []byte{0xa9, 0x05, 0x9c, 0xbb}},
+		{"bytes", []byte{0x01, 0x02}, []byte{0x01, 0x02}},
+		{"string", "hi", []byte("hi")},
+	}
+	for _, tt := range tests {
+		encoded, err := ledgerTypedDataValue(tt.typ, tt.value)
+		require.NoError(t, err, tt.typ)
+		require.Equal(t, tt.want, encoded, tt.typ)
+	}
+	for _, tt := range []struct {
+		typ   string
+		value interface{}
+	}{
+		{"uint8", "256"}, {"uint8", "-1"}, {"int8", "128"}, {"address", "0x01"}, {"bool", "yes"}, {"uint256", 1.5},
+	} {
+		_, err := ledgerTypedDataValue(tt.typ, tt.value)
+		require.Error(t, err, "%s %v", tt.typ, tt.value)
+	}
+}
+
+func TestLedgerTypedDataFieldDefIntegers(t *testing.T) {
+	for _, typ := range []string{"uint", "int", "uint8", "int256"} {
+		_, err := ledgerTypedDataFieldDef(apitypes.TypedData{}, apitypes.Type{Name: "v", Type: typ})
+		require.NoError(t, err, typ)
+	}
+	// Only the prefix is stripped, not any run of its letters
+	for _, typ := range []string{"uintu8", "intt8", "uintint8", "int7", "uint264"} {
+		_, err := ledgerTypedDataFieldDef(apitypes.TypedData{}, apitypes.Type{Name: "v", Type: typ})
+		require.Error(t, err, typ)
+	}
+}
+
+func TestWalletSignText(t *testing.T) {
+	key := mustGenerateKey(t)
+	device := newMockEthereumApp(key, nil)
+	w, path := newMockWallet(t, device)
+
+	account, err := w.Derive(path, true)
+	require.NoError(t, err)
+
+	// Long enough to span multiple chunks
+	text := bytes.Repeat([]byte("hello ledger "), 40)
+	signature, err := w.SignText(account, text)
+	require.NoError(t, err)
+	require.Len(t, signature, crypto.SignatureLength)
+	require.Contains(t, []byte{27, 28}, signature[crypto.RecoveryIDOffset])
+
+	sig := common.CopyBytes(signature)
+	sig[crypto.RecoveryIDOffset] -= 27
+	pubkey, err := crypto.SigToPub(gethaccounts.TextHash(text), sig)
+	require.NoError(t, err)
+	require.Equal(t, account.Address, crypto.PubkeyToAddress(*pubkey))
+
+	var chunks []mockAPDU
+	for _, apdu := range device.history {
+		if apdu.ins == byte(ledgerOpSignPersonalMsg) {
+			chunks = append(chunks, apdu)
+		}
+	}
+	require.Len(t, chunks, 3)
+	require.Equal(t, byte(ledgerP1InitPersonalMessage), chunks[0].p1)
+	require.Equal(t, byte(ledgerP1ContPersonalMessage), chunks[1].p1)
+}
+
+func TestWalletSignTextUnsupported(t *testing.T) {
+	w, path := newMockWallet(t, newMockEthereumApp(mustGenerateKey(t), nil))
+
+	account, err := w.Derive(path, true)
+	require.NoError(t, err)
+
+	w.driver.(*ledgerDriver).device = &mockLedger{handler: func(apdu mockAPDU) ([]byte, uint16) { return nil, 0x6d00 }}
+	_, err = w.SignText(account, []byte("hello"))
+	require.ErrorIs(t, err, gethaccounts.ErrNotSupported)
+}
+
+func TestWalletSignAuthorization(t *testing.T) {
+	key := mustGenerateKey(t)
+	device := newMockEthereumApp(key, nil)
+	w, path := newMockWallet(t, device)
+
+	account, err := w.Derive(path, true)
+	require.NoError(t, err)
+
+	delegate := common.HexToAddress("0x63c0c19a282a1b52b07dd5a65b58948a07dae32b")
+	auth := coretypes.SetCodeAuthorization{Address: delegate, Nonce: 256}
+	auth.ChainID.SetUint64(1)
+
+	signed, err := w.SignAuthorization(account, auth)
+	require.NoError(t, err)
+
+	authority, err := signed.Authority()
+	require.NoError(t, err)
+	require.Equal(t, account.Address, authority)
+
+	// The authorization must have been sent as a minimal TLV structure
+	apdu := device.history[len(device.history)-1]
+	require.Equal(t, byte(ledgerOpSignAuthority), apdu.ins)
+	require.Equal(t, byte(ledgerP1InitAuthorizationData), apdu.p1)
+
+	tlv := append([]byte{0x00, 1, 1, 0x01, 20}, delegate.Bytes()...)
+	tlv = append(tlv, 0x02, 1, 1, 0x03, 2, 0x01, 0x00)
+	require.Equal(t, uint16(len(tlv)), binary.BigEndian.Uint16(apdu.data[1+4*len(path):]))
+	require.Equal(t, tlv, apdu.data[1+4*len(path)+2:])
+
+	// The signed authorization can be embedded in a set-code transaction
+	tx := coretypes.NewTx(&coretypes.SetCodeTx{
+		ChainID: uint256.NewInt(1), Nonce: 5, GasTipCap: uint256.NewInt(1), GasFeeCap: uint256.NewInt(2), Gas: 60000,
+		To: account.Address, Value: uint256.NewInt(0), AuthList: []coretypes.SetCodeAuthorization{signed},
+	})
+	raw, err := w.SignTx(account, tx, big.NewInt(1))
+	require.NoError(t, err)
+
+	decoded := new(coretypes.Transaction)
+	require.NoError(t, decoded.UnmarshalBinary(raw))
+	require.Equal(t, []coretypes.SetCodeAuthorization{signed}, decoded.SetCodeAuthorizations())
+
+	sender, err := coretypes.Sender(coretypes.LatestSignerForChainID(big.NewInt(1)), decoded)
+	require.NoError(t, err)
+	require.Equal(t, account.Address, sender)
+}
+
+func TestWalletSignTxUnsignedAuthorization(t *testing.T) {
+	key := mustGenerateKey(t)
+	device := newMockEthereumApp(key, nil)
+	w, path := newMockWallet(t, device)
+
+	account, err := w.Derive(path, true)
+	require.NoError(t, err)
+	device.history = nil
+
+	tx := coretypes.NewTx(&coretypes.SetCodeTx{
+		ChainID: uint256.NewInt(1), GasTipCap: uint256.NewInt(1), GasFeeCap: uint256.NewInt(2), Gas: 60000, To: account.Address,
+		Value: uint256.NewInt(0), AuthList: []coretypes.SetCodeAuthorization{{Address: common.Address{0x01}}},
+	})
+	_, err = w.SignTx(account, tx, big.NewInt(1))
+	require.ErrorContains(t, err, "authorization 0 is not signed")
+	require.Empty(t, device.history)
+}
+
+func TestLedgerSignAuthorizationUnsupported(t *testing.T) {
+	device := &mockLedger{handler: func(apdu mockAPDU) ([]byte, uint16) { return nil, 0x6d00 }}
+	driver := &ledgerDriver{device: device, version: [3]byte{1, 9, 0}}
+
+	_, err := driver.SignAuthorization(gethaccounts.DefaultBaseDerivationPath, coretypes.SetCodeAuthorization{})
+	require.ErrorIs(t, err, gethaccounts.ErrNotSupported)
+}
+
+func TestWalletDeriveEth2PublicKey(t *testing.T) {
+	_, _, g1, _ := bls12381.Generators()
+	var point bls12381.G1Affine
+	point.ScalarMultiplication(&g1, big.NewInt(7))
+	pubkey := point.Bytes()
+
+	var reply []byte
+	device := newMockEthereumApp(mustGenerateKey(t), func(apdu mockAPDU) ([]byte, uint16) {
+		switch apdu.ins {
+		case byte(ledgerOpEth2GetPublicKey):
+			return reply, ledgerStatusOK
+		case byte(ledgerOpEth2SetWithdraw):
+			return nil, ledgerStatusOK
+		}
+		return nil, 0x6d00
+	})
+	w, _ := newMockWallet(t, device)
+
+	// Valid keys are returned as is, with the path sent verbatim
+	reply = pubkey[:]
+	key, err := w.DeriveEth2PublicKey(accounts.Eth2WithdrawalPath(3))
+	require.NoError(t, err)
+	require.Equal(t, pubkey[:], key.Bytes())
+
+	apdu := device.history[len(device.history)-1]
+	require.Equal(t, []byte{4, 0, 0, 0x30, 0x5d, 0, 0, 0x0e, 0x10, 0, 0, 0, 3, 0, 0, 0, 0}, apdu.data)
+
+	// Malformed keys are rejected
+	reply = pubkey[:47]
+	_, err = w.DeriveEth2PublicKey(accounts.Eth2WithdrawalPath(0))
+	require.ErrorContains(t, err, "invalid BLS public key length")
+
+	reply = common.CopyBytes(pubkey[:])
+	reply[47] ^= 0x01
+	_, err = w.DeriveEth2PublicKey(accounts.Eth2WithdrawalPath(0))
+	require.ErrorContains(t, err, "invalid BLS public key")
+
+	reply = make([]byte, 48)
+	_, err = w.DeriveEth2PublicKey(accounts.Eth2WithdrawalPath(0))
+	require.ErrorContains(t, err, "not in compressed form")
+
+	// Non EIP-2334 paths never reach the device
+	device.history = nil
+	_, err = w.DeriveEth2PublicKey(gethaccounts.DefaultBaseDerivationPath)
+	require.Error(t, err)
+	require.Empty(t, device.history)
+
+	// Withdrawal indices are sent big endian
+	require.NoError(t, w.SetEth2WithdrawalIndex(0x01020304))
+	require.Equal(t, []byte{1, 2, 3, 4}, device.history[0].data)
+}
+
+func TestLedgerEth2Unsupported(t *testing.T) {
+	device := &mockLedger{handler: func(apdu mockAPDU) ([]byte, uint16) { return nil, 0x6d00 }}
+	driver := &ledgerDriver{device: device, version: [3]byte{1, 9, 0}}
+
+	_, err := driver.Eth2PublicKey(accounts.Eth2WithdrawalPath(0))
+	require.ErrorIs(t, err, gethaccounts.ErrNotSupported)
+	require.ErrorIs(t, driver.SetEth2WithdrawalIndex(1), gethaccounts.ErrNotSupported)
+}
+
+// newMockPrivacyHandler answers privacy operations the way the Ethereum app does,
+// using the account's secp256k1 private key as the X25519 scalar.
+func newMockPrivacyHandler(key *ecdsa.PrivateKey) func(apdu mockAPDU) ([]byte, uint16) {
+	return func(apdu mockAPDU) ([]byte, uint16) {
+		if apdu.ins != byte(ledgerOpPrivacy) {
+			return nil, 0x6d00
+		}
+		scalar := crypto.FromECDSA(key)
+		peer := curve25519.Basepoint
+		if apdu.p2 == byte(ledgerP2PrivacySharedSecret) {
+			peer = apdu.data[1+4*int(apdu.data[0]):]
+		}
+		out, err := curve25519.X25519(scalar, peer)
+		if err != nil {
+			return nil, 0x6a80
+		}
+		return out, ledgerStatusOK
+	}
+}
+
+func TestWalletDecrypt(t *testing.T) {
+	// Test vector from MetaMask's eth-sig-util
+	key, err := crypto.HexToECDSA("7e5374ec2ef0d91761a6e72fdf8f6ac665519bfdf6da0a2329cf0d804514b816")
+	require.NoError(t, err)
+
+	device := newMockEthereumApp(key, newMockPrivacyHandler(key))
+	w, path := newMockWallet(t, device)
+
+	account, err := w.Derive(path, true)
+	require.NoError(t, err)
+
+	ciphertext := `{"version":"x25519-xsalsa20-poly1305","nonce":"1dvWO7uOnBnO7iNDJ9kO9pTasLuKNlej","ephemPublicKey":"FBH1/pAEHOOW14Lu3FWkgV3qOEcuL78Zy+qW1RwzMXQ=","ciphertext":"f8kBcl/NCyf3sybfbwAKk/np2Bzt9lRVkZejr6uh5FgnNlH/ic62DZzy"}`
+	message, err := w.Decrypt(account, []byte(ciphertext))
+	require.NoError(t, err)
+	require.Equal(t, "My name is Satoshi Buterin", string(message))
+
+	apdu := device.history[len(device.history)-1]
+	require.Equal(t, byte(ledgerP1ConfirmPrivacyOperation), apdu.p1)
+	require.Equal(t, byte(ledgerP2PrivacySharedSecret), apdu.p2)
+
+	// Hex encoded JSON, as sent by dapps, is accepted too
+	message, err = w.Decrypt(account, []byte(hexutil.Encode([]byte(ciphertext))))
+	require.NoError(t, err)
+	require.Equal(t, "My name is Satoshi Buterin", string(message))
+}
+
+func TestWalletEncryptionRoundtrip(t *testing.T) {
+	key := mustGenerateKey(t)
+	w, path := newMockWallet(t, newMockEthereumApp(key, newMockPrivacyHandler(key)))
+
+	account, err := w.Derive(path, true)
+	require.NoError(t, err)
+
+	pubkey, err := w.GetEncryptionPublicKey(account)
+	require.NoError(t, err)
+
+	data, err := accounts.Encrypt(pubkey, []byte("hello ledger"))
+	require.NoError(t, err)
+	ciphertext, err := json.Marshal(data)
+	require.NoError(t, err)
+
+	message, err := w.Decrypt(account, ciphertext)
+	require.NoError(t, err)
+	require.Equal(t, "hello ledger", string(message))
+
+	// Tampered ciphertexts and unknown schemes are rejected
+	data.Ciphertext[0] ^= 0xff
+	ciphertext, _ = json.Marshal(data)
+	_, err = w.Decrypt(account, ciphertext)
+	require.EqualError(t, err, "decryption failed")
+
+	data.Version = "x25519-chacha20-poly1305"
+	ciphertext, _ = json.Marshal(data)
+	_, err = w.Decrypt(account, ciphertext)
+	require.ErrorContains(t, err, "unsupported encryption version")
+}
+
+// providerFunc is an adapter to use functions as transaction metadata providers.
+type providerFunc func(tx *coretypes.Transaction, chainID *big.Int) (*TransactionMetadata, error)
+
+func (f providerFunc) TransactionMetadata(tx *coretypes.Transaction, chainID *big.Int) (*TransactionMetadata, error) {
+	return f(tx, chainID)
+}
+
+// typedProviderFunc is an adapter to use functions as typed data metadata
+// providers.
+type typedProviderFunc func(typedData apitypes.TypedData) (*TypedDataMetadata, error)
+
+func (f typedProviderFunc) TypedDataMetadata(typedData apitypes.TypedData) (*TypedDataMetadata, error) {
+	return f(typedData)
+}
+
+func mustGenerateKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	return key
+}
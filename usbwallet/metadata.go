@@ -0,0 +1,85 @@
+package usbwallet
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// TrustedNameResolver looks up human readable names (ENS or other domains) for
+// addresses, packaged into payloads signed by a source the Ledger Ethereum app
+// trusts. The resolved name is displayed by the device in place of the address
+// when confirming a transaction.
+type TrustedNameResolver interface {
+	// ResolveTrustedName returns the signed trusted name payload for an address
+	// on the given chain. The payload must embed the challenge retrieved from the
+	// device, otherwise it will be rejected as a replay. A nil payload without an
+	// error signals that the address has no known name.
+	ResolveTrustedName(address common.Address, chainID *big.Int, challenge uint32) ([]byte, error)
+}
+
+// TrustedNameResolverFunc is an adapter to allow the use of ordinary functions
+// as trusted name resolvers.
+type TrustedNameResolverFunc func(address common.Address, chainID *big.Int, challenge uint32) ([]byte, error)
+
+// ResolveTrustedName implements TrustedNameResolver, calling f(address, chainID,
+// challenge).
+func (f TrustedNameResolverFunc) ResolveTrustedName(address common.Address, chainID *big.Int, challenge uint32) ([]byte, error) {
+	return f(address, chainID, challenge)
+}
+
+// TransactionMetadata is signed clear signing metadata describing how the Ledger
+// Ethereum app should display the calldata of a transaction, instead of asking
+// the user to blind sign it.
+type TransactionMetadata struct {
+	Info   []byte   // Signed descriptor of the transaction (contract, selector, intent)
+	Fields [][]byte // Signed descriptors of the calldata fields, in display order
+}
+
+// TransactionMetadataProvider looks up the clear signing metadata of outgoing
+// transactions, e.g. from ERC-7730 descriptors.
+type TransactionMetadataProvider interface {
+	// TransactionMetadata returns the signed clear signing metadata describing
+	// the given transaction. A nil metadata without an error signals that the
+	// transaction is unknown and will be blind signed.
+	TransactionMetadata(tx *coretypes.Transaction, chainID *big.Int) (*TransactionMetadata, error)
+}
+
+// TypedDataMetadata is signed clear signing metadata describing how the Ledger
+// Ethereum app should display the fields of an EIP-712 message, instead of only
+// showing its domain and message hashes.
+type TypedDataMetadata struct {
+	Info   []byte           // Signed descriptor of the message (display name, number of fields)
+	Fields []TypedDataField // Signed descriptors of the displayed fields
+}
+
+// TypedDataField is the signed descriptor of a message field, provided to the
+// device right before the field's value is streamed.
+type TypedDataField struct {
+	Path       string               // Dot separated path of the field in the message, "[]" for array elements (e.g. "permitted.[].amount")
+	Format     TypedDataFieldFormat // Rendering of the field's value
+	Descriptor []byte               // Signed descriptor (display name and signature)
+}
+
+// TypedDataFieldFormat selects how the device renders the value of a described
+// EIP-712 message field.
+type TypedDataFieldFormat byte
+
+const (
+	TypedDataFormatRaw         TypedDataFieldFormat = iota // Value displayed as is
+	TypedDataFormatDateTime                                // Unix timestamp displayed as a date
+	TypedDataFormatAmountToken                             // Token address of an amount, joined with its value
+	TypedDataFormatAmount                                  // Amount displayed in units of its token
+)
+
+// TypedDataMetadataProvider looks up the clear signing metadata of EIP-712
+// messages, e.g. from ERC-7730 descriptors matched on their domain and primary
+// type.
+type TypedDataMetadataProvider interface {
+	// TypedDataMetadata returns the signed clear signing metadata describing the
+	// given message. A nil metadata without an error signals that the message is
+	// unknown and only its hashes will be displayed.
+	TypedDataMetadata(typedData apitypes.TypedData) (*TypedDataMetadata, error)
+}
@@ -0,0 +1,437 @@
+// Package simulator implements an in-process Ledger device running the Ethereum
+// app, speaking the same HID transport as the real hardware. It is meant to run
+// tests and local setups end-to-end without a physical device attached.
+package simulator
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"sync"
+
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// AppVersion is the Ethereum app version reported by the simulated device.
+var AppVersion = [3]byte{1, 13, 0}
+
+// Status words returned by the simulated device.
+const (
+	statusOK              = 0x9000
+	statusUserRejected    = 0x6985
+	statusInvalidData     = 0x6a80
+	statusInsNotSupported = 0x6d00
+	statusClaNotSupported = 0x6e00
+	statusInternalFailure = 0x6f00
+)
+
+// Instruction classes, codes and parameters understood by the simulated device.
+const (
+	claEthereum             = 0xe0
+	claDashboard            = 0xb0
+	insGetAppAndVersion     = 0x01
+	insGetAddress           = 0x02
+	insSignTransaction      = 0x04
+	insGetConfiguration     = 0x06
+	insSignPersonalMessage  = 0x08
+	insSignTypedMessage     = 0x0c
+	insPrivacy              = 0x18
+	insGetChallenge         = 0x20
+	insProvideTrustedName   = 0x22
+	insProvideTxInfo        = 0x26
+	insProvideTxField       = 0x28
+	insSignAuthorization    = 0x34
+	p1ConfirmAddress        = 0x01
+	p1ContTransactionData   = 0x80
+	p1ContPersonalMessage   = 0x80
+	p1InitAuthorizationData = 0x01
+	p2StoreTransaction      = 0x01
+	p2StartSigningFlow      = 0x02
+	p2PrivacySharedSecret   = 0x02
+)
+
+// errInvalidFrame is returned if a written HID frame lacks the transport header.
+var errInvalidFrame = errors.New("simulator: invalid frame header")
+
+// Device is a simulated Ledger running the Ethereum app, deriving its keys from
+// a BIP-39 mnemonic. It implements io.ReadWriteCloser over HID frames.
+type Device struct {
+	seed []byte // BIP-39 seed the BIP-32 keys are derived from
+
+	reject bool // Whether the simulated user rejects every confirmation
+
+	request []byte // Partially received APDU, including its length prefix
+	pending []byte // Reply frames not yet read by the host
+
+	stored  []byte // Transaction being streamed or stored awaiting signing
+	signing []byte // Derivation path of the streamed request
+	message []byte // Personal message or authorization being streamed
+
+	lock sync.Mutex
+}
+
+// New creates a simulated device holding the seed of the given mnemonic. The
+// mnemonic is not checked against the BIP-39 word list.
+func New(mnemonic string) *Device {
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	return &Device{
+		seed: pbkdf2.Key([]byte(mnemonic), []byte("mnemonic"), 2048, 64, sha512.New),
+	}
+}
+
+// SetRejecting configures whether the simulated user rejects all subsequent
+// requests needing confirmation on the device.
+func (d *Device) SetRejecting(reject bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.reject = reject
+}
+
+// PrivateKey derives the BIP-32 private key of a derivation path, as used by the
+// device for signing.
+func (d *Device) PrivateKey(path gethaccounts.DerivationPath) (*ecdsa.PrivateKey, error) {
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(d.seed)
+	sum := mac.Sum(nil)
+
+	var (
+		order = crypto.S256().Params().N
+		key   = new(big.Int).SetBytes(sum[:32])
+		chain = sum[32:]
+	)
+	for _, index := range path {
+		mac = hmac.New(sha512.New, chain)
+		if index >= 0x80000000 {
+			mac.Write([]byte{0x00})
+			mac.Write(math.PaddedBigBytes(key, 32))
+		} else {
+			priv, err := crypto.ToECDSA(math.PaddedBigBytes(key, 32))
+			if err != nil {
+				return nil, err
+			}
+			mac.Write(crypto.CompressPubkey(&priv.PublicKey))
+		}
+		mac.Write(binary.BigEndian.AppendUint32(nil, index))
+		sum = mac.Sum(nil)
+
+		tweak := new(big.Int).SetBytes(sum[:32])
+		if tweak.Cmp(order) >= 0 {
+			return nil, errors.New("simulator: invalid child key")
+		}
+		key.Add(key, tweak).Mod(key, order)
+		chain = sum[32:]
+	}
+	return crypto.ToECDSA(math.PaddedBigBytes(key, 32))
+}
+
+// Address derives the Ethereum address of a derivation path.
+func (d *Device) Address(path gethaccounts.DerivationPath) (common.Address, error) {
+	key, err := d.PrivateKey(path)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(key.PublicKey), nil
+}
+
+// Write implements io.Writer, accepting an HID frame of an APDU. Once the APDU
+// is complete, it is processed and the reply queued up for reading.
+func (d *Device) Write(frame []byte) (int, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if len(frame) < 5 || frame[0] != 0x01 || frame[1] != 0x01 || frame[2] != 0x05 {
+		return 0, errInvalidFrame
+	}
+	d.request = append(d.request, frame[5:]...)
+	if len(d.request) < 2 {
+		return len(frame), nil
+	}
+	size := int(binary.BigEndian.Uint16(d.request))
+	if len(d.request) < 2+size {
+		return len(frame), nil
+	}
+	raw := d.request[2 : 2+size]
+	d.request = nil
+
+	var (
+		data   []byte
+		status uint16 = statusInvalidData
+	)
+	if len(raw) >= 5 {
+		data, status = d.exchange(raw[0], raw[1], raw[2], raw[3], common.CopyBytes(raw[5:]))
+	}
+	reply := binary.BigEndian.AppendUint16(data, status)
+
+	// Frame the reply the same way the device would
+	reply = append(binary.BigEndian.AppendUint16(nil, uint16(len(reply))), reply...)
+	for seq := 0; len(reply) > 0; seq++ {
+		out := []byte{0x01, 0x01, 0x05, byte(seq >> 8), byte(seq)}
+		n := min(64-len(out), len(reply))
+
+		out = append(out, reply[:n]...)
+		out = append(out, make([]byte, 64-len(out))...)
+		d.pending = append(d.pending, out...)
+		reply = reply[n:]
+	}
+	return len(frame), nil
+}
+
+// Read implements io.Reader, returning the queued reply frames.
+func (d *Device) Read(buf []byte) (int, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if len(d.pending) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(buf, d.pending)
+	d.pending = d.pending[n:]
+	return n, nil
+}
+
+// Close implements io.Closer, dropping any half processed exchange. The device
+// can be reused afterwards, like a reconnected Ledger.
+func (d *Device) Close() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.request, d.pending = nil, nil
+	d.stored, d.signing, d.message = nil, nil, nil
+	return nil
+}
+
+// exchange processes a single APDU, returning the reply data and status word.
+func (d *Device) exchange(cla, ins, p1, p2 byte, data []byte) ([]byte, uint16) {
+	if cla == claDashboard && ins == insGetAppAndVersion {
+		version := fmt.Sprintf("%d.%d.%d", AppVersion[0], AppVersion[1], AppVersion[2])
+
+		reply := append([]byte{0x01, 8}, "Ethereum"...)
+		reply = append(append(reply, byte(len(version))), version...)
+		return append(reply, 0x01, 0x00), statusOK
+	}
+	if cla != claEthereum {
+		return nil, statusClaNotSupported
+	}
+	switch ins {
+	case insGetConfiguration:
+		return []byte{0x01, AppVersion[0], AppVersion[1], AppVersion[2]}, statusOK
+
+	case insGetAddress:
+		key, _, status := d.parseKey(data)
+		if status != statusOK {
+			return nil, status
+		}
+		if p1 == p1ConfirmAddress && d.reject {
+			return nil, statusUserRejected
+		}
+		pubkey := crypto.FromECDSAPub(&key.PublicKey)
+		addr := hex.EncodeToString(crypto.PubkeyToAddress(key.PublicKey).Bytes())
+
+		reply := append([]byte{byte(len(pubkey))}, pubkey...)
+		reply = append(reply, byte(len(addr)))
+		return append(reply, addr...), statusOK
+
+	case insSignTransaction:
+		return d.signTransaction(p1, p2, data)
+
+	case insSignPersonalMessage:
+		if p1 != p1ContPersonalMessage {
+			_, rest, status := d.parseKey(data)
+			if status != statusOK {
+				return nil, status
+			}
+			d.signing, d.message = data[:len(data)-len(rest)], rest
+		} else {
+			d.message = append(d.message, data...)
+		}
+		if len(d.message) < 4 || len(d.message)-4 < int(binary.BigEndian.Uint32(d.message)) {
+			return nil, statusOK
+		}
+		return d.sign(gethaccounts.TextHash(d.message[4:]), 27)
+
+	case insSignTypedMessage:
+		_, rest, status := d.parseKey(data)
+		if status != statusOK {
+			return nil, status
+		}
+		if len(rest) != 64 {
+			return nil, statusInvalidData
+		}
+		d.signing = data[:len(data)-len(rest)]
+		return d.sign(crypto.Keccak256([]byte{0x19, 0x01}, rest), 27)
+
+	case insSignAuthorization:
+		return d.signAuthorization(p1, data)
+
+	case insPrivacy:
+		key, rest, status := d.parseKey(data)
+		if status != statusOK {
+			return nil, status
+		}
+		if d.reject {
+			return nil, statusUserRejected
+		}
+		peer := curve25519.Basepoint
+		if p2 == p2PrivacySharedSecret {
+			peer = rest
+		}
+		out, err := curve25519.X25519(crypto.FromECDSA(key), peer)
+		if err != nil {
+			return nil, statusInvalidData
+		}
+		return out, statusOK
+
+	case insGetChallenge:
+		return []byte{0xde, 0xad, 0xbe, 0xef}, statusOK
+
+	case insProvideTrustedName, insProvideTxInfo, insProvideTxField:
+		return nil, statusOK // Metadata is accepted without checking its signature
+	}
+	return nil, statusInsNotSupported
+}
+
+// signTransaction accumulates the streamed transaction, signing it once fully
+// received (or once its signing flow is started after storing it).
+func (d *Device) signTransaction(p1, p2 byte, data []byte) ([]byte, uint16) {
+	if p2 == p2StartSigningFlow {
+		return d.signStoredTransaction()
+	}
+	if p1 != p1ContTransactionData {
+		_, rest, status := d.parseKey(data)
+		if status != statusOK {
+			return nil, status
+		}
+		d.signing, d.stored = data[:len(data)-len(rest)], rest
+	} else {
+		d.stored = append(d.stored, data...)
+	}
+	// Wait for the rest of the transaction if it's not yet complete
+	payload := d.stored
+	if len(payload) > 0 && payload[0] < 0xc0 {
+		payload = payload[1:]
+	}
+	if _, _, rest, err := rlp.Split(payload); err != nil || len(rest) > 0 {
+		return nil, statusOK
+	}
+	if p2 == p2StoreTransaction {
+		return nil, statusOK
+	}
+	return d.signStoredTransaction()
+}
+
+// signStoredTransaction signs the received transaction payload, computing V the
+// way the Ethereum app does.
+func (d *Device) signStoredTransaction() ([]byte, uint16) {
+	payload := d.stored
+	d.stored = nil
+	if len(payload) == 0 {
+		return nil, statusInvalidData
+	}
+	// Typed transactions use the plain parity as V
+	if payload[0] < 0xc0 {
+		return d.sign(crypto.Keccak256(payload), 0)
+	}
+	// Legacy transactions carry the chain ID for EIP-155 replay protection, of
+	// which only the lowest byte is returned
+	var fields []rlp.RawValue
+	if err := rlp.DecodeBytes(payload, &fields); err != nil {
+		return nil, statusInvalidData
+	}
+	offset := byte(27)
+	if len(fields) == 9 {
+		chainID := new(big.Int)
+		if err := rlp.DecodeBytes(fields[6], chainID); err != nil {
+			return nil, statusInvalidData
+		}
+		offset = byte(chainID.Uint64()*2 + 35)
+	}
+	return d.sign(crypto.Keccak256(payload), offset)
+}
+
+// signAuthorization accumulates the TLV encoded EIP-7702 authorization, signing
+// it once fully received.
+func (d *Device) signAuthorization(p1 byte, data []byte) ([]byte, uint16) {
+	if p1 == p1InitAuthorizationData {
+		_, rest, status := d.parseKey(data)
+		if status != statusOK {
+			return nil, status
+		}
+		d.signing, d.message = data[:len(data)-len(rest)], rest
+	} else {
+		d.message = append(d.message, data...)
+	}
+	if len(d.message) < 2 || len(d.message)-2 < int(binary.BigEndian.Uint16(d.message)) {
+		return nil, statusOK
+	}
+	var (
+		auth coretypes.SetCodeAuthorization
+		tlv  = d.message[2:]
+	)
+	for len(tlv) > 0 {
+		if len(tlv) < 2 || len(tlv) < 2+int(tlv[1]) {
+			return nil, statusInvalidData
+		}
+		tag, value := tlv[0], tlv[2:2+int(tlv[1])]
+		switch tag {
+		case 0x01:
+			auth.Address = common.BytesToAddress(value)
+		case 0x02:
+			auth.ChainID.SetBytes(value)
+		case 0x03:
+			auth.Nonce = new(big.Int).SetBytes(value).Uint64()
+		}
+		tlv = tlv[2+len(value):]
+	}
+	sighash := auth.SigHash()
+	return d.sign(sighash[:], 0)
+}
+
+// sign signs the hash with the key of the current request's derivation path,
+// returning the [V || R || S] reply with the offset added to V.
+func (d *Device) sign(hash []byte, offset byte) ([]byte, uint16) {
+	key, _, status := d.parseKey(d.signing)
+	d.signing = nil
+	if status != statusOK {
+		return nil, status
+	}
+	if d.reject {
+		return nil, statusUserRejected
+	}
+	sig, err := crypto.Sign(hash, key)
+	if err != nil {
+		return nil, statusInternalFailure
+	}
+	return append([]byte{offset + sig[crypto.RecoveryIDOffset]}, sig[:crypto.RecoveryIDOffset]...), statusOK
+}
+
+// parseKey derives the key of the derivation path prefixing a request payload,
+// returning the data following the path.
+func (d *Device) parseKey(data []byte) (*ecdsa.PrivateKey, []byte, uint16) {
+	if len(data) < 1 || data[0] > 10 || len(data) < 1+4*int(data[0]) {
+		return nil, nil, statusInvalidData
+	}
+	path := make(gethaccounts.DerivationPath, data[0])
+	for i := range path {
+		path[i] = binary.BigEndian.Uint32(data[1+4*i:])
+	}
+	key, err := d.PrivateKey(path)
+	if err != nil {
+		return nil, nil, statusInvalidData
+	}
+	return key, data[1+4*len(path):], statusOK
+}
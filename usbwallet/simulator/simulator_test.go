@@ -0,0 +1,106 @@
+package simulator_test
+
+import (
+	"math/big"
+	"testing"
+
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evmos/ethereum-ledger-go/accounts"
+	"github.com/evmos/ethereum-ledger-go/internal/testutil"
+	"github.com/evmos/ethereum-ledger-go/usbwallet"
+)
+
+func TestDeviceDerivation(t *testing.T) {
+	wallet, account, device := testutil.SimulatedWallet(t)
+
+	addr, err := device.Address(gethaccounts.DefaultBaseDerivationPath)
+	require.NoError(t, err)
+	require.Equal(t, testutil.Address, addr)
+	require.Equal(t, addr, account.Address)
+
+	status, err := wallet.Status()
+	require.NoError(t, err)
+	require.Equal(t, "Ethereum app v1.13.0 online", status)
+}
+
+func TestDeviceConfirmAddress(t *testing.T) {
+	wallet, account, device := testutil.SimulatedWallet(t)
+
+	path := gethaccounts.DerivationPath{0x80000000 + 44, 0x80000000 + 60, 0x80000000 + 0, 0, 1}
+	confirmed, err := wallet.ConfirmAddress(path)
+	require.NoError(t, err)
+
+	expected, err := device.Address(path)
+	require.NoError(t, err)
+	require.Equal(t, expected, confirmed.Address)
+
+	// Confirmed accounts aren't pinned, and the user may deny the address
+	require.Equal(t, []accounts.Account{account}, wallet.Accounts())
+
+	device.SetRejecting(true)
+	_, err = wallet.ConfirmAddress(path)
+	require.ErrorIs(t, err, usbwallet.ErrLedgerUserRejected)
+}
+
+func TestDeviceSignTx(t *testing.T) {
+	wallet, account, _ := testutil.SimulatedWallet(t)
+
+	to := common.HexToAddress("0x0102030405060708091011121314151617181920")
+	chainID := big.NewInt(1337)
+	data := make([]byte, 600) // Spans multiple APDU chunks
+
+	txs := []*coretypes.Transaction{
+		coretypes.NewTransaction(1, to, big.NewInt(1), 100000, big.NewInt(1), data),
+		coretypes.NewTx(&coretypes.DynamicFeeTx{
+			ChainID:   chainID,
+			Nonce:     2,
+			GasTipCap: big.NewInt(1),
+			GasFeeCap: big.NewInt(10),
+			Gas:       100000,
+			To:        &to,
+			Value:     big.NewInt(1),
+			Data:      data,
+		}),
+	}
+	for _, tx := range txs {
+		raw, err := wallet.SignTx(account, tx, chainID)
+		require.NoError(t, err)
+
+		signed := new(coretypes.Transaction)
+		require.NoError(t, signed.UnmarshalBinary(raw))
+
+		sender, err := coretypes.Sender(coretypes.LatestSignerForChainID(chainID), signed)
+		require.NoError(t, err)
+		require.Equal(t, account.Address, sender)
+	}
+}
+
+func TestDeviceSignText(t *testing.T) {
+	wallet, account, _ := testutil.SimulatedWallet(t)
+
+	text := []byte("hello simulator")
+	sig, err := wallet.SignText(account, text)
+	require.NoError(t, err)
+
+	sig[crypto.RecoveryIDOffset] -= 27
+	pubkey, err := crypto.SigToPub(gethaccounts.TextHash(text), sig)
+	require.NoError(t, err)
+	require.Equal(t, account.Address, crypto.PubkeyToAddress(*pubkey))
+}
+
+func TestDeviceRejects(t *testing.T) {
+	wallet, account, device := testutil.SimulatedWallet(t)
+
+	device.SetRejecting(true)
+	_, err := wallet.SignText(account, []byte("hello"))
+	require.ErrorIs(t, err, usbwallet.ErrLedgerUserRejected)
+
+	device.SetRejecting(false)
+	_, err = wallet.SignText(account, []byte("hello"))
+	require.NoError(t, err)
+}
@@ -62,11 +62,60 @@ type driver interface {
 	// address located on that path.
 	Derive(path gethaccounts.DerivationPath) (common.Address, *ecdsa.PublicKey, error)
 
+	// ConfirmAddress sends a derivation request to the USB device, displaying the
+	// address located on that path and waiting for the user to confirm it.
+	ConfirmAddress(path gethaccounts.DerivationPath) (common.Address, *ecdsa.PublicKey, error)
+
 	// SignTx sends the transaction to the USB device and waits for the user to confirm
-	// or deny the transaction.
-	SignTx(path gethaccounts.DerivationPath, tx *coretypes.Transaction, chainID *big.Int) (common.Address, []byte, error)
+	// or deny the transaction. The optional metadata describes the transaction's
+	// calldata so the device can display it instead of requiring blind signing.
+	SignTx(path gethaccounts.DerivationPath, tx *coretypes.Transaction, chainID *big.Int, meta *TransactionMetadata) (common.Address, []byte, error)
 
 	SignTypedMessage(path gethaccounts.DerivationPath, messageHash []byte, domainHash []byte) ([]byte, error)
+
+	// SignTypedData streams the EIP-712 message to the USB device along with the
+	// metadata describing its fields, and waits for the user to confirm or deny
+	// it, returning the 65 byte [R || S || V] signature with V being 27 or 28.
+	SignTypedData(path gethaccounts.DerivationPath, typedData apitypes.TypedData, meta *TypedDataMetadata) ([]byte, error)
+
+	// SignPersonalMessage sends the EIP-191 personal message to the USB device and
+	// waits for the user to confirm or deny it, returning the 65 byte [R || S || V]
+	// signature with V being 27 or 28.
+	SignPersonalMessage(path gethaccounts.DerivationPath, message []byte) ([]byte, error)
+
+	// SignAminoJSON sends the Cosmos SDK Amino JSON sign doc to the USB device and
+	// waits for the user to confirm or deny it, returning the 64 byte [R || S]
+	// signature.
+	SignAminoJSON(path gethaccounts.DerivationPath, signDoc []byte) ([]byte, error)
+
+	// SignAuthorization sends the EIP-7702 authorization to the USB device and waits
+	// for the user to confirm or deny it, returning the 65 byte [R || S || V]
+	// signature with V being the recovery parity.
+	SignAuthorization(path gethaccounts.DerivationPath, auth coretypes.SetCodeAuthorization) ([]byte, error)
+
+	// Eth2PublicKey sends an EIP-2334 derivation request to the USB device and
+	// returns the BLS12-381 public key located on that path.
+	Eth2PublicKey(path gethaccounts.DerivationPath) (accounts.BLSPublicKey, error)
+
+	// SetEth2WithdrawalIndex sets the withdrawal key index the USB device uses
+	// when generating Ethereum 2 deposit data.
+	SetEth2WithdrawalIndex(index uint32) error
+
+	// EncryptionPublicKey retrieves the X25519 public encryption key the USB device
+	// derives for the account at the given path.
+	EncryptionPublicKey(path gethaccounts.DerivationPath) ([32]byte, error)
+
+	// SharedSecret computes the X25519 shared secret between the account at the
+	// given path and the peer key, waiting for the user to confirm it.
+	SharedSecret(path gethaccounts.DerivationPath, peer [32]byte) ([32]byte, error)
+
+	// Challenge retrieves a fresh anti-replay challenge from the USB device, to be
+	// embedded into signed metadata payloads.
+	Challenge() (uint32, error)
+
+	// ProvideTrustedName sends a signed trusted name payload to the USB device, to
+	// be displayed in place of the matching address during the next signing.
+	ProvideTrustedName(payload []byte) error
 }
 
 // wallet represents the common functionality shared by all USB hardware
@@ -77,8 +126,9 @@ type wallet struct {
 	driver driver            // Hardware implementation of the low level device operations
 	url    *gethaccounts.URL // Textual URL uniquely identifying this wallet
 
-	info   usb.DeviceInfo // Known USB device infos about the wallet
-	device *usb.Device    // USB device advertising itself as a hardware wallet
+	info   usb.DeviceInfo                     // Known USB device infos about the wallet
+	dial   func() (io.ReadWriteCloser, error) // Optional opener of non-USB devices (e.g. simulators)
+	device io.ReadWriteCloser                 // USB device advertising itself as a hardware wallet
 
 	accounts []accounts.Account                             // List of derive accounts pinned on the hardware wallet
 	paths    map[common.Address]gethaccounts.DerivationPath // Known derivation paths for signing operations
@@ -129,6 +179,11 @@ func (w *wallet) Status() (string, error) {
 // Open implements accounts.Wallet, attempting to open a USB connection to the
 // hardware wallet.
 func (w *wallet) Open(passphrase string) error {
+	// Resolve the hub settings before locking the wallet, the hub locks the other way around
+	var hrp string
+	if _, ok := w.driver.(hrpDriver); ok {
+		hrp = w.hub.cosmosPrefix()
+	}
 	w.stateLock.Lock() // State lock is enough since there's no connection yet at this point
 	defer w.stateLock.Unlock()
 
@@ -138,7 +193,15 @@ func (w *wallet) Open(passphrase string) error {
 	}
 	// Make sure the actual device connection is done only once
 	if w.device == nil {
-		device, err := w.info.Open()
+		var (
+			device io.ReadWriteCloser
+			err    error
+		)
+		if w.dial != nil {
+			device, err = w.dial()
+		} else {
+			device, err = w.info.Open()
+		}
 		if err != nil {
 			return err
 		}
@@ -147,6 +210,9 @@ func (w *wallet) Open(passphrase string) error {
 		w.commsLock <- struct{}{} // Enable lock
 	}
 	// Delegate device initialization to the underlying driver
+	if d, ok := w.driver.(hrpDriver); ok {
+		d.setHRP(hrp)
+	}
 	if err := w.driver.Open(w.device, passphrase); err != nil {
 		return err
 	}
@@ -157,6 +223,9 @@ func (w *wallet) Open(passphrase string) error {
 
 	go w.heartbeat()
 
+	// Notify anyone listening for wallet events that a new device is accessible
+	go w.hub.updateFeed.Send(accounts.WalletEvent{Wallet: w, Kind: gethaccounts.WalletOpened})
+
 	return nil
 }
 
@@ -319,44 +388,104 @@ func (w *wallet) Derive(path gethaccounts.DerivationPath, pin bool) (accounts.Ac
 	return account, nil
 }
 
-// Format the hd path to harden the first three values (purpose, coinType, account)
-// if needed, modifying the array in-place.
-func formatPathIfNeeded(path gethaccounts.DerivationPath) {
-	for i := 0; i < 3; i++ {
-		if path[i] < 0x80000000 {
-			path[i] += 0x80000000
-		}
+// ConfirmAddress implements accounts.Wallet, deriving the account at the path
+// while displaying its address on the device for the user to compare against and
+// confirm. The account is not pinned.
+func (w *wallet) ConfirmAddress(path gethaccounts.DerivationPath) (accounts.Account, error) {
+	formatPathIfNeeded(path)
+
+	var (
+		address   common.Address
+		publicKey *ecdsa.PublicKey
+	)
+	err := w.withDevice(func() (err error) {
+		address, publicKey, err = w.driver.ConfirmAddress(path)
+		return err
+	})
+	if err != nil {
+		return accounts.Account{}, err
 	}
+	return accounts.Account{
+		Address:   address,
+		PublicKey: publicKey,
+		URL:       gethaccounts.URL{Scheme: w.url.Scheme, Path: fmt.Sprintf("%s/%s", w.url.Path, path)},
+	}, nil
 }
 
-// signHash implements accounts.Wallet, however signing arbitrary data is not
-// supported for hardware wallets, so this method will always return an error.
-func (w *wallet) signHash(account accounts.Account, hash []byte) ([]byte, error) {
-	return nil, gethaccounts.ErrNotSupported
+// DeriveEth2PublicKey implements accounts.Wallet, retrieving the BLS12-381 public
+// key at the specific EIP-2334 derivation path. Contrary to Derive, the path is
+// used verbatim without hardening any of its components.
+func (w *wallet) DeriveEth2PublicKey(path gethaccounts.DerivationPath) (accounts.BLSPublicKey, error) {
+	if err := accounts.ValidateEth2Path(path); err != nil {
+		return accounts.BLSPublicKey{}, err
+	}
+	w.stateLock.RLock() // Avoid device disappearing during derivation
+	defer w.stateLock.RUnlock()
+
+	if w.device == nil {
+		return accounts.BLSPublicKey{}, gethaccounts.ErrWalletClosed
+	}
+	<-w.commsLock // Avoid concurrent hardware access
+	defer func() { w.commsLock <- struct{}{} }()
+
+	return w.driver.Eth2PublicKey(path)
 }
 
-// SignData signs keccak256(data). The mimetype parameter describes the type of data being signed
-func (w *wallet) signData(account accounts.Account, mimeType string, data []byte) ([]byte, error) {
-	// Unless we are doing 712 signing, simply dispatch to signHash
-	if !(mimeType == gethaccounts.MimetypeTypedData && len(data) == 66 && data[0] == 0x19 && data[1] == 0x01) {
-		return w.signHash(account, crypto.Keccak256(data))
+// SetEth2WithdrawalIndex implements accounts.Wallet, setting the withdrawal key
+// index used by the device when generating Ethereum 2 deposit data.
+func (w *wallet) SetEth2WithdrawalIndex(index uint32) error {
+	w.stateLock.RLock() // Avoid device disappearing during the exchange
+	defer w.stateLock.RUnlock()
+
+	if w.device == nil {
+		return gethaccounts.ErrWalletClosed
 	}
+	<-w.commsLock // Avoid concurrent hardware access
+	defer func() { w.commsLock <- struct{}{} }()
 
-	// dispatch to 712 signing if the mimetype is TypedData and the format matches
+	return w.driver.SetEth2WithdrawalIndex(index)
+}
+
+// GetEncryptionPublicKey implements accounts.Wallet, retrieving the X25519 public
+// key the device derives for the account, compatible with eth_getEncryptionPublicKey.
+func (w *wallet) GetEncryptionPublicKey(account accounts.Account) (accounts.EncryptionPublicKey, error) {
+	var pubkey [32]byte
+	err := w.withAccount(account, func(path gethaccounts.DerivationPath) (err error) {
+		pubkey, err = w.driver.EncryptionPublicKey(path)
+		return err
+	})
+	return pubkey, err
+}
+
+// Decrypt implements accounts.Wallet, opening an eth_decrypt compatible message
+// with the shared secret computed by the device, so the private key never leaves it.
+func (w *wallet) Decrypt(account accounts.Account, ciphertext []byte) ([]byte, error) {
+	data, err := accounts.ParseEncryptedData(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	var secret [32]byte
+	err = w.withAccount(account, func(path gethaccounts.DerivationPath) (err error) {
+		secret, err = w.driver.SharedSecret(path, [32]byte(data.EphemPublicKey))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data.Open(secret)
+}
+
+// withDevice runs a device exchange requiring user confirmation, holding the
+// comms lock and keeping the hub from enumerating devices while it's pending.
+func (w *wallet) withDevice(fn func() error) error {
 	w.stateLock.RLock() // Comms have own mutex, this is for the state fields
 	defer w.stateLock.RUnlock()
 
 	// If the wallet is closed, abort
 	if w.device == nil {
-		return nil, gethaccounts.ErrWalletClosed
+		return gethaccounts.ErrWalletClosed
 	}
-	// Make sure the requested account is contained within
-	path, ok := w.paths[account.Address]
-	if !ok {
-		return nil, gethaccounts.ErrUnknownAccount
-	}
-	// All infos gathered and metadata checks out, request signing
-	<-w.commsLock
+	<-w.commsLock // Avoid concurrent hardware access
 	defer func() { w.commsLock <- struct{}{} }()
 
 	// Ensure the device isn't screwed with while user confirmation is pending
@@ -370,16 +499,118 @@ func (w *wallet) signData(account accounts.Account, mimeType string, data []byte
 		w.hub.commsPend--
 		w.hub.commsLock.Unlock()
 	}()
-	// Sign the transaction
-	signature, err := w.driver.SignTypedMessage(path, data[2:34], data[34:66])
+	return fn()
+}
+
+// withAccount runs a device exchange requiring user confirmation (see withDevice)
+// against the derivation path of the account.
+func (w *wallet) withAccount(account accounts.Account, fn func(path gethaccounts.DerivationPath) error) error {
+	return w.withDevice(func() error {
+		// Make sure the requested account is contained within
+		path, ok := w.paths[account.Address]
+		if !ok {
+			return gethaccounts.ErrUnknownAccount
+		}
+		return fn(path)
+	})
+}
+
+// Format the hd path to harden the first three values (purpose, coinType, account)
+// if needed, modifying the array in-place.
+func formatPathIfNeeded(path gethaccounts.DerivationPath) {
+	for i := 0; i < 3; i++ {
+		if path[i] < 0x80000000 {
+			path[i] += 0x80000000
+		}
+	}
+}
+
+// signHash implements accounts.Wallet, however signing arbitrary data is not
+// supported for hardware wallets, so this method will always return an error.
+func (w *wallet) signHash(account accounts.Account, hash []byte) ([]byte, error) {
+	return nil, gethaccounts.ErrNotSupported
+}
+
+// SignData implements accounts.Wallet. The mimetype parameter describes the type
+// of data being signed: Cosmos SDK Amino JSON sign docs are sent to the device as
+// is, EIP-712 data must be the 66 byte 0x1901 || domainHash || messageHash blob.
+// Arbitrary data would require signing keccak256(data) blindly, which hardware
+// wallets refuse.
+func (w *wallet) SignData(account accounts.Account, mimeType string, data []byte) ([]byte, error) {
+	if mimeType == accounts.MimetypeAminoJSON {
+		return w.signAminoJSON(account, data)
+	}
+	// Unless we are doing 712 signing, simply dispatch to signHash
+	if !(mimeType == gethaccounts.MimetypeTypedData && len(data) == 66 && data[0] == 0x19 && data[1] == 0x01) {
+		return w.signHash(account, crypto.Keccak256(data))
+	}
+
+	// dispatch to 712 signing if the mimetype is TypedData and the format matches
+	var signature []byte
+	err := w.withAccount(account, func(path gethaccounts.DerivationPath) (err error) {
+		signature, err = w.driver.SignTypedMessage(path, data[2:34], data[34:66])
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 	return signature, nil
 }
 
-func (w *wallet) signText(account accounts.Account, text []byte) ([]byte, error) {
-	return w.signHash(account, gethaccounts.TextHash(text))
+// signAminoJSON sends the Amino JSON sign doc over to the device to request a
+// confirmation from the user, returning the compact signature.
+func (w *wallet) signAminoJSON(account accounts.Account, signDoc []byte) ([]byte, error) {
+	var signature []byte
+	err := w.withAccount(account, func(path gethaccounts.DerivationPath) (err error) {
+		signature, err = w.driver.SignAminoJSON(path, signDoc)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Verify the signer to avoid hardware fault surprises. The compact signature
+	// lacks the recovery id, so try both parities.
+	if len(signature) != crypto.SignatureLength-1 {
+		return nil, fmt.Errorf("invalid signature length %d", len(signature))
+	}
+	var (
+		hash      = crypto.Keccak256(signDoc)
+		recovered = append(append([]byte{}, signature...), 0)
+	)
+	for v := byte(0); v < 2; v++ {
+		recovered[crypto.RecoveryIDOffset] = v
+		if pubkey, err := crypto.SigToPub(hash, recovered); err == nil && crypto.PubkeyToAddress(*pubkey) == account.Address {
+			return signature, nil
+		}
+	}
+	return nil, fmt.Errorf("signer mismatch: expected %s", account.Address)
+}
+
+// SignText implements accounts.Wallet, sending the text over to the device to be
+// signed as an EIP-191 personal message after the user confirms it. The returned
+// signature is in [R || S || V] format with V being 27 or 28.
+func (w *wallet) SignText(account accounts.Account, text []byte) ([]byte, error) {
+	var signature []byte
+	err := w.withAccount(account, func(path gethaccounts.DerivationPath) (err error) {
+		signature, err = w.driver.SignPersonalMessage(path, text)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Verify the signer to avoid hardware fault surprises
+	recovered := make([]byte, len(signature))
+	copy(recovered, signature)
+	recovered[crypto.RecoveryIDOffset] -= 27
+
+	pubkey, err := crypto.SigToPub(gethaccounts.TextHash(text), recovered)
+	if err != nil {
+		return nil, err
+	}
+	if signer := crypto.PubkeyToAddress(*pubkey); signer != account.Address {
+		return nil, fmt.Errorf("signer mismatch: expected %s, got %s", account.Address, signer)
+	}
+	return signature, nil
 }
 
 // SignTx implements accounts.Wallet. It sends the transaction over to the Ledger
@@ -390,38 +621,33 @@ func (w *wallet) signText(account accounts.Account, text []byte) ([]byte, error)
 // too old to sign EIP-155 transactions, but such is requested nonetheless, an error
 // will be returned opposed to silently signing in Homestead mode.
 func (w *wallet) SignTx(account accounts.Account, tx *coretypes.Transaction, chainID *big.Int) ([]byte, error) {
-	w.stateLock.RLock() // Comms have own mutex, this is for the state fields
-	defer w.stateLock.RUnlock()
-
-	// If the wallet is closed, abort
-	if w.device == nil {
-		return nil, gethaccounts.ErrWalletClosed
-	}
-	// Make sure the requested account is contained within
-	path, ok := w.paths[account.Address]
-	if !ok {
-		return nil, gethaccounts.ErrUnknownAccount
+	// Embedded EIP-7702 authorizations must have been signed beforehand
+	for i, auth := range tx.SetCodeAuthorizations() {
+		if _, err := auth.Authority(); err != nil {
+			return nil, fmt.Errorf("authorization %d is not signed: %w", i, err)
+		}
 	}
-	// All infos gathered and metadata checks out, request signing
-	<-w.commsLock
-	defer func() { w.commsLock <- struct{}{} }()
-
-	// Ensure the device isn't screwed with while user confirmation is pending
-	// TODO(karalabe): remove if hotplug lands on Windows
-	w.hub.commsLock.Lock()
-	w.hub.commsPend++
-	w.hub.commsLock.Unlock()
-
-	defer func() {
-		w.hub.commsLock.Lock()
-		w.hub.commsPend--
-		w.hub.commsLock.Unlock()
-	}()
-	// Sign the transaction and verify the sender to avoid hardware fault surprises
-	sender, signed, err := w.driver.SignTx(path, tx, chainID)
+	var (
+		sender common.Address
+		signed []byte
+	)
+	err := w.withAccount(account, func(path gethaccounts.DerivationPath) error {
+		// Let the device display the recipient's trusted name if one is known
+		if err := w.provideTrustedName(tx, chainID); err != nil {
+			return err
+		}
+		// Look up the clear signing metadata of the calldata, if any
+		meta, err := w.transactionMetadata(tx, chainID)
+		if err != nil {
+			return err
+		}
+		sender, signed, err = w.driver.SignTx(path, tx, chainID, meta)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
+	// Verify the sender to avoid hardware fault surprises
 
 	if sender != account.Address {
 		return nil, fmt.Errorf("signer mismatch: expected %s, got %s", account.Address, sender)
@@ -430,6 +656,119 @@ func (w *wallet) SignTx(account accounts.Account, tx *coretypes.Transaction, cha
 	return signed, nil
 }
 
+// SignAuthorization implements accounts.Wallet. It sends the EIP-7702 authorization
+// over to the Ledger wallet to request a confirmation from the user, returning the
+// signed authorization once its authority was verified to be the account.
+func (w *wallet) SignAuthorization(account accounts.Account, auth coretypes.SetCodeAuthorization) (coretypes.SetCodeAuthorization, error) {
+	var signature []byte
+	err := w.withAccount(account, func(path gethaccounts.DerivationPath) (err error) {
+		signature, err = w.driver.SignAuthorization(path, auth)
+		return err
+	})
+	if err != nil {
+		return coretypes.SetCodeAuthorization{}, err
+	}
+	// Assemble the signed authorization and verify the authority to avoid hardware fault surprises
+	signed := coretypes.SetCodeAuthorization{
+		ChainID: auth.ChainID,
+		Address: auth.Address,
+		Nonce:   auth.Nonce,
+		V:       signature[crypto.RecoveryIDOffset],
+	}
+	signed.R.SetBytes(signature[:crypto.DigestLength])
+	signed.S.SetBytes(signature[crypto.DigestLength:crypto.RecoveryIDOffset])
+
+	authority, err := signed.Authority()
+	if err != nil {
+		return coretypes.SetCodeAuthorization{}, err
+	}
+	if authority != account.Address {
+		return coretypes.SetCodeAuthorization{}, fmt.Errorf("signer mismatch: expected %s, got %s", account.Address, authority)
+	}
+	return signed, nil
+}
+
+// provideTrustedName resolves the recipient of a transaction through the hub's
+// trusted name resolver and sends the resulting payload to the device. Nothing
+// is done if no resolver is configured, the transaction creates a contract, the
+// name is unknown or the device does not support trusted names.
+//
+// Note, provideTrustedName assumes the comms lock is held!
+func (w *wallet) provideTrustedName(tx *coretypes.Transaction, chainID *big.Int) error {
+	w.hub.stateLock.RLock()
+	resolver := w.hub.nameResolver
+	w.hub.stateLock.RUnlock()
+
+	if resolver == nil || tx.To() == nil {
+		return nil
+	}
+	challenge, err := w.driver.Challenge()
+	if err != nil {
+		// Older apps can't display trusted names, fall back to the raw address
+		if errors.Is(err, gethaccounts.ErrNotSupported) {
+			return nil
+		}
+		return err
+	}
+	payload, err := resolver.ResolveTrustedName(*tx.To(), chainID, challenge)
+	if err != nil {
+		return fmt.Errorf("failed to resolve trusted name for %s: %w", tx.To(), err)
+	}
+	if payload == nil {
+		return nil
+	}
+	return w.driver.ProvideTrustedName(payload)
+}
+
+// transactionMetadata looks up the clear signing metadata of a transaction via
+// the hub's metadata provider. Nil is returned if no provider is configured or
+// the transaction has no calldata to describe.
+func (w *wallet) transactionMetadata(tx *coretypes.Transaction, chainID *big.Int) (*TransactionMetadata, error) {
+	w.hub.stateLock.RLock()
+	provider := w.hub.metaProvider
+	w.hub.stateLock.RUnlock()
+
+	if provider == nil || len(tx.Data()) == 0 {
+		return nil, nil
+	}
+	meta, err := provider.TransactionMetadata(tx, chainID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve transaction metadata: %w", err)
+	}
+	return meta, nil
+}
+
+// typedDataMetadata looks up the clear signing metadata of an EIP-712 message
+// via the hub's metadata provider. Nil is returned if no provider is configured.
+func (w *wallet) typedDataMetadata(typedData apitypes.TypedData) (*TypedDataMetadata, error) {
+	w.hub.stateLock.RLock()
+	provider := w.hub.typedProvider
+	w.hub.stateLock.RUnlock()
+
+	if provider == nil {
+		return nil, nil
+	}
+	meta, err := provider.TypedDataMetadata(typedData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve typed data metadata: %w", err)
+	}
+	return meta, nil
+}
+
+// signTypedData streams the EIP-712 message and its clear signing metadata over
+// to the device to request a confirmation from the user.
+func (w *wallet) signTypedData(account accounts.Account, typedData apitypes.TypedData, meta *TypedDataMetadata) ([]byte, error) {
+	var signature []byte
+	err := w.withAccount(account, func(path gethaccounts.DerivationPath) (err error) {
+		signature, err = w.driver.SignTypedData(path, typedData, meta)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return signature, nil
+}
+
 func (w *wallet) verifyTypedDataSignature(account accounts.Account, rawData []byte, signature []byte) error {
 	if len(signature) != crypto.SignatureLength {
 		return fmt.Errorf("invalid signature length: %d", len(signature))
@@ -468,7 +807,19 @@ func (w *wallet) SignTypedData(account accounts.Account, typedData apitypes.Type
 
 	rawDataBz := []byte(rawData)
 
-	sigBytes, err := w.signData(account, "data/typed", rawDataBz)
+	// Stream the message along with its clear signing metadata if known, falling
+	// back to signing the hashes on apps unable to display the fields
+	meta, err := w.typedDataMetadata(typedData)
+	if err != nil {
+		return nil, err
+	}
+	var sigBytes []byte
+	if meta != nil {
+		sigBytes, err = w.signTypedData(account, typedData, meta)
+	}
+	if meta == nil || errors.Is(err, gethaccounts.ErrNotSupported) {
+		sigBytes, err = w.SignData(account, "data/typed", rawDataBz)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,192 @@
+// Package web3signer serves Ledger accounts through the eth1 JSON-RPC API of
+// Consensys Web3Signer (eth_accounts, eth_sign, eth_signTransaction and
+// eth_signTypedData), for infrastructure already speaking it.
+package web3signer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"sync"
+	"time"
+
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+
+	"github.com/evmos/ethereum-ledger-go/accounts"
+	"github.com/evmos/ethereum-ledger-go/internal/resolver"
+)
+
+var (
+	// ErrUnknownAccount is returned if a request names an account that is not
+	// configured, or not derived by any of the Ledger wallets.
+	ErrUnknownAccount = resolver.ErrUnknownAccount
+
+	// ErrChainID is returned if a transaction requests a chain ID other than the
+	// one the signer is configured with.
+	ErrChainID = resolver.ErrChainID
+
+	// ErrGasLimit is returned if a transaction to sign lacks a gas limit, which
+	// the signer has no node to estimate with.
+	ErrGasLimit = errors.New("web3signer: gas limit not set")
+)
+
+// API implements the Web3Signer eth1 API, exposed in the "eth" namespace, on
+// top of the Ledger wallets of a backend (e.g. an EthereumLedger or usbwallet.Hub).
+//
+// Only the accounts derived at the configured paths are served, and only if they
+// match the expected addresses configured for them, if any.
+type API struct {
+	resolver *resolver.Resolver
+	expected map[string]common.Address // Expected address of each path, if configured
+	chainID  *big.Int
+	logger   *slog.Logger
+
+	mismatched sync.Map // Paths already reported to derive an unexpected address
+}
+
+// NewAPI creates a Web3Signer eth1 API serving the configured accounts of the
+// backend's wallets.
+func NewAPI(backend accounts.Backend, config *Config) (*API, error) {
+	var (
+		paths    []gethaccounts.DerivationPath
+		expected = make(map[string]common.Address)
+	)
+	for _, account := range config.Accounts {
+		path, err := gethaccounts.ParseDerivationPath(account.Path)
+		if err != nil {
+			return nil, fmt.Errorf("web3signer: invalid path %q: %w", account.Path, err)
+		}
+		paths = append(paths, path)
+		if account.Address != nil {
+			expected[path.String()] = *account.Address
+		}
+	}
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	return &API{
+		resolver: resolver.New(backend, paths),
+		expected: expected,
+		chainID:  config.ChainID,
+		logger:   logger,
+	}, nil
+}
+
+// Accounts returns the addresses of the accounts available for signing.
+func (api *API) Accounts(ctx context.Context) ([]common.Address, error) {
+	addrs := make([]common.Address, 0) // Empty list instead of null if none found
+	for _, account := range api.accounts() {
+		addrs = append(addrs, account.Address)
+	}
+	api.log(ctx, "eth_accounts", common.Address{}, time.Now(), nil)
+	return addrs, nil
+}
+
+// Sign signs the data with the account as an EIP-191 personal message. The
+// signature is in [R || S || V] format with V being 27 or 28.
+func (api *API) Sign(ctx context.Context, addr common.Address, data hexutil.Bytes) (sig hexutil.Bytes, err error) {
+	defer api.log(ctx, "eth_sign", addr, time.Now(), &err)
+
+	account, err := api.find(addr)
+	if err != nil {
+		return nil, err
+	}
+	return account.Wallet.SignText(account.Account, data)
+}
+
+// SignTransaction signs the transaction with the account in its from field,
+// returning its binary encoding. The nonce and gas limit must be set, as there
+// is no node to retrieve them from.
+func (api *API) SignTransaction(ctx context.Context, args apitypes.SendTxArgs) (raw hexutil.Bytes, err error) {
+	defer api.log(ctx, "eth_signTransaction", args.From.Address(), time.Now(), &err)
+
+	if args.Gas == 0 {
+		return nil, ErrGasLimit
+	}
+	chainID, err := resolver.ChainID(args.ChainID, api.chainID)
+	if err != nil {
+		return nil, err
+	}
+	args.ChainID = (*hexutil.Big)(chainID)
+
+	account, err := api.find(args.From.Address())
+	if err != nil {
+		return nil, err
+	}
+	tx, err := args.ToTransaction()
+	if err != nil {
+		return nil, err
+	}
+	return account.Wallet.SignTx(account.Account, tx, args.ChainID.ToInt())
+}
+
+// SignTypedData signs the EIP-712 typed data with the account. The typed data is
+// accepted both as a JSON object and as a string holding one. The signature is in
+// [R || S || V] format with V being 27 or 28.
+func (api *API) SignTypedData(ctx context.Context, addr common.Address, data json.RawMessage) (sig hexutil.Bytes, err error) {
+	defer api.log(ctx, "eth_signTypedData", addr, time.Now(), &err)
+
+	var str string
+	if json.Unmarshal(data, &str) == nil {
+		data = json.RawMessage(str)
+	}
+	var typedData apitypes.TypedData
+	if err := json.Unmarshal(data, &typedData); err != nil {
+		return nil, fmt.Errorf("web3signer: invalid typed data: %w", err)
+	}
+	account, err := api.find(addr)
+	if err != nil {
+		return nil, err
+	}
+	return account.Wallet.SignTypedData(account.Account, typedData)
+}
+
+// accounts returns the derived accounts, dropping the ones not matching their
+// expected address.
+func (api *API) accounts() []resolver.Account {
+	var served []resolver.Account
+	for _, account := range api.resolver.Accounts() {
+		path := account.Path.String()
+		if expected, ok := api.expected[path]; ok && expected != account.Address {
+			if _, reported := api.mismatched.LoadOrStore(path, true); !reported {
+				api.logger.Warn("Ledger derived unexpected address, not serving it", "path", path, "expected", expected.Hex(), "derived", account.Address.Hex())
+			}
+			continue
+		}
+		served = append(served, account)
+	}
+	return served
+}
+
+// find looks up the served account with the given address.
+func (api *API) find(addr common.Address) (resolver.Account, error) {
+	for _, account := range api.accounts() {
+		if account.Address == addr {
+			return account, nil
+		}
+	}
+	return resolver.Account{}, fmt.Errorf("%w: %s", ErrUnknownAccount, addr)
+}
+
+// log records a served request along with the requesting peer, its duration and
+// outcome.
+func (api *API) log(ctx context.Context, method string, addr common.Address, start time.Time, err *error) {
+	peer := rpc.PeerInfoFromContext(ctx)
+	attrs := []any{"method", method, "remote", peer.RemoteAddr, "elapsed", time.Since(start)}
+	if addr != (common.Address{}) {
+		attrs = append(attrs, "account", addr.Hex())
+	}
+	if err != nil && *err != nil {
+		api.logger.Warn("Request failed", append(attrs, "err", *err)...)
+		return
+	}
+	api.logger.Info("Served request", attrs...)
+}
@@ -0,0 +1,45 @@
+package web3signer
+
+import (
+	"encoding/json"
+	"log/slog"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// AccountConfig configures an account served by the signer.
+type AccountConfig struct {
+	Path    string          `json:"path"`              // Derivation path of the account (e.g. m/44'/60'/0'/0/0)
+	Address *common.Address `json:"address,omitempty"` // Expected address, guarding against a device with another seed
+}
+
+// Config configures the accounts and chain served by an API.
+type Config struct {
+	ChainID  *big.Int        `json:"chainId"`  // Chain transactions are signed for
+	Accounts []AccountConfig `json:"accounts"` // Accounts derived on every wallet, m/44'/60'/0'/0/0 if empty
+
+	Logger *slog.Logger `json:"-"` // Logger requests are reported to, discarded if nil
+}
+
+// LoadConfig parses a JSON configuration file, e.g.:
+//
+//	{
+//	  "chainId": 1,
+//	  "accounts": [
+//	    {"path": "m/44'/60'/0'/0/0", "address": "0xbcf6368dF2C2999893064aDe8C4a4b1b6d3C077B"},
+//	    {"path": "m/44'/60'/0'/0/1"}
+//	  ]
+//	}
+func LoadConfig(file string) (*Config, error) {
+	blob, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	config := new(Config)
+	if err := json.Unmarshal(blob, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
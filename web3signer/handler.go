@@ -0,0 +1,23 @@
+package web3signer
+
+import (
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// NewHandler creates an HTTP handler serving the API over JSON-RPC, along with
+// Web3Signer's /upcheck liveness endpoint.
+func NewHandler(api *API) (http.Handler, error) {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("eth", api); err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/upcheck", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("OK"))
+	})
+	mux.Handle("/", srv)
+	return mux, nil
+}
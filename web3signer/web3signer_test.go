@@ -0,0 +1,193 @@
+package web3signer
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gethaccounts "github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/stretchr/testify/require"
+
+	"github.com/evmos/ethereum-ledger-go/internal/testutil"
+	"github.com/evmos/ethereum-ledger-go/usbwallet"
+	"github.com/evmos/ethereum-ledger-go/usbwallet/simulator"
+)
+
+const testTyped = `{
+	"types": {
+		"EIP712Domain": [{"name": "name", "type": "string"}, {"name": "chainId", "type": "uint256"}],
+		"Mail": [{"name": "contents", "type": "string"}]
+	},
+	"primaryType": "Mail",
+	"domain": {"name": "Ether Mail", "chainId": "1337"},
+	"message": {"contents": "Hello, Bob!"}
+}`
+
+var testChainID = big.NewInt(1337)
+
+// startSigner serves the configured accounts of a simulated Ledger over HTTP,
+// returning a client connected to it and the log of the requests served.
+func startSigner(t *testing.T, accounts ...AccountConfig) (*simulator.Device, *rpc.Client, *bytes.Buffer, string) {
+	t.Helper()
+
+	var (
+		hub, device = testutil.SimulatedHub()
+		logs        = new(bytes.Buffer)
+	)
+	api, err := NewAPI(hub, &Config{
+		ChainID:  testChainID,
+		Accounts: accounts,
+		Logger:   slog.New(slog.NewTextHandler(logs, nil)),
+	})
+	require.NoError(t, err)
+
+	handler, err := NewHandler(api)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := rpc.DialHTTP(server.URL)
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+
+	return device, client, logs, server.URL
+}
+
+func TestAccounts(t *testing.T) {
+	_, device := testutil.SimulatedHub()
+	second, err := device.Address(gethaccounts.DerivationPath{0x80000000 + 44, 0x80000000 + 60, 0x80000000 + 0, 0, 1})
+	require.NoError(t, err)
+
+	// Accounts deriving an address other than the expected one are not served
+	wrong := common.HexToAddress("0x3535353535353535353535353535353535353535")
+	_, client, logs, url := startSigner(t,
+		AccountConfig{Path: "m/44'/60'/0'/0/0", Address: &testutil.Address},
+		AccountConfig{Path: "m/44'/60'/0'/0/1"},
+		AccountConfig{Path: "m/44'/60'/0'/0/2", Address: &wrong},
+	)
+	var addrs []common.Address
+	require.NoError(t, client.Call(&addrs, "eth_accounts"))
+	require.Equal(t, []common.Address{testutil.Address, second}, addrs)
+	require.Contains(t, logs.String(), "Ledger derived unexpected address")
+	require.Contains(t, logs.String(), "method=eth_accounts")
+
+	// The liveness endpoint answers plain GETs
+	res, err := http.Get(url + "/upcheck")
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "OK", string(body))
+}
+
+func TestSign(t *testing.T) {
+	device, client, logs, _ := startSigner(t)
+	msg := []byte("hello ledger")
+
+	var sig hexutil.Bytes
+	require.NoError(t, client.Call(&sig, "eth_sign", testutil.Address, hexutil.Bytes(msg)))
+	require.Len(t, sig, 65)
+
+	sig[64] -= 27
+	pub, err := crypto.SigToPub(gethaccounts.TextHash(msg), sig)
+	require.NoError(t, err)
+	require.Equal(t, testutil.Address, crypto.PubkeyToAddress(*pub))
+	require.Contains(t, logs.String(), "method=eth_sign")
+	require.Contains(t, logs.String(), "account="+testutil.Address.Hex())
+
+	// Unknown accounts and device rejections are reported and logged
+	err = client.Call(&sig, "eth_sign", common.Address{0x01}, hexutil.Bytes(msg))
+	require.ErrorContains(t, err, ErrUnknownAccount.Error())
+
+	device.SetRejecting(true)
+	require.Error(t, client.Call(&sig, "eth_sign", testutil.Address, hexutil.Bytes(msg)))
+	require.Contains(t, logs.String(), "Request failed")
+}
+
+func TestSignTransaction(t *testing.T) {
+	_, client, _, _ := startSigner(t, AccountConfig{Path: "m/44'/60'/0'/0/0"})
+	tx := map[string]interface{}{
+		"from":                 testutil.Address,
+		"to":                   "0x3535353535353535353535353535353535353535",
+		"gas":                  "0x5208",
+		"maxFeePerGas":         "0x3b9aca00",
+		"maxPriorityFeePerGas": "0x1",
+		"value":                "0x1",
+		"nonce":                "0x7",
+		"data":                 "0xdead",
+	}
+	var raw hexutil.Bytes
+	require.NoError(t, client.Call(&raw, "eth_signTransaction", tx))
+
+	signed := new(coretypes.Transaction)
+	require.NoError(t, signed.UnmarshalBinary(raw))
+	require.Equal(t, uint8(coretypes.DynamicFeeTxType), signed.Type())
+	require.Equal(t, testChainID, signed.ChainId())
+	require.Equal(t, uint64(7), signed.Nonce())
+
+	sender, err := coretypes.Sender(coretypes.LatestSignerForChainID(testChainID), signed)
+	require.NoError(t, err)
+	require.Equal(t, testutil.Address, sender)
+
+	// Without a node to ask, the gas limit is required and the chain is fixed
+	delete(tx, "gas")
+	require.ErrorContains(t, client.Call(&raw, "eth_signTransaction", tx), ErrGasLimit.Error())
+
+	tx["gas"], tx["chainId"] = "0x5208", "0x1"
+	require.ErrorContains(t, client.Call(&raw, "eth_signTransaction", tx), ErrChainID.Error())
+}
+
+func TestSignTypedData(t *testing.T) {
+	_, client, _, _ := startSigner(t)
+
+	var typedData apitypes.TypedData
+	require.NoError(t, json.Unmarshal([]byte(testTyped), &typedData))
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	require.NoError(t, err)
+
+	// Typed data is accepted both as an object and a JSON string
+	for _, param := range []interface{}{json.RawMessage(testTyped), testTyped} {
+		var sig hexutil.Bytes
+		require.NoError(t, client.Call(&sig, "eth_signTypedData", testutil.Address, param))
+
+		sig[64] -= 27
+		pub, err := crypto.SigToPub(hash, sig)
+		require.NoError(t, err)
+		require.Equal(t, testutil.Address, crypto.PubkeyToAddress(*pub))
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{
+		"chainId": 1337,
+		"accounts": [{"path": "m/44'/60'/0'/0/0", "address": "0xbcf6368dF2C2999893064aDe8C4a4b1b6d3C077B"}, {"path": "m/44'/60'/1'/0/0"}]
+	}`), 0o600))
+
+	config, err := LoadConfig(file)
+	require.NoError(t, err)
+	require.Equal(t, testChainID, config.ChainID)
+	require.Len(t, config.Accounts, 2)
+	require.Equal(t, testutil.Address, *config.Accounts[0].Address)
+	require.Nil(t, config.Accounts[1].Address)
+
+	// Invalid paths are refused upfront
+	_, err = NewAPI(usbwallet.NewSimulatedHub(), &Config{Accounts: []AccountConfig{{Path: "m/44'/x"}}})
+	require.Error(t, err)
+}